@@ -27,6 +27,12 @@
 # If BYPASS_UPLOAD is set then the generated package will not be uploaded to
 # to Google Storage. This is meant for debugging.
 #
+# LOCAL_PUSH_DIR
+# --------------
+# If LOCAL_PUSH_DIR is set then the generated package and its metadata are
+# copied into that directory instead of being uploaded to Google Storage. This
+# is for push and pulld running with --package_dir=${LOCAL_PUSH_DIR}.
+#
 # DEPENDS
 # -------
 # If DEPENDS is specified it should be a list of dependencies that this package
@@ -148,16 +154,31 @@ then
   else
     DIRTY=false
   fi
-  gsutil \
-    -h x-goog-meta-appname:${APPNAME} \
-    -h x-goog-meta-userid:${USERID} \
-    -h x-goog-meta-hash:${HASH} \
-    -h x-goog-meta-datetime:${DATETIME} \
-    -h x-goog-meta-dirty:${DIRTY} \
-    -h "x-goog-meta-note:$1" \
-    -h "x-goog-meta-services:$SYSTEMD" \
-    cp ${OUT}/${APPNAME}.deb \
-    gs://skia-push/debs/${APPNAME}/${APPNAME}:${USERID}:${DATETIME}:${HASH}.deb
+  if [ -v LOCAL_PUSH_DIR ]
+  then
+    DEST=${LOCAL_PUSH_DIR}/debs/${APPNAME}/${APPNAME}:${USERID}:${DATETIME}:${HASH}.deb
+    mkdir -p ${LOCAL_PUSH_DIR}/debs/${APPNAME}
+    cp ${OUT}/${APPNAME}.deb ${DEST}
+    python -c 'import json, sys; print(json.dumps(dict(zip(sys.argv[1::2], sys.argv[2::2]))))' \
+      appname "${APPNAME}" \
+      userid "${USERID}" \
+      hash "${HASH}" \
+      datetime "${DATETIME}" \
+      dirty "${DIRTY}" \
+      note "$1" \
+      services "${SYSTEMD}" > ${DEST}.json
+  else
+    gsutil \
+      -h x-goog-meta-appname:${APPNAME} \
+      -h x-goog-meta-userid:${USERID} \
+      -h x-goog-meta-hash:${HASH} \
+      -h x-goog-meta-datetime:${DATETIME} \
+      -h x-goog-meta-dirty:${DIRTY} \
+      -h "x-goog-meta-note:$1" \
+      -h "x-goog-meta-services:$SYSTEMD" \
+      cp ${OUT}/${APPNAME}.deb \
+      gs://skia-push/debs/${APPNAME}/${APPNAME}:${USERID}:${DATETIME}:${HASH}.deb
+  fi
 else
   echo "Upload bypassed."
 fi
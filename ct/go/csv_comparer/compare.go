@@ -0,0 +1,233 @@
+package csv_comparer
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// Default significance level of the statistical tests.
+	DEFAULT_ALPHA = 0.05
+
+	// Default number of bootstrap resamples used to compute confidence intervals.
+	DEFAULT_BOOTSTRAP_ITERATIONS = 1000
+
+	// Minimum number of values needed on each side before a per-page test is run.
+	MIN_PAGE_SAMPLES = 3
+)
+
+// Config controls how two Results are compared.
+type Config struct {
+	// Pages whose absolute percentage difference is below this value are not listed for a metric.
+	VarianceThreshold float64
+	// Percentage of pages to discard from both the top and the bottom of each metric, ordered by
+	// percentage difference.
+	DiscardOutliers float64
+	// Metrics with fewer pages than this after discarding outliers are left out of the report.
+	MinPages int
+	// Significance level of the statistical tests.
+	Alpha float64
+	// Number of bootstrap resamples used to compute confidence intervals.
+	BootstrapIterations int
+	// Seed for the bootstrap resampling, so that reports are reproducible.
+	Seed int64
+}
+
+// DefaultConfig returns a Config with no threshold, no outlier trimming and default test settings.
+func DefaultConfig() *Config {
+	return &Config{
+		MinPages:            1,
+		Alpha:               DEFAULT_ALPHA,
+		BootstrapIterations: DEFAULT_BOOTSTRAP_ITERATIONS,
+		Seed:                1,
+	}
+}
+
+// PageResult is the comparison of a single metric on a single page.
+type PageResult struct {
+	PageName string `json:"page_name"`
+	// Mean of the values of the page in each run.
+	NoPatch   float64 `json:"nopatch"`
+	WithPatch float64 `json:"withpatch"`
+	// Number of values of the page in each run.
+	NoPatchSamples   int     `json:"nopatch_samples"`
+	WithPatchSamples int     `json:"withpatch_samples"`
+	PercDiff         float64 `json:"perc_diff"`
+	PercChange       float64 `json:"perc_change"`
+	// P-value of a Mann-Whitney U test over the repeated values of the page, or -1 if there were
+	// fewer than MIN_PAGE_SAMPLES values on either side.
+	PValue float64 `json:"p_value"`
+}
+
+// MetricResult is the comparison of a single metric over all pages present in both runs.
+type MetricResult struct {
+	Name string `json:"name"`
+	// Number of pages compared, after discarding outliers.
+	NumPages int `json:"num_pages"`
+	// Totals over all compared pages.
+	NoPatchTotal   float64 `json:"nopatch_total"`
+	WithPatchTotal float64 `json:"withpatch_total"`
+	PercDiff       float64 `json:"perc_diff"`
+	PercChange     float64 `json:"perc_change"`
+	// Wilcoxon signed-rank test of the per-page differences between the nopatch and the
+	// withpatch run. WilcoxonW is the sum of the ranks of the pages which increased.
+	WilcoxonW float64 `json:"wilcoxon_w"`
+	PValue    float64 `json:"p_value"`
+	// Bootstrap confidence interval of PercChange at level 1-Alpha.
+	CILow  float64 `json:"ci_low"`
+	CIHigh float64 `json:"ci_high"`
+	// True if the p-value is below Alpha and the confidence interval does not contain zero.
+	Significant bool `json:"significant"`
+	// Pages whose percentage difference is at or above the variance threshold, ordered by
+	// decreasing percentage difference.
+	Pages []*PageResult `json:"pages"`
+	// Names of the pages discarded as outliers.
+	DiscardedPages []string `json:"discarded_pages"`
+}
+
+// Report is the result of comparing a nopatch run against a withpatch run.
+type Report struct {
+	Config *Config `json:"config"`
+	// Metrics ordered by decreasing absolute percentage difference.
+	Metrics []*MetricResult `json:"metrics"`
+	// Pages which only appear in one of the two runs.
+	MissingFromNoPatch   []string `json:"missing_from_nopatch"`
+	MissingFromWithPatch []string `json:"missing_from_withpatch"`
+}
+
+// Compare aligns the pages of the nopatch and withpatch Results and computes per-metric and
+// per-page deltas and significance tests.
+func Compare(noPatch, withPatch *Results, cfg *Config) *Report {
+	report := &Report{
+		Config:               cfg,
+		Metrics:              []*MetricResult{},
+		MissingFromNoPatch:   []string{},
+		MissingFromWithPatch: []string{},
+	}
+	for _, page := range noPatch.PageNames() {
+		if _, ok := withPatch.Pages[page]; !ok {
+			report.MissingFromWithPatch = append(report.MissingFromWithPatch, page)
+		}
+	}
+	for _, page := range withPatch.PageNames() {
+		if _, ok := noPatch.Pages[page]; !ok {
+			report.MissingFromNoPatch = append(report.MissingFromNoPatch, page)
+		}
+	}
+
+	inNoPatch := make(map[string]bool, len(noPatch.Fields))
+	for _, f := range noPatch.Fields {
+		inNoPatch[f] = true
+	}
+	r := rand.New(rand.NewSource(cfg.Seed))
+	for _, field := range withPatch.Fields {
+		if !inNoPatch[field] {
+			continue
+		}
+		if m := compareMetric(field, noPatch, withPatch, cfg, r); m != nil {
+			report.Metrics = append(report.Metrics, m)
+		}
+	}
+	sort.Sort(metricsByPercDiff(report.Metrics))
+	return report
+}
+
+// compareMetric compares a single field across all pages which have values for it in both runs.
+// Returns nil if too few pages remain after discarding outliers.
+func compareMetric(field string, noPatch, withPatch *Results, cfg *Config, r *rand.Rand) *MetricResult {
+	pages := []*PageResult{}
+	for _, page := range withPatch.PageNames() {
+		noPatchValues := noPatch.Pages[page][field]
+		withPatchValues := withPatch.Pages[page][field]
+		if len(noPatchValues) == 0 || len(withPatchValues) == 0 {
+			continue
+		}
+		p := &PageResult{
+			PageName:         page,
+			NoPatch:          Mean(noPatchValues),
+			WithPatch:        Mean(withPatchValues),
+			NoPatchSamples:   len(noPatchValues),
+			WithPatchSamples: len(withPatchValues),
+			PValue:           -1,
+		}
+		p.PercDiff = PercentageDiff(p.NoPatch, p.WithPatch)
+		p.PercChange = PercentageChange(p.NoPatch, p.WithPatch)
+		if len(noPatchValues) >= MIN_PAGE_SAMPLES && len(withPatchValues) >= MIN_PAGE_SAMPLES {
+			_, p.PValue = MannWhitneyU(noPatchValues, withPatchValues)
+		}
+		pages = append(pages, p)
+	}
+
+	sort.Sort(pagesByPercDiff(pages))
+	discarded := []string{}
+	if cfg.DiscardOutliers > 0 {
+		numOutliers := int(float64(len(pages)) * cfg.DiscardOutliers / 100)
+		if 2*numOutliers >= len(pages) {
+			numOutliers = len(pages) / 2
+		}
+		for _, p := range pages[:numOutliers] {
+			discarded = append(discarded, p.PageName)
+		}
+		for _, p := range pages[len(pages)-numOutliers:] {
+			discarded = append(discarded, p.PageName)
+		}
+		pages = pages[numOutliers : len(pages)-numOutliers]
+	}
+	if len(pages) == 0 || len(pages) < cfg.MinPages {
+		return nil
+	}
+
+	m := &MetricResult{
+		Name:           field,
+		NumPages:       len(pages),
+		Pages:          []*PageResult{},
+		DiscardedPages: discarded,
+	}
+	noPatchValues := make([]float64, len(pages))
+	withPatchValues := make([]float64, len(pages))
+	for i, p := range pages {
+		noPatchValues[i] = p.NoPatch
+		withPatchValues[i] = p.WithPatch
+		m.NoPatchTotal += p.NoPatch
+		m.WithPatchTotal += p.WithPatch
+		if math.Abs(p.PercDiff) >= cfg.VarianceThreshold {
+			m.Pages = append(m.Pages, p)
+		}
+	}
+	m.PercDiff = PercentageDiff(m.NoPatchTotal, m.WithPatchTotal)
+	m.PercChange = PercentageChange(m.NoPatchTotal, m.WithPatchTotal)
+	// Each page contributes a pair of values, so test the per-page differences rather than
+	// treating the two runs as independent samples.
+	m.WilcoxonW, m.PValue = WilcoxonSignedRank(noPatchValues, withPatchValues)
+	m.CILow, m.CIHigh = BootstrapPercentageChangeCI(noPatchValues, withPatchValues, 1-cfg.Alpha, cfg.BootstrapIterations, r)
+	m.Significant = m.PValue < cfg.Alpha && (m.CILow > 0 || m.CIHigh < 0)
+	return m
+}
+
+// pagesByPercDiff implements sort.Interface to order PageResults by decreasing percentage
+// difference, then by page name.
+type pagesByPercDiff []*PageResult
+
+func (p pagesByPercDiff) Len() int      { return len(p) }
+func (p pagesByPercDiff) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p pagesByPercDiff) Less(i, j int) bool {
+	if p[i].PercDiff != p[j].PercDiff {
+		return p[i].PercDiff > p[j].PercDiff
+	}
+	return p[i].PageName < p[j].PageName
+}
+
+// metricsByPercDiff implements sort.Interface to order MetricResults by decreasing absolute
+// percentage difference, then by name.
+type metricsByPercDiff []*MetricResult
+
+func (m metricsByPercDiff) Len() int      { return len(m) }
+func (m metricsByPercDiff) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m metricsByPercDiff) Less(i, j int) bool {
+	a, b := math.Abs(m[i].PercDiff), math.Abs(m[j].PercDiff)
+	if a != b {
+		return a > b
+	}
+	return m[i].Name < m[j].Name
+}
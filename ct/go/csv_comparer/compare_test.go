@@ -0,0 +1,117 @@
+package csv_comparer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+const noPatchCSV = `field1,page_name,field2,field3
+10,http://www.webpage1.com/ (#1),1,abc
+10,http://www.webpage2.com/ (#2),1,
+10,http://www.webpage3.com/ (#3),1,
+10,http://www.webpage4.com/ (#4),-,
+10,http://www.webpage5.com/ (#5),1,
+10,http://www.webpage6.com/ (#6),1,
+`
+
+const withPatchCSV = `page_name,field1,field2,field4
+http://www.webpage2.com/ (#2),20,1,5
+http://www.webpage3.com/ (#3),20,1,5
+http://www.webpage4.com/ (#4),20,1,5
+http://www.webpage5.com/ (#5),20,1,5
+http://www.webpage6.com/ (#6),50,1,5
+http://www.webpage7.com/ (#7),20,1,5
+`
+
+func readTestCSVs(t *testing.T) (*Results, *Results) {
+	noPatch, err := ReadCSV(strings.NewReader(noPatchCSV))
+	assert.NoError(t, err)
+	withPatch, err := ReadCSV(strings.NewReader(withPatchCSV))
+	assert.NoError(t, err)
+	return noPatch, withPatch
+}
+
+func TestReadCSV(t *testing.T) {
+	noPatch, _ := readTestCSVs(t)
+	assert.Equal(t, []string{"field1", "field2", "field3"}, noPatch.Fields)
+	assert.Len(t, noPatch.Pages, 6)
+	page := noPatch.Pages["http://www.webpage1.com/ (#1)"]
+	assert.Equal(t, []float64{10}, page["field1"])
+	assert.Equal(t, []float64{1}, page["field2"])
+	// Non-numeric and empty cells are ignored; "-" is zero.
+	assert.Nil(t, page["field3"])
+	assert.Equal(t, []float64{0}, noPatch.Pages["http://www.webpage4.com/ (#4)"]["field2"])
+
+	// Repeated pages accumulate values.
+	r, err := ReadCSV(strings.NewReader("page_name,a\np,1\np,2\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1, 2}, r.Pages["p"]["a"])
+
+	_, err = ReadCSV(strings.NewReader("a,b\n1,2\n"))
+	assert.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	noPatch, withPatch := readTestCSVs(t)
+	report := Compare(noPatch, withPatch, DefaultConfig())
+
+	assert.Equal(t, []string{"http://www.webpage7.com/ (#7)"}, report.MissingFromNoPatch)
+	assert.Equal(t, []string{"http://www.webpage1.com/ (#1)"}, report.MissingFromWithPatch)
+
+	// field3 and field4 only exist in one of the runs.
+	assert.Len(t, report.Metrics, 2)
+	field1 := report.Metrics[0]
+	assert.Equal(t, "field1", field1.Name)
+	assert.Equal(t, 5, field1.NumPages)
+	assert.Equal(t, 50.0, field1.NoPatchTotal)
+	assert.Equal(t, 130.0, field1.WithPatchTotal)
+	assert.Equal(t, 160.0, field1.PercChange)
+	assert.True(t, field1.Significant)
+	assert.True(t, field1.CILow > 0)
+	assert.Len(t, field1.Pages, 5)
+	assert.Equal(t, "http://www.webpage6.com/ (#6)", field1.Pages[0].PageName)
+	assert.Equal(t, -1.0, field1.Pages[0].PValue)
+
+	field2 := report.Metrics[1]
+	assert.Equal(t, "field2", field2.Name)
+	assert.False(t, field2.Significant)
+
+	// Discarding outliers removes the top and bottom pages.
+	cfg := DefaultConfig()
+	cfg.DiscardOutliers = 20
+	cfg.VarianceThreshold = 70
+	report = Compare(noPatch, withPatch, cfg)
+	field1 = report.Metrics[0]
+	assert.Equal(t, 3, field1.NumPages)
+	assert.Len(t, field1.DiscardedPages, 2)
+	assert.Equal(t, "http://www.webpage6.com/ (#6)", field1.DiscardedPages[0])
+	assert.Equal(t, 30.0, field1.NoPatchTotal)
+	assert.Equal(t, 60.0, field1.WithPatchTotal)
+	// Only pages at or above the variance threshold are listed.
+	assert.Len(t, field1.Pages, 0)
+
+	// Metrics with too few pages are dropped.
+	cfg = DefaultConfig()
+	cfg.MinPages = 6
+	assert.Len(t, Compare(noPatch, withPatch, cfg).Metrics, 0)
+}
+
+func TestReportOutput(t *testing.T) {
+	noPatch, withPatch := readTestCSVs(t)
+	report := Compare(noPatch, withPatch, DefaultConfig())
+
+	buf := bytes.Buffer{}
+	assert.NoError(t, report.WriteJSON(&buf))
+	decoded := &Report{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), decoded))
+	assert.Equal(t, report, decoded)
+
+	buf.Reset()
+	assert.NoError(t, report.WriteHTML(&buf))
+	assert.Contains(t, buf.String(), "http://www.webpage6.com/ (#6)")
+	assert.Contains(t, buf.String(), `<tr class="significant">`)
+}
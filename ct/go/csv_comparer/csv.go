@@ -0,0 +1,120 @@
+/*
+	Native comparison of the merged nopatch and withpatch CSV files of Chromium perf runs.
+*/
+
+package csv_comparer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"go.skia.org/infra/go/util"
+)
+
+const (
+	// Name of the column which identifies the page of each row.
+	PAGE_NAME_FIELD = "page_name"
+)
+
+// Results contains the values parsed from a merged telemetry CSV file.
+type Results struct {
+	// Names of all numeric fields, ie. all columns except PAGE_NAME_FIELD, in file order.
+	Fields []string
+	// Maps page name to field name to the values of that field for the page. There is one
+	// value per row of the page, so pages which were repeated have multiple values.
+	Pages map[string]map[string][]float64
+}
+
+// PageNames returns the sorted names of all pages in the Results.
+func (r *Results) PageNames() []string {
+	names := make([]string, 0, len(r.Pages))
+	for name := range r.Pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseValue parses a single CSV cell. The second return value is false if the cell does not hold a
+// number. As in csv_comparer.py, "-" is treated as zero.
+func parseValue(cell string) (float64, bool) {
+	if cell == "" {
+		return 0, false
+	}
+	if cell == "-" {
+		return 0, true
+	}
+	v, err := strconv.ParseFloat(cell, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// ReadCSV parses a merged telemetry CSV from the given reader. Cells which are empty or do not
+// contain a number are ignored.
+func ReadCSV(r io.Reader) (*Results, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Could not read CSV: %s", err)
+	}
+	if len(rows) < 1 {
+		return nil, fmt.Errorf("CSV has no header")
+	}
+	headers := rows[0]
+	pageCol := -1
+	fields := []string{}
+	for i, h := range headers {
+		if h == PAGE_NAME_FIELD {
+			pageCol = i
+		} else {
+			fields = append(fields, h)
+		}
+	}
+	if pageCol == -1 {
+		return nil, fmt.Errorf("CSV has no %s column", PAGE_NAME_FIELD)
+	}
+	results := &Results{
+		Fields: fields,
+		Pages:  map[string]map[string][]float64{},
+	}
+	for _, row := range rows[1:] {
+		if pageCol >= len(row) || row[pageCol] == "" {
+			continue
+		}
+		page, ok := results.Pages[row[pageCol]]
+		if !ok {
+			page = map[string][]float64{}
+			results.Pages[row[pageCol]] = page
+		}
+		for i, cell := range row {
+			if i == pageCol || i >= len(headers) {
+				continue
+			}
+			if v, ok := parseValue(cell); ok {
+				page[headers[i]] = append(page[headers[i]], v)
+			}
+		}
+	}
+	return results, nil
+}
+
+// ReadCSVFile parses the merged telemetry CSV file at the given path.
+func ReadCSVFile(path string) (*Results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open %s: %s", path, err)
+	}
+	defer util.Close(f)
+	results, err := ReadCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse %s: %s", path, err)
+	}
+	return results, nil
+}
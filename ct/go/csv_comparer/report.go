@@ -0,0 +1,126 @@
+package csv_comparer
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+
+	"go.skia.org/infra/go/util"
+)
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"perc": func(v float64) string {
+		return fmt.Sprintf("%.2f%%", v)
+	},
+	"num": func(v float64) string {
+		return fmt.Sprintf("%.4g", v)
+	},
+	"pvalue": func(v float64) string {
+		if v < 0 {
+			return "n/a"
+		}
+		return fmt.Sprintf("%.4f", v)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <title>Chromium Perf Comparison</title>
+  <style>
+    table { border-collapse: collapse; }
+    th, td { border: solid black 1px; padding: 4px 8px; }
+    tr.significant { background-color: #FFE0E0; }
+  </style>
+</head>
+<body>
+  <h2>Chromium Perf Comparison</h2>
+  <p>
+    Variance threshold: {{perc .Config.VarianceThreshold}},
+    discarded outliers: {{perc .Config.DiscardOutliers}},
+    significance level: {{.Config.Alpha}}
+  </p>
+  <table>
+    <tr>
+      <th>Metric</th><th>Pages</th><th>NoPatch Total</th><th>WithPatch Total</th>
+      <th>% Diff</th><th>% Change</th><th>CI of % Change</th><th>p-value</th>
+    </tr>
+    {{range .Metrics}}
+    <tr{{if .Significant}} class="significant"{{end}}>
+      <td><a href="#{{.Name}}">{{.Name}}</a></td>
+      <td>{{.NumPages}}</td>
+      <td>{{num .NoPatchTotal}}</td>
+      <td>{{num .WithPatchTotal}}</td>
+      <td>{{perc .PercDiff}}</td>
+      <td>{{perc .PercChange}}</td>
+      <td>[{{perc .CILow}}, {{perc .CIHigh}}]</td>
+      <td>{{pvalue .PValue}}</td>
+    </tr>
+    {{end}}
+  </table>
+  {{range .Metrics}}
+  <h3 id="{{.Name}}">{{.Name}}</h3>
+  <table>
+    <tr>
+      <th>Page</th><th>NoPatch</th><th>WithPatch</th><th>% Diff</th><th>% Change</th><th>p-value</th>
+    </tr>
+    {{range .Pages}}
+    <tr>
+      <td>{{.PageName}}</td>
+      <td>{{num .NoPatch}}</td>
+      <td>{{num .WithPatch}}</td>
+      <td>{{perc .PercDiff}}</td>
+      <td>{{perc .PercChange}}</td>
+      <td>{{pvalue .PValue}}</td>
+    </tr>
+    {{end}}
+  </table>
+  {{if .DiscardedPages}}
+  <p>Discarded outliers: {{range .DiscardedPages}}{{.}} {{end}}</p>
+  {{end}}
+  {{end}}
+  {{if .MissingFromNoPatch}}
+  <h3>Pages missing from the nopatch run</h3>
+  <ul>{{range .MissingFromNoPatch}}<li>{{.}}</li>{{end}}</ul>
+  {{end}}
+  {{if .MissingFromWithPatch}}
+  <h3>Pages missing from the withpatch run</h3>
+  <ul>{{range .MissingFromWithPatch}}<li>{{.}}</li>{{end}}</ul>
+  {{end}}
+</body>
+</html>
+`))
+
+// WriteJSON writes the Report as JSON to the given writer.
+func (r *Report) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// WriteHTML writes the Report as a standalone HTML page to the given writer.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+// WriteFiles writes the Report as JSON and HTML to the given paths.
+func (r *Report) WriteFiles(jsonPath, htmlPath string) error {
+	write := func(path string, fn func(io.Writer) error) error {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("Could not create %s: %s", path, err)
+		}
+		defer util.Close(f)
+		if err := fn(f); err != nil {
+			return fmt.Errorf("Could not write %s: %s", path, err)
+		}
+		return nil
+	}
+	if err := write(jsonPath, r.WriteJSON); err != nil {
+		return err
+	}
+	return write(htmlPath, r.WriteHTML)
+}
@@ -0,0 +1,205 @@
+package csv_comparer
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// PercentageDiff returns the difference between the two values as a percentage of their average,
+// matching _GetPercentageDiff in csv_comparer.py.
+func PercentageDiff(value1, value2 float64) float64 {
+	avg := (value1 + value2) / 2
+	if avg == 0 {
+		return 0
+	}
+	return (value2 - value1) / avg * 100
+}
+
+// PercentageChange returns the change from value1 to value2 as a percentage of value1, matching
+// _GetPercentageChange in csv_comparer.py.
+func PercentageChange(value1, value2 float64) float64 {
+	if value1 == 0 {
+		return 0
+	}
+	return (value2 - value1) / value1 * 100
+}
+
+// Mean returns the arithmetic mean of the values, or zero if there are none.
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// sample is a single value of either of the two samples compared by MannWhitneyU.
+type sample struct {
+	value float64
+	fromX bool
+}
+
+// samples implements sort.Interface to order samples by value.
+type samples []sample
+
+func (s samples) Len() int           { return len(s) }
+func (s samples) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s samples) Less(i, j int) bool { return s[i].value < s[j].value }
+
+// MannWhitneyU performs a two-sided Mann-Whitney U test of whether the samples x and y come from
+// the same distribution. It returns the U statistic of x and the p-value, computed with the normal
+// approximation including tie and continuity corrections. If either sample is empty or all values
+// are tied the p-value is 1.
+func MannWhitneyU(x, y []float64) (float64, float64) {
+	n1, n2 := len(x), len(y)
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+
+	all := make(samples, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Sort(all)
+
+	// Assign ranks, averaging over ties, and accumulate the tie correction term.
+	rankSumX := 0.0
+	tieTerm := 0.0
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+		// Ranks are 1-based; tied values share the average of ranks i+1..j.
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].fromX {
+				rankSumX += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	fn1, fn2 := float64(n1), float64(n2)
+	n := fn1 + fn2
+	u := rankSumX - fn1*(fn1+1)/2
+	mu := fn1 * fn2 / 2
+	variance := fn1 * fn2 / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return u, 1
+	}
+	z := (math.Abs(u-mu) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	return u, math.Erfc(z / math.Sqrt2)
+}
+
+// rankedDiffs implements sort.Interface to order paired differences by absolute value.
+type rankedDiffs []float64
+
+func (d rankedDiffs) Len() int           { return len(d) }
+func (d rankedDiffs) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d rankedDiffs) Less(i, j int) bool { return math.Abs(d[i]) < math.Abs(d[j]) }
+
+// WilcoxonSignedRank performs a two-sided Wilcoxon signed-rank test of whether the differences
+// y[i]-x[i] of the paired values are symmetric around zero. It returns the sum of the ranks of the
+// positive differences and the p-value, computed with the normal approximation including tie and
+// continuity corrections. Zero differences are dropped. If there are no non-zero differences the
+// p-value is 1. x and y must have the same length.
+func WilcoxonSignedRank(x, y []float64) (float64, float64) {
+	diffs := make(rankedDiffs, 0, len(x))
+	for i := range x {
+		if d := y[i] - x[i]; d != 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	n := len(diffs)
+	if n == 0 {
+		return 0, 1
+	}
+	sort.Sort(diffs)
+
+	// Assign ranks by absolute value, averaging over ties, and accumulate the tie correction term.
+	rankSumPos := 0.0
+	tieTerm := 0.0
+	for i := 0; i < n; {
+		j := i
+		for j < n && math.Abs(diffs[j]) == math.Abs(diffs[i]) {
+			j++
+		}
+		// Ranks are 1-based; tied values share the average of ranks i+1..j.
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if diffs[k] > 0 {
+				rankSumPos += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	fn := float64(n)
+	mu := fn * (fn + 1) / 4
+	variance := fn*(fn+1)*(2*fn+1)/24 - tieTerm/48
+	if variance <= 0 {
+		return rankSumPos, 1
+	}
+	z := (math.Abs(rankSumPos-mu) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	return rankSumPos, math.Erfc(z / math.Sqrt2)
+}
+
+// BootstrapPercentageChangeCI estimates a confidence interval at the given level (eg. 0.95) for the
+// percentage change between the totals of paired values, ie. PercentageChange(sum(x), sum(y)). The
+// pairs are resampled with replacement the given number of times using the provided source of
+// randomness. x and y must have the same length.
+func BootstrapPercentageChangeCI(x, y []float64, level float64, iterations int, r *rand.Rand) (float64, float64) {
+	n := len(x)
+	if n == 0 || iterations <= 0 {
+		return 0, 0
+	}
+	changes := make([]float64, iterations)
+	for i := 0; i < iterations; i++ {
+		sumX, sumY := 0.0, 0.0
+		for j := 0; j < n; j++ {
+			k := r.Intn(n)
+			sumX += x[k]
+			sumY += y[k]
+		}
+		changes[i] = PercentageChange(sumX, sumY)
+	}
+	sort.Float64s(changes)
+	alpha := (1 - level) / 2
+	return quantile(changes, alpha), quantile(changes, 1-alpha)
+}
+
+// quantile returns the q-th quantile of the sorted values using linear interpolation.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	if lo < 0 {
+		lo = 0
+	}
+	if hi >= len(sorted) {
+		hi = len(sorted) - 1
+	}
+	frac := pos - float64(lo)
+	return sorted[lo] + (sorted[hi]-sorted[lo])*frac
+}
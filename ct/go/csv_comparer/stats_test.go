@@ -0,0 +1,111 @@
+package csv_comparer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestPercentages(t *testing.T) {
+	assert.Equal(t, 0.0, PercentageDiff(0, 0))
+	assert.InDelta(t, 66.67, PercentageDiff(1, 2), 0.01)
+	assert.Equal(t, 0.0, PercentageChange(0, 5))
+	assert.Equal(t, 100.0, PercentageChange(1, 2))
+	assert.Equal(t, -50.0, PercentageChange(2, 1))
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	// Empty samples and identical samples are not significant.
+	_, p := MannWhitneyU(nil, []float64{1, 2})
+	assert.Equal(t, 1.0, p)
+	_, p = MannWhitneyU([]float64{3, 3, 3}, []float64{3, 3, 3})
+	assert.Equal(t, 1.0, p)
+
+	// Completely separated samples.
+	x := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	y := []float64{11, 12, 13, 14, 15, 16, 17, 18}
+	u, p := MannWhitneyU(x, y)
+	assert.Equal(t, 0.0, u)
+	assert.True(t, p < 0.001)
+	u, p2 := MannWhitneyU(y, x)
+	assert.Equal(t, 64.0, u)
+	assert.InDelta(t, p, p2, 1e-12)
+
+	// Interleaved samples are not significant.
+	_, p = MannWhitneyU([]float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10})
+	assert.True(t, p > 0.5)
+
+	// Ties are averaged: x has ranks 1.5, 1.5 and 3.
+	u, _ = MannWhitneyU([]float64{1, 1, 2}, []float64{3, 4})
+	assert.Equal(t, 0.0, u)
+	u, _ = MannWhitneyU([]float64{1, 3}, []float64{1, 2})
+	assert.Equal(t, 2.5, u)
+}
+
+func TestWilcoxonSignedRank(t *testing.T) {
+	// No or only zero differences are not significant.
+	_, p := WilcoxonSignedRank(nil, nil)
+	assert.Equal(t, 1.0, p)
+	_, p = WilcoxonSignedRank([]float64{1, 2, 3}, []float64{1, 2, 3})
+	assert.Equal(t, 1.0, p)
+
+	// All differences positive, with ranks 1..8.
+	x := []float64{10, 11, 12, 13, 14, 15, 16, 17}
+	y := make([]float64, len(x))
+	for i, v := range x {
+		y[i] = v + float64(i+1)
+	}
+	w, p := WilcoxonSignedRank(x, y)
+	assert.Equal(t, 36.0, w)
+	assert.InDelta(t, 0.0143, p, 0.0001)
+	w, p2 := WilcoxonSignedRank(y, x)
+	assert.Equal(t, 0.0, w)
+	assert.InDelta(t, p, p2, 1e-12)
+
+	// Mixed signs are not significant.
+	w, p = WilcoxonSignedRank([]float64{0, 0, 0, 0}, []float64{1, -2, 3, -4})
+	assert.Equal(t, 4.0, w)
+	assert.True(t, p > 0.5)
+
+	// Ties are averaged and zero differences are dropped: the differences 1, -1 and 2 have
+	// ranks 1.5, 1.5 and 3.
+	w, _ = WilcoxonSignedRank([]float64{0, 0, 0, 5}, []float64{1, -1, 2, 5})
+	assert.Equal(t, 4.5, w)
+
+	// A consistent change across pages of very different magnitudes is only detected when the
+	// values are paired.
+	x = []float64{1, 3, 10, 30, 100, 300, 1000, 3000, 10000, 30000}
+	y = make([]float64, len(x))
+	for i, v := range x {
+		y[i] = v * 1.01
+	}
+	_, p = WilcoxonSignedRank(x, y)
+	assert.True(t, p < 0.01)
+	_, p = MannWhitneyU(x, y)
+	assert.True(t, p > 0.5)
+}
+
+func TestBootstrapPercentageChangeCI(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x := []float64{10, 11, 9, 10, 12, 8, 10, 10}
+	y := make([]float64, len(x))
+	for i, v := range x {
+		y[i] = v * 1.5
+	}
+	// A constant ratio gives a degenerate interval.
+	lo, hi := BootstrapPercentageChangeCI(x, y, 0.95, 200, r)
+	assert.InDelta(t, 50.0, lo, 1e-9)
+	assert.InDelta(t, 50.0, hi, 1e-9)
+
+	y = []float64{10, 16, 9, 14, 12, 9, 15, 11}
+	lo, hi = BootstrapPercentageChangeCI(x, y, 0.95, 1000, r)
+	assert.True(t, lo < hi)
+	assert.True(t, lo > 0)
+	assert.False(t, math.IsNaN(lo))
+
+	lo, hi = BootstrapPercentageChangeCI(nil, nil, 0.95, 1000, r)
+	assert.Equal(t, 0.0, lo)
+	assert.Equal(t, 0.0, hi)
+}
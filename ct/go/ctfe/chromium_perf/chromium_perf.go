@@ -62,6 +62,7 @@ type DBTask struct {
 	Results              sql.NullString `db:"results"`
 	NoPatchRawOutput     sql.NullString `db:"nopatch_raw_output"`
 	WithPatchRawOutput   sql.NullString `db:"withpatch_raw_output"`
+	Comparison           sql.NullString `db:"comparison"`
 }
 
 func (task DBTask) GetTaskName() string {
@@ -215,6 +216,7 @@ type UpdateVars struct {
 	Results            sql.NullString
 	NoPatchRawOutput   sql.NullString
 	WithPatchRawOutput sql.NullString
+	Comparison         sql.NullString
 }
 
 func (vars *UpdateVars) UriPath() string {
@@ -226,6 +228,7 @@ func (task *UpdateVars) GetUpdateExtraClausesAndBinds() ([]string, []interface{}
 		{"NoPatchRawOutput", task.NoPatchRawOutput.String, 255},
 		{"WithPatchRawOutput", task.WithPatchRawOutput.String, 255},
 		{"Results", task.Results.String, 255},
+		{"Comparison", task.Comparison.String, 255},
 	}); err != nil {
 		return nil, nil, err
 	}
@@ -243,6 +246,10 @@ func (task *UpdateVars) GetUpdateExtraClausesAndBinds() ([]string, []interface{}
 		clauses = append(clauses, "withpatch_raw_output = ?")
 		args = append(args, task.WithPatchRawOutput.String)
 	}
+	if task.Comparison.Valid {
+		clauses = append(clauses, "comparison = ?")
+		args = append(args, task.Comparison.String)
+	}
 	return clauses, args, nil
 }
 
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"text/template"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -25,6 +26,7 @@ import (
 	"go.skia.org/infra/ct/go/ctfe/task_types"
 	ctfeutil "go.skia.org/infra/ct/go/ctfe/util"
 	"go.skia.org/infra/ct/go/db"
+	ctutil "go.skia.org/infra/ct/go/util"
 	"go.skia.org/infra/go/httputils"
 )
 
@@ -70,6 +72,61 @@ func GetOldestPendingTask() (task_common.Task, error) {
 	return oldestTask, nil
 }
 
+// GetUserQuotas returns the quotas of all users who have one, keyed by username.
+func GetUserQuotas() (map[string]*UserQuota, error) {
+	quotas := []*UserQuota{}
+	query := fmt.Sprintf("SELECT * FROM %s;", db.TABLE_USER_QUOTAS)
+	if err := db.DB.Select(&quotas, query); err != nil {
+		return nil, fmt.Errorf("Failed to query DB: %v", err)
+	}
+	ret := make(map[string]*UserQuota, len(quotas))
+	for _, q := range quotas {
+		ret[q.Username] = q
+	}
+	return ret, nil
+}
+
+// GetTaskQueue returns all pending tasks of any type in fair-share order. See FairShareOrder.
+func GetTaskQueue() ([]*QueueEntry, error) {
+	now := time.Now().UTC()
+	windowStartTs := now.Add(-FAIR_SHARE_WINDOW).Format(ctutil.TS_FORMAT)
+	pending := []task_common.Task{}
+	started := []task_common.Task{}
+	for _, prototype := range task_types.Prototypes() {
+		query := fmt.Sprintf("SELECT * FROM %s WHERE ts_started IS NULL;", prototype.TableName())
+		data, err := prototype.Select(query)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to query DB: %v", err)
+		}
+		pending = append(pending, task_common.AsTaskSlice(data)...)
+
+		query = fmt.Sprintf("SELECT * FROM %s WHERE ts_started IS NOT NULL AND (ts_completed IS NULL OR ts_started >= ?);", prototype.TableName())
+		data, err = prototype.Select(query, windowStartTs)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to query DB: %v", err)
+		}
+		started = append(started, task_common.AsTaskSlice(data)...)
+	}
+	quotas, err := GetUserQuotas()
+	if err != nil {
+		return nil, err
+	}
+	return FairShareOrder(pending, started, quotas, now), nil
+}
+
+// GetNextPendingTask returns the pending task which should run next according to fair-share
+// ordering and user quotas, or nil if there is no runnable task.
+func GetNextPendingTask() (task_common.Task, error) {
+	queue, err := GetTaskQueue()
+	if err != nil {
+		return nil, err
+	}
+	if len(queue) == 0 || queue[0].Blocked != "" {
+		return nil, nil
+	}
+	return queue[0].Task, nil
+}
+
 // Union of all task types, to be easily marshalled/unmarshalled to/from JSON. At most one field
 // should be non-nil when serialized as JSON.
 type oldestPendingTask struct {
@@ -138,18 +195,34 @@ func DecodeTask(taskJson io.Reader) (task_common.Task, error) {
 	}
 }
 
+// getOldestPendingTaskHandler serves the task that the poller should run next. Despite its URI, the
+// task is chosen using fair-share ordering; see GetNextPendingTask.
 func getOldestPendingTaskHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	oldestTask, err := GetOldestPendingTask()
+	nextTask, err := GetNextPendingTask()
 	if err != nil {
-		httputils.ReportError(w, r, err, "Failed to get oldest pending task")
+		httputils.ReportError(w, r, err, "Failed to get next pending task")
 		return
 	}
 
-	if err := EncodeTask(w, oldestTask); err != nil {
+	if err := EncodeTask(w, nextTask); err != nil {
 		httputils.ReportError(w, r, err,
-			fmt.Sprintf("Failed to encode JSON for %#v", oldestTask))
+			fmt.Sprintf("Failed to encode JSON for %#v", nextTask))
+		return
+	}
+}
+
+func getTaskQueueHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	queue, err := GetTaskQueue()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to get task queue")
+		return
+	}
+	if err := json.NewEncoder(w).Encode(queue); err != nil {
+		httputils.ReportError(w, r, err, "Failed to encode JSON")
 		return
 	}
 }
@@ -184,4 +257,5 @@ func AddHandlers(r *mux.Router) {
 	// Task Queue handlers.
 	r.HandleFunc("/"+ctfeutil.PENDING_TASKS_URI, pendingTasksView).Methods("GET")
 	r.HandleFunc("/"+ctfeutil.GET_OLDEST_PENDING_TASK_URI, getOldestPendingTaskHandler).Methods("GET")
+	r.HandleFunc("/"+ctfeutil.GET_TASK_QUEUE_URI, getTaskQueueHandler).Methods("POST")
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"testing"
+	"time"
 
 	"go.skia.org/infra/ct/go/ctfe/admin_tasks"
 	"go.skia.org/infra/ct/go/ctfe/capture_skps"
@@ -11,6 +12,7 @@ import (
 	"go.skia.org/infra/ct/go/ctfe/chromium_perf"
 	"go.skia.org/infra/ct/go/ctfe/lua_scripts"
 	"go.skia.org/infra/ct/go/ctfe/task_common"
+	ctutil "go.skia.org/infra/ct/go/util"
 
 	expect "github.com/stretchr/testify/assert"
 	assert "github.com/stretchr/testify/require"
@@ -85,3 +87,75 @@ func TestEncodeTaskDecodeTaskRoundTrip(t *testing.T) {
 		SkiaRev:     "586101c79b0490b50623e76c71a5fd67d8d92b08",
 	})
 }
+
+func TestEstimateRunTime(t *testing.T) {
+	expect.Equal(t, CHROMIUM_BUILD_RUN_TIME, EstimateRunTime(&chromium_builds.DBTask{}))
+	expect.Equal(t, TASK_OVERHEAD, EstimateRunTime(&chromium_perf.DBTask{PageSets: "unknown"}))
+
+	small := EstimateRunTime(&chromium_perf.DBTask{PageSets: ctutil.PAGESET_TYPE_10k, RepeatRuns: 1})
+	large := EstimateRunTime(&chromium_perf.DBTask{PageSets: ctutil.PAGESET_TYPE_100k, RepeatRuns: 1})
+	repeated := EstimateRunTime(&chromium_perf.DBTask{PageSets: ctutil.PAGESET_TYPE_10k, RepeatRuns: 3})
+	expect.True(t, small > TASK_OVERHEAD)
+	expect.True(t, large > small)
+	expect.Equal(t, 3*(small-TASK_OVERHEAD), repeated-TASK_OVERHEAD)
+}
+
+func TestFairShareOrder(t *testing.T) {
+	now := time.Date(2016, time.September, 1, 12, 0, 0, 0, time.UTC)
+	id := int64(0)
+	task := func(username string, added time.Time) *chromium_perf.DBTask {
+		id++
+		return &chromium_perf.DBTask{
+			CommonCols: task_common.CommonCols{
+				Id:       id,
+				TsAdded:  sql.NullInt64{Int64: timeToTs(added), Valid: true},
+				Username: username,
+			},
+			PageSets:   ctutil.PAGESET_TYPE_10k,
+			RepeatRuns: 1,
+		}
+	}
+
+	// Alice submits a batch of tasks before Bob and Carol submit one each.
+	a1 := task("alice", now.Add(-5*time.Hour))
+	a2 := task("alice", now.Add(-4*time.Hour))
+	a3 := task("alice", now.Add(-3*time.Hour))
+	b1 := task("bob", now.Add(-2*time.Hour))
+	c1 := task("carol", now.Add(-1*time.Hour))
+	pending := []task_common.Task{c1, a3, b1, a2, a1}
+
+	queue := FairShareOrder(pending, nil, nil, now)
+	assert.Len(t, queue, 5)
+	ids := []int64{}
+	for i, e := range queue {
+		expect.Equal(t, i+1, e.QueuePosition)
+		expect.Equal(t, "", e.Blocked)
+		ids = append(ids, e.Id)
+	}
+	expect.Equal(t, []int64{a1.Id, b1.Id, c1.Id, a2.Id, a3.Id}, ids)
+	expect.Equal(t, timeToTs(now), queue[0].EstimatedStartTs)
+	expect.Equal(t, timeToTs(now.Add(EstimateRunTime(a1))), queue[1].EstimatedStartTs)
+
+	// Bob has a running task, so Alice goes first and Bob is pushed back.
+	running := task("bob", now.Add(-10*time.Hour))
+	running.TsStarted = sql.NullInt64{Int64: timeToTs(now.Add(-10 * time.Minute)), Valid: true}
+	queue = FairShareOrder(pending, []task_common.Task{running}, nil, now)
+	expect.Equal(t, a1.Id, queue[0].Id)
+	expect.Equal(t, c1.Id, queue[1].Id)
+	expect.Equal(t, timeToTs(now.Add(EstimateRunTime(running)-10*time.Minute)), queue[0].EstimatedStartTs)
+
+	// Quotas block users and move their tasks to the back of the queue.
+	quotas := map[string]*UserQuota{
+		"alice": {Username: "alice", MaxWeeklyTasks: 1},
+		"bob":   {Username: "bob", MaxConcurrentTasks: 1},
+	}
+	queue = FairShareOrder(pending, []task_common.Task{running}, quotas, now)
+	ids = []int64{}
+	for _, e := range queue {
+		ids = append(ids, e.Id)
+	}
+	expect.Equal(t, []int64{a1.Id, c1.Id, a2.Id, a3.Id, b1.Id}, ids)
+	expect.Equal(t, "", queue[1].Blocked)
+	expect.Equal(t, BLOCKED_WEEKLY_QUOTA, queue[2].Blocked)
+	expect.Equal(t, BLOCKED_CONCURRENT_QUOTA, queue[4].Blocked)
+}
@@ -0,0 +1,288 @@
+/*
+	Fair-share ordering of pending tasks.
+*/
+
+package pending_tasks
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"go.skia.org/infra/ct/go/ctfe/admin_tasks"
+	"go.skia.org/infra/ct/go/ctfe/capture_skps"
+	"go.skia.org/infra/ct/go/ctfe/chromium_analysis"
+	"go.skia.org/infra/ct/go/ctfe/chromium_builds"
+	"go.skia.org/infra/ct/go/ctfe/chromium_perf"
+	"go.skia.org/infra/ct/go/ctfe/lua_scripts"
+	"go.skia.org/infra/ct/go/ctfe/task_common"
+	ctutil "go.skia.org/infra/ct/go/util"
+)
+
+const (
+	// Usage of the cluster by a user is accumulated over this window when deciding whose task
+	// runs next. It is also the window used for weekly quotas.
+	FAIR_SHARE_WINDOW = 7 * 24 * time.Hour
+
+	// Fixed overhead of every task (syncing, building, isolating and triggering).
+	TASK_OVERHEAD = 20 * time.Minute
+
+	// Estimated run time of a Chromium build task; these do not depend on page sets.
+	CHROMIUM_BUILD_RUN_TIME = 2 * time.Hour
+
+	// Reasons a pending task may be held back from running.
+	BLOCKED_CONCURRENT_QUOTA = "User has reached the concurrent task quota"
+	BLOCKED_WEEKLY_QUOTA     = "User has reached the weekly task quota"
+)
+
+// Approximate time spent on a single page by each task type on a single worker. These only need to
+// be accurate relative to each other since they are used to order and estimate tasks.
+var perPageRunTime = map[string]time.Duration{
+	"ChromiumPerf":            10 * time.Second,
+	"ChromiumAnalysis":        5 * time.Second,
+	"CaptureSkps":             10 * time.Second,
+	"LuaScript":               1 * time.Second,
+	"RecreatePageSets":        100 * time.Millisecond,
+	"RecreateWebpageArchives": 20 * time.Second,
+}
+
+// UserQuota limits how many tasks a single user may have in flight. A zero value for either field
+// means that there is no limit.
+type UserQuota struct {
+	Username           string `db:"username"`
+	MaxConcurrentTasks int64  `db:"max_concurrent_tasks"`
+	MaxWeeklyTasks     int64  `db:"max_weekly_tasks"`
+}
+
+// QueueEntry describes a pending task and where it currently sits in the fair-share queue.
+type QueueEntry struct {
+	Task task_common.Task `json:"-"`
+
+	TaskType string
+	Id       int64
+	Username string
+	TsAdded  int64
+	// 1-based position in the queue. Blocked tasks are placed after all runnable tasks.
+	QueuePosition int
+	// Estimated time the task will occupy the cluster.
+	EstimatedRunTimeSecs int64
+	// Estimated time at which the task will start, as a CT timestamp.
+	EstimatedStartTs int64
+	// Non-empty if the task is held back by a quota.
+	Blocked string
+}
+
+// pageSetsAndRepeats returns the page set and number of times each page is run for the given task.
+// Tasks which do not use page sets return an empty string.
+func pageSetsAndRepeats(task task_common.Task) (string, int64) {
+	switch t := task.(type) {
+	case *chromium_perf.DBTask:
+		repeats := t.RepeatRuns
+		if repeats < 1 {
+			repeats = 1
+		}
+		// Every page is run both with and without the patch.
+		return t.PageSets, 2 * repeats
+	case *chromium_analysis.DBTask:
+		return t.PageSets, 1
+	case *capture_skps.DBTask:
+		return t.PageSets, 1
+	case *lua_scripts.DBTask:
+		return t.PageSets, 1
+	case *admin_tasks.RecreatePageSetsDBTask:
+		return t.PageSets, 1
+	case *admin_tasks.RecreateWebpageArchivesDBTask:
+		return t.PageSets, 1
+	default:
+		return "", 0
+	}
+}
+
+// EstimateRunTime returns a rough estimate of how long the given task will take to complete, based
+// on its type, the size of its page set and its repeat count.
+func EstimateRunTime(task task_common.Task) time.Duration {
+	if _, ok := task.(*chromium_builds.DBTask); ok {
+		return CHROMIUM_BUILD_RUN_TIME
+	}
+	pageSets, repeats := pageSetsAndRepeats(task)
+	info, ok := ctutil.PagesetTypeToInfo[pageSets]
+	if !ok {
+		return TASK_OVERHEAD
+	}
+	perPage := perPageRunTime[task.GetTaskName()]
+	pages := int64(info.NumPages) * repeats
+	return TASK_OVERHEAD + time.Duration(pages)*perPage/time.Duration(ctutil.NUM_BARE_METAL_MACHINES)
+}
+
+// tsToTime converts a CT timestamp, eg: 20160102150405, into a time.Time.
+func tsToTime(ts int64) time.Time {
+	return ctutil.GetTimeFromTs(strconv.FormatInt(ts, 10))
+}
+
+// timeToTs converts a time.Time into a CT timestamp.
+func timeToTs(t time.Time) int64 {
+	ts, _ := strconv.ParseInt(t.UTC().Format(ctutil.TS_FORMAT), 10, 64)
+	return ts
+}
+
+// userState tracks a single user's usage while simulating the queue.
+type userState struct {
+	username string
+	// Estimated cluster time used by the user within FAIR_SHARE_WINDOW, including simulated tasks.
+	usage time.Duration
+	// Number of tasks of this user that are currently running.
+	running int64
+	// Number of tasks of this user started within FAIR_SHARE_WINDOW, including simulated tasks.
+	startedThisWeek int64
+	// Pending tasks of this user, oldest first.
+	pending []task_common.Task
+}
+
+// blockedReason returns a non-empty string if the user may not start another task given quota.
+func (u *userState) blockedReason(quota *UserQuota) string {
+	if quota == nil {
+		return ""
+	}
+	if quota.MaxConcurrentTasks > 0 && u.running >= quota.MaxConcurrentTasks {
+		return BLOCKED_CONCURRENT_QUOTA
+	}
+	if quota.MaxWeeklyTasks > 0 && u.startedThisWeek >= quota.MaxWeeklyTasks {
+		return BLOCKED_WEEKLY_QUOTA
+	}
+	return ""
+}
+
+// FairShareOrder orders the pending tasks so that users who have used the cluster least recently
+// go first. pending contains tasks that have not started. started contains tasks that are running
+// or that started within FAIR_SHARE_WINDOW of now; they count towards each user's usage and quotas.
+// quotas maps usernames to their quota; users without an entry are not limited.
+//
+// The queue is built by repeatedly picking the oldest pending task of the user with the least
+// accumulated usage, adding that task's estimated run time to the user's usage. Tasks of users who
+// have exhausted their quota are placed at the end of the queue, oldest first, and marked blocked.
+// Start times are estimated assuming tasks run one after another.
+func FairShareOrder(pending, started []task_common.Task, quotas map[string]*UserQuota, now time.Time) []*QueueEntry {
+	users := map[string]*userState{}
+	getUser := func(username string) *userState {
+		u, ok := users[username]
+		if !ok {
+			u = &userState{username: username}
+			users[username] = u
+		}
+		return u
+	}
+
+	// Remaining time of currently running tasks delays everything in the queue.
+	var busyFor time.Duration
+	windowStart := now.Add(-FAIR_SHARE_WINDOW)
+	for _, task := range started {
+		common := task.GetCommonCols()
+		u := getUser(common.Username)
+		estimate := EstimateRunTime(task)
+		u.usage += estimate
+		if !common.TsStarted.Valid {
+			continue
+		}
+		startedAt := tsToTime(common.TsStarted.Int64)
+		if !startedAt.Before(windowStart) {
+			u.startedThisWeek++
+		}
+		if !common.TsCompleted.Valid {
+			u.running++
+			if remaining := estimate - now.Sub(startedAt); remaining > busyFor {
+				busyFor = remaining
+			}
+		}
+	}
+
+	sortedPending := make([]task_common.Task, len(pending))
+	copy(sortedPending, pending)
+	sort.Sort(byTsAdded(sortedPending))
+	for _, task := range sortedPending {
+		u := getUser(task.GetCommonCols().Username)
+		u.pending = append(u.pending, task)
+	}
+
+	queue := make([]*QueueEntry, 0, len(pending))
+	blocked := []*QueueEntry{}
+	eta := now.Add(busyFor)
+	for {
+		// Find the user with the least usage who may run another task. Ties go to the user
+		// with the oldest pending task.
+		var next *userState
+		for _, u := range users {
+			if len(u.pending) == 0 {
+				continue
+			}
+			if reason := u.blockedReason(quotas[u.username]); reason != "" {
+				for _, task := range u.pending {
+					blocked = append(blocked, newQueueEntry(task, reason))
+				}
+				u.pending = nil
+				continue
+			}
+			if next == nil || u.usage < next.usage ||
+				(u.usage == next.usage && byTsAdded{u.pending[0], next.pending[0]}.Less(0, 1)) {
+				next = u
+			}
+		}
+		if next == nil {
+			break
+		}
+		task := next.pending[0]
+		next.pending = next.pending[1:]
+		entry := newQueueEntry(task, "")
+		entry.EstimatedStartTs = timeToTs(eta)
+		queue = append(queue, entry)
+
+		estimate := EstimateRunTime(task)
+		eta = eta.Add(estimate)
+		next.usage += estimate
+		next.startedThisWeek++
+	}
+
+	sort.Sort(queueEntriesByTsAdded(blocked))
+	queue = append(queue, blocked...)
+	for i, entry := range queue {
+		entry.QueuePosition = i + 1
+	}
+	return queue
+}
+
+func newQueueEntry(task task_common.Task, blocked string) *QueueEntry {
+	common := task.GetCommonCols()
+	return &QueueEntry{
+		Task:                 task,
+		TaskType:             task.GetTaskName(),
+		Id:                   common.Id,
+		Username:             common.Username,
+		TsAdded:              common.TsAdded.Int64,
+		EstimatedRunTimeSecs: int64(EstimateRunTime(task).Seconds()),
+		Blocked:              blocked,
+	}
+}
+
+// byTsAdded implements sort.Interface to order Tasks by TsAdded, then task type and ID.
+type byTsAdded []task_common.Task
+
+func (t byTsAdded) Len() int      { return len(t) }
+func (t byTsAdded) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t byTsAdded) Less(i, j int) bool {
+	a, b := t[i].GetCommonCols(), t[j].GetCommonCols()
+	if a.TsAdded.Int64 != b.TsAdded.Int64 {
+		return a.TsAdded.Int64 < b.TsAdded.Int64
+	}
+	if t[i].GetTaskName() != t[j].GetTaskName() {
+		return t[i].GetTaskName() < t[j].GetTaskName()
+	}
+	return a.Id < b.Id
+}
+
+// queueEntriesByTsAdded implements sort.Interface to order QueueEntries by TsAdded.
+type queueEntriesByTsAdded []*QueueEntry
+
+func (q queueEntriesByTsAdded) Len() int      { return len(q) }
+func (q queueEntriesByTsAdded) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q queueEntriesByTsAdded) Less(i, j int) bool {
+	return byTsAdded{q[i].Task, q[j].Task}.Less(0, 1)
+}
@@ -81,6 +81,7 @@ const (
 
 	PENDING_TASKS_URI           = "queue/"
 	GET_OLDEST_PENDING_TASK_URI = "_/get_oldest_pending_task"
+	GET_TASK_QUEUE_URI          = "_/get_task_queue"
 
 	PAGE_SETS_PARAMETERS_POST_URI = "_/page_sets/"
 	CL_DATA_POST_URI              = "_/cl_data"
@@ -22,6 +22,7 @@ const (
 	TABLE_CHROMIUM_BUILD_TASKS            = "ChromiumBuildTasks"
 	TABLE_RECREATE_PAGE_SETS_TASKS        = "RecreatePageSetsTasks"
 	TABLE_RECREATE_WEBPAGE_ARCHIVES_TASKS = "RecreateWebpageArchivesTasks"
+	TABLE_USER_QUOTAS                     = "UserQuotas"
 
 	// From https://dev.mysql.com/doc/refman/5.0/en/storage-requirements.html
 	TEXT_MAX_LENGTH      = 1<<16 - 1
@@ -308,6 +309,26 @@ var v15_down = []string{
 	`ALTER TABLE ChromiumPerfTasks DROP catapult_patch`,
 }
 
+var v16_up = []string{
+	`CREATE TABLE IF NOT EXISTS UserQuotas (
+		username               VARCHAR(255) NOT NULL PRIMARY KEY,
+		max_concurrent_tasks   BIGINT       NOT NULL DEFAULT 0,
+		max_weekly_tasks       BIGINT       NOT NULL DEFAULT 0
+	)`,
+}
+
+var v16_down = []string{
+	`DROP TABLE IF EXISTS UserQuotas`,
+}
+
+var v17_up = []string{
+	`ALTER TABLE ChromiumPerfTasks ADD comparison VARCHAR(255)`,
+}
+
+var v17_down = []string{
+	`ALTER TABLE ChromiumPerfTasks DROP comparison`,
+}
+
 // Define the migration steps.
 // Note: Only add to this list, once a step has landed in version control it
 // must not be changed.
@@ -387,6 +408,16 @@ var migrationSteps = []database.MigrationStep{
 		MySQLUp:   v15_up,
 		MySQLDown: v15_down,
 	},
+	// version 16: Create UserQuotas table.
+	{
+		MySQLUp:   v16_up,
+		MySQLDown: v16_down,
+	},
+	// version 17: Add comparison column to ChromiumPerfTasks.
+	{
+		MySQLUp:   v17_up,
+		MySQLDown: v17_down,
+	},
 }
 
 // MigrationSteps returns the database migration steps.
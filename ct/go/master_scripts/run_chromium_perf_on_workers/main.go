@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/skia-dev/glog"
+	"go.skia.org/infra/ct/go/csv_comparer"
 	"go.skia.org/infra/ct/go/ctfe/chromium_perf"
 	"go.skia.org/infra/ct/go/frontend"
 	"go.skia.org/infra/ct/go/master_scripts/master_common"
@@ -52,6 +53,8 @@ var (
 	benchmarkPatchLink  = util.MASTER_LOGSERVER_LINK
 	noPatchOutputLink   = util.MASTER_LOGSERVER_LINK
 	withPatchOutputLink = util.MASTER_LOGSERVER_LINK
+	// Link to the csv_comparer report, empty if it could not be written.
+	comparisonLink = ""
 )
 
 func sendEmail(recipients []string) {
@@ -100,6 +103,9 @@ func updateWebappTask() {
 	vars.Results = sql.NullString{String: htmlOutputLink, Valid: true}
 	vars.NoPatchRawOutput = sql.NullString{String: noPatchOutputLink, Valid: true}
 	vars.WithPatchRawOutput = sql.NullString{String: withPatchOutputLink, Valid: true}
+	if comparisonLink != "" {
+		vars.Comparison = sql.NullString{String: comparisonLink, Valid: true}
+	}
 	skutil.LogErr(frontend.UpdateWebappTaskV2(&vars))
 }
 
@@ -269,6 +275,13 @@ func main() {
 		return
 	}
 
+	// Also write the native statistical comparison alongside the csv_comparer.py output.
+	if err := writeComparisonReport(noPatchCSVPath, withPatchCSVPath, htmlOutputDir); err != nil {
+		glog.Errorf("Could not write comparison report: %s", err)
+	} else {
+		comparisonLink = htmlOutputLinkBase + "comparison.html"
+	}
+
 	// Copy the HTML files to Google Storage.
 	if err := gs.UploadDir(htmlOutputDir, htmlRemoteDir, true); err != nil {
 		glog.Errorf("Could not upload %s to %s: %s", htmlOutputDir, htmlRemoteDir, err)
@@ -277,3 +290,21 @@ func main() {
 
 	taskCompletedSuccessfully = true
 }
+
+// writeComparisonReport compares the merged nopatch and withpatch CSV files using csv_comparer
+// and writes JSON and HTML reports into outputDir.
+func writeComparisonReport(noPatchCSVPath, withPatchCSVPath, outputDir string) error {
+	noPatch, err := csv_comparer.ReadCSVFile(noPatchCSVPath)
+	if err != nil {
+		return err
+	}
+	withPatch, err := csv_comparer.ReadCSVFile(withPatchCSVPath)
+	if err != nil {
+		return err
+	}
+	cfg := csv_comparer.DefaultConfig()
+	cfg.VarianceThreshold = *varianceThreshold
+	cfg.DiscardOutliers = *discardOutliers
+	report := csv_comparer.Compare(noPatch, withPatch, cfg)
+	return report.WriteFiles(filepath.Join(outputDir, "comparison.json"), filepath.Join(outputDir, "comparison.html"))
+}
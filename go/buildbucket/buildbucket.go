@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"go.skia.org/infra/go/util"
@@ -268,3 +270,11 @@ func (c *Client) GetTrybotsForCL(issueID int64, patchsetID int64) ([]*Build, err
 	url := fmt.Sprintf("%s/search?tag=buildset%%3Apatch%%2Frietveld%%2Fcodereview.chromium.org%%2F%d%%2F%d", apiUrl, issueID, patchsetID)
 	return c.Search(url)
 }
+
+// GetTrybotsForGerritCL retrieves trybot results for the given change on the
+// Gerrit instance at gerritURL.
+func (c *Client) GetTrybotsForGerritCL(gerritURL string, issueID int64, patchsetID int64) ([]*Build, error) {
+	host := strings.TrimPrefix(strings.TrimPrefix(gerritURL, "https://"), "http://")
+	buildset := fmt.Sprintf("buildset:patch/gerrit/%s/%d/%d", strings.TrimRight(host, "/"), issueID, patchsetID)
+	return c.Search(fmt.Sprintf("%s/search?tag=%s", apiUrl, url.QueryEscape(buildset)))
+}
@@ -0,0 +1,84 @@
+package codereview
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/util"
+)
+
+// MAX_POLL_ISSUES is the maximum number of modified issues IssueCache
+// retrieves per poll.
+const MAX_POLL_ISSUES = 10000
+
+// IssueCache is an LRU cache for Issues that polls in the background to
+// determine if issues have been updated. If so it expells them from the cache
+// to force a reload.
+type IssueCache struct {
+	cache     *lru.Cache
+	review    CodeReview
+	timeDelta time.Duration
+	mutex     sync.Mutex
+}
+
+// NewIssueCache returns a new cache for the given code review system, poll
+// interval and maximum cache size.
+func NewIssueCache(review CodeReview, pollInterval time.Duration, cacheSize int) *IssueCache {
+	ret := &IssueCache{
+		cache:     lru.New(cacheSize),
+		review:    review,
+		timeDelta: pollInterval * 2,
+	}
+
+	// Start the poller.
+	go util.Repeat(pollInterval, nil, ret.poll)
+	return ret
+}
+
+// Add an issue to the cache.
+func (c *IssueCache) Add(key int64, value *Issue) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache.Add(key, value)
+}
+
+// Get retrieves an issue from the cache.
+func (c *IssueCache) Get(key int64) (*Issue, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if val, ok := c.cache.Get(key); ok {
+		return val.(*Issue), true
+	}
+	return nil, false
+}
+
+// GetIssue returns the issue from the cache, or retrieves it from the code
+// review system and adds it to the cache.
+func (c *IssueCache) GetIssue(issueID int64) (*Issue, error) {
+	if issue, ok := c.Get(issueID); ok {
+		return issue, nil
+	}
+	issue, err := c.review.GetIssue(issueID)
+	if err != nil {
+		return nil, err
+	}
+	c.Add(issueID, issue)
+	return issue, nil
+}
+
+// poll removes all issues that have changed in the recent past.
+func (c *IssueCache) poll() {
+	keys, err := c.review.ModifiedIssues(time.Now().Add(-c.timeDelta), MAX_POLL_ISSUES)
+	if err != nil {
+		glog.Errorf("Error polling %s: %s", c.review.Url(), err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, key := range keys {
+		c.cache.Remove(key)
+	}
+}
@@ -0,0 +1,84 @@
+// codereview is an abstraction over the code review systems that trybot
+// results can come from, so that tools like Gold and Perf can work with issues
+// in Rietveld and changes in Gerrit the same way. Both are called issues here.
+//
+// The issues of a code review system are identified in trace databases by a
+// source of the form Url() + "/" + issue ID, see IssueSource.
+package codereview
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Status values of a Tryjob.
+	TRYJOB_SCHEDULED = "scheduled"
+	TRYJOB_RUNNING   = "running"
+	TRYJOB_COMPLETE  = "complete"
+	TRYJOB_FAILED    = "failed"
+)
+
+// Issue contains the information about an issue that is common to all code
+// review systems.
+type Issue struct {
+	ID        int64
+	Subject   string
+	Owner     string
+	Created   time.Time
+	Modified  time.Time
+	Patchsets []int64
+	Closed    bool
+	Committed bool
+}
+
+// Patchset contains the information about one patchset of an issue.
+type Patchset struct {
+	ID       int64
+	IssueID  int64
+	Owner    string
+	Created  time.Time
+	Modified time.Time
+	Tryjobs  []*Tryjob
+}
+
+// Tryjob is a trybot run on a patchset.
+type Tryjob struct {
+	Master      string
+	Builder     string
+	BuildNumber int64
+
+	// Status is one of the TRYJOB_* constants.
+	Status string
+}
+
+// CodeReview is the interface to a code review system.
+type CodeReview interface {
+	// Url returns the URL of the code review system, without a trailing
+	// slash.
+	Url() string
+
+	// GetIssue returns the issue with the given ID.
+	GetIssue(issueID int64) (*Issue, error)
+
+	// GetPatchset returns the given patchset of an issue, including its
+	// tryjobs.
+	GetPatchset(issueID, patchsetID int64) (*Patchset, error)
+
+	// ModifiedIssues returns the IDs of up to limit issues which were
+	// modified after the given time.
+	ModifiedIssues(after time.Time, limit int) ([]int64, error)
+}
+
+// IssueSource returns the source under which the results of the issue are
+// stored in trace databases.
+func IssueSource(review CodeReview, issueID int64) string {
+	return fmt.Sprintf("%s/%d", review.Url(), issueID)
+}
+
+// IsIssueSource returns true if the source belongs to an issue of the given
+// code review system.
+func IsIssueSource(review CodeReview, source string) bool {
+	return strings.HasPrefix(source, review.Url()+"/")
+}
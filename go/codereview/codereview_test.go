@@ -0,0 +1,135 @@
+package codereview
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/gerrit"
+	"go.skia.org/infra/go/mockhttpclient"
+	"go.skia.org/infra/go/rietveld"
+)
+
+const (
+	TEST_GERRIT_URL   = "https://skia-review.googlesource.com"
+	TEST_RIETVELD_URL = "https://codereview.chromium.org"
+
+	BUILDBUCKET_SEARCH_URL = "https://cr-buildbucket.appspot.com/_ah/api/buildbucket/v1/search?tag=buildset%3Apatch%2Fgerrit%2Fskia-review.googlesource.com%2F2345%2F2"
+)
+
+func readTestData(t *testing.T, path ...string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join(path...))
+	assert.NoError(t, err)
+	return b
+}
+
+func tryjobStatus(tryjobs []*Tryjob) map[string]string {
+	ret := make(map[string]string, len(tryjobs))
+	for _, tj := range tryjobs {
+		ret[fmt.Sprintf("%s:%s:%d", tj.Master, tj.Builder, tj.BuildNumber)] = tj.Status
+	}
+	return ret
+}
+
+func TestGerrit(t *testing.T) {
+	m := mockhttpclient.NewURLMock()
+	m.Mock(TEST_GERRIT_URL+"/changes/2345/detail?o=ALL_REVISIONS", mockhttpclient.MockGetDialogue(readTestData(t, "..", "gerrit", "testdata", "change_detail.txt")))
+	m.Mock(BUILDBUCKET_SEARCH_URL, mockhttpclient.MockGetDialogue(readTestData(t, "testdata", "buildbucket_search.json")))
+	review := NewGerrit(gerrit.New(TEST_GERRIT_URL, m.Client()))
+
+	assert.Equal(t, TEST_GERRIT_URL+"/2345", IssueSource(review, 2345))
+	assert.True(t, IsIssueSource(review, TEST_GERRIT_URL+"/2345"))
+	assert.False(t, IsIssueSource(review, TEST_RIETVELD_URL+"/2345"))
+
+	issue, err := review.GetIssue(2345)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2345), issue.ID)
+	assert.Equal(t, "jdoe@example.com", issue.Owner)
+	assert.Equal(t, []int64{1, 2}, issue.Patchsets)
+	assert.False(t, issue.Closed)
+
+	patchset, err := review.GetPatchset(2345, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), patchset.ID)
+	assert.Equal(t, issue.Modified, patchset.Created)
+	assert.Equal(t, map[string]string{
+		"client.skia:Test-Ubuntu-GCC-GCE-CPU-AVX2-x86_64-Debug-Trybot:1234":        TRYJOB_COMPLETE,
+		"client.skia:Test-Win8-MSVC-ShuttleB-GPU-HD4600-x86_64-Release-Trybot:567": TRYJOB_RUNNING,
+		"client.skia.compile:Build-Ubuntu-GCC-x86_64-Release-Trybot:0":             TRYJOB_SCHEDULED,
+		"client.skia:Test-Android-GCC-Nexus5-GPU-Adreno330-Arm7-Debug-Trybot:89":   TRYJOB_FAILED,
+	}, tryjobStatus(patchset.Tryjobs))
+
+	_, err = review.GetPatchset(2345, 3)
+	assert.Error(t, err)
+}
+
+func TestRietveld(t *testing.T) {
+	m := mockhttpclient.NewURLMock()
+	m.Mock(TEST_RIETVELD_URL+"/api/2383713002/20001", mockhttpclient.MockGetDialogue(readTestData(t, "testdata", "rietveld_patchset.json")))
+	review := NewRietveld(rietveld.New(TEST_RIETVELD_URL, m.Client()))
+	assert.Equal(t, TEST_RIETVELD_URL+"/2383713002", IssueSource(review, 2383713002))
+
+	patchset, err := review.GetPatchset(2383713002, 20001)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20001), patchset.ID)
+	assert.Equal(t, int64(2383713002), patchset.IssueID)
+	assert.Equal(t, map[string]string{
+		"client.skia:Test-Ubuntu-GCC-GCE-CPU-AVX2-x86_64-Debug-Trybot:1001":         TRYJOB_COMPLETE,
+		"client.skia:Test-Win8-MSVC-ShuttleB-GPU-HD4600-x86_64-Release-Trybot:1002": TRYJOB_RUNNING,
+		"client.skia:Test-Mac-Clang-MacMini6.2-CPU-AVX-x86_64-Debug-Trybot:-1":      TRYJOB_SCHEDULED,
+		"client.skia:Test-Android-GCC-Nexus5-GPU-Adreno330-Arm7-Debug-Trybot:1004":  TRYJOB_FAILED,
+	}, tryjobStatus(patchset.Tryjobs))
+}
+
+// countingReview is a CodeReview which counts the calls to GetIssue.
+type countingReview struct {
+	mutex    sync.Mutex
+	calls    int
+	modified []int64
+}
+
+func (c *countingReview) Url() string {
+	return TEST_GERRIT_URL
+}
+
+func (c *countingReview) GetIssue(issueID int64) (*Issue, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls++
+	return &Issue{ID: issueID}, nil
+}
+
+func (c *countingReview) GetPatchset(issueID, patchsetID int64) (*Patchset, error) {
+	return nil, fmt.Errorf("Not implemented")
+}
+
+func (c *countingReview) ModifiedIssues(after time.Time, limit int) ([]int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.modified, nil
+}
+
+func TestIssueCache(t *testing.T) {
+	review := &countingReview{}
+	cache := NewIssueCache(review, time.Hour, 10)
+
+	for i := 0; i < 3; i++ {
+		issue, err := cache.GetIssue(2345)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2345), issue.ID)
+	}
+	assert.Equal(t, 1, review.calls)
+
+	// Modified issues are reloaded after the next poll.
+	review.mutex.Lock()
+	review.modified = []int64{2345}
+	review.mutex.Unlock()
+	cache.poll()
+	_, err := cache.GetIssue(2345)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, review.calls)
+}
@@ -0,0 +1,165 @@
+package codereview
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/buildbucket"
+	"go.skia.org/infra/go/gerrit"
+)
+
+// Values of buildbucket.Build.Status and buildbucket.Build.Result.
+const (
+	BUILDBUCKET_STATUS_SCHEDULED = "SCHEDULED"
+	BUILDBUCKET_STATUS_STARTED   = "STARTED"
+	BUILDBUCKET_STATUS_COMPLETED = "COMPLETED"
+	BUILDBUCKET_RESULT_SUCCESS   = "SUCCESS"
+
+	// BUILDBUCKET_MASTER_PREFIX is the prefix of the buckets of buildbot
+	// masters.
+	BUILDBUCKET_MASTER_PREFIX = "master."
+)
+
+// gerritReview implements CodeReview for Gerrit. Gerrit doesn't know about
+// tryjobs, so they are retrieved from buildbucket.
+type gerritReview struct {
+	api *gerrit.Gerrit
+}
+
+// NewGerrit returns a CodeReview which uses the given Gerrit instance.
+func NewGerrit(api *gerrit.Gerrit) CodeReview {
+	return &gerritReview{api: api}
+}
+
+// See CodeReview interface.
+func (g *gerritReview) Url() string {
+	return g.api.Url()
+}
+
+// See CodeReview interface.
+func (g *gerritReview) GetIssue(issueID int64) (*Issue, error) {
+	change, err := g.api.GetIssueProperties(issueID)
+	if err != nil {
+		return nil, err
+	}
+	patchsets := make([]int64, 0, len(change.Patchsets))
+	for _, rev := range change.Patchsets {
+		patchsets = append(patchsets, rev.Number)
+	}
+	owner := ""
+	if change.Owner != nil {
+		owner = change.Owner.Email
+	}
+	return &Issue{
+		ID:        change.Issue,
+		Subject:   change.Subject,
+		Owner:     owner,
+		Created:   change.Created,
+		Modified:  change.Updated,
+		Patchsets: patchsets,
+		Closed:    change.IsClosed(),
+		Committed: change.Status == gerrit.CHANGE_STATUS_MERGED,
+	}, nil
+}
+
+// buildbucketTryjob converts a build from buildbucket into a Tryjob.
+func buildbucketTryjob(b *buildbucket.Build) (*Tryjob, error) {
+	params := struct {
+		BuilderName string `json:"builder_name"`
+	}{}
+	if err := json.Unmarshal([]byte(b.ParametersJson), &params); err != nil {
+		return nil, fmt.Errorf("Invalid parameters of build %s: %s", b.Id, err)
+	}
+	ret := &Tryjob{
+		Master:  strings.TrimPrefix(b.Bucket, BUILDBUCKET_MASTER_PREFIX),
+		Builder: params.BuilderName,
+	}
+
+	// Builds only have a build number once they have started.
+	if b.ResultDetailsJson != "" {
+		details := struct {
+			Properties struct {
+				BuildNumber int64 `json:"buildnumber"`
+			} `json:"properties"`
+		}{}
+		if err := json.Unmarshal([]byte(b.ResultDetailsJson), &details); err != nil {
+			return nil, fmt.Errorf("Invalid result details of build %s: %s", b.Id, err)
+		}
+		ret.BuildNumber = details.Properties.BuildNumber
+	}
+
+	switch b.Status {
+	case BUILDBUCKET_STATUS_SCHEDULED:
+		ret.Status = TRYJOB_SCHEDULED
+	case BUILDBUCKET_STATUS_STARTED:
+		ret.Status = TRYJOB_RUNNING
+	case BUILDBUCKET_STATUS_COMPLETED:
+		if b.Result == BUILDBUCKET_RESULT_SUCCESS {
+			ret.Status = TRYJOB_COMPLETE
+		} else {
+			ret.Status = TRYJOB_FAILED
+		}
+	default:
+		ret.Status = TRYJOB_FAILED
+	}
+	return ret, nil
+}
+
+// See CodeReview interface.
+func (g *gerritReview) GetPatchset(issueID, patchsetID int64) (*Patchset, error) {
+	change, err := g.api.GetIssueProperties(issueID)
+	if err != nil {
+		return nil, err
+	}
+	var rev *gerrit.Revision
+	for _, r := range change.Patchsets {
+		if r.Number == patchsetID {
+			rev = r
+			break
+		}
+	}
+	if rev == nil {
+		return nil, fmt.Errorf("Issue %d has no patchset %d", issueID, patchsetID)
+	}
+	builds, err := g.api.GetTrybotResults(issueID, patchsetID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve tryjobs of %d/%d: %s", issueID, patchsetID, err)
+	}
+	tryjobs := make([]*Tryjob, 0, len(builds))
+	for _, b := range builds {
+		tj, err := buildbucketTryjob(b)
+		if err != nil {
+			glog.Errorf("Skipping tryjob: %s", err)
+			continue
+		}
+		tryjobs = append(tryjobs, tj)
+	}
+	owner := ""
+	if change.Owner != nil {
+		owner = change.Owner.Email
+	}
+	return &Patchset{
+		ID:       patchsetID,
+		IssueID:  issueID,
+		Owner:    owner,
+		Created:  rev.Created,
+		Modified: rev.Created,
+		Tryjobs:  tryjobs,
+	}, nil
+}
+
+// See CodeReview interface.
+func (g *gerritReview) ModifiedIssues(after time.Time, limit int) ([]int64, error) {
+	changes, err := g.api.Search(limit, gerrit.SearchModifiedAfter(after))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]int64, 0, len(changes))
+	for _, c := range changes {
+		ret = append(ret, c.Issue)
+	}
+	return ret, nil
+}
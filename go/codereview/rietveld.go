@@ -0,0 +1,92 @@
+package codereview
+
+import (
+	"time"
+
+	"go.skia.org/infra/go/rietveld"
+)
+
+// Rietveld tryjob results, see TryjobResult in Rietveld's models.py.
+const (
+	RIETVELD_RESULT_RUNNING   = -1
+	RIETVELD_RESULT_SUCCESS   = 0
+	RIETVELD_RESULT_FAILURE   = 2
+	RIETVELD_RESULT_SCHEDULED = 6
+)
+
+// rietveldReview implements CodeReview for Rietveld.
+type rietveldReview struct {
+	api *rietveld.Rietveld
+}
+
+// NewRietveld returns a CodeReview which uses the given Rietveld instance.
+func NewRietveld(api *rietveld.Rietveld) CodeReview {
+	return &rietveldReview{api: api}
+}
+
+// See CodeReview interface.
+func (r *rietveldReview) Url() string {
+	return r.api.Url()
+}
+
+// See CodeReview interface.
+func (r *rietveldReview) GetIssue(issueID int64) (*Issue, error) {
+	issue, err := r.api.GetIssueProperties(issueID, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Issue{
+		ID:        issue.Issue,
+		Subject:   issue.Subject,
+		Owner:     issue.Owner,
+		Created:   issue.Created,
+		Modified:  issue.Modified,
+		Patchsets: issue.Patchsets,
+		Closed:    issue.Closed,
+		Committed: issue.Committed,
+	}, nil
+}
+
+// rietveldTryjobStatus returns the status of a Rietveld tryjob.
+func rietveldTryjobStatus(result int64) string {
+	switch result {
+	case RIETVELD_RESULT_SCHEDULED:
+		return TRYJOB_SCHEDULED
+	case RIETVELD_RESULT_RUNNING:
+		return TRYJOB_RUNNING
+	case RIETVELD_RESULT_SUCCESS:
+		return TRYJOB_COMPLETE
+	default:
+		return TRYJOB_FAILED
+	}
+}
+
+// See CodeReview interface.
+func (r *rietveldReview) GetPatchset(issueID, patchsetID int64) (*Patchset, error) {
+	patchset, err := r.api.GetPatchset(issueID, patchsetID)
+	if err != nil {
+		return nil, err
+	}
+	tryjobs := make([]*Tryjob, 0, len(patchset.TryjobResults))
+	for _, tj := range patchset.TryjobResults {
+		tryjobs = append(tryjobs, &Tryjob{
+			Master:      tj.Master,
+			Builder:     tj.Builder,
+			BuildNumber: tj.BuildNumber,
+			Status:      rietveldTryjobStatus(tj.Result),
+		})
+	}
+	return &Patchset{
+		ID:       patchset.Patchset,
+		IssueID:  patchset.Issue,
+		Owner:    patchset.Owner,
+		Created:  patchset.Created,
+		Modified: patchset.Modified,
+		Tryjobs:  tryjobs,
+	}, nil
+}
+
+// See CodeReview interface.
+func (r *rietveldReview) ModifiedIssues(after time.Time, limit int) ([]int64, error) {
+	return r.api.SearchKeys(limit, rietveld.SearchModifiedAfter(after))
+}
@@ -0,0 +1,346 @@
+// failover lets a spare take over from a master when the master stops being
+// healthy, and hand back to it once it recovers.
+//
+// A Manager runs a HealthCheck against the master every period. Once the
+// check has failed FailThreshold times in a row the Manager runs the Takeover
+// of its Action, and once the check has succeeded RecoverThreshold times in a
+// row after that it runs the Release. For example, to bring up a virtual IP
+// after five failed connections to the master's ssh port:
+//
+//    m := failover.New("skolo.hotspare", failover.NewTCPCheck("192.168.1.199:22", time.Second),
+//      failover.NewCommandAction("sudo ifconfig eth0:0 192.168.1.200", "sudo ifconfig eth0:0 down"), 5, 1)
+//    go m.Run(time.Second)
+//
+// If more than one spare watches the same master, give each Manager the same
+// Lease with SetLease, so that only the spare that holds the lease takes over.
+package failover
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.skia.org/infra/go/exec"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/metrics2"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+)
+
+// HealthCheck checks the health of the master.
+type HealthCheck interface {
+	// Check returns nil if the master is healthy.
+	Check() error
+}
+
+// HealthCheckFunc is a HealthCheck that calls a func.
+type HealthCheckFunc func() error
+
+// Check implements HealthCheck.
+func (f HealthCheckFunc) Check() error {
+	return f()
+}
+
+// NewTCPCheck returns a HealthCheck that succeeds if a TCP connection to addr
+// can be made within timeout.
+func NewTCPCheck(addr string, timeout time.Duration) HealthCheck {
+	return HealthCheckFunc(func() error {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return fmt.Errorf("Failed to connect to %s: %s", addr, err)
+		}
+		util.Close(conn)
+		return nil
+	})
+}
+
+// NewHTTPCheck returns a HealthCheck that succeeds if a GET of url returns a
+// 2xx status code. If client is nil a client with a short timeout is used.
+func NewHTTPCheck(client *http.Client, url string) HealthCheck {
+	if client == nil {
+		client = httputils.NewTimeoutClient()
+	}
+	return HealthCheckFunc(func() error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return fmt.Errorf("Failed to GET %s: %s", url, err)
+		}
+		defer util.Close(resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("GET %s returned %s", url, resp.Status)
+		}
+		return nil
+	})
+}
+
+// NewCommandCheck returns a HealthCheck that succeeds if the command exits
+// with status zero within timeout. A zero timeout means no limit.
+func NewCommandCheck(timeout time.Duration, name string, args ...string) HealthCheck {
+	return HealthCheckFunc(func() error {
+		_, err := exec.RunCommand(&exec.Command{
+			Name:    name,
+			Args:    args,
+			Timeout: timeout,
+		})
+		return err
+	})
+}
+
+// Action is run by the Manager when the spare takes over from the master, and
+// when it hands back to the master. Both should be idempotent.
+type Action interface {
+	Takeover() error
+	Release() error
+}
+
+// ActionFuncs is an Action that calls funcs. Either func may be nil.
+type ActionFuncs struct {
+	TakeoverFunc func() error
+	ReleaseFunc  func() error
+}
+
+// Takeover implements Action.
+func (a ActionFuncs) Takeover() error {
+	if a.TakeoverFunc == nil {
+		return nil
+	}
+	return a.TakeoverFunc()
+}
+
+// Release implements Action.
+func (a ActionFuncs) Release() error {
+	if a.ReleaseFunc == nil {
+		return nil
+	}
+	return a.ReleaseFunc()
+}
+
+// NewCommandAction returns an Action that runs the takeover command line on
+// Takeover and the release command line on Release. The command lines are
+// split at spaces, see exec.ParseCommand. An empty command line does nothing.
+func NewCommandAction(takeover, release string) Action {
+	run := func(commandLine string) error {
+		if commandLine == "" {
+			return nil
+		}
+		out, err := exec.RunSimple(commandLine)
+		sklog.Infof("Output of %q: %s", commandLine, out)
+		return err
+	}
+	return ActionFuncs{
+		TakeoverFunc: func() error { return run(takeover) },
+		ReleaseFunc:  func() error { return run(release) },
+	}
+}
+
+// Actions is an Action that runs several Actions. Takeover runs them in order
+// and Release runs them in reverse order. Every Action is run even if an
+// earlier one fails, and the first error is returned.
+type Actions []Action
+
+// Takeover implements Action.
+func (a Actions) Takeover() error {
+	var firstErr error
+	for _, action := range a {
+		if err := action.Takeover(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Release implements Action.
+func (a Actions) Release() error {
+	var firstErr error
+	for i := len(a) - 1; i >= 0; i-- {
+		if err := a[i].Release(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Manager decides when the spare takes over from the master.
+type Manager struct {
+	name             string
+	check            HealthCheck
+	action           Action
+	failThreshold    int
+	recoverThreshold int
+
+	lease    Lease
+	holder   string
+	leaseTTL time.Duration
+
+	// lastRenew is when the lease was last acquired or renewed.
+	lastRenew time.Time
+
+	mutex                sync.Mutex
+	active               bool
+	consecutiveFailures  int
+	consecutiveSuccesses int
+}
+
+// New creates a new Manager. The name is used in log messages and as the
+// prefix of the metrics, e.g. "skolo.hotspare" reports
+// "skolo.hotspare.consecutive_failures" and "skolo.hotspare.active". The
+// thresholds are the number of failed checks in a row after which the spare
+// takes over, and the number of successful checks in a row after which it
+// releases again.
+func New(name string, check HealthCheck, action Action, failThreshold, recoverThreshold int) *Manager {
+	return &Manager{
+		name:             name,
+		check:            check,
+		action:           action,
+		failThreshold:    util.MaxInt(failThreshold, 1),
+		recoverThreshold: util.MaxInt(recoverThreshold, 1),
+	}
+}
+
+// SetLease makes the Manager acquire the lease as holder before it takes
+// over, and renew it while it's active. The ttl should be several times the
+// period of Run, so a spare that dies loses the lease but a live one doesn't.
+// If the lease is lost while active, or can't be renewed for the ttl, the
+// Manager releases.
+func (m *Manager) SetLease(lease Lease, holder string, ttl time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lease = lease
+	m.holder = holder
+	m.leaseTTL = ttl
+}
+
+// SetActive tells the Manager whether the spare has already taken over, e.g.
+// after the spare was restarted.
+func (m *Manager) SetActive(active bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.active = active
+}
+
+// Active returns true if the spare has taken over from the master.
+func (m *Manager) Active() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.active
+}
+
+// Step runs the health check once and takes over or releases as needed.
+func (m *Manager) Step() error {
+	checkErr := m.check.Check()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if checkErr != nil {
+		sklog.Errorf("%s: master is unhealthy: %s", m.name, checkErr)
+		m.consecutiveFailures++
+		m.consecutiveSuccesses = 0
+	} else {
+		m.consecutiveSuccesses++
+		m.consecutiveFailures = 0
+	}
+	var err error
+	if !m.active && m.consecutiveFailures >= m.failThreshold {
+		err = m.takeover()
+	} else if m.active && m.consecutiveSuccesses >= m.recoverThreshold {
+		err = m.release()
+	} else if m.active {
+		err = m.renew()
+	}
+	metrics2.GetInt64Metric(m.name+".consecutive_failures", nil).Update(int64(m.consecutiveFailures))
+	active := int64(0)
+	if m.active {
+		active = 1
+	}
+	metrics2.GetInt64Metric(m.name+".active", nil).Update(active)
+	return err
+}
+
+// takeover acquires the lease, if any, and runs the takeover action. The
+// caller must hold m.mutex.
+func (m *Manager) takeover() error {
+	if m.lease != nil {
+		ok, err := m.lease.Acquire(m.holder, m.leaseTTL)
+		if err != nil {
+			return fmt.Errorf("Failed to acquire lease: %s", err)
+		}
+		if !ok {
+			sklog.Infof("%s: master is down, but another spare holds the lease", m.name)
+			return nil
+		}
+		m.lastRenew = time.Now()
+	}
+	sklog.Infof("%s: taking over, master is down", m.name)
+	if err := m.action.Takeover(); err != nil {
+		// Give other spares a chance, and try again on the next Step.
+		m.releaseLease()
+		return fmt.Errorf("Failed to take over: %s", err)
+	}
+	m.active = true
+	return nil
+}
+
+// release runs the release action and gives up the lease, if any. The caller
+// must hold m.mutex.
+func (m *Manager) release() error {
+	sklog.Infof("%s: releasing, master is live", m.name)
+	if err := m.action.Release(); err != nil {
+		return fmt.Errorf("Failed to release: %s", err)
+	}
+	m.active = false
+	m.releaseLease()
+	return nil
+}
+
+// renew renews the lease, if any, releasing if it has been lost or has
+// expired. The caller must hold m.mutex.
+func (m *Manager) renew() error {
+	if m.lease == nil {
+		return nil
+	}
+	ok, err := m.lease.Acquire(m.holder, m.leaseTTL)
+	if err != nil {
+		if time.Since(m.lastRenew) < m.leaseTTL {
+			// Keep serving, the lease only expires after the ttl.
+			return fmt.Errorf("Failed to renew lease: %s", err)
+		}
+		// Another spare may hold the lease by now.
+		sklog.Errorf("%s: lease expired, failed to renew it since %s", m.name, m.lastRenew)
+		if releaseErr := m.action.Release(); releaseErr != nil {
+			return fmt.Errorf("Failed to release after the lease expired: %s", releaseErr)
+		}
+		m.active = false
+		return fmt.Errorf("Failed to renew lease: %s", err)
+	}
+	if ok {
+		m.lastRenew = time.Now()
+		return nil
+	}
+	sklog.Errorf("%s: lost the lease to another spare", m.name)
+	if err := m.action.Release(); err != nil {
+		return fmt.Errorf("Failed to release after losing the lease: %s", err)
+	}
+	m.active = false
+	return nil
+}
+
+// releaseLease gives up the lease, if any. The caller must hold m.mutex.
+func (m *Manager) releaseLease() {
+	if m.lease == nil {
+		return
+	}
+	if err := m.lease.Release(m.holder); err != nil {
+		sklog.Errorf("%s: failed to release lease: %s", m.name, err)
+	}
+}
+
+// Run calls Step every period, forever.
+func (m *Manager) Run(period time.Duration) {
+	for _ = range time.Tick(period) {
+		if err := m.Step(); err != nil {
+			sklog.Errorf("%s: %s", m.name, err)
+		}
+	}
+}
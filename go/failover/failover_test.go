@@ -0,0 +1,241 @@
+package failover
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+// fakeAction records the calls to Takeover and Release.
+type fakeAction struct {
+	calls []string
+	fail  bool
+}
+
+func (f *fakeAction) Takeover() error {
+	f.calls = append(f.calls, "takeover")
+	if f.fail {
+		return fmt.Errorf("takeover failed")
+	}
+	return nil
+}
+
+func (f *fakeAction) Release() error {
+	f.calls = append(f.calls, "release")
+	return nil
+}
+
+// fakeLease is a Lease held by at most one holder, which never expires.
+// Acquire fails while fail is true.
+type fakeLease struct {
+	holder string
+	fail   bool
+}
+
+func (f *fakeLease) Acquire(holder string, ttl time.Duration) (bool, error) {
+	if f.fail {
+		return false, fmt.Errorf("lease store is down")
+	}
+	if f.holder != "" && f.holder != holder {
+		return false, nil
+	}
+	f.holder = holder
+	return true, nil
+}
+
+func (f *fakeLease) Release(holder string) error {
+	if f.holder == holder {
+		f.holder = ""
+	}
+	return nil
+}
+
+// healthCheck returns a HealthCheck that fails while *down is true.
+func healthCheck(down *bool) HealthCheck {
+	return HealthCheckFunc(func() error {
+		if *down {
+			return fmt.Errorf("master is down")
+		}
+		return nil
+	})
+}
+
+func TestManagerHysteresis(t *testing.T) {
+	down := false
+	action := &fakeAction{}
+	m := New("test.failover", healthCheck(&down), action, 3, 2)
+
+	assert.NoError(t, m.Step())
+	down = true
+	assert.NoError(t, m.Step())
+	assert.NoError(t, m.Step())
+	assert.False(t, m.Active())
+	assert.Equal(t, 0, len(action.calls))
+
+	// A success resets the count of failures.
+	down = false
+	assert.NoError(t, m.Step())
+	down = true
+	assert.NoError(t, m.Step())
+	assert.NoError(t, m.Step())
+	assert.False(t, m.Active())
+	assert.NoError(t, m.Step())
+	assert.True(t, m.Active())
+	assert.Equal(t, []string{"takeover"}, action.calls)
+
+	// Takeover only happens once.
+	assert.NoError(t, m.Step())
+	assert.Equal(t, []string{"takeover"}, action.calls)
+
+	// It takes two successes in a row to release.
+	down = false
+	assert.NoError(t, m.Step())
+	down = true
+	assert.NoError(t, m.Step())
+	down = false
+	assert.NoError(t, m.Step())
+	assert.True(t, m.Active())
+	assert.NoError(t, m.Step())
+	assert.False(t, m.Active())
+	assert.Equal(t, []string{"takeover", "release"}, action.calls)
+}
+
+func TestManagerTakeoverFails(t *testing.T) {
+	down := true
+	action := &fakeAction{fail: true}
+	lease := &fakeLease{}
+	m := New("test.failover", healthCheck(&down), action, 1, 1)
+	m.SetLease(lease, "spare-1", time.Minute)
+
+	// The lease is given up again so another spare may try.
+	assert.Error(t, m.Step())
+	assert.False(t, m.Active())
+	assert.Equal(t, "", lease.holder)
+
+	action.fail = false
+	assert.NoError(t, m.Step())
+	assert.True(t, m.Active())
+	assert.Equal(t, "spare-1", lease.holder)
+	assert.Equal(t, []string{"takeover", "takeover"}, action.calls)
+}
+
+func TestManagerLease(t *testing.T) {
+	down := true
+	lease := &fakeLease{}
+	action1 := &fakeAction{}
+	action2 := &fakeAction{}
+	m1 := New("test.failover", healthCheck(&down), action1, 1, 1)
+	m1.SetLease(lease, "spare-1", time.Minute)
+	m2 := New("test.failover", healthCheck(&down), action2, 1, 1)
+	m2.SetLease(lease, "spare-2", time.Minute)
+
+	// Only one spare takes over.
+	assert.NoError(t, m1.Step())
+	assert.NoError(t, m2.Step())
+	assert.True(t, m1.Active())
+	assert.False(t, m2.Active())
+	assert.Equal(t, 0, len(action2.calls))
+
+	// A spare that loses its lease releases.
+	lease.holder = "spare-2"
+	assert.NoError(t, m1.Step())
+	assert.False(t, m1.Active())
+	assert.Equal(t, []string{"takeover", "release"}, action1.calls)
+	assert.NoError(t, m2.Step())
+	assert.True(t, m2.Active())
+
+	// Once the master is back the lease is released.
+	down = false
+	assert.NoError(t, m2.Step())
+	assert.False(t, m2.Active())
+	assert.Equal(t, "", lease.holder)
+}
+
+func TestManagerLeaseExpires(t *testing.T) {
+	down := true
+	lease := &fakeLease{}
+	action := &fakeAction{}
+	m := New("test.failover", healthCheck(&down), action, 1, 1)
+	m.SetLease(lease, "spare-1", 50*time.Millisecond)
+	assert.NoError(t, m.Step())
+	assert.True(t, m.Active())
+
+	// A failed renewal keeps serving until the ttl has passed.
+	lease.fail = true
+	assert.Error(t, m.Step())
+	assert.True(t, m.Active())
+	assert.Equal(t, []string{"takeover"}, action.calls)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Error(t, m.Step())
+	assert.False(t, m.Active())
+	assert.Equal(t, []string{"takeover", "release"}, action.calls)
+
+	// The spare takes over again once it can get the lease.
+	lease.fail = false
+	assert.NoError(t, m.Step())
+	assert.True(t, m.Active())
+}
+
+func TestSetActive(t *testing.T) {
+	down := false
+	action := &fakeAction{}
+	m := New("test.failover", healthCheck(&down), action, 1, 1)
+	m.SetActive(true)
+	assert.NoError(t, m.Step())
+	assert.False(t, m.Active())
+	assert.Equal(t, []string{"release"}, action.calls)
+}
+
+func TestActions(t *testing.T) {
+	calls := []string{}
+	action := func(name string, fail bool) Action {
+		return ActionFuncs{
+			TakeoverFunc: func() error {
+				calls = append(calls, "takeover "+name)
+				if fail {
+					return fmt.Errorf("%s failed", name)
+				}
+				return nil
+			},
+			ReleaseFunc: func() error {
+				calls = append(calls, "release "+name)
+				return nil
+			},
+		}
+	}
+	a := Actions{action("a", false), action("b", true), action("c", false)}
+	err := a.Takeover()
+	assert.Error(t, err)
+	assert.Equal(t, "b failed", err.Error())
+	assert.NoError(t, a.Release())
+	assert.Equal(t, []string{"takeover a", "takeover b", "takeover c", "release c", "release b", "release a"}, calls)
+
+	assert.NoError(t, ActionFuncs{}.Takeover())
+}
+
+func TestHealthChecks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	assert.NoError(t, NewHTTPCheck(nil, ts.URL+"/healthz").Check())
+	assert.Error(t, NewHTTPCheck(nil, ts.URL+"/missing").Check())
+
+	assert.NoError(t, NewTCPCheck(ts.Listener.Addr().String(), time.Second).Check())
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	assert.NoError(t, lis.Close())
+	assert.Error(t, NewTCPCheck(addr, time.Second).Check())
+
+	assert.NoError(t, NewCommandCheck(time.Minute, "true").Check())
+	assert.Error(t, NewCommandCheck(time.Minute, "false").Check())
+}
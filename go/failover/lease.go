@@ -0,0 +1,234 @@
+package failover
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.skia.org/infra/go/sharedb"
+	"go.skia.org/infra/go/util"
+	"golang.org/x/net/context"
+)
+
+const (
+	// STALE_LOCK is how old the lock file of a FileLease must be before it is
+	// assumed to have been left behind by a crashed process.
+	STALE_LOCK = time.Minute
+
+	// SHAREDB_TIMEOUT is the time limit of the requests a ShareDBLease makes.
+	SHAREDB_TIMEOUT = 10 * time.Second
+)
+
+// Lease makes sure that only one spare takes over at a time. Leases expire, so
+// a spare that dies doesn't keep the lease forever, which means the clocks of
+// the spares need to be roughly in sync.
+type Lease interface {
+	// Acquire acquires the lease for holder for ttl, or renews it if holder
+	// already holds it. Returns false if another holder holds the lease.
+	Acquire(holder string, ttl time.Duration) (bool, error)
+
+	// Release gives up the lease if holder holds it.
+	Release(holder string) error
+}
+
+// leaseRecord is the stored state of a lease.
+type leaseRecord struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// available returns true if holder may take the lease given its current
+// record, which is nil if nobody holds it.
+func (r *leaseRecord) available(holder string, now time.Time) bool {
+	return r == nil || r.Holder == holder || now.After(r.Expires)
+}
+
+// FileLease is a Lease stored in a file, typically on a file system shared by
+// all the spares, such as NFS. Changes to the file are serialized with a lock
+// file next to it.
+type FileLease struct {
+	filename string
+}
+
+// NewFileLease returns a new FileLease stored in filename.
+func NewFileLease(filename string) *FileLease {
+	return &FileLease{filename: filename}
+}
+
+// lock creates the lock file, returning a func that removes it again.
+func (l *FileLease) lock() (func(), error) {
+	lockName := l.filename + ".lock"
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(lockName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			util.Close(f)
+			return func() { util.Remove(lockName) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("Failed to create lock file: %s", err)
+		}
+		st, err := os.Stat(lockName)
+		if err == nil && time.Since(st.ModTime()) > STALE_LOCK {
+			util.Remove(lockName)
+			continue
+		}
+		break
+	}
+	return nil, fmt.Errorf("Lease %s is locked.", l.filename)
+}
+
+// read returns the current record, or nil if there is none.
+func (l *FileLease) read() (*leaseRecord, error) {
+	b, err := ioutil.ReadFile(l.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to read lease: %s", err)
+	}
+	r := &leaseRecord{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("Failed to decode lease: %s", err)
+	}
+	return r, nil
+}
+
+// Acquire implements Lease.
+func (l *FileLease) Acquire(holder string, ttl time.Duration) (bool, error) {
+	unlock, err := l.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	current, err := l.read()
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if !current.available(holder, now) {
+		return false, nil
+	}
+	b, err := json.Marshal(&leaseRecord{Holder: holder, Expires: now.Add(ttl)})
+	if err != nil {
+		return false, err
+	}
+	tmpName := filepath.Join(filepath.Dir(l.filename), "."+filepath.Base(l.filename)+".tmp")
+	if err := ioutil.WriteFile(tmpName, b, 0644); err != nil {
+		return false, fmt.Errorf("Failed to write lease: %s", err)
+	}
+	if err := os.Rename(tmpName, l.filename); err != nil {
+		return false, fmt.Errorf("Failed to write lease: %s", err)
+	}
+	return true, nil
+}
+
+// Release implements Lease.
+func (l *FileLease) Release(holder string) error {
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	current, err := l.read()
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Holder != holder {
+		return nil
+	}
+	if err := os.Remove(l.filename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to remove lease: %s", err)
+	}
+	return nil
+}
+
+// ShareDBLease is a Lease stored in a key of a sharedb server. Changes are
+// made with conditional transactions, so two spares can't both acquire it.
+type ShareDBLease struct {
+	client   *sharedb.ShareDB
+	database string
+	bucket   string
+	key      string
+}
+
+// NewShareDBLease returns a new ShareDBLease stored in the given key.
+func NewShareDBLease(client *sharedb.ShareDB, database, bucket, key string) *ShareDBLease {
+	return &ShareDBLease{
+		client:   client,
+		database: database,
+		bucket:   bucket,
+		key:      key,
+	}
+}
+
+// errNoLease is returned by ShareDBLease.read if the database doesn't exist.
+var errNoLease = errors.New("No lease database.")
+
+// read returns the current record and its raw value, or nil if there is none.
+func (l *ShareDBLease) read(ctx context.Context) (*leaseRecord, []byte, error) {
+	resp, err := l.client.Get(ctx, &sharedb.GetRequest{Database: l.database, Bucket: l.bucket, Key: l.key})
+	if err != nil {
+		if strings.Contains(err.Error(), "does not exist") {
+			return nil, nil, errNoLease
+		}
+		return nil, nil, fmt.Errorf("Failed to read lease: %s", err)
+	}
+	if len(resp.Value) == 0 {
+		return nil, nil, nil
+	}
+	r := &leaseRecord{}
+	if err := json.Unmarshal(resp.Value, r); err != nil {
+		return nil, nil, fmt.Errorf("Failed to decode lease: %s", err)
+	}
+	return r, resp.Value, nil
+}
+
+// Acquire implements Lease.
+func (l *ShareDBLease) Acquire(holder string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), SHAREDB_TIMEOUT)
+	defer cancel()
+	current, old, err := l.read(ctx)
+	if err == errNoLease {
+		// The database doesn't exist until the first lease is written.
+		current, old = nil, nil
+	} else if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if !current.available(holder, now) {
+		return false, nil
+	}
+	b, err := json.Marshal(&leaseRecord{Holder: holder, Expires: now.Add(ttl)})
+	if err != nil {
+		return false, err
+	}
+	// Fails if another spare changed the lease since we read it.
+	ok, err := l.client.CompareAndSwap(ctx, l.database, l.bucket, l.key, old, b)
+	if err != nil {
+		return false, fmt.Errorf("Failed to write lease: %s", err)
+	}
+	return ok, nil
+}
+
+// Release implements Lease.
+func (l *ShareDBLease) Release(holder string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), SHAREDB_TIMEOUT)
+	defer cancel()
+	current, old, err := l.read(ctx)
+	if err == errNoLease {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if current == nil || current.Holder != holder {
+		return nil
+	}
+	if _, err := l.client.NewTxn(l.database).IfEquals(l.bucket, l.key, old).Delete(l.bucket, l.key).Commit(ctx); err != nil {
+		return fmt.Errorf("Failed to remove lease: %s", err)
+	}
+	return nil
+}
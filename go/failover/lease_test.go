@@ -0,0 +1,91 @@
+package failover
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/sharedb"
+	"go.skia.org/infra/go/testutils"
+	"google.golang.org/grpc"
+)
+
+// testLease runs the tests common to all Lease implementations.
+func testLease(t *testing.T, lease Lease) {
+	// Releasing a lease nobody holds is fine.
+	assert.NoError(t, lease.Release("spare-1"))
+
+	ok, err := lease.Acquire("spare-1", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Renewing works, acquiring a held lease doesn't.
+	ok, err = lease.Acquire("spare-1", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = lease.Acquire("spare-2", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// Only the holder can release.
+	assert.NoError(t, lease.Release("spare-2"))
+	ok, err = lease.Acquire("spare-2", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, lease.Release("spare-1"))
+	ok, err = lease.Acquire("spare-2", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Expired leases can be taken over.
+	ok, err = lease.Acquire("spare-2", -time.Second)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = lease.Acquire("spare-1", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestFileLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "failover")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	filename := filepath.Join(dir, "lease.json")
+	testLease(t, NewFileLease(filename))
+
+	// A held lock blocks changes until it's stale.
+	lease := NewFileLease(filename)
+	assert.NoError(t, ioutil.WriteFile(filename+".lock", []byte{}, 0644))
+	_, err = lease.Acquire("spare-1", time.Minute)
+	assert.Error(t, err)
+	old := time.Now().Add(-2 * STALE_LOCK)
+	assert.NoError(t, os.Chtimes(filename+".lock", old, old))
+	ok, err := lease.Acquire("spare-1", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = os.Stat(filename + ".lock")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestShareDBLease(t *testing.T) {
+	testutils.SkipIfShort(t)
+	dir, err := ioutil.TempDir("", "failover")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	sharedb.RegisterShareDBServer(grpcServer, sharedb.NewServer(dir))
+	go func() { _ = grpcServer.Serve(lis) }()
+	defer grpcServer.Stop()
+	client, err := sharedb.New(lis.Addr().String())
+	assert.NoError(t, err)
+	defer testutils.CloseInTest(t, client)
+
+	testLease(t, NewShareDBLease(client, "failover", "leases", "master"))
+}
@@ -0,0 +1,223 @@
+// gerrit is a client for the REST API of the Gerrit code review system. It
+// only supports the read-only calls that are needed to track the results of
+// trybots, so anonymous access is enough.
+package gerrit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go.skia.org/infra/go/buildbucket"
+	"go.skia.org/infra/go/util"
+)
+
+const (
+	GERRIT_SKIA_URL = "https://skia-review.googlesource.com"
+
+	// TIME_FORMAT is the format of timestamps in Gerrit, which are in UTC.
+	TIME_FORMAT = "2006-01-02 15:04:05.000000000"
+
+	// XSSI_PREFIX is the first line of all JSON responses from Gerrit.
+	XSSI_PREFIX = ")]}'"
+
+	// Values of ChangeInfo.Status.
+	CHANGE_STATUS_NEW       = "NEW"
+	CHANGE_STATUS_MERGED    = "MERGED"
+	CHANGE_STATUS_ABANDONED = "ABANDONED"
+)
+
+// Owner is the owner of a change.
+type Owner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Revision is a patchset of a change.
+type Revision struct {
+	ID            string    `json:"-"`
+	Number        int64     `json:"_number"`
+	CreatedString string    `json:"created"`
+	Created       time.Time `json:"-"`
+}
+
+// ChangeInfo contains information about a Gerrit change, which is called an
+// issue elsewhere.
+type ChangeInfo struct {
+	Id              string               `json:"id"`
+	Project         string               `json:"project"`
+	Branch          string               `json:"branch"`
+	Subject         string               `json:"subject"`
+	Status          string               `json:"status"`
+	CreatedString   string               `json:"created"`
+	Created         time.Time            `json:"-"`
+	UpdatedString   string               `json:"updated"`
+	Updated         time.Time            `json:"-"`
+	Owner           *Owner               `json:"owner"`
+	Issue           int64                `json:"_number"`
+	Revisions       map[string]*Revision `json:"revisions"`
+	Patchsets       []*Revision          `json:"-"`
+	MoreChanges     bool                 `json:"_more_changes"`
+	CurrentRevision string               `json:"current_revision"`
+}
+
+// IsClosed returns true iff the change was merged or abandoned.
+func (c *ChangeInfo) IsClosed() bool {
+	return c.Status == CHANGE_STATUS_MERGED || c.Status == CHANGE_STATUS_ABANDONED
+}
+
+func parseTime(t string) time.Time {
+	parsed, _ := time.Parse(TIME_FORMAT, t)
+	return parsed
+}
+
+// revisionSlice sorts Revisions by patchset number.
+type revisionSlice []*Revision
+
+func (r revisionSlice) Len() int           { return len(r) }
+func (r revisionSlice) Less(i, j int) bool { return r[i].Number < r[j].Number }
+func (r revisionSlice) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// fixup parses the timestamps of the change and fills in Patchsets.
+func (c *ChangeInfo) fixup() {
+	c.Created = parseTime(c.CreatedString)
+	c.Updated = parseTime(c.UpdatedString)
+	c.Patchsets = make([]*Revision, 0, len(c.Revisions))
+	for id, rev := range c.Revisions {
+		rev.ID = id
+		rev.Created = parseTime(rev.CreatedString)
+		c.Patchsets = append(c.Patchsets, rev)
+	}
+	sort.Sort(revisionSlice(c.Patchsets))
+}
+
+// Gerrit is an object used for interacting with a Gerrit instance.
+type Gerrit struct {
+	client *http.Client
+	url    string
+}
+
+// New returns a new Gerrit instance. If client is nil, the default
+// http.Client will be used for anonymous access.
+func New(url string, client *http.Client) *Gerrit {
+	url = strings.TrimRight(url, "/")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Gerrit{
+		url:    url,
+		client: client,
+	}
+}
+
+// Url returns the URL of the server for this Gerrit instance.
+func (g *Gerrit) Url() string {
+	return g.url
+}
+
+// get fetches the given path and decodes the JSON response into rv after
+// stripping the XSSI prefix.
+func (g *Gerrit) get(suburl string, rv interface{}) error {
+	resp, err := g.client.Get(g.url + suburl)
+	if err != nil {
+		return fmt.Errorf("Failed to GET %s: %s", g.url+suburl, err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("Not a valid Issue %s", g.url+suburl)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Error retrieving %s: %d %s", g.url+suburl, resp.StatusCode, resp.Status)
+	}
+	r := bufio.NewReader(resp.Body)
+	prefix, err := r.ReadString('\n')
+	if err != nil || strings.TrimSpace(prefix) != XSSI_PREFIX {
+		return fmt.Errorf("Missing XSSI prefix in response from %s", g.url+suburl)
+	}
+	if err := json.NewDecoder(r).Decode(rv); err != nil {
+		return fmt.Errorf("Failed to decode JSON: %s", err)
+	}
+	return nil
+}
+
+// GetIssueProperties returns the details of the change with the given number,
+// including all of its patchsets.
+func (g *Gerrit) GetIssueProperties(issue int64) (*ChangeInfo, error) {
+	change := &ChangeInfo{}
+	if err := g.get(fmt.Sprintf("/changes/%d/detail?o=ALL_REVISIONS", issue), change); err != nil {
+		return nil, fmt.Errorf("Failed to load details for issue %d: %v", issue, err)
+	}
+	change.fixup()
+	return change, nil
+}
+
+// SearchTerm is a wrapper for search terms to pass into the Search method.
+type SearchTerm struct {
+	Key   string
+	Value string
+}
+
+// SearchOwner is a SearchTerm used for filtering by change owner.
+func SearchOwner(name string) *SearchTerm {
+	return &SearchTerm{
+		Key:   "owner",
+		Value: name,
+	}
+}
+
+// SearchModifiedAfter is a SearchTerm used for finding changes modified after
+// a particular time.Time.
+func SearchModifiedAfter(after time.Time) *SearchTerm {
+	return &SearchTerm{
+		Key:   "after",
+		Value: fmt.Sprintf("%q", after.UTC().Format("2006-01-02 15:04:05")),
+	}
+}
+
+// SearchOpen is a SearchTerm used for filtering changes by open/closed status.
+func SearchOpen(open bool) *SearchTerm {
+	value := "closed"
+	if open {
+		value = "open"
+	}
+	return &SearchTerm{
+		Key:   "status",
+		Value: value,
+	}
+}
+
+// Search returns up to limit changes which fit the given criteria, most
+// recently updated first. The changes don't include their patchsets, use
+// GetIssueProperties for those.
+func (g *Gerrit) Search(limit int, terms ...*SearchTerm) ([]*ChangeInfo, error) {
+	q := make([]string, 0, len(terms))
+	for _, term := range terms {
+		q = append(q, fmt.Sprintf("%s:%s", term.Key, term.Value))
+	}
+	ret := []*ChangeInfo{}
+	for {
+		searchUrl := fmt.Sprintf("/changes/?q=%s&n=%d&S=%d", url.QueryEscape(strings.Join(q, " ")), limit-len(ret), len(ret))
+		var data []*ChangeInfo
+		if err := g.get(searchUrl, &data); err != nil {
+			return nil, fmt.Errorf("Gerrit search failed: %v", err)
+		}
+		for _, change := range data {
+			change.fixup()
+		}
+		ret = append(ret, data...)
+		if len(data) == 0 || !data[len(data)-1].MoreChanges || len(ret) >= limit {
+			break
+		}
+	}
+	return ret, nil
+}
+
+// GetTrybotResults returns trybot results for the given change and patchset.
+func (g *Gerrit) GetTrybotResults(issueID int64, patchsetID int64) ([]*buildbucket.Build, error) {
+	return buildbucket.NewClient(g.client).GetTrybotsForGerritCL(g.url, issueID, patchsetID)
+}
@@ -0,0 +1,66 @@
+package gerrit
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/mockhttpclient"
+)
+
+const (
+	TEST_GERRIT_URL = "https://skia-review.googlesource.com"
+
+	CHANGE_DETAIL_URL = TEST_GERRIT_URL + "/changes/2345/detail?o=ALL_REVISIONS"
+)
+
+func readTestData(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	return b
+}
+
+func TestGetIssueProperties(t *testing.T) {
+	m := mockhttpclient.NewURLMock()
+	m.Mock(CHANGE_DETAIL_URL, mockhttpclient.MockGetDialogue(readTestData(t, "change_detail.txt")))
+	api := New(TEST_GERRIT_URL+"/", m.Client())
+	assert.Equal(t, TEST_GERRIT_URL, api.Url())
+
+	change, err := api.GetIssueProperties(2345)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2345), change.Issue)
+	assert.Equal(t, "Add a Gerrit test change", change.Subject)
+	assert.Equal(t, "jdoe@example.com", change.Owner.Email)
+	assert.False(t, change.IsClosed())
+	assert.Equal(t, time.Date(2016, 10, 4, 14, 12, 40, 0, time.UTC), change.Created)
+	assert.Equal(t, time.Date(2016, 10, 5, 9, 30, 12, 0, time.UTC), change.Updated)
+
+	// Patchsets are sorted by number.
+	assert.Equal(t, 2, len(change.Patchsets))
+	assert.Equal(t, int64(1), change.Patchsets[0].Number)
+	assert.Equal(t, "aaaa1111", change.Patchsets[0].ID)
+	assert.Equal(t, int64(2), change.Patchsets[1].Number)
+	assert.Equal(t, change.Updated, change.Patchsets[1].Created)
+
+	// Responses without the XSSI prefix are rejected.
+	m.Mock(CHANGE_DETAIL_URL, mockhttpclient.MockGetDialogue([]byte(`{"_number": 2345}`)))
+	_, err = api.GetIssueProperties(2345)
+	assert.Error(t, err)
+}
+
+func TestSearch(t *testing.T) {
+	after := time.Date(2016, 10, 5, 0, 0, 0, 0, time.UTC)
+	m := mockhttpclient.NewURLMock()
+	m.Mock(TEST_GERRIT_URL+"/changes/?q=after%3A%222016-10-05+00%3A00%3A00%22+status%3Aopen&n=10&S=0", mockhttpclient.MockGetDialogue(readTestData(t, "search.txt")))
+	api := New(TEST_GERRIT_URL, m.Client())
+
+	changes, err := api.Search(10, SearchModifiedAfter(after), SearchOpen(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(changes))
+	assert.Equal(t, int64(2345), changes[0].Issue)
+	assert.Equal(t, int64(2301), changes[1].Issue)
+	assert.True(t, changes[1].IsClosed())
+	assert.True(t, changes[1].Updated.After(after))
+}
@@ -2,6 +2,7 @@ package ingestion
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/skia-dev/glog"
@@ -23,6 +25,7 @@ import (
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/go/vcsinfo"
 	gc_event "go.skia.org/infra/grandcentral/go/event"
+	"go.skia.org/infra/grandcentral/go/eventlog"
 	"golang.org/x/net/context"
 	"google.golang.org/api/option"
 )
@@ -30,6 +33,9 @@ import (
 // Limit the number of times the ingester tries to get a file before giving up.
 const MAX_URI_GET_TRIES = 4
 
+// How often the event log of grandcentral is checked for new storage events.
+const REPLAY_PERIOD = 15 * time.Second
+
 // Constructor is the signature that has to be implemented to register a
 // Processor implementation to be instantiated by name from a config struct.
 //   vcs is an instance that might be shared across multiple ingesters.
@@ -84,7 +90,7 @@ func IngestersFromConfig(config *sharedconfig.Config, client *http.Client, evt *
 		// Instantiate the sources
 		sources := make([]Source, 0, len(ingesterConf.Sources))
 		for _, dataSource := range ingesterConf.Sources {
-			oneSource, err := getSource(id, dataSource, client, evt)
+			oneSource, err := getSource(id, dataSource, client, evt, config.GrandCentralURL, ingesterConf.StatusDir)
 			if err != nil {
 				return nil, fmt.Errorf("Error instantiating sources for ingester '%s': %s", id, err)
 			}
@@ -109,14 +115,28 @@ func IngestersFromConfig(config *sharedconfig.Config, client *http.Client, evt *
 }
 
 // getSource returns an instance of source that is either getting data from
-// Google storage or the local fileystem.
-func getSource(id string, dataSource *sharedconfig.DataSource, client *http.Client, evt *eventbus.EventBus) (Source, error) {
+// Google storage or the local fileystem. If grandCentralURL is not empty then
+// Google storage sources replay their events from it and keep their position
+// in statusDir.
+func getSource(id string, dataSource *sharedconfig.DataSource, client *http.Client, evt *eventbus.EventBus, grandCentralURL, statusDir string) (Source, error) {
 	if dataSource.Dir == "" {
 		return nil, fmt.Errorf("Datasource for %s is missing a directory.", id)
 	}
 
 	if dataSource.Bucket != "" {
-		return NewGoogleStorageSource(id, dataSource.Bucket, dataSource.Dir, client, evt)
+		src, err := NewGoogleStorageSource(id, dataSource.Bucket, dataSource.Dir, client, evt)
+		if err != nil || grandCentralURL == "" {
+			return src, err
+		}
+		dir, err := fileutil.EnsureDirExists(filepath.Join(statusDir, id))
+		if err != nil {
+			return nil, err
+		}
+		seqFile := filepath.Join(dir, fmt.Sprintf("events-%x", md5.Sum([]byte(dataSource.Bucket+"/"+dataSource.Dir))))
+		if err := src.(*GoogleStorageSource).ReplayFrom(grandCentralURL, seqFile); err != nil {
+			return nil, err
+		}
+		return src, nil
 	}
 	return NewFileSystemSource(id, dataSource.Dir)
 }
@@ -134,6 +154,7 @@ type GoogleStorageSource struct {
 	storageClient *storage.Client
 	client        *http.Client
 	evt           *eventbus.EventBus
+	follower      *eventlog.Follower
 }
 
 // NewGoogleStorageSource returns a new instance of GoogleStorageSource based
@@ -178,9 +199,46 @@ func (g *GoogleStorageSource) Poll(startTime, endTime int64) ([]ResultFileLocati
 	return retval, nil
 }
 
+// ReplayFrom makes the source read its events from the event log of the
+// grandcentral server at serverURL instead of the event bus. Since the position
+// in the log is kept in seqFile, the events published while the ingester was
+// down are not lost.
+func (g *GoogleStorageSource) ReplayFrom(serverURL, seqFile string) error {
+	follower, err := eventlog.NewFollower(g.client, serverURL, gc_event.GLOBAL_GOOGLE_STORAGE, seqFile)
+	if err != nil {
+		return fmt.Errorf("Failed to follow the event log of %s: %s", serverURL, err)
+	}
+	g.follower = follower
+	return nil
+}
+
 // See Source interface.
 func (g *GoogleStorageSource) EventChan() <-chan []ResultFileLocation {
 	ch := make(chan []ResultFileLocation)
+	if g.follower != nil {
+		go g.follower.Run(REPLAY_PERIOD, func(e *eventlog.Entry) error {
+			storageEv := &gc_event.GoogleStorageEventData{}
+			if err := e.Decode(storageEv); err != nil {
+				glog.Errorf("Invalid storage event %d: %s", e.Seq, err)
+				return nil
+			}
+			if storageEv.Bucket != g.bucket || !strings.HasPrefix(storageEv.Name, g.rootDir) || !validIngestionFile(storageEv.Name) {
+				return nil
+			}
+			result, err := g.storageClient.Bucket(storageEv.Bucket).Object(storageEv.Name).Attrs(context.Background())
+			if err == storage.ErrObjectNotExist {
+				glog.Warningf("Skipping event %d, %s/%s no longer exists.", e.Seq, storageEv.Bucket, storageEv.Name)
+				return nil
+			} else if err != nil {
+				// The event is retried in the next step.
+				return fmt.Errorf("Error retrieving obj attributes for %s/%s: %s", storageEv.Bucket, storageEv.Name, err)
+			}
+			ch <- []ResultFileLocation{newGSResultFileLocation(result, g.rootDir, g.storageClient)}
+			return nil
+		})
+		return ch
+	}
+
 	g.evt.SubscribeAsync(gc_event.StorageEvent(g.bucket, g.rootDir), func(eventData interface{}) {
 		storageEv := eventData.(*gc_event.GoogleStorageEventData)
 		if validIngestionFile(storageEv.Name) {
@@ -40,6 +40,18 @@ var (
 	isolatedHashRegexp        = regexp.MustCompile(isolatedHashRegexpPattern)
 )
 
+// LocalStore stores isolated inputs on the local machine instead of an
+// isolate server, eg. swarming.LocalClient.
+type LocalStore interface {
+	// StoreIsolated stores the contents of dir and returns its hash. The
+	// command runs in the relativeCwd subdirectory.
+	StoreIsolated(dir string, command []string, relativeCwd string) (string, error)
+
+	// IsolatedPath returns the directory holding the files of the given
+	// isolate.
+	IsolatedPath(hash string) string
+}
+
 // Client is a Skia-specific wrapper around the Isolate executable.
 type Client struct {
 	gs            *gs.DownloadHelper
@@ -47,6 +59,11 @@ type Client struct {
 	isolateserver string
 	ServerUrl     string
 	workdir       string
+
+	// LocalStore, if set, receives the isolated inputs created by
+	// IsolateTasks instead of the isolate server, and the Deps of the tasks
+	// are read from it. ServerUrl should be FAKE_SERVER_URL in that case.
+	LocalStore LocalStore
 }
 
 // NewClient returns a Client instance.
@@ -310,6 +327,9 @@ func (c *Client) IsolateTasks(tasks []*Task) ([]string, error) {
 	if err := c.BatchArchiveTasks(genJsonFiles, ""); err != nil {
 		return nil, err
 	}
+	if c.LocalStore != nil {
+		return c.storeLocally(tasks, isolatedFiles)
+	}
 
 	// Rewrite the isolated files with any extra dependencies.
 	for i, f := range isolatedFiles {
@@ -357,3 +377,115 @@ func (c *Client) IsolateTasks(tasks []*Task) ([]string, error) {
 	}
 	return rv, nil
 }
+
+// storeLocally copies the files listed in the given isolated files, one for
+// each task, and the files of the tasks' Deps into c.LocalStore and returns
+// the resulting hashes.
+func (c *Client) storeLocally(tasks []*Task, isolatedFiles []string) ([]string, error) {
+	rv := make([]string, 0, len(tasks))
+	for i, f := range isolatedFiles {
+		hash, err := c.storeOneLocally(tasks[i], f)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, hash)
+	}
+	return rv, nil
+}
+
+// storeOneLocally copies the files of a single task into c.LocalStore.
+func (c *Client) storeOneLocally(t *Task, isolatedFile string) (string, error) {
+	isolated, err := readIsolatedFile(isolatedFile)
+	if err != nil {
+		return "", err
+	}
+	if len(isolated.Includes) > 0 {
+		return "", fmt.Errorf("Local isolate stores don't support includes: %v", isolated.Includes)
+	}
+
+	// The files are relative to the root directory, which contains the
+	// directory of the isolate file at RelativeCwd.
+	isolateFile, err := filepath.Abs(t.IsolateFile)
+	if err != nil {
+		return "", err
+	}
+	rootDir := filepath.Dir(isolateFile)
+	if relCwd := filepath.Clean(filepath.FromSlash(isolated.RelativeCwd)); relCwd != "." {
+		if !strings.HasSuffix(rootDir, string(filepath.Separator)+relCwd) {
+			return "", fmt.Errorf("%s is not in relative_cwd %q of %s", isolateFile, isolated.RelativeCwd, isolatedFile)
+		}
+		rootDir = strings.TrimSuffix(rootDir, string(filepath.Separator)+relCwd)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "isolate_local")
+	if err != nil {
+		return "", fmt.Errorf("Failed to create temporary dir: %s", err)
+	}
+	defer util.RemoveAll(tmpDir)
+	for _, dep := range t.Deps {
+		if err := copyDir(c.LocalStore.IsolatedPath(dep), tmpDir); err != nil {
+			return "", fmt.Errorf("Failed to copy isolated dependency %s: %s", dep, err)
+		}
+	}
+	for relPath, props := range isolated.Files {
+		dest := filepath.Join(tmpDir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return "", err
+		}
+		if m, ok := props.(map[string]interface{}); ok {
+			if link, ok := m["l"].(string); ok {
+				if err := os.Symlink(link, dest); err != nil {
+					return "", err
+				}
+				continue
+			}
+		}
+		if err := copyFile(filepath.Join(rootDir, filepath.FromSlash(relPath)), dest); err != nil {
+			return "", err
+		}
+	}
+	return c.LocalStore.StoreIsolated(tmpDir, isolated.Command, isolated.RelativeCwd)
+}
+
+// copyDir copies the files and directories in src into dest.
+func copyDir(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies the regular file src to dest, keeping its permissions.
+func copyFile(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer util.Close(in)
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		util.Close(out)
+		return err
+	}
+	return out.Close()
+}
@@ -33,7 +33,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/securecookie"
@@ -55,6 +58,10 @@ const (
 
 	// DEFAULT_ADMIN_WHITELIST is the white list of users we consider admins when we can't retrieve the whitelist from metadata.
 	DEFAULT_ADMIN_WHITELIST = "benjaminwagner@google.com borenet@google.com jcgregorio@google.com kjlubick@google.com rmistry@google.com stephana@google.com"
+
+	// BEARER_PREFIX is the prefix of the value of the Authorization header
+	// for requests that are authenticated via an OAuth 2.0 access token.
+	BEARER_PREFIX = "Bearer "
 )
 
 var (
@@ -85,6 +92,21 @@ var (
 	activeAdminEmailWhiteList map[string]bool
 
 	DEFAULT_SCOPE = []string{"email"}
+
+	// tokenInfoURL is the endpoint used to validate bearer tokens. It is a
+	// variable so it can be changed for testing.
+	tokenInfoURL = "https://www.googleapis.com/oauth2/v3/tokeninfo"
+
+	// bearerClientIDs contains the OAuth 2.0 client ids whose access tokens
+	// are accepted as bearer tokens. If empty, bearer tokens are rejected.
+	bearerClientIDs map[string]bool
+
+	// bearerSessions caches the sessions of validated bearer tokens keyed by
+	// the access token.
+	bearerSessions = map[string]*bearerSession{}
+
+	// bearerMutex protects bearerClientIDs and bearerSessions.
+	bearerMutex sync.Mutex
 )
 
 // Session is encrypted and serialized and stored in a user's cookie.
@@ -161,6 +183,10 @@ func LoginURL(w http.ResponseWriter, r *http.Request) string {
 }
 
 func getSession(r *http.Request) (*Session, error) {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, BEARER_PREFIX) {
+		return getBearerSession(strings.TrimPrefix(auth, BEARER_PREFIX))
+	}
+
 	cookie, err := r.Cookie(COOKIE_NAME)
 	if err != nil {
 		return nil, err
@@ -345,14 +371,8 @@ func OAuth2CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	email := strings.ToLower(decoded.Email)
-	parts := strings.Split(email, "@")
-	if len(parts) != 2 {
-		http.Error(w, "Invalid email address received.", 500)
-		return
-	}
-
-	if len(activeUserDomainWhiteList) > 0 && !activeUserDomainWhiteList[parts[1]] && !activeUserEmailWhiteList[email] {
-		http.Error(w, "Accounts from your domain are not allowed or your email address is not white listed.", 500)
+	if err := checkWhiteList(email); err != nil {
+		http.Error(w, err.Error(), 500)
 		return
 	}
 	s := Session{
@@ -429,6 +449,128 @@ func ForceAuth(h http.Handler, oauthCallbackPath string) http.Handler {
 	})
 }
 
+// checkWhiteList returns an error if the given email address is not allowed to
+// log in.
+func checkWhiteList(email string) error {
+	parts := strings.Split(email, "@")
+	if len(parts) != 2 {
+		return fmt.Errorf("Invalid email address received.")
+	}
+
+	if len(activeUserDomainWhiteList) > 0 && !activeUserDomainWhiteList[parts[1]] && !activeUserEmailWhiteList[email] {
+		return fmt.Errorf("Accounts from your domain are not allowed or your email address is not white listed.")
+	}
+	return nil
+}
+
+// EnableBearerTokens allows requests to be authenticated via an
+// "Authorization: Bearer <access token>" header instead of a cookie. This
+// allows command line tools to act on behalf of a user. Only access tokens
+// that were issued to one of the given OAuth 2.0 client ids and that include
+// the 'email' scope are accepted. The same white lists apply as for logins via
+// the browser.
+func EnableBearerTokens(clientIDs []string) {
+	bearerMutex.Lock()
+	defer bearerMutex.Unlock()
+	bearerClientIDs = make(map[string]bool, len(clientIDs))
+	for _, id := range clientIDs {
+		bearerClientIDs[id] = true
+	}
+}
+
+// bearerSession is a session derived from a bearer token.
+type bearerSession struct {
+	session *Session
+	expires time.Time
+}
+
+// tokenInfo is the response of the token info endpoint.
+type tokenInfo struct {
+	Audience      string `json:"aud"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"email_verified"`
+	ExpiresIn     string `json:"expires_in"`
+	ID            string `json:"sub"`
+}
+
+// getBearerSession returns the session for the given access token. Tokens are
+// validated via the token info endpoint and the result is cached until the
+// token expires.
+func getBearerSession(accessToken string) (*Session, error) {
+	now := time.Now()
+	bearerMutex.Lock()
+	enabled := len(bearerClientIDs) > 0
+	bs, ok := bearerSessions[accessToken]
+	bearerMutex.Unlock()
+
+	if !enabled {
+		return nil, fmt.Errorf("Bearer tokens are not accepted.")
+	}
+	if ok && now.Before(bs.expires) {
+		return bs.session, nil
+	}
+
+	info, err := fetchTokenInfo(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	email := strings.ToLower(info.Email)
+	if err := checkWhiteList(email); err != nil {
+		return nil, err
+	}
+	expiresIn, err := strconv.ParseInt(info.ExpiresIn, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid expiration of bearer token: %s", err)
+	}
+
+	bearerMutex.Lock()
+	defer bearerMutex.Unlock()
+	if !bearerClientIDs[info.Audience] {
+		return nil, fmt.Errorf("Bearer token was issued to unknown client: %s", info.Audience)
+	}
+
+	// Remove expired sessions so the cache does not grow indefinitely.
+	for token, bs := range bearerSessions {
+		if !now.Before(bs.expires) {
+			delete(bearerSessions, token)
+		}
+	}
+
+	s := &Session{
+		Email:     email,
+		ID:        info.ID,
+		AuthScope: strings.Join(oauthConfig.Scopes, " "),
+		Token:     &oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"},
+	}
+	bearerSessions[accessToken] = &bearerSession{
+		session: s,
+		expires: now.Add(time.Duration(expiresIn) * time.Second),
+	}
+	return s, nil
+}
+
+// fetchTokenInfo retrieves the information about the given access token from
+// the token info endpoint.
+func fetchTokenInfo(accessToken string) (*tokenInfo, error) {
+	resp, err := httputils.NewTimeoutClient().Get(tokenInfoURL + "?access_token=" + url.QueryEscape(accessToken))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to validate bearer token: %s", err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Invalid bearer token. Got status: %s", resp.Status)
+	}
+
+	info := &tokenInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return nil, fmt.Errorf("Failed to decode token info: %s", err)
+	}
+	if info.EmailVerified != "true" {
+		return nil, fmt.Errorf("Bearer token does not contain a verified email address.")
+	}
+	return info, nil
+}
+
 func splitAuthWhiteList(whiteList string) (map[string]bool, map[string]bool) {
 	domains := map[string]bool{}
 	emails := map[string]bool{}
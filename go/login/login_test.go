@@ -60,3 +60,53 @@ func TestLoggedInAs(t *testing.T) {
 	r.AddCookie(cookie)
 	assert.Equal(t, LoggedInAs(r), "fred@example.com", "Correctly get logged in email.")
 }
+
+func TestBearerToken(t *testing.T) {
+	once.Do(loginInit)
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.FormValue("access_token") {
+		case "good-token":
+			_, _ = w.Write([]byte(`{"aud": "cli-client-id", "email": "Fred@Google.com", "email_verified": "true", "expires_in": "3600", "sub": "12345"}`))
+		case "other-client-token":
+			_, _ = w.Write([]byte(`{"aud": "other-client-id", "email": "fred@google.com", "email_verified": "true", "expires_in": "3600", "sub": "12345"}`))
+		case "other-domain-token":
+			_, _ = w.Write([]byte(`{"aud": "cli-client-id", "email": "fred@example.com", "email_verified": "true", "expires_in": "3600", "sub": "12345"}`))
+		default:
+			http.Error(w, "Invalid token", http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+	tokenInfoURL = ts.URL
+
+	requestWithToken := func(token string) *http.Request {
+		r, err := http.NewRequest("GET", "http://example.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", BEARER_PREFIX+token)
+		return r
+	}
+
+	// Bearer tokens are rejected unless they are enabled.
+	assert.Equal(t, "", LoggedInAs(requestWithToken("good-token")))
+	assert.Equal(t, 0, requests)
+
+	EnableBearerTokens([]string{"cli-client-id"})
+	defer EnableBearerTokens(nil)
+
+	email, id := UserIdentifiers(requestWithToken("good-token"))
+	assert.Equal(t, "fred@google.com", email)
+	assert.Equal(t, "12345", id)
+	assert.Equal(t, 1, requests)
+
+	// Validated tokens are cached.
+	assert.Equal(t, "fred@google.com", LoggedInAs(requestWithToken("good-token")))
+	assert.Equal(t, 1, requests)
+
+	assert.Equal(t, "", LoggedInAs(requestWithToken("other-client-token")))
+	assert.Equal(t, "", LoggedInAs(requestWithToken("other-domain-token")))
+	assert.Equal(t, "", LoggedInAs(requestWithToken("bad-token")))
+}
@@ -0,0 +1,167 @@
+package packages
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/gs"
+	"go.skia.org/infra/go/util"
+	"google.golang.org/api/storage/v1"
+)
+
+// GCSStore is a PackageStore in a Google Storage bucket, laid out as:
+//
+//    gs://{bucket}/debs/{appname}/{uniquely named Debian package}.deb
+//    gs://{bucket}/server/{servername}.json
+//
+// The package metadata is kept in the metadata of the .deb objects.
+type GCSStore struct {
+	client     *http.Client
+	store      *storage.Service
+	bucketName string
+}
+
+// NewGCSStore returns a new GCSStore for the given bucket. The client must be
+// authorized to read and write the bucket.
+func NewGCSStore(client *http.Client, store *storage.Service, bucketName string) *GCSStore {
+	return &GCSStore{
+		client:     client,
+		store:      store,
+		bucketName: bucketName,
+	}
+}
+
+// listPackages returns the packages under the given prefix, with the prefix
+// stripped from their names, keyed by application name.
+func (g *GCSStore) listPackages(prefix string) (map[string][]*Package, error) {
+	req := g.store.Objects.List(g.bucketName).Prefix(prefix)
+	ret := map[string][]*Package{}
+	for {
+		objs, err := req.Do()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list debian packages in Google Storage: %s", err)
+		}
+		for _, o := range objs.Items {
+			p := packageFromMetadata(o.Name[len(prefix):], o.Metadata)
+			if p == nil {
+				glog.Errorf("Debian package without proper metadata: %s", o.Name)
+				continue
+			}
+			key := o.Metadata["appname"]
+			ret[key] = append(ret[key], p)
+		}
+		if objs.NextPageToken == "" {
+			break
+		}
+		req.PageToken(objs.NextPageToken)
+	}
+	for _, value := range ret {
+		sort.Sort(PackageSlice(value))
+	}
+	return ret, nil
+}
+
+// AllAvailable implements PackageStore.
+func (g *GCSStore) AllAvailable() (map[string][]*Package, error) {
+	return g.listPackages("debs/")
+}
+
+// AllAvailableApp implements PackageStore.
+func (g *GCSStore) AllAvailableApp(appName string) ([]*Package, error) {
+	all, err := g.listPackages(fmt.Sprintf("debs/%s/", appName))
+	if err != nil {
+		return nil, err
+	}
+	ret := []*Package{}
+	for _, ps := range all {
+		ret = append(ret, ps...)
+	}
+	sort.Sort(PackageSlice(ret))
+	return ret, nil
+}
+
+// InstalledForServer implements PackageStore.
+func (g *GCSStore) InstalledForServer(serverName string) (*Installed, error) {
+	ret := &Installed{
+		Names:      []string{},
+		Generation: -1,
+	}
+
+	filename := "server/" + serverName + ".json"
+	obj, err := g.store.Objects.Get(g.bucketName, filename).Do()
+	if err != nil {
+		return ret, fmt.Errorf("Failed to retrieve Google Storage metadata about packages file %q: %s", filename, err)
+	}
+
+	glog.Infof("Fetching: %s", obj.MediaLink)
+	resp, err := g.get(obj.MediaLink)
+	if err != nil {
+		return ret, fmt.Errorf("Failed to retrieve packages file: %s", err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != 200 {
+		return ret, fmt.Errorf("Wrong status code: %#v", *resp)
+	}
+	dec := json.NewDecoder(resp.Body)
+
+	value := []string{}
+	if err := dec.Decode(&value); err != nil {
+		return ret, fmt.Errorf("Failed to decode packages file: %s", err)
+	}
+	sort.Strings(value)
+	ret.Names = value
+	ret.Generation = obj.Generation
+
+	return ret, nil
+}
+
+// PutInstalled implements PackageStore.
+func (g *GCSStore) PutInstalled(serverName string, packages []string, generation int64) error {
+	b, err := json.Marshal(packages)
+	if err != nil {
+		return fmt.Errorf("Failed to encode installed packages: %s", err)
+	}
+	buf := bytes.NewBuffer(b)
+	req := g.store.Objects.Insert(g.bucketName, &storage.Object{Name: "server/" + serverName + ".json"}).Media(buf)
+	if generation != -1 {
+		req = req.IfGenerationMatch(generation)
+	}
+	if _, err = req.Do(); err != nil {
+		return fmt.Errorf("Failed to write installed packages list to Google Storage for %s: %s", serverName, err)
+	}
+	return nil
+}
+
+// Download implements PackageStore.
+func (g *GCSStore) Download(name string, w io.Writer) error {
+	obj, err := g.store.Objects.Get(g.bucketName, "debs/"+name).Do()
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve Google Storage metadata about debian package: %s", err)
+	}
+	resp, err := g.get(obj.MediaLink)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve packages file: %s", err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Wrong status code: %#v", *resp)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("Failed to download file: %s", err)
+	}
+	return nil
+}
+
+// get retrieves the media at the given Google Storage media link.
+func (g *GCSStore) get(mediaLink string) (*http.Response, error) {
+	req, err := gs.RequestForStorageURL(mediaLink)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to construct request object for media: %s", err)
+	}
+	return g.client.Do(req)
+}
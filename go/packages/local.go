@@ -0,0 +1,200 @@
+package packages
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/util"
+)
+
+// METADATA_SUFFIX is appended to the name of a package file in a LocalStore
+// to get the name of the file that holds its metadata.
+const METADATA_SUFFIX = ".json"
+
+// LocalStore is a PackageStore in a local directory, laid out the same way as
+// the Google Storage bucket:
+//
+//    {dir}/debs/{appname}/{uniquely named Debian package}.deb
+//    {dir}/debs/{appname}/{uniquely named Debian package}.deb.json
+//    {dir}/server/{servername}.json
+//
+// The .deb.json file holds the package metadata as a JSON object of strings,
+// for example:
+//
+//    {"appname": "pulld", "hash": "7e0ff60...", "services": "pulld.service"}
+//
+// The generation of a server's list is the modification time of its file in
+// nanoseconds. The directory can be shared, e.g. over NFS, by the push server
+// and all the pulld instances.
+type LocalStore struct {
+	dir string
+
+	// mutex serializes the read-modify-write of PutInstalled.
+	mutex sync.Mutex
+}
+
+// NewLocalStore returns a new LocalStore in the given directory, creating the
+// directory structure if needed.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	for _, d := range []string{"debs", "server"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			return nil, fmt.Errorf("Failed to create package store dir: %s", err)
+		}
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// AllAvailable implements PackageStore.
+func (l *LocalStore) AllAvailable() (map[string][]*Package, error) {
+	apps, err := ioutil.ReadDir(filepath.Join(l.dir, "debs"))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list debian packages: %s", err)
+	}
+	ret := map[string][]*Package{}
+	for _, fi := range apps {
+		if !fi.IsDir() {
+			continue
+		}
+		ps, err := l.AllAvailableApp(fi.Name())
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			p.Name = fi.Name() + "/" + p.Name
+		}
+		if len(ps) > 0 {
+			ret[fi.Name()] = ps
+		}
+	}
+	return ret, nil
+}
+
+// AllAvailableApp implements PackageStore.
+func (l *LocalStore) AllAvailableApp(appName string) ([]*Package, error) {
+	dir := filepath.Join(l.dir, "debs", appName)
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Failed to list debian packages: %s", err)
+	}
+	ret := []*Package{}
+	for _, fi := range fileInfos {
+		if fi.IsDir() || strings.HasSuffix(fi.Name(), METADATA_SUFFIX) {
+			continue
+		}
+		metadata := map[string]string{}
+		b, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()+METADATA_SUFFIX))
+		if err == nil {
+			err = json.Unmarshal(b, &metadata)
+		}
+		if err != nil {
+			glog.Errorf("Failed to read the metadata of %s: %s", fi.Name(), err)
+			continue
+		}
+		p := packageFromMetadata(fi.Name(), metadata)
+		if p == nil {
+			glog.Errorf("Debian package without proper metadata: %s", fi.Name())
+			continue
+		}
+		ret = append(ret, p)
+	}
+	sort.Sort(PackageSlice(ret))
+	return ret, nil
+}
+
+func (l *LocalStore) serverFile(serverName string) string {
+	return filepath.Join(l.dir, "server", serverName+".json")
+}
+
+// InstalledForServer implements PackageStore.
+func (l *LocalStore) InstalledForServer(serverName string) (*Installed, error) {
+	ret := &Installed{
+		Names:      []string{},
+		Generation: -1,
+	}
+	f, err := os.Open(l.serverFile(serverName))
+	if err != nil {
+		return ret, fmt.Errorf("Failed to open packages file for %s: %s", serverName, err)
+	}
+	defer util.Close(f)
+	fi, err := f.Stat()
+	if err != nil {
+		return ret, fmt.Errorf("Failed to stat packages file for %s: %s", serverName, err)
+	}
+	value := []string{}
+	if err := json.NewDecoder(f).Decode(&value); err != nil {
+		return ret, fmt.Errorf("Failed to decode packages file: %s", err)
+	}
+	sort.Strings(value)
+	ret.Names = value
+	ret.Generation = fi.ModTime().UnixNano()
+	return ret, nil
+}
+
+// PutInstalled implements PackageStore.
+func (l *LocalStore) PutInstalled(serverName string, packages []string, generation int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	filename := l.serverFile(serverName)
+	current := int64(0)
+	if fi, err := os.Stat(filename); err == nil {
+		current = fi.ModTime().UnixNano()
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to stat packages file for %s: %s", serverName, err)
+	}
+	if generation != -1 && current != generation {
+		return fmt.Errorf("Failed to write installed packages list for %s: generation %d doesn't match %d", serverName, generation, current)
+	}
+	b, err := json.Marshal(packages)
+	if err != nil {
+		return fmt.Errorf("Failed to encode installed packages: %s", err)
+	}
+	// Write to a temp file and rename it, so readers never see a partial list.
+	f, err := ioutil.TempFile(filepath.Dir(filename), serverName)
+	if err != nil {
+		return fmt.Errorf("Failed to create tmp file: %s", err)
+	}
+	_, writeErr := f.Write(b)
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Failed to close tmp file: %s", err)
+	}
+	if writeErr != nil {
+		return fmt.Errorf("Failed to write installed packages list for %s: %s", serverName, writeErr)
+	}
+	// File times have a coarse resolution, make sure the generation changes.
+	fi, err := os.Stat(f.Name())
+	if err != nil {
+		return fmt.Errorf("Failed to stat tmp file: %s", err)
+	}
+	if fi.ModTime().UnixNano() <= current {
+		t := time.Unix(0, current+1)
+		if err := os.Chtimes(f.Name(), t, t); err != nil {
+			return fmt.Errorf("Failed to set the generation of the installed packages list: %s", err)
+		}
+	}
+	if err := os.Rename(f.Name(), filename); err != nil {
+		return fmt.Errorf("Failed to write installed packages list for %s: %s", serverName, err)
+	}
+	return nil
+}
+
+// Download implements PackageStore.
+func (l *LocalStore) Download(name string, w io.Writer) error {
+	f, err := os.Open(filepath.Join(l.dir, "debs", filepath.FromSlash(name)))
+	if err != nil {
+		return fmt.Errorf("Failed to open debian package: %s", err)
+	}
+	defer util.Close(f)
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("Failed to download file: %s", err)
+	}
+	return nil
+}
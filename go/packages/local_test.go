@@ -0,0 +1,109 @@
+package packages
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+const (
+	PULLD_OLD = "pulld:someone@example.org:2016-06-01T00:00:00Z:1111111111111111111111111111111111111111.deb"
+	PULLD_NEW = "pulld:someone@example.org:2016-06-02T00:00:00Z:2222222222222222222222222222222222222222.deb"
+)
+
+func addPackage(t *testing.T, dir, app, name, metadata, contents string) {
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "debs", app), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "debs", app, name), []byte(contents), 0644))
+	if metadata != "" {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "debs", app, name+METADATA_SUFFIX), []byte(metadata), 0644))
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packages")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	store, err := NewLocalStore(dir)
+	assert.NoError(t, err)
+
+	all, err := store.AllAvailable()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(all))
+
+	addPackage(t, dir, "pulld", PULLD_OLD, `{"appname": "pulld", "hash": "1111111111111111111111111111111111111111", "datetime": "2016-06-01T00:00:00Z", "services": "pulld.service"}`, "old")
+	addPackage(t, dir, "pulld", PULLD_NEW, `{"appname": "pulld", "hash": "2222222222222222222222222222222222222222", "datetime": "2016-06-02T00:00:00Z", "dirty": "true", "note": "A note"}`, "new")
+	// Packages without metadata are skipped.
+	addPackage(t, dir, "pulld", "pulld:broken.deb", "", "broken")
+
+	ps, err := store.AllAvailableApp("pulld")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ps))
+	assert.Equal(t, &Package{
+		Name:     PULLD_NEW,
+		Hash:     "2222222222222222222222222222222222222222",
+		Built:    time.Date(2016, 6, 2, 0, 0, 0, 0, time.UTC),
+		Dirty:    true,
+		Note:     "A note",
+		Services: []string{},
+	}, ps[0])
+	assert.Equal(t, []string{"pulld.service"}, ps[1].Services)
+
+	ps, err = store.AllAvailableApp("unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ps))
+
+	all, err = store.AllAvailable()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(all))
+	assert.Equal(t, "pulld/"+PULLD_NEW, all["pulld"][0].Name)
+	byName, err := AllAvailableByPackageName(store)
+	assert.NoError(t, err)
+	assert.Equal(t, "1111111111111111111111111111111111111111", byName["pulld/"+PULLD_OLD].Hash)
+
+	var buf bytes.Buffer
+	assert.NoError(t, store.Download("pulld/"+PULLD_OLD, &buf))
+	assert.Equal(t, "old", buf.String())
+	assert.Error(t, store.Download("pulld/missing.deb", &buf))
+}
+
+func TestLocalStoreInstalled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packages")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	store, err := NewLocalStore(dir)
+	assert.NoError(t, err)
+
+	installed, err := store.InstalledForServer("skia-push")
+	assert.Error(t, err)
+	assert.Equal(t, &Installed{Names: []string{}, Generation: -1}, installed)
+
+	// A list that doesn't exist yet can be written with either -1 or 0.
+	assert.Error(t, store.PutInstalled("skia-push", []string{"pulld/" + PULLD_OLD}, 12))
+	assert.NoError(t, store.PutInstalled("skia-push", []string{"pulld/" + PULLD_OLD}, 0))
+	installed, err = store.InstalledForServer("skia-push")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pulld/" + PULLD_OLD}, installed.Names)
+
+	// Writes with an old generation fail.
+	assert.NoError(t, store.PutInstalled("skia-push", []string{"pulld/" + PULLD_NEW, "logserverd/"}, installed.Generation))
+	assert.Error(t, store.PutInstalled("skia-push", []string{}, installed.Generation))
+	installed, err = store.InstalledForServer("skia-push")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"logserverd/", "pulld/" + PULLD_NEW}, installed.Names)
+
+	assert.NoError(t, store.PutInstalled("skia-push", []string{}, -1))
+	installed, err = store.InstalledForServer("skia-push")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, installed.Names)
+
+	info, err := NewAllInfo(store, []string{"skia-push", "skia-new"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, info.AllInstalled()["skia-push"].Names)
+	assert.Equal(t, int64(-1), info.AllInstalled()["skia-new"].Generation)
+}
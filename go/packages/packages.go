@@ -6,12 +6,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
-	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,20 +16,10 @@ import (
 	"github.com/BurntSushi/toml"
 	"github.com/skia-dev/glog"
 	iexec "go.skia.org/infra/go/exec"
-	"go.skia.org/infra/go/gs"
 	"go.skia.org/infra/go/util"
-	"google.golang.org/api/storage/v1"
 )
 
-var (
-	bucketName = "skia-push"
-)
-
-func SetBucketName(s string) {
-	bucketName = s
-}
-
-// Package represents a single Debian package uploaded to Google Storage.
+// Package represents a single Debian package in a PackageStore.
 type Package struct {
 	Name     string // The unique name of this release package.
 	Hash     string
@@ -53,7 +40,7 @@ type Installed struct {
 	// Names is a list of package names, of the form "{appname}/{appname}:{author}:{date}:{githash}.deb"
 	Names []string
 
-	// Generation is the Google Storage generation number of the config file at the time we read it.
+	// Generation is the generation number of the config file in the PackageStore at the time we read it.
 	// Use this to avoid the lost-update problem: https://cloud.google.com/storage/docs/generations-preconditions#_ReadModWrite
 	Generation int64
 }
@@ -63,14 +50,12 @@ type AllInfo struct {
 	mutex        sync.Mutex
 	allInstalled map[string]*Installed
 	allAvailable map[string][]*Package
-	client       *http.Client
-	store        *storage.Service
+	store        PackageStore
 	serverNames  []string
 }
 
-func NewAllInfo(client *http.Client, store *storage.Service, serverNames []string) (*AllInfo, error) {
+func NewAllInfo(store PackageStore, serverNames []string) (*AllInfo, error) {
 	a := &AllInfo{
-		client:      client,
 		store:       store,
 		serverNames: serverNames,
 	}
@@ -95,11 +80,11 @@ func (a *AllInfo) step() error {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 	var err error
-	a.allInstalled, err = allInstalled(a.client, a.store, a.serverNames)
+	a.allInstalled, err = allInstalled(a.store, a.serverNames)
 	if err != nil {
 		return err
 	}
-	a.allAvailable, err = AllAvailable(a.store)
+	a.allAvailable, err = a.store.AllAvailable()
 	if err != nil {
 		return err
 	}
@@ -114,7 +99,7 @@ func (a *AllInfo) AllAvailable() map[string][]*Package {
 }
 
 // AllAvailableByPackageName returns all known packages for all applications
-// uploaded to gs://<bucketName>/debs/. They are mapped by the package name.
+// in the store. They are mapped by the package name.
 func (a *AllInfo) AllAvailableByPackageName() map[string]*Package {
 	allAvailable := a.AllAvailable()
 	ret := map[string]*Package{}
@@ -128,30 +113,13 @@ func (a *AllInfo) AllAvailableByPackageName() map[string]*Package {
 
 // PutInstalled writes a new list of installed packages for the given server.
 func (a *AllInfo) PutInstalled(serverName string, packages []string, generation int64) error {
-	err := PutInstalled(a.store, serverName, packages, generation)
+	err := a.store.PutInstalled(serverName, packages, generation)
 	if err != nil {
 		return err
 	}
 	return a.step()
 }
 
-// PutInstalled writes a new list of installed packages for the given server.
-func PutInstalled(store *storage.Service, serverName string, packages []string, generation int64) error {
-	b, err := json.Marshal(packages)
-	if err != nil {
-		return fmt.Errorf("Failed to encode installed packages: %s", err)
-	}
-	buf := bytes.NewBuffer(b)
-	req := store.Objects.Insert(bucketName, &storage.Object{Name: "server/" + serverName + ".json"}).Media(buf)
-	if generation != -1 {
-		req = req.IfGenerationMatch(generation)
-	}
-	if _, err = req.Do(); err != nil {
-		return fmt.Errorf("Failed to write installed packages list to Google Storage for %s: %s", serverName, err)
-	}
-	return nil
-}
-
 func (a *AllInfo) AllInstalled() map[string]*Installed {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
@@ -160,10 +128,10 @@ func (a *AllInfo) AllInstalled() map[string]*Installed {
 }
 
 // allInstalled returns a map of all known server names to their list of installed package names.
-func allInstalled(client *http.Client, store *storage.Service, names []string) (map[string]*Installed, error) {
+func allInstalled(store PackageStore, names []string) (map[string]*Installed, error) {
 	ret := map[string]*Installed{}
 	for _, name := range names {
-		p, err := InstalledForServer(client, store, name)
+		p, err := store.InstalledForServer(name)
 		if err != nil {
 			glog.Errorf("Failed to retrieve remote package list: %s", err)
 		}
@@ -231,88 +199,10 @@ func (p PackageSlice) String() string {
 	return strings.Join(strs, "\n")
 }
 
-// AllAvailable returns all known packages for all applications uploaded to
-// gs://<bucketName>/debs/.
-func AllAvailable(store *storage.Service) (map[string][]*Package, error) {
-	req := store.Objects.List(bucketName).Prefix("debs")
-	ret := map[string][]*Package{}
-	for {
-		objs, err := req.Do()
-		if err != nil {
-			return nil, fmt.Errorf("Failed to list debian packages in Google Storage: %s", err)
-		}
-		for _, o := range objs.Items {
-			key := safeGet(o.Metadata, "appname", "")
-			if key == "" {
-				glog.Errorf("Debian package without proper metadata: %s", o.Name)
-				continue
-			}
-			p := &Package{
-				Name:     o.Name[5:], // Strip of debs/ from the beginning.
-				Hash:     safeGet(o.Metadata, "hash", ""),
-				UserID:   safeGet(o.Metadata, "userid", ""),
-				Built:    safeGetTime(o.Metadata, "datetime"),
-				Dirty:    safeGetBool(o.Metadata, "dirty"),
-				Note:     safeGet(o.Metadata, "note", ""),
-				Services: safeGetStringSlice(o.Metadata, "services"),
-			}
-			if _, ok := ret[key]; !ok {
-				ret[key] = []*Package{}
-			}
-			ret[key] = append(ret[key], p)
-		}
-		if objs.NextPageToken == "" {
-			break
-		}
-		req.PageToken(objs.NextPageToken)
-	}
-	for _, value := range ret {
-		sort.Sort(PackageSlice(value))
-	}
-	return ret, nil
-}
-
-// AllAvailableApp returns all known packages for the given applications uploaded to
-// gs://<bucketName>/debs/<appname>.
-func AllAvailableApp(store *storage.Service, appName string) ([]*Package, error) {
-	prefix := fmt.Sprintf("debs/%s/", appName)
-	req := store.Objects.List(bucketName).Prefix(prefix)
-	ret := []*Package{}
-	for {
-		objs, err := req.Do()
-		if err != nil {
-			return nil, fmt.Errorf("Failed to list debian packages in Google Storage: %s", err)
-		}
-		for _, o := range objs.Items {
-			key := safeGet(o.Metadata, "appname", "")
-			if key == "" {
-				glog.Errorf("Debian package without proper metadata: %s", o.Name)
-				continue
-			}
-			p := &Package{
-				Name:     o.Name[len(prefix):], // Strip of debs/ from the beginning.
-				Hash:     safeGet(o.Metadata, "hash", ""),
-				UserID:   safeGet(o.Metadata, "userid", ""),
-				Built:    safeGetTime(o.Metadata, "datetime"),
-				Dirty:    safeGetBool(o.Metadata, "dirty"),
-				Note:     safeGet(o.Metadata, "note", ""),
-				Services: safeGetStringSlice(o.Metadata, "services"),
-			}
-			ret = append(ret, p)
-		}
-		if objs.NextPageToken == "" {
-			break
-		}
-		req.PageToken(objs.NextPageToken)
-	}
-	sort.Sort(PackageSlice(ret))
-	return ret, nil
-}
-
 // AllAvailableByPackageName returns all known packages for all applications
-// uploaded to gs://<bucketName>/debs/. They are mapped by the package name.
-func AllAvailableByPackageName(store *storage.Service) (map[string]*Package, error) {
-	allAvailable, err := AllAvailable(store)
+// in the store. They are mapped by the package name.
+func AllAvailableByPackageName(store PackageStore) (map[string]*Package, error) {
+	allAvailable, err := store.AllAvailable()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve all available packages: %s", err)
 	}
@@ -325,46 +215,6 @@ func AllAvailableByPackageName(store *storage.Service) (map[string]*Package, err
 	return ret, nil
 }
 
-// InstalledForServer returns a list of package names of installed packages for
-// the given server.
-func InstalledForServer(client *http.Client, store *storage.Service, serverName string) (*Installed, error) {
-	ret := &Installed{
-		Names:      []string{},
-		Generation: -1,
-	}
-
-	filename := "server/" + serverName + ".json"
-	obj, err := store.Objects.Get(bucketName, filename).Do()
-	if err != nil {
-		return ret, fmt.Errorf("Failed to retrieve Google Storage metadata about packages file %q: %s", filename, err)
-	}
-
-	glog.Infof("Fetching: %s", obj.MediaLink)
-	req, err := gs.RequestForStorageURL(obj.MediaLink)
-	if err != nil {
-		return ret, fmt.Errorf("Failed to construct request object for media: %s", err)
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return ret, fmt.Errorf("Failed to retrieve packages file: %s", err)
-	}
-	defer util.Close(resp.Body)
-	if resp.StatusCode != 200 {
-		return ret, fmt.Errorf("Wrong status code: %#v", *resp)
-	}
-	dec := json.NewDecoder(resp.Body)
-
-	value := []string{}
-	if err := dec.Decode(&value); err != nil {
-		return ret, fmt.Errorf("Failed to decode packages file: %s", err)
-	}
-	sort.Strings(value)
-	ret.Names = value
-	ret.Generation = obj.Generation
-
-	return ret, nil
-}
-
 // FromLocalFile loads a list of installed debian package names from a local file.
 func FromLocalFile(filename string) ([]string, error) {
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
@@ -399,27 +249,15 @@ func ToLocalFile(packages []string, filename string) error {
 	return nil
 }
 
-// Install downloads and installs a debian package from Google Storage.
-func Install(client *http.Client, store *storage.Service, name string) error {
+// Install downloads and installs a debian package from the store.
+func Install(store PackageStore, name string) error {
 	glog.Infof("Installing: %s", name)
-	obj, err := store.Objects.Get(bucketName, "debs/"+name).Do()
-	if err != nil {
-		return fmt.Errorf("Failed to retrieve Google Storage metadata about debian package: %s", err)
-	}
-	req, err := gs.RequestForStorageURL(obj.MediaLink)
-	if err != nil {
-		return fmt.Errorf("Failed to construct request object for media: %s", err)
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("Failed to retrieve packages file: %s", err)
-	}
-	defer util.Close(resp.Body)
 	f, err := ioutil.TempFile("", "skia-pull")
 	if err != nil {
 		return fmt.Errorf("Failed to create tmp file: %s", err)
 	}
-	_, copyErr := io.Copy(f, resp.Body)
+	defer util.Remove(f.Name())
+	copyErr := store.Download(name, f)
 	if err := f.Close(); err != nil {
 		return fmt.Errorf("Failed to close temporary file: %v", err)
 	}
@@ -0,0 +1,51 @@
+package packages
+
+import "io"
+
+// PackageStore is where the debian packages and the lists of packages
+// installed on each server are kept.
+//
+// Packages are named "{appname}/{uniquely named Debian package}.deb" and the
+// metadata of each package is the same set of key/value pairs that
+// bash/release.sh attaches to the packages it uploads, i.e. "appname",
+// "hash", "userid", "datetime", "dirty", "note" and "services".
+type PackageStore interface {
+	// AllAvailable returns all known packages for all applications, keyed by
+	// application name and sorted from newest to oldest.
+	AllAvailable() (map[string][]*Package, error)
+
+	// AllAvailableApp returns all known packages for the given application,
+	// sorted from newest to oldest. The names of the packages don't include
+	// the "{appname}/" prefix.
+	AllAvailableApp(appName string) ([]*Package, error)
+
+	// InstalledForServer returns the list of package names that should be
+	// installed on the given server. If the list can't be read then an error
+	// is returned along with an empty Installed with a Generation of -1.
+	InstalledForServer(serverName string) (*Installed, error)
+
+	// PutInstalled writes a new list of installed packages for the given
+	// server. The write fails if the generation isn't the current generation
+	// of the list, unless generation is -1.
+	PutInstalled(serverName string, packages []string, generation int64) error
+
+	// Download writes the contents of the named package to w.
+	Download(name string, w io.Writer) error
+}
+
+// packageFromMetadata returns the Package of the given name with the given
+// metadata, or nil if the metadata is missing the appname.
+func packageFromMetadata(name string, m map[string]string) *Package {
+	if safeGet(m, "appname", "") == "" {
+		return nil
+	}
+	return &Package{
+		Name:     name,
+		Hash:     safeGet(m, "hash", ""),
+		UserID:   safeGet(m, "userid", ""),
+		Built:    safeGetTime(m, "datetime"),
+		Dirty:    safeGetBool(m, "dirty"),
+		Note:     safeGet(m, "note", ""),
+		Services: safeGetStringSlice(m, "services"),
+	}
+}
@@ -123,6 +123,7 @@ func ParseKey(key string) (map[string]string, error) {
 
 // queryParam represents a query on a particular parameter in a key.
 type queryParam struct {
+	key         string         // The param key.
 	keyMatch    string         // The param key, including the leading "," and trailing "=".
 	keyMatchLen int            // The length of keyMatch.
 	isWildCard  bool           // True if this is a wildcard value match.
@@ -221,6 +222,7 @@ func New(q url.Values) (*Query, error) {
 			}
 		}
 		params = append(params, queryParam{
+			key:         key,
 			keyMatch:    keyMatch,
 			keyMatchLen: len(keyMatch),
 			isWildCard:  isWildCard,
@@ -267,3 +269,26 @@ func (q *Query) Matches(s string) bool {
 	}
 	return true
 }
+
+// MatchesParams returns true if the given params match the query. It is the
+// equivalent of calling Matches on the structured key made from the params,
+// but doesn't require the params to be valid for a structured key.
+func (q *Query) MatchesParams(p map[string]string) bool {
+	for _, part := range q.params {
+		value, ok := p[part.key]
+		if !ok {
+			return false
+		}
+		if part.isWildCard {
+			continue
+		}
+		if part.isRegex {
+			if !part.reg.MatchString(value) {
+				return false
+			}
+		} else if part.isNegative == util.In(value, part.values) {
+			return false
+		}
+	}
+	return true
+}
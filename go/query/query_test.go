@@ -302,6 +302,11 @@ func TestMatches(t *testing.T) {
 		if got, want := q.Matches(tc.key), tc.matches; got != want {
 			t.Errorf("Failed matching %q to %#v. Got %v Want %v. %s", tc.key, tc.query, got, want, tc.reason)
 		}
+		p, err := ParseKey(tc.key)
+		assert.NoError(t, err)
+		if got, want := q.MatchesParams(p), tc.matches; got != want {
+			t.Errorf("Failed matching params %#v to %#v. Got %v Want %v. %s", p, tc.query, got, want, tc.reason)
+		}
 	}
 }
 
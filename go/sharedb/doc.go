@@ -2,8 +2,13 @@
 // BoltDB over the network.
 //
 // It provides a simplified interface than using BoltDB locally.
-// In particular it only provides atomicity for individual read and
-// write operations, but not transactions across multiple I/O operations.
+// Individual read and write operations are atomic, and multiple writes
+// to a database can be applied atomically with Batch, optionally
+// guarded by compare-and-swap conditions. There are no read-write
+// transactions beyond that.
+//
+// Changes to a database, bucket or key prefix can be streamed with Watch,
+// so clients don't have to poll for updates.
 //
 // Each data item is address by a triple: database,bucket,key.
 // 'database' maps to a BoltDB database stored in a single file.
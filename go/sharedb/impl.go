@@ -5,6 +5,7 @@ package sharedb
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"sync"
 
 	"github.com/boltdb/bolt"
+	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/fileutil"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -40,6 +42,119 @@ func (s *ShareDB) Close() error {
 	return s.cc.Close()
 }
 
+// Txn collects conditions and mutations on a single database which are then
+// applied atomically by Commit. For example, to move a key only if it hasn't
+// changed:
+//
+//    ok, err := client.NewTxn("mydb").
+//      IfEquals("bucket", "old", oldValue).
+//      Delete("bucket", "old").
+//      Put("bucket", "new", oldValue).
+//      Commit(ctx)
+//
+type Txn struct {
+	client *ShareDB
+	req    *BatchRequest
+}
+
+// NewTxn returns a new empty Txn on the given database.
+func (s *ShareDB) NewTxn(database string) *Txn {
+	return &Txn{
+		client: s,
+		req: &BatchRequest{
+			Database:   database,
+			Conditions: []*Condition{},
+			Mutations:  []*Mutation{},
+		},
+	}
+}
+
+// IfEquals adds the condition that the key exists and has the given value.
+func (t *Txn) IfEquals(bucket, key string, value []byte) *Txn {
+	t.req.Conditions = append(t.req.Conditions, &Condition{Bucket: bucket, Key: key, Value: value})
+	return t
+}
+
+// IfAbsent adds the condition that the key does not exist.
+func (t *Txn) IfAbsent(bucket, key string) *Txn {
+	t.req.Conditions = append(t.req.Conditions, &Condition{Bucket: bucket, Key: key, Absent: true})
+	return t
+}
+
+// Put adds a write of the value to the key.
+func (t *Txn) Put(bucket, key string, value []byte) *Txn {
+	t.req.Mutations = append(t.req.Mutations, &Mutation{Bucket: bucket, Key: key, Value: value})
+	return t
+}
+
+// Delete adds the removal of the key.
+func (t *Txn) Delete(bucket, key string) *Txn {
+	t.req.Mutations = append(t.req.Mutations, &Mutation{Bucket: bucket, Key: key, Delete: true})
+	return t
+}
+
+// Commit applies the Txn. It returns false if any of the conditions didn't
+// hold, in which case nothing was written.
+func (t *Txn) Commit(ctx context.Context) (bool, error) {
+	resp, err := t.client.Batch(ctx, t.req)
+	if err != nil {
+		return false, err
+	}
+	return resp.Ok, nil
+}
+
+// CompareAndSwap sets the key to newValue only if its current value is
+// oldValue. If oldValue is nil the key must not exist. Returns true if the
+// value was written.
+func (s *ShareDB) CompareAndSwap(ctx context.Context, database, bucket, key string, oldValue, newValue []byte) (bool, error) {
+	txn := s.NewTxn(database)
+	if oldValue == nil {
+		txn.IfAbsent(bucket, key)
+	} else {
+		txn.IfEquals(bucket, key, oldValue)
+	}
+	return txn.Put(bucket, key, newValue).Commit(ctx)
+}
+
+// Subscribe starts watching the given database for changes to keys with the
+// given prefix in the given bucket. An empty bucket or prefix matches all
+// buckets or keys. It only returns once the watch is registered on the server,
+// so all changes made after Subscribe returns are sent on the returned
+// channel. The channel is closed when ctx is cancelled or the watch fails.
+func (s *ShareDB) Subscribe(ctx context.Context, database, bucket, prefix string) (<-chan *WatchEvent, error) {
+	stream, err := s.Watch(ctx, &WatchRequest{Database: database, Bucket: bucket, Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	ev, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if ev.Type != WatchEvent_READY {
+		return nil, fmt.Errorf("Expected a READY event, got %s", ev.Type)
+	}
+
+	ch := make(chan *WatchEvent)
+	go func() {
+		defer close(ch)
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					glog.Errorf("Watch on %s/%s/%s failed: %s", database, bucket, prefix, err)
+				}
+				return
+			}
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
 // rpcServer implements the ShareDBServer define in the sharedb.proto file.
 // This implementation is based on BoltDB. It stores key-value pairs that
 // are addressable via: database/bucket/key.
@@ -49,6 +164,16 @@ type rpcServer struct {
 	dataDir   string
 	databases map[string]*bolt.DB
 	dbsMutex  sync.Mutex
+
+	// writeMutexes holds one lock per database, protected by dbsMutex. Writes
+	// to a watched database hold it exclusively so that watchers are notified
+	// of changes in the order they were committed. See update.
+	writeMutexes map[string]*sync.RWMutex
+
+	// watchers are the currently registered watchers, protected by
+	// watchersMutex.
+	watchers      map[*watcher]bool
+	watchersMutex sync.Mutex
 }
 
 // NewServer returns a instance that implements the ShareDBServer interface that
@@ -56,8 +181,10 @@ type rpcServer struct {
 // It can then be used to run an RPC server. See tests for details.
 func NewServer(dataDir string) ShareDBServer {
 	ret := &rpcServer{
-		dataDir:   fileutil.Must(fileutil.EnsureDirExists(dataDir)),
-		databases: map[string]*bolt.DB{},
+		dataDir:      fileutil.Must(fileutil.EnsureDirExists(dataDir)),
+		databases:    map[string]*bolt.DB{},
+		writeMutexes: map[string]*sync.RWMutex{},
+		watchers:     map[*watcher]bool{},
 	}
 	return ret
 }
@@ -98,12 +225,15 @@ func (r *rpcServer) Put(ctx context.Context, req *PutRequest) (*PutResponse, err
 		return &PutResponse{false}, err
 	}
 
-	err = db.Batch(func(tx *bolt.Tx) error {
+	err = r.update(req.Database, db, func(tx *bolt.Tx) ([]*WatchEvent, error) {
 		bucket, err := tx.CreateBucketIfNotExists([]byte(req.Bucket))
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return bucket.Put([]byte(req.Key), req.Value)
+		if err := bucket.Put([]byte(req.Key), req.Value); err != nil {
+			return nil, err
+		}
+		return []*WatchEvent{putEvent(req.Database, req.Bucket, req.Key, req.Value)}, nil
 	})
 	return &PutResponse{err == nil}, err
 }
@@ -123,13 +253,19 @@ func (r *rpcServer) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResp
 		return result, nil
 	}
 
-	err = db.Batch(func(tx *bolt.Tx) error {
+	err = r.update(req.Database, db, func(tx *bolt.Tx) ([]*WatchEvent, error) {
 		bucket := tx.Bucket([]byte(req.Bucket))
 		if bucket == nil {
-			return nil
+			return nil, nil
 		}
 
-		return bucket.Delete([]byte(req.Key))
+		if bucket.Get([]byte(req.Key)) == nil {
+			return nil, nil
+		}
+		if err := bucket.Delete([]byte(req.Key)); err != nil {
+			return nil, err
+		}
+		return []*WatchEvent{deleteEvent(req.Database, req.Bucket, req.Key)}, nil
 	})
 	result.Ok = (err == nil)
 	return result, err
@@ -217,6 +353,234 @@ func (r *rpcServer) Keys(ctx context.Context, req *KeysRequest) (*KeysResponse,
 	})
 }
 
+// errConditionFailed is returned from a Batch transaction to roll it back when
+// one of its conditions doesn't hold.
+var errConditionFailed = errors.New("Condition failed.")
+
+// Batch applies all the mutations in the BatchRequest in a single
+// transaction. If any of the conditions don't hold then nothing is written
+// and BatchResponse.Ok is false.
+func (r *rpcServer) Batch(ctx context.Context, req *BatchRequest) (*BatchResponse, error) {
+	result := &BatchResponse{}
+	db, err := r.getDB(req.Database, len(req.Mutations) > 0)
+	if err != nil {
+		return result, err
+	}
+
+	err = r.update(req.Database, db, func(tx *bolt.Tx) ([]*WatchEvent, error) {
+		for _, c := range req.Conditions {
+			var val []byte
+			if bucket := tx.Bucket([]byte(c.Bucket)); bucket != nil {
+				val = bucket.Get([]byte(c.Key))
+			}
+			if c.Absent != (val == nil) || (!c.Absent && !bytes.Equal(val, c.Value)) {
+				return nil, errConditionFailed
+			}
+		}
+
+		events := make([]*WatchEvent, 0, len(req.Mutations))
+		for _, m := range req.Mutations {
+			if m.Delete {
+				bucket := tx.Bucket([]byte(m.Bucket))
+				if bucket == nil || bucket.Get([]byte(m.Key)) == nil {
+					continue
+				}
+				if err := bucket.Delete([]byte(m.Key)); err != nil {
+					return nil, err
+				}
+				events = append(events, deleteEvent(req.Database, m.Bucket, m.Key))
+			} else {
+				bucket, err := tx.CreateBucketIfNotExists([]byte(m.Bucket))
+				if err != nil {
+					return nil, err
+				}
+				if err := bucket.Put([]byte(m.Key), m.Value); err != nil {
+					return nil, err
+				}
+				events = append(events, putEvent(req.Database, m.Bucket, m.Key, m.Value))
+			}
+		}
+		return events, nil
+	})
+	if err == errConditionFailed {
+		return result, nil
+	}
+	result.Ok = (err == nil)
+	return result, err
+}
+
+// Watch sends a WatchEvent for every change made to the keys selected by the
+// WatchRequest until the client cancels the call. The first event sent is
+// always of type READY. If the client falls too far behind it is dropped and
+// an error is returned.
+func (r *rpcServer) Watch(req *WatchRequest, stream ShareDB_WatchServer) error {
+	if req.Database == "" {
+		return fmt.Errorf("A database must be given to watch.")
+	}
+	w := r.addWatcher(req)
+	defer r.removeWatcher(w)
+
+	if err := stream.Send(&WatchEvent{Type: WatchEvent_READY, Database: req.Database}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-w.ch:
+			if !ok {
+				return fmt.Errorf("Watcher for %s/%s/%s fell behind and was dropped.", req.Database, req.Bucket, req.Prefix)
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// WATCH_BUFFER_SIZE is the number of events buffered for each watcher before
+// it is considered to have fallen behind.
+const WATCH_BUFFER_SIZE = 1000
+
+// watcher is a registered call to Watch.
+type watcher struct {
+	req *WatchRequest
+	ch  chan *WatchEvent
+}
+
+// matches returns true if the watcher is interested in the event.
+func (w *watcher) matches(ev *WatchEvent) bool {
+	return w.req.Database == ev.Database &&
+		(w.req.Bucket == "" || w.req.Bucket == ev.Bucket) &&
+		strings.HasPrefix(ev.Key, w.req.Prefix)
+}
+
+func (r *rpcServer) addWatcher(req *WatchRequest) *watcher {
+	w := &watcher{
+		req: req,
+		ch:  make(chan *WatchEvent, WATCH_BUFFER_SIZE),
+	}
+	// Wait for writes that don't notify watchers to be committed.
+	mutex := r.writeMutex(req.Database)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	r.watchersMutex.Lock()
+	defer r.watchersMutex.Unlock()
+	r.watchers[w] = true
+	return w
+}
+
+func (r *rpcServer) removeWatcher(w *watcher) {
+	r.watchersMutex.Lock()
+	defer r.watchersMutex.Unlock()
+	if r.watchers[w] {
+		delete(r.watchers, w)
+		close(w.ch)
+	}
+}
+
+// notify sends the events to all interested watchers. Watchers whose buffers
+// are full are dropped rather than blocking the writer.
+func (r *rpcServer) notify(events []*WatchEvent) {
+	r.watchersMutex.Lock()
+	defer r.watchersMutex.Unlock()
+	for w := range r.watchers {
+		for _, ev := range events {
+			if !w.matches(ev) {
+				continue
+			}
+			select {
+			case w.ch <- ev:
+			default:
+				delete(r.watchers, w)
+				close(w.ch)
+			}
+			if !r.watchers[w] {
+				break
+			}
+		}
+	}
+}
+
+// watched returns true if any watcher is registered for the database.
+func (r *rpcServer) watched(database string) bool {
+	r.watchersMutex.Lock()
+	defer r.watchersMutex.Unlock()
+	for w := range r.watchers {
+		if w.req.Database == database {
+			return true
+		}
+	}
+	return false
+}
+
+// update runs fn in a read-write transaction on db and notifies the watchers
+// of the events fn returns once the transaction has been committed. fn may be
+// called more than once and must be idempotent.
+func (r *rpcServer) update(database string, db *bolt.DB, fn func(tx *bolt.Tx) ([]*WatchEvent, error)) error {
+	mutex := r.writeMutex(database)
+
+	// Nobody needs to see the events of an unwatched database in order, so
+	// concurrent writes can be coalesced into one transaction. Holding the
+	// read lock keeps watchers from being added until the write is committed.
+	mutex.RLock()
+	if !r.watched(database) {
+		err := db.Batch(func(tx *bolt.Tx) error {
+			_, err := fn(tx)
+			return err
+		})
+		mutex.RUnlock()
+		return err
+	}
+	mutex.RUnlock()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	var events []*WatchEvent
+	err := db.Update(func(tx *bolt.Tx) error {
+		var err error
+		events, err = fn(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	r.notify(events)
+	return nil
+}
+
+func putEvent(database, bucket, key string, value []byte) *WatchEvent {
+	return &WatchEvent{
+		Type:     WatchEvent_PUT,
+		Database: database,
+		Bucket:   bucket,
+		Key:      key,
+		Value:    value,
+	}
+}
+
+func deleteEvent(database, bucket, key string) *WatchEvent {
+	return &WatchEvent{
+		Type:     WatchEvent_DELETE,
+		Database: database,
+		Bucket:   bucket,
+		Key:      key,
+	}
+}
+
+// writeMutex returns the lock that orders the writes to the database.
+func (r *rpcServer) writeMutex(database string) *sync.RWMutex {
+	r.dbsMutex.Lock()
+	defer r.dbsMutex.Unlock()
+	mutex, ok := r.writeMutexes[database]
+	if !ok {
+		mutex = &sync.RWMutex{}
+		r.writeMutexes[database] = mutex
+	}
+	return mutex
+}
+
 // getDB returns a BoltDB if the instance exists in the internal map of
 // databases or on disk. Otherwise it will create the database on disk if
 // the 'create' parameter is true. If the database does not exist and create
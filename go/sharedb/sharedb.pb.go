@@ -21,6 +21,12 @@ It has these top-level messages:
 	BucketsResponse
 	KeysRequest
 	KeysResponse
+	Condition
+	Mutation
+	BatchRequest
+	BatchResponse
+	WatchRequest
+	WatchEvent
 */
 package sharedb
 
@@ -44,6 +50,32 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type WatchEvent_Type int32
+
+const (
+	WatchEvent_PUT    WatchEvent_Type = 0
+	WatchEvent_DELETE WatchEvent_Type = 1
+	// READY is sent once when the watch has been registered. All changes
+	// made after it are sent.
+	WatchEvent_READY WatchEvent_Type = 2
+)
+
+var WatchEvent_Type_name = map[int32]string{
+	0: "PUT",
+	1: "DELETE",
+	2: "READY",
+}
+var WatchEvent_Type_value = map[string]int32{
+	"PUT":    0,
+	"DELETE": 1,
+	"READY":  2,
+}
+
+func (x WatchEvent_Type) String() string {
+	return proto.EnumName(WatchEvent_Type_name, int32(x))
+}
+func (WatchEvent_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{17, 0} }
+
 type GetRequest struct {
 	Database string `protobuf:"bytes,1,opt,name=database" json:"database,omitempty"`
 	Bucket   string `protobuf:"bytes,2,opt,name=bucket" json:"bucket,omitempty"`
@@ -162,6 +194,96 @@ func (m *KeysResponse) String() string            { return proto.CompactTextStri
 func (*KeysResponse) ProtoMessage()               {}
 func (*KeysResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
+// Condition is a compare-and-swap precondition of a BatchRequest.
+type Condition struct {
+	Bucket string `protobuf:"bytes,1,opt,name=bucket" json:"bucket,omitempty"`
+	Key    string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	// If true the key must not exist. Otherwise the key must exist and its
+	// value must be equal to 'value'.
+	Absent bool   `protobuf:"varint,3,opt,name=absent" json:"absent,omitempty"`
+	Value  []byte `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *Condition) Reset()                    { *m = Condition{} }
+func (m *Condition) String() string            { return proto.CompactTextString(m) }
+func (*Condition) ProtoMessage()               {}
+func (*Condition) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+// Mutation is a single put or delete in a BatchRequest.
+type Mutation struct {
+	Bucket string `protobuf:"bytes,1,opt,name=bucket" json:"bucket,omitempty"`
+	Key    string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Value  []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	// If true the key is deleted and value is ignored.
+	Delete bool `protobuf:"varint,4,opt,name=delete" json:"delete,omitempty"`
+}
+
+func (m *Mutation) Reset()                    { *m = Mutation{} }
+func (m *Mutation) String() string            { return proto.CompactTextString(m) }
+func (*Mutation) ProtoMessage()               {}
+func (*Mutation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+type BatchRequest struct {
+	Database   string       `protobuf:"bytes,1,opt,name=database" json:"database,omitempty"`
+	Conditions []*Condition `protobuf:"bytes,2,rep,name=conditions" json:"conditions,omitempty"`
+	Mutations  []*Mutation  `protobuf:"bytes,3,rep,name=mutations" json:"mutations,omitempty"`
+}
+
+func (m *BatchRequest) Reset()                    { *m = BatchRequest{} }
+func (m *BatchRequest) String() string            { return proto.CompactTextString(m) }
+func (*BatchRequest) ProtoMessage()               {}
+func (*BatchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *BatchRequest) GetConditions() []*Condition {
+	if m != nil {
+		return m.Conditions
+	}
+	return nil
+}
+
+func (m *BatchRequest) GetMutations() []*Mutation {
+	if m != nil {
+		return m.Mutations
+	}
+	return nil
+}
+
+type BatchResponse struct {
+	// True if all the conditions held and the mutations were applied.
+	Ok bool `protobuf:"varint,1,opt,name=ok" json:"ok,omitempty"`
+}
+
+func (m *BatchResponse) Reset()                    { *m = BatchResponse{} }
+func (m *BatchResponse) String() string            { return proto.CompactTextString(m) }
+func (*BatchResponse) ProtoMessage()               {}
+func (*BatchResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+type WatchRequest struct {
+	Database string `protobuf:"bytes,1,opt,name=database" json:"database,omitempty"`
+	// If not empty only changes to this bucket are sent.
+	Bucket string `protobuf:"bytes,2,opt,name=bucket" json:"bucket,omitempty"`
+	// If not empty only changes to keys with this prefix are sent.
+	Prefix string `protobuf:"bytes,3,opt,name=prefix" json:"prefix,omitempty"`
+}
+
+func (m *WatchRequest) Reset()                    { *m = WatchRequest{} }
+func (m *WatchRequest) String() string            { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()               {}
+func (*WatchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+type WatchEvent struct {
+	Type     WatchEvent_Type `protobuf:"varint,1,opt,name=type,enum=sharedb.WatchEvent_Type" json:"type,omitempty"`
+	Database string          `protobuf:"bytes,2,opt,name=database" json:"database,omitempty"`
+	Bucket   string          `protobuf:"bytes,3,opt,name=bucket" json:"bucket,omitempty"`
+	Key      string          `protobuf:"bytes,4,opt,name=key" json:"key,omitempty"`
+	Value    []byte          `protobuf:"bytes,5,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *WatchEvent) Reset()                    { *m = WatchEvent{} }
+func (m *WatchEvent) String() string            { return proto.CompactTextString(m) }
+func (*WatchEvent) ProtoMessage()               {}
+func (*WatchEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
 func init() {
 	proto.RegisterType((*GetRequest)(nil), "sharedb.GetRequest")
 	proto.RegisterType((*GetResponse)(nil), "sharedb.GetResponse")
@@ -175,6 +297,13 @@ func init() {
 	proto.RegisterType((*BucketsResponse)(nil), "sharedb.BucketsResponse")
 	proto.RegisterType((*KeysRequest)(nil), "sharedb.KeysRequest")
 	proto.RegisterType((*KeysResponse)(nil), "sharedb.KeysResponse")
+	proto.RegisterType((*Condition)(nil), "sharedb.Condition")
+	proto.RegisterType((*Mutation)(nil), "sharedb.Mutation")
+	proto.RegisterType((*BatchRequest)(nil), "sharedb.BatchRequest")
+	proto.RegisterType((*BatchResponse)(nil), "sharedb.BatchResponse")
+	proto.RegisterType((*WatchRequest)(nil), "sharedb.WatchRequest")
+	proto.RegisterType((*WatchEvent)(nil), "sharedb.WatchEvent")
+	proto.RegisterEnum("sharedb.WatchEvent_Type", WatchEvent_Type_name, WatchEvent_Type_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -194,6 +323,12 @@ type ShareDBClient interface {
 	Databases(ctx context.Context, in *DatabasesRequest, opts ...grpc.CallOption) (*DatabasesResponse, error)
 	Buckets(ctx context.Context, in *BucketsRequest, opts ...grpc.CallOption) (*BucketsResponse, error)
 	Keys(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*KeysResponse, error)
+	// Batch atomically applies a set of puts and deletes to a database in a
+	// single transaction, if all of its conditions hold.
+	Batch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	// Watch streams the changes made to a database, optionally restricted to
+	// a bucket and a key prefix.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (ShareDB_WatchClient, error)
 }
 
 type shareDBClient struct {
@@ -258,6 +393,47 @@ func (c *shareDBClient) Keys(ctx context.Context, in *KeysRequest, opts ...grpc.
 	return out, nil
 }
 
+func (c *shareDBClient) Batch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	out := new(BatchResponse)
+	err := grpc.Invoke(ctx, "/sharedb.ShareDB/Batch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareDBClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (ShareDB_WatchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_ShareDB_serviceDesc.Streams[0], c.cc, "/sharedb.ShareDB/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &shareDBWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ShareDB_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type shareDBWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *shareDBWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for ShareDB service
 
 type ShareDBServer interface {
@@ -267,6 +443,12 @@ type ShareDBServer interface {
 	Databases(context.Context, *DatabasesRequest) (*DatabasesResponse, error)
 	Buckets(context.Context, *BucketsRequest) (*BucketsResponse, error)
 	Keys(context.Context, *KeysRequest) (*KeysResponse, error)
+	// Batch atomically applies a set of puts and deletes to a database in a
+	// single transaction, if all of its conditions hold.
+	Batch(context.Context, *BatchRequest) (*BatchResponse, error)
+	// Watch streams the changes made to a database, optionally restricted to
+	// a bucket and a key prefix.
+	Watch(*WatchRequest, ShareDB_WatchServer) error
 }
 
 func RegisterShareDBServer(s *grpc.Server, srv ShareDBServer) {
@@ -381,6 +563,45 @@ func _ShareDB_Keys_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ShareDB_Batch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareDBServer).Batch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sharedb.ShareDB/Batch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareDBServer).Batch(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareDB_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ShareDBServer).Watch(m, &shareDBWatchServer{stream})
+}
+
+type ShareDB_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type shareDBWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *shareDBWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _ShareDB_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "sharedb.ShareDB",
 	HandlerType: (*ShareDBServer)(nil),
@@ -409,36 +630,60 @@ var _ShareDB_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Keys",
 			Handler:    _ShareDB_Keys_Handler,
 		},
+		{
+			MethodName: "Batch",
+			Handler:    _ShareDB_Batch_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _ShareDB_Watch_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: fileDescriptor0,
 }
 
 func init() { proto.RegisterFile("sharedb.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 368 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x9c, 0x93, 0xcd, 0x4e, 0xfa, 0x40,
-	0x14, 0xc5, 0xff, 0x50, 0x3e, 0x4f, 0x81, 0x3f, 0x8c, 0x5f, 0xa5, 0x31, 0x46, 0xc7, 0x8d, 0x2b,
-	0x16, 0x18, 0x57, 0x24, 0x2c, 0x48, 0x13, 0x13, 0xdd, 0x10, 0x7d, 0x82, 0x56, 0xae, 0x91, 0x80,
-	0x14, 0x99, 0xd6, 0xc0, 0x33, 0xfb, 0x12, 0xb6, 0xd3, 0x61, 0x5a, 0xbe, 0x12, 0xc3, 0xf2, 0x9e,
-	0xce, 0xef, 0xdc, 0x3b, 0x73, 0x6e, 0x51, 0x17, 0x1f, 0xee, 0x82, 0x46, 0x5e, 0x67, 0xbe, 0xf0,
-	0x03, 0x9f, 0x95, 0x55, 0xc9, 0x7b, 0xc0, 0x23, 0x05, 0x2f, 0xf4, 0x15, 0x92, 0x08, 0x58, 0x13,
-	0x95, 0x91, 0x1b, 0xb8, 0x9e, 0x2b, 0xc8, 0xca, 0x5d, 0xe7, 0xee, 0xaa, 0xac, 0x81, 0x92, 0x17,
-	0xbe, 0x4d, 0x28, 0xb0, 0xf2, 0xb2, 0x36, 0x61, 0x4c, 0x68, 0x65, 0x19, 0x71, 0xc1, 0x2f, 0x61,
-	0x4a, 0x58, 0xcc, 0xfd, 0x99, 0x20, 0x56, 0x47, 0xf1, 0xdb, 0x9d, 0x86, 0x09, 0x5a, 0xe3, 0x4f,
-	0xc0, 0x30, 0x3c, 0xd2, 0x3a, 0xf5, 0x2a, 0x48, 0xaf, 0x36, 0x4c, 0xe9, 0xa5, 0x3a, 0x01, 0x79,
-	0x7f, 0x22, 0x6d, 0x2a, 0xbc, 0x8f, 0xba, 0x43, 0x53, 0x0a, 0xe8, 0xe8, 0x4b, 0x34, 0xd6, 0xfc,
-	0x1e, 0x77, 0x86, 0xa6, 0xa3, 0xcc, 0x84, 0x6a, 0xc0, 0x6f, 0xd1, 0xca, 0x68, 0x0a, 0x8a, 0x7a,
-	0xc8, 0x81, 0x45, 0x04, 0x1a, 0x91, 0x2d, 0x47, 0x63, 0x20, 0x7b, 0x8a, 0x83, 0x73, 0xf1, 0x1b,
-	0xfc, 0xd7, 0x67, 0x0e, 0xd8, 0x10, 0xcc, 0x67, 0x5a, 0x89, 0xbf, 0xdf, 0x2d, 0xaa, 0xe7, 0x0b,
-	0x7a, 0x1f, 0x2f, 0xd5, 0x43, 0xb6, 0x50, 0xfd, 0x1c, 0xcf, 0x86, 0x89, 0x54, 0xd0, 0x92, 0xbb,
-	0x54, 0x52, 0x51, 0x4e, 0x72, 0x85, 0x5a, 0xd2, 0x66, 0xff, 0x18, 0xdd, 0x9f, 0x3c, 0xca, 0xaf,
-	0xf1, 0xca, 0x38, 0x03, 0xd6, 0x85, 0x11, 0xa5, 0xce, 0x4e, 0x3a, 0xeb, 0x95, 0x4a, 0x17, 0xc8,
-	0x3e, 0xdd, 0x14, 0x13, 0x37, 0xfe, 0x2f, 0x66, 0xa2, 0xfc, 0x32, 0x4c, 0xba, 0x19, 0x19, 0x26,
-	0x13, 0x71, 0xc4, 0xf4, 0x50, 0x4a, 0x82, 0x61, 0xe7, 0xfa, 0xc4, 0x46, 0xd2, 0xf6, 0xc5, 0x8e,
-	0xae, 0x61, 0x07, 0x55, 0x9d, 0x11, 0x6b, 0xa7, 0xe7, 0xb6, 0xb2, 0xb4, 0xed, 0x7d, 0x9f, 0xb4,
-	0x4b, 0x1f, 0x65, 0x15, 0x10, 0x4b, 0x7b, 0x6d, 0xc6, 0x6a, 0x5b, 0xbb, 0x1f, 0x34, 0xff, 0x80,
-	0x42, 0xfc, 0xac, 0x2c, 0xbd, 0x62, 0x26, 0x4c, 0xfb, 0x6c, 0x4b, 0x5d, 0x63, 0x5e, 0x49, 0xfe,
-	0xa4, 0xf7, 0xbf, 0x01, 0x00, 0x00, 0xff, 0xff, 0x96, 0x4a, 0x11, 0x18, 0xb5, 0x03, 0x00, 0x00,
+	// 579 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x55, 0xdb, 0x6e, 0xd3, 0x4a,
+	0x14, 0x8d, 0xe3, 0x4b, 0xe2, 0x95, 0xc4, 0x27, 0x9d, 0x1e, 0x8a, 0x6b, 0x10, 0x2a, 0x03, 0xaa,
+	0xfa, 0x54, 0xa1, 0x20, 0x04, 0x52, 0xa5, 0x0a, 0x8a, 0x43, 0x25, 0x2e, 0x92, 0x55, 0x82, 0x2a,
+	0x1e, 0x9d, 0x64, 0x50, 0xa3, 0xb4, 0x76, 0xc8, 0x8c, 0xab, 0xe6, 0x57, 0xf8, 0x38, 0xbe, 0x05,
+	0x79, 0xec, 0x8c, 0x1d, 0x37, 0xa1, 0xa8, 0x8f, 0xb3, 0xbd, 0xd7, 0x65, 0xf6, 0xec, 0x95, 0xa0,
+	0xc3, 0x2f, 0xc2, 0x39, 0x1b, 0x0f, 0x0f, 0x67, 0xf3, 0x58, 0xc4, 0xa4, 0x91, 0x1f, 0xe9, 0x11,
+	0x70, 0xca, 0xc4, 0x19, 0xfb, 0x99, 0x30, 0x2e, 0x48, 0x17, 0xcd, 0x71, 0x28, 0xc2, 0x61, 0xc8,
+	0x99, 0xab, 0xed, 0x69, 0x07, 0x36, 0x71, 0x60, 0x0d, 0x93, 0xd1, 0x94, 0x09, 0xb7, 0x2e, 0xcf,
+	0x2d, 0xe8, 0x53, 0xb6, 0x70, 0xf5, 0xf4, 0x40, 0x1f, 0xa3, 0x25, 0xc1, 0x7c, 0x16, 0x47, 0x9c,
+	0x91, 0x0e, 0xcc, 0xeb, 0xf0, 0x32, 0xc9, 0xa0, 0x6d, 0xfa, 0x11, 0x08, 0x92, 0x7b, 0x52, 0x17,
+	0x5c, 0x86, 0xe4, 0xda, 0x45, 0x2b, 0x48, 0x0a, 0x25, 0xa0, 0x1e, 0x4f, 0x25, 0x4d, 0x93, 0x1e,
+	0xa3, 0xe3, 0xb3, 0x4b, 0x26, 0xd8, 0xbd, 0x2f, 0xe1, 0x2c, 0xf1, 0x6b, 0xd8, 0x09, 0xba, 0x7e,
+	0x4e, 0xc6, 0x73, 0x01, 0xfa, 0x0c, 0x5b, 0xa5, 0x5a, 0x0e, 0x72, 0x60, 0x49, 0xc3, 0xdc, 0xd5,
+	0xf6, 0xf4, 0x03, 0x9b, 0x52, 0x38, 0x27, 0x52, 0x93, 0x6f, 0xf4, 0x45, 0x9f, 0xe2, 0x3f, 0xd5,
+	0xb3, 0x81, 0x86, 0xa1, 0xf5, 0x89, 0x2d, 0xf8, 0xbf, 0xdf, 0xcd, 0x81, 0x35, 0x9b, 0xb3, 0x1f,
+	0x93, 0x9b, 0x7c, 0x90, 0x5b, 0xb0, 0xaf, 0x26, 0x51, 0x90, 0x95, 0x0c, 0x55, 0x0a, 0x6f, 0xf2,
+	0x92, 0x29, 0x9d, 0x3c, 0x41, 0x3b, 0x93, 0xd9, 0x60, 0xe3, 0x14, 0xf6, 0xfb, 0x38, 0x1a, 0x4f,
+	0xc4, 0x24, 0x8e, 0x4a, 0x92, 0x5a, 0x79, 0x9c, 0x4a, 0x3f, 0x1c, 0x72, 0x16, 0x09, 0xa9, 0xdf,
+	0xac, 0x3e, 0xe4, 0x07, 0x34, 0xbf, 0x24, 0x22, 0xbc, 0x9b, 0x47, 0xe1, 0x52, 0x9a, 0x76, 0xda,
+	0x3b, 0x96, 0xaf, 0x24, 0x79, 0x9a, 0x34, 0x42, 0xfb, 0x24, 0x14, 0xa3, 0x8b, 0xcd, 0x83, 0xd9,
+	0x07, 0x46, 0x4b, 0xcb, 0xdc, 0xad, 0xef, 0xe9, 0x07, 0xad, 0x1e, 0x39, 0x5c, 0xc6, 0xa0, 0xb8,
+	0xcd, 0x73, 0xd8, 0x57, 0xb9, 0x23, 0xee, 0xea, 0xb2, 0x6d, 0x4b, 0xb5, 0x2d, 0xbd, 0xd2, 0x47,
+	0xe8, 0xe4, 0x7a, 0x6b, 0x96, 0xe4, 0x2d, 0xda, 0xe7, 0x7f, 0x37, 0x73, 0xc7, 0x2b, 0xd1, 0x5f,
+	0x1a, 0x20, 0x29, 0xfa, 0xd7, 0x2c, 0x12, 0x64, 0x1f, 0x86, 0x58, 0xcc, 0x32, 0xb0, 0xd3, 0x73,
+	0x95, 0x9d, 0xa2, 0xe5, 0x70, 0xb0, 0x98, 0xb1, 0x15, 0xa1, 0x7a, 0x45, 0x48, 0x2f, 0xcf, 0xd4,
+	0x58, 0x9d, 0xa9, 0x29, 0xdf, 0x62, 0x1f, 0x86, 0x64, 0x69, 0x40, 0x0f, 0xbe, 0x0d, 0xba, 0x35,
+	0x02, 0x58, 0x7e, 0xff, 0x73, 0x7f, 0xd0, 0xef, 0x6a, 0xc4, 0x86, 0x79, 0xd6, 0x7f, 0xe7, 0x7f,
+	0xef, 0xd6, 0x7b, 0xbf, 0x75, 0x34, 0xbe, 0xa6, 0x0e, 0xfc, 0x13, 0xd2, 0x83, 0x7e, 0xca, 0x04,
+	0xd9, 0x56, 0x96, 0x8a, 0x5f, 0x0f, 0xef, 0xff, 0xd5, 0x62, 0x36, 0x28, 0x5a, 0x4b, 0x31, 0x41,
+	0x52, 0xc6, 0x04, 0xc9, 0x1a, 0x4c, 0x29, 0xdf, 0xb4, 0x46, 0x8e, 0x60, 0x65, 0xa9, 0x24, 0x3b,
+	0xaa, 0x63, 0x25, 0xe6, 0xde, 0xc3, 0x5b, 0x75, 0x05, 0xf6, 0x61, 0xab, 0x80, 0x92, 0xdd, 0xa2,
+	0xaf, 0x12, 0x64, 0xcf, 0x5b, 0xf7, 0x49, 0xb1, 0x1c, 0xa3, 0x91, 0xa7, 0x93, 0x14, 0x5a, 0xab,
+	0x99, 0xf6, 0xdc, 0xdb, 0x1f, 0x14, 0xfe, 0x15, 0x8c, 0x34, 0x53, 0xa4, 0xb8, 0x62, 0x29, 0xc9,
+	0xde, 0x83, 0x4a, 0x55, 0xc1, 0xde, 0xc0, 0x94, 0x9b, 0x46, 0x8a, 0x8e, 0xf2, 0xa6, 0x7b, 0x3b,
+	0xd5, 0xb2, 0x42, 0xbe, 0x86, 0x79, 0x5e, 0x41, 0x96, 0xd7, 0xd2, 0xdb, 0x5e, 0xb3, 0x47, 0xb4,
+	0xf6, 0x42, 0x1b, 0x5a, 0xf2, 0x4f, 0xe1, 0xe5, 0x9f, 0x01, 0x00, 0x7c, 0x26, 0x16, 0xd3, 0x25,
+	0x06, 0x00, 0x00,
 }
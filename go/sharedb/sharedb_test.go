@@ -5,6 +5,7 @@ import (
 	"net"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -127,3 +128,200 @@ func startServer(t *testing.T, serverImpl ShareDBServer) (*grpc.Server, *ShareDB
 
 	return nil, nil, fmt.Errorf("Unable to connect to server.")
 }
+
+func TestBatch(t *testing.T) {
+	serverImpl := NewServer(DATA_DIR)
+	defer util.RemoveAll(DATA_DIR)
+
+	grpcServer, client, err := startServer(t, serverImpl)
+	assert.NoError(t, err)
+	defer grpcServer.Stop()
+	defer func() { assert.NoError(t, client.Close()) }()
+
+	dbName := "database002"
+	ctx := context.Background()
+
+	// Conditions on keys that don't exist yet.
+	ok, err := client.NewTxn(dbName).
+		IfAbsent("b1", "k1").
+		Put("b1", "k1", []byte("v1")).
+		Put("b2", "k2", []byte("v2")).
+		Commit(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = client.NewTxn(dbName).
+		IfAbsent("b1", "k1").
+		Put("b1", "k1", []byte("other")).
+		Commit(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// A failed condition leaves all keys untouched.
+	ok, err = client.NewTxn(dbName).
+		IfEquals("b1", "k1", []byte("v1")).
+		IfEquals("b2", "k2", []byte("wrong")).
+		Put("b1", "k1", []byte("v1-new")).
+		Delete("b2", "k2").
+		Commit(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	resp, err := client.Get(ctx, &GetRequest{dbName, "b1", "k1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", string(resp.Value))
+	resp, err = client.Get(ctx, &GetRequest{dbName, "b2", "k2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(resp.Value))
+
+	ok, err = client.NewTxn(dbName).
+		IfEquals("b1", "k1", []byte("v1")).
+		IfEquals("b2", "k2", []byte("v2")).
+		Put("b1", "k1", []byte("v1-new")).
+		Delete("b2", "k2").
+		Commit(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	resp, err = client.Get(ctx, &GetRequest{dbName, "b1", "k1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "v1-new", string(resp.Value))
+	resp, err = client.Get(ctx, &GetRequest{dbName, "b2", "k2"})
+	assert.NoError(t, err)
+	assert.Nil(t, resp.Value)
+
+	// CompareAndSwap.
+	ok, err = client.CompareAndSwap(ctx, dbName, "b1", "k1", []byte("v1"), []byte("v1-cas"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = client.CompareAndSwap(ctx, dbName, "b1", "k1", []byte("v1-new"), []byte("v1-cas"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = client.CompareAndSwap(ctx, dbName, "b1", "k3", nil, []byte("v3"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Batches on databases that don't exist fail unless they write.
+	_, err = client.Batch(ctx, &BatchRequest{Database: "does-not-exist", Conditions: []*Condition{&Condition{Bucket: "b", Key: "k", Absent: true}}})
+	assert.Error(t, err)
+}
+
+func TestWatch(t *testing.T) {
+	serverImpl := NewServer(DATA_DIR)
+	defer util.RemoveAll(DATA_DIR)
+
+	grpcServer, client, err := startServer(t, serverImpl)
+	assert.NoError(t, err)
+	defer grpcServer.Stop()
+	defer func() { assert.NoError(t, client.Close()) }()
+
+	dbName := "database003"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	allCh, err := client.Subscribe(ctx, dbName, "", "")
+	assert.NoError(t, err)
+	prefixCh, err := client.Subscribe(ctx, dbName, "b1", "foo")
+	assert.NoError(t, err)
+
+	_, err = client.Put(ctx, &PutRequest{dbName, "b1", "foo1", []byte("1")})
+	assert.NoError(t, err)
+	_, err = client.Put(ctx, &PutRequest{dbName, "b1", "bar1", []byte("2")})
+	assert.NoError(t, err)
+	_, err = client.Put(ctx, &PutRequest{dbName, "b2", "foo2", []byte("3")})
+	assert.NoError(t, err)
+	_, err = client.Put(ctx, &PutRequest{"otherdb", "b1", "foo3", []byte("4")})
+	assert.NoError(t, err)
+	ok, err := client.NewTxn(dbName).Delete("b1", "foo1").Put("b1", "foo4", []byte("5")).Commit(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	// Deleting a key that doesn't exist is not a change.
+	_, err = client.Delete(ctx, &DeleteRequest{dbName, "b1", "foo1"})
+	assert.NoError(t, err)
+	_, err = client.Put(ctx, &PutRequest{dbName, "b1", "foo5", []byte("6")})
+	assert.NoError(t, err)
+
+	next := func(ch <-chan *WatchEvent) *WatchEvent {
+		select {
+		case ev := <-ch:
+			return ev
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "Timed out waiting for event.")
+		}
+		return nil
+	}
+	expAll := []*WatchEvent{
+		putEvent(dbName, "b1", "foo1", []byte("1")),
+		putEvent(dbName, "b1", "bar1", []byte("2")),
+		putEvent(dbName, "b2", "foo2", []byte("3")),
+		deleteEvent(dbName, "b1", "foo1"),
+		putEvent(dbName, "b1", "foo4", []byte("5")),
+		putEvent(dbName, "b1", "foo5", []byte("6")),
+	}
+	for _, exp := range expAll {
+		assert.Equal(t, exp, next(allCh))
+	}
+	expPrefix := []*WatchEvent{
+		putEvent(dbName, "b1", "foo1", []byte("1")),
+		deleteEvent(dbName, "b1", "foo1"),
+		putEvent(dbName, "b1", "foo4", []byte("5")),
+		putEvent(dbName, "b1", "foo5", []byte("6")),
+	}
+	for _, exp := range expPrefix {
+		assert.Equal(t, exp, next(prefixCh))
+	}
+
+	// Cancelling closes the channels.
+	cancel()
+	_, ok = <-allCh
+	assert.False(t, ok)
+	_, ok = <-prefixCh
+	assert.False(t, ok)
+}
+
+func TestWatchConcurrentWrites(t *testing.T) {
+	serverImpl := NewServer(DATA_DIR)
+	defer util.RemoveAll(DATA_DIR)
+
+	grpcServer, client, err := startServer(t, serverImpl)
+	assert.NoError(t, err)
+	defer grpcServer.Stop()
+	defer func() { assert.NoError(t, client.Close()) }()
+
+	dbName := "database004"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := client.Subscribe(ctx, dbName, "", "")
+	assert.NoError(t, err)
+
+	// Write the same key concurrently to the watched database and to one
+	// without watchers.
+	const N = 50
+	var wg sync.WaitGroup
+	for i := 0; i < N; i++ {
+		for _, db := range []string{dbName, "otherdb"} {
+			wg.Add(1)
+			go func(db string, i int) {
+				defer wg.Done()
+				_, err := client.Put(ctx, &PutRequest{db, "b1", "key", []byte(fmt.Sprintf("%d", i))})
+				assert.NoError(t, err)
+			}(db, i)
+		}
+	}
+	wg.Wait()
+
+	// The last event must match the value that was committed last.
+	var last *WatchEvent
+	for i := 0; i < N; i++ {
+		select {
+		case last = <-ch:
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "Timed out waiting for event.")
+		}
+	}
+	resp, err := client.Get(ctx, &GetRequest{dbName, "b1", "key"})
+	assert.NoError(t, err)
+	assert.Equal(t, resp.Value, last.Value)
+	resp, err = client.Get(ctx, &GetRequest{"otherdb", "b1", "key"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, 0, len(resp.Value))
+}
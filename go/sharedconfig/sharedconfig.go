@@ -41,6 +41,8 @@ type Config struct {
 	GitRepoDir string // Directory location for the repo.
 	GitRepoURL string // Git URL of the repo.
 	Ingesters  map[string]*IngesterConfig
+
+	GrandCentralURL string // If set, storage events are replayed from the event log of this grandcentral server.
 }
 
 // ConfigFromTomlFile parses a TOML file into a Config struct.
@@ -287,8 +287,13 @@ func (c *apiClient) TriggerTask(t *swarming.SwarmingRpcsNewTaskRequest) (*swarmi
 }
 
 func (c *apiClient) RetryTask(t *swarming.SwarmingRpcsTaskRequestMetadata) (*swarming.SwarmingRpcsTaskRequestMetadata, error) {
-	// Swarming API does not have a way to Retry commands. This was done
-	// intentionally by swarming-eng to reduce API surface.
+	return c.TriggerTask(retryRequest(t))
+}
+
+// retryRequest returns a request for a new task which is a retry of the given
+// task. Swarming API does not have a way to Retry commands. This was done
+// intentionally by swarming-eng to reduce API surface.
+func retryRequest(t *swarming.SwarmingRpcsTaskRequestMetadata) *swarming.SwarmingRpcsNewTaskRequest {
 	newReq := &swarming.SwarmingRpcsNewTaskRequest{}
 	newReq.Name = fmt.Sprintf("%s (retry)", t.Request.Name)
 	newReq.ParentTaskId = t.Request.ParentTaskId
@@ -300,7 +305,7 @@ func (c *apiClient) RetryTask(t *swarming.SwarmingRpcsTaskRequestMetadata) (*swa
 	newReq.User = t.Request.User
 	newReq.ForceSendFields = t.Request.ForceSendFields
 
-	newReq.Tags = t.Request.Tags
+	newReq.Tags = append([]string{}, t.Request.Tags...)
 	// Add retries tag. Increment it if it already exists.
 	foundRetriesTag := false
 	for i, tag := range newReq.Tags {
@@ -317,8 +322,7 @@ func (c *apiClient) RetryTask(t *swarming.SwarmingRpcsTaskRequestMetadata) (*swa
 	if !foundRetriesTag {
 		newReq.Tags = append(newReq.Tags, "retries:1")
 	}
-
-	return c.TriggerTask(newReq)
+	return newReq
 }
 
 func (c *apiClient) GetTask(id string) (*swarming.SwarmingRpcsTaskResult, error) {
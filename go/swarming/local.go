@@ -0,0 +1,888 @@
+package swarming
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/fileutil"
+	"go.skia.org/infra/go/util"
+
+	swarming "github.com/luci/luci-go/common/api/swarming/swarming/v1"
+)
+
+const (
+	// ISOLATED_OUTDIR is replaced in the command and arguments of tasks run by
+	// a LocalClient with the directory whose contents become the isolated
+	// output of the task, as is done by Swarming.
+	ISOLATED_OUTDIR = "${ISOLATED_OUTDIR}"
+
+	// LOCAL_ISOLATE_NAMESPACE is the namespace reported for isolates stored by
+	// a LocalClient.
+	LOCAL_ISOLATE_NAMESPACE = "local"
+
+	// Task states, as reported by the Swarming API.
+	TASK_STATE_BOT_DIED  = "BOT_DIED"
+	TASK_STATE_CANCELED  = "CANCELED"
+	TASK_STATE_COMPLETED = "COMPLETED"
+	TASK_STATE_EXPIRED   = "EXPIRED"
+	TASK_STATE_PENDING   = "PENDING"
+	TASK_STATE_RUNNING   = "RUNNING"
+	TASK_STATE_TIMED_OUT = "TIMED_OUT"
+
+	// Pseudo-states accepted by ListTasks.
+	TASK_STATE_ALL             = "ALL"
+	TASK_STATE_PENDING_RUNNING = "PENDING_RUNNING"
+
+	// How often a LocalClient checks for expired tasks and I/O timeouts.
+	LOCAL_POLL_PERIOD = 100 * time.Millisecond
+
+	// PUBSUB_URL is the Cloud Pub/Sub API endpoint which a LocalClient
+	// publishes task notifications to.
+	PUBSUB_URL = "https://pubsub.googleapis.com/v1/"
+
+	// PUBSUB_SCOPE is the OAuth scope needed to publish to PUBSUB_URL.
+	PUBSUB_SCOPE = "https://www.googleapis.com/auth/pubsub"
+)
+
+// LocalBot describes one of the fake bots of a LocalClient.
+type LocalBot struct {
+	Id         string              `json:"id"`
+	Dimensions map[string][]string `json:"dimensions"`
+}
+
+// ReadLocalBots reads a JSON list of LocalBots from the given file.
+func ReadLocalBots(filename string) ([]*LocalBot, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %s: %s", filename, err)
+	}
+	defer util.Close(f)
+	bots := []*LocalBot{}
+	if err := json.NewDecoder(f).Decode(&bots); err != nil {
+		return nil, fmt.Errorf("Failed to decode %s: %s", filename, err)
+	}
+	return bots, nil
+}
+
+// localBot is the state of a LocalBot.
+type localBot struct {
+	*LocalBot
+	// taskId is the ID of the task the bot is running, if any.
+	taskId string
+	// terminated is true if GracefullyShutdownBot was called for the bot.
+	terminated bool
+}
+
+// matches returns true if the bot has all of the given dimensions.
+func (b *localBot) matches(dims map[string]string) bool {
+	for k, v := range dims {
+		if !util.In(v, b.Dimensions[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// localTask is a task known to a LocalClient.
+type localTask struct {
+	request *swarming.SwarmingRpcsTaskRequest
+	result  *swarming.SwarmingRpcsTaskResult
+	created time.Time
+	// cancel is closed to kill the task while it is running.
+	cancel chan struct{}
+	// logFile holds the combined stdout and stderr of the task once started.
+	logFile string
+	// pubsubAuthToken is sent along with the Pub/Sub notification for the
+	// task. It is not part of the task's request metadata.
+	pubsubAuthToken string
+}
+
+// metadata returns a copy of the task's request and result.
+func (t *localTask) metadata() *swarming.SwarmingRpcsTaskRequestMetadata {
+	req := *t.request
+	res := *t.result
+	return &swarming.SwarmingRpcsTaskRequestMetadata{
+		Request:    &req,
+		TaskId:     res.TaskId,
+		TaskResult: &res,
+	}
+}
+
+// kill asks for the running task to be killed. Assumes the caller holds the
+// LocalClient's mutex.
+func (t *localTask) kill() {
+	select {
+	case <-t.cancel:
+	default:
+		close(t.cancel)
+	}
+}
+
+// LocalClient is an ApiClient which runs tasks as subprocesses on the local
+// machine, on a pool of fake bots with configurable dimensions. Isolated
+// inputs and outputs are stored in a local directory, see StoreIsolated. It
+// honors task priorities, dimensions, expiration, and execution and I/O
+// timeouts, and publishes Pub/Sub notifications when tasks finish, so that
+// code which uses Swarming can be exercised end-to-end without a Swarming
+// server.
+type LocalClient struct {
+	workDir    string
+	isolateDir string
+
+	// httpClient is used to publish Pub/Sub notifications to pubsubUrl.
+	httpClient *http.Client
+	pubsubUrl  string
+
+	bots  map[string]*localBot
+	tasks map[string]*localTask
+	// nextId is used to generate task IDs.
+	nextId int64
+	mtx    sync.Mutex
+
+	// wake is used to ask the dispatcher to look for tasks to run.
+	wake chan struct{}
+	stop chan struct{}
+	// running tracks the Go routines waiting for running tasks and sending
+	// notifications.
+	running sync.WaitGroup
+}
+
+// NewLocalClient returns a LocalClient which stores its data in workDir and
+// runs tasks on the given bots. Pub/Sub notifications are published using
+// httpClient, which needs the Pub/Sub scope. If httpClient is nil no
+// notifications are sent. Call Close to stop it.
+func NewLocalClient(workDir string, bots []*LocalBot, httpClient *http.Client) (*LocalClient, error) {
+	c := &LocalClient{
+		workDir:    workDir,
+		isolateDir: filepath.Join(workDir, "isolate"),
+		httpClient: httpClient,
+		pubsubUrl:  PUBSUB_URL,
+		bots:       make(map[string]*localBot, len(bots)),
+		tasks:      map[string]*localTask{},
+		nextId:     time.Now().UnixNano(),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+	}
+	for _, b := range bots {
+		if _, ok := c.bots[b.Id]; ok {
+			return nil, fmt.Errorf("Duplicate bot ID %s", b.Id)
+		}
+		c.bots[b.Id] = &localBot{LocalBot: b}
+	}
+	for _, d := range []string{c.isolateDir, filepath.Join(workDir, "tasks")} {
+		if _, err := fileutil.EnsureDirExists(d); err != nil {
+			return nil, err
+		}
+	}
+	go c.dispatch()
+	return c, nil
+}
+
+// Close kills all running tasks and stops the LocalClient.
+func (c *LocalClient) Close() error {
+	close(c.stop)
+	c.mtx.Lock()
+	for _, t := range c.tasks {
+		if t.result.State == TASK_STATE_RUNNING {
+			t.kill()
+		}
+	}
+	c.mtx.Unlock()
+	c.running.Wait()
+	return nil
+}
+
+// dispatch looks for tasks to run whenever it is woken and periodically, to
+// catch expired tasks.
+func (c *LocalClient) dispatch() {
+	ticker := time.NewTicker(LOCAL_POLL_PERIOD)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.wake:
+		case <-ticker.C:
+		}
+		c.schedule()
+	}
+}
+
+// poke wakes the dispatcher without blocking.
+func (c *LocalClient) poke() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// localTaskSlice sorts pending tasks by priority and then creation time.
+type localTaskSlice []*localTask
+
+func (s localTaskSlice) Len() int      { return len(s) }
+func (s localTaskSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s localTaskSlice) Less(i, j int) bool {
+	if s[i].request.Priority != s[j].request.Priority {
+		return s[i].request.Priority < s[j].request.Priority
+	}
+	return s[i].created.Before(s[j].created)
+}
+
+// schedule expires old pending tasks and starts pending tasks on free bots.
+func (c *LocalClient) schedule() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	select {
+	case <-c.stop:
+		return
+	default:
+	}
+
+	now := time.Now()
+	pending := localTaskSlice{}
+	for _, t := range c.tasks {
+		if t.result.State != TASK_STATE_PENDING {
+			continue
+		}
+		if t.request.ExpirationSecs > 0 && now.Sub(t.created) > time.Duration(t.request.ExpirationSecs)*time.Second {
+			t.result.State = TASK_STATE_EXPIRED
+			t.result.AbandonedTs = formatTimestamp(now)
+			t.result.ModifiedTs = t.result.AbandonedTs
+			c.notify(t)
+			continue
+		}
+		pending = append(pending, t)
+	}
+	sort.Sort(pending)
+
+	botIds := make([]string, 0, len(c.bots))
+	for id := range c.bots {
+		botIds = append(botIds, id)
+	}
+	sort.Strings(botIds)
+	for _, t := range pending {
+		dims := map[string]string{}
+		if t.request.Properties != nil {
+			for _, d := range t.request.Properties.Dimensions {
+				dims[d.Key] = d.Value
+			}
+		}
+		for _, id := range botIds {
+			b := c.bots[id]
+			if b.taskId == "" && !b.terminated && b.matches(dims) {
+				c.start(t, b, now)
+				break
+			}
+		}
+	}
+}
+
+// start runs the task on the bot. Assumes the caller holds c.mtx.
+func (c *LocalClient) start(t *localTask, b *localBot, now time.Time) {
+	b.taskId = t.result.TaskId
+	t.result.State = TASK_STATE_RUNNING
+	t.result.BotId = b.Id
+	t.result.StartedTs = formatTimestamp(now)
+	t.result.ModifiedTs = t.result.StartedTs
+	t.result.TryNumber = 1
+	t.cancel = make(chan struct{})
+	t.logFile = filepath.Join(c.workDir, "tasks", t.result.TaskId+".log")
+
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		state, exitCode, outputs, err := c.run(t, b)
+		if err != nil {
+			glog.Errorf("Local Swarming task %s failed: %s", t.result.TaskId, err)
+			appendToLog(t.logFile, err)
+		}
+		finished := time.Now()
+
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		t.result.State = state
+		t.result.CompletedTs = formatTimestamp(finished)
+		t.result.ModifiedTs = t.result.CompletedTs
+		t.result.Duration = finished.Sub(now).Seconds()
+		t.result.ExitCode = int64(exitCode)
+		t.result.Failure = state != TASK_STATE_COMPLETED || exitCode != 0
+		t.result.InternalFailure = state == TASK_STATE_BOT_DIED
+		if outputs != "" {
+			t.result.OutputsRef = &swarming.SwarmingRpcsFilesRef{
+				Isolated:       outputs,
+				Isolatedserver: "file://" + c.isolateDir,
+				Namespace:      LOCAL_ISOLATE_NAMESPACE,
+			}
+		}
+		b.taskId = ""
+		c.notify(t)
+		c.poke()
+	}()
+}
+
+// pubsubMessage is a message in a Pub/Sub publish request.
+type pubsubMessage struct {
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Data       string            `json:"data"`
+}
+
+// notify publishes the notification for a finished task to the task's Pub/Sub
+// topic, if any, as Swarming does. Assumes the caller holds c.mtx.
+func (c *LocalClient) notify(t *localTask) {
+	if c.httpClient == nil || t.request.PubsubTopic == "" {
+		return
+	}
+	topic := t.request.PubsubTopic
+	data, err := json.Marshal(map[string]string{
+		"task_id":  t.result.TaskId,
+		"userdata": t.request.PubsubUserdata,
+	})
+	if err != nil {
+		glog.Errorf("Failed to encode Pub/Sub notification for task %s: %s", t.result.TaskId, err)
+		return
+	}
+	msg := &pubsubMessage{
+		Data: base64.StdEncoding.EncodeToString(data),
+	}
+	if t.pubsubAuthToken != "" {
+		msg.Attributes = map[string]string{"auth_token": t.pubsubAuthToken}
+	}
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		if err := c.publish(topic, msg); err != nil {
+			glog.Errorf("Failed to send Pub/Sub notification for task %s: %s", t.result.TaskId, err)
+		}
+	}()
+}
+
+// publish sends the message to the given Pub/Sub topic.
+func (c *LocalClient) publish(topic string, msg *pubsubMessage) error {
+	body, err := json.Marshal(map[string][]*pubsubMessage{"messages": {msg}})
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Post(c.pubsubUrl+topic+":publish", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Failed to publish to %s: %s", topic, err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to publish to %s: %s", topic, resp.Status)
+	}
+	return nil
+}
+
+// run executes the task on the bot and waits for it to finish. Returns the
+// final state of the task, its exit code and the hash of its isolated
+// outputs, if any.
+func (c *LocalClient) run(t *localTask, b *localBot) (string, int, string, error) {
+	props := t.request.Properties
+	if props == nil {
+		return TASK_STATE_BOT_DIED, -1, "", fmt.Errorf("Task has no properties.")
+	}
+	taskDir := filepath.Join(c.workDir, "tasks", t.result.TaskId)
+	outDir := taskDir + "-out"
+	for _, d := range []string{taskDir, outDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return TASK_STATE_BOT_DIED, -1, "", err
+		}
+	}
+
+	// Set up the inputs and determine the command to run and its working
+	// directory.
+	command := props.Command
+	cwd := taskDir
+	if props.InputsRef != nil && props.InputsRef.Isolated != "" {
+		info, err := c.readIsolated(props.InputsRef.Isolated, taskDir)
+		if err != nil {
+			return TASK_STATE_BOT_DIED, -1, "", err
+		}
+		if len(command) == 0 {
+			command = info.Command
+		}
+		cwd = filepath.Join(taskDir, info.RelativeCwd)
+	}
+	command = append(append([]string{}, command...), props.ExtraArgs...)
+	if len(command) == 0 {
+		return TASK_STATE_BOT_DIED, -1, "", fmt.Errorf("Task has no command.")
+	}
+	for i, arg := range command {
+		command[i] = strings.Replace(arg, ISOLATED_OUTDIR, outDir, -1)
+	}
+
+	env := append(os.Environ(),
+		"SWARMING_BOT_ID="+b.Id,
+		"SWARMING_TASK_ID="+t.result.TaskId,
+		"ISOLATED_OUTDIR="+outDir,
+	)
+	for _, e := range props.Env {
+		env = append(env, fmt.Sprintf("%s=%s", e.Key, e.Value))
+	}
+
+	log, err := os.Create(t.logFile)
+	if err != nil {
+		return TASK_STATE_BOT_DIED, -1, "", err
+	}
+	defer util.Close(log)
+	// Run the task in its own process group, so that the whole group can be
+	// killed. Otherwise children of the task would be left behind.
+	cmd := osexec.Command(command[0], command[1:]...)
+	cmd.Dir = cwd
+	cmd.Env = env
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(log, "%s\n", err)
+		return TASK_STATE_BOT_DIED, -1, "", fmt.Errorf("Failed to start %q: %s", command, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var hardTimeout <-chan time.Time
+	if props.ExecutionTimeoutSecs > 0 {
+		hardTimeout = time.After(time.Duration(props.ExecutionTimeoutSecs) * time.Second)
+	}
+	ioTimeout := time.Duration(props.IoTimeoutSecs) * time.Second
+	lastOutput := time.Now()
+	var lastSize int64
+	ticker := time.NewTicker(LOCAL_POLL_PERIOD)
+	defer ticker.Stop()
+
+	// kill stops the process group and waits for the process to exit.
+	kill := func() {
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			glog.Errorf("Failed to kill task %s: %s", t.result.TaskId, err)
+		}
+		<-done
+	}
+	for {
+		select {
+		case err := <-done:
+			exitCode := 0
+			if err != nil {
+				exitErr, ok := err.(*osexec.ExitError)
+				if !ok {
+					return TASK_STATE_BOT_DIED, -1, "", err
+				}
+				exitCode = exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+			}
+			outputs, err := c.storeOutputs(outDir)
+			if err != nil {
+				return TASK_STATE_BOT_DIED, exitCode, "", err
+			}
+			return TASK_STATE_COMPLETED, exitCode, outputs, nil
+		case <-hardTimeout:
+			kill()
+			fmt.Fprintf(log, "\nTask exceeded execution timeout of %ds.\n", props.ExecutionTimeoutSecs)
+			return TASK_STATE_TIMED_OUT, -1, "", nil
+		case <-ticker.C:
+			if ioTimeout == 0 {
+				continue
+			}
+			fi, err := log.Stat()
+			if err != nil {
+				glog.Errorf("Failed to stat %s: %s", t.logFile, err)
+				continue
+			}
+			if fi.Size() != lastSize {
+				lastSize = fi.Size()
+				lastOutput = time.Now()
+			} else if time.Since(lastOutput) > ioTimeout {
+				kill()
+				fmt.Fprintf(log, "\nTask exceeded I/O timeout of %ds.\n", props.IoTimeoutSecs)
+				return TASK_STATE_TIMED_OUT, -1, "", nil
+			}
+		case <-t.cancel:
+			kill()
+			return TASK_STATE_CANCELED, -1, "", nil
+		}
+	}
+}
+
+// appendToLog records an error which prevented a task from running in its
+// log file.
+func appendToLog(logFile string, taskErr error) {
+	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		glog.Errorf("Failed to open %s: %s", logFile, err)
+		return
+	}
+	defer util.Close(f)
+	fmt.Fprintf(f, "%s\n", taskErr)
+}
+
+// storeOutputs stores the contents of the output directory of a task as an
+// isolate, and returns its hash, or "" if the directory is empty.
+func (c *LocalClient) storeOutputs(outDir string) (string, error) {
+	infos, err := ioutil.ReadDir(outDir)
+	if err != nil {
+		return "", err
+	}
+	if len(infos) == 0 {
+		return "", nil
+	}
+	return c.StoreIsolated(outDir, nil, "")
+}
+
+// isolatedInfo is the metadata stored alongside the files of an isolate.
+type isolatedInfo struct {
+	Command     []string `json:"command"`
+	RelativeCwd string   `json:"relative_cwd"`
+}
+
+// StoreIsolated copies the contents of dir into the local isolate store and
+// returns its hash, which can be used as the InputsRef.Isolated of a task.
+// When the task runs the files are copied into its task directory and, if
+// the task doesn't specify a command, the given command is run in the
+// relativeCwd subdirectory of the task directory.
+func (c *LocalClient) StoreIsolated(dir string, command []string, relativeCwd string) (string, error) {
+	// Hash the relative paths, modes and contents of all files, the command
+	// and its working directory.
+	h := sha1.New()
+	if err := json.NewEncoder(h).Encode(&isolatedInfo{Command: command, RelativeCwd: relativeCwd}); err != nil {
+		return "", err
+	}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\n%o\n", rel, info.Mode())
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer util.Close(f)
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to hash %s: %s", dir, err)
+	}
+	hash := fmt.Sprintf("%x", h.Sum(nil))
+
+	dest := c.IsolatedPath(hash)
+	if fileutil.FileExists(dest) {
+		return hash, nil
+	}
+	if err := copyTree(dir, dest); err != nil {
+		util.RemoveAll(dest)
+		return "", fmt.Errorf("Failed to store %s: %s", dir, err)
+	}
+	b, err := json.Marshal(&isolatedInfo{Command: command, RelativeCwd: relativeCwd})
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(dest+".json", b, 0644); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// IsolatedPath returns the directory holding the files of the given isolate,
+// eg. the isolated outputs of a task.
+func (c *LocalClient) IsolatedPath(hash string) string {
+	return filepath.Join(c.isolateDir, hash)
+}
+
+// readIsolated copies the files of the given isolate into dest and returns its
+// metadata.
+func (c *LocalClient) readIsolated(hash, dest string) (*isolatedInfo, error) {
+	src := c.IsolatedPath(hash)
+	b, err := ioutil.ReadFile(src + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("Unknown isolated input %s: %s", hash, err)
+	}
+	info := &isolatedInfo{}
+	if err := json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("Invalid isolated input %s: %s", hash, err)
+	}
+	if err := copyTree(src, dest); err != nil {
+		return nil, fmt.Errorf("Failed to copy isolated input %s: %s", hash, err)
+	}
+	return info, nil
+}
+
+// copyTree copies the files and directories in src into dest.
+func copyTree(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer util.Close(in)
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			util.Close(out)
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// formatTimestamp formats the time as the Swarming API does.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(TIMESTAMP_FORMAT)
+}
+
+// TaskOutput returns the combined stdout and stderr of the given task so far.
+func (c *LocalClient) TaskOutput(id string) (string, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t, ok := c.tasks[id]
+	if !ok {
+		return "", fmt.Errorf("No such task: %s", id)
+	}
+	if t.logFile == "" {
+		return "", nil
+	}
+	b, err := ioutil.ReadFile(t.logFile)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read output of task %s: %s", id, err)
+	}
+	return string(b), nil
+}
+
+func (c *LocalClient) SwarmingService() *swarming.Service {
+	return nil
+}
+
+func (c *LocalClient) ListBots(dimensions map[string]string) ([]*swarming.SwarmingRpcsBotInfo, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := formatTimestamp(time.Now())
+	rv := make([]*swarming.SwarmingRpcsBotInfo, 0, len(c.bots))
+	for _, b := range c.bots {
+		if b.terminated && b.taskId == "" {
+			continue
+		}
+		if !b.matches(dimensions) {
+			continue
+		}
+		keys := make([]string, 0, len(b.Dimensions))
+		for k := range b.Dimensions {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dims := make([]*swarming.SwarmingRpcsStringListPair, 0, len(keys))
+		for _, k := range keys {
+			dims = append(dims, &swarming.SwarmingRpcsStringListPair{
+				Key:   k,
+				Value: b.Dimensions[k],
+			})
+		}
+		info := &swarming.SwarmingRpcsBotInfo{
+			BotId:      b.Id,
+			Dimensions: dims,
+			LastSeenTs: now,
+			TaskId:     b.taskId,
+		}
+		if t, ok := c.tasks[b.taskId]; ok {
+			info.TaskName = t.request.Name
+		}
+		rv = append(rv, info)
+	}
+	return rv, nil
+}
+
+func (c *LocalClient) ListSkiaBots() ([]*swarming.SwarmingRpcsBotInfo, error) {
+	return c.ListBots(map[string]string{
+		DIMENSION_POOL_KEY: DIMENSION_POOL_VALUE_SKIA,
+	})
+}
+
+func (c *LocalClient) ListSkiaTriggerBots() ([]*swarming.SwarmingRpcsBotInfo, error) {
+	return c.ListBots(map[string]string{
+		DIMENSION_POOL_KEY: DIMENSION_POOL_VALUE_SKIA_TRIGGERS,
+	})
+}
+
+func (c *LocalClient) ListCTBots() ([]*swarming.SwarmingRpcsBotInfo, error) {
+	return c.ListBots(map[string]string{
+		DIMENSION_POOL_KEY: DIMENSION_POOL_VALUE_CT,
+	})
+}
+
+// GracefullyShutdownBot stops the bot from taking new tasks. It disappears
+// from ListBots once it has finished its current task.
+func (c *LocalClient) GracefullyShutdownBot(id string) (*swarming.SwarmingRpcsTerminateResponse, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	b, ok := c.bots[id]
+	if !ok {
+		return nil, fmt.Errorf("No such bot: %s", id)
+	}
+	b.terminated = true
+	return &swarming.SwarmingRpcsTerminateResponse{
+		TaskId: c.newId(),
+	}, nil
+}
+
+func (c *LocalClient) ListTasks(start, end time.Time, tags []string, state string) ([]*swarming.SwarmingRpcsTaskRequestMetadata, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	rv := make([]*swarming.SwarmingRpcsTaskRequestMetadata, 0, len(c.tasks))
+	tagSet := util.NewStringSet(tags)
+	for _, t := range c.tasks {
+		if !start.IsZero() && t.created.Before(start) {
+			continue
+		}
+		if !end.IsZero() && t.created.After(end) {
+			continue
+		}
+		if len(tagSet.Intersect(util.NewStringSet(t.request.Tags))) != len(tagSet) {
+			continue
+		}
+		switch state {
+		case "", TASK_STATE_ALL:
+		case TASK_STATE_PENDING_RUNNING:
+			if t.result.State != TASK_STATE_PENDING && t.result.State != TASK_STATE_RUNNING {
+				continue
+			}
+		default:
+			if t.result.State != state {
+				continue
+			}
+		}
+		rv = append(rv, t.metadata())
+	}
+	return rv, nil
+}
+
+func (c *LocalClient) ListSkiaTasks(start, end time.Time) ([]*swarming.SwarmingRpcsTaskRequestMetadata, error) {
+	return c.ListTasks(start, end, []string{"pool:Skia"}, "")
+}
+
+// CancelTask cancels a pending task or kills a running one.
+func (c *LocalClient) CancelTask(id string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t, ok := c.tasks[id]
+	if !ok {
+		return fmt.Errorf("No such task: %s", id)
+	}
+	switch t.result.State {
+	case TASK_STATE_PENDING:
+		t.result.State = TASK_STATE_CANCELED
+		t.result.AbandonedTs = formatTimestamp(time.Now())
+		t.result.ModifiedTs = t.result.AbandonedTs
+		c.notify(t)
+	case TASK_STATE_RUNNING:
+		t.kill()
+	default:
+		return fmt.Errorf("Could not cancel task %s in state %s", id, t.result.State)
+	}
+	return nil
+}
+
+// newId returns a new unique task ID. Assumes the caller holds c.mtx.
+func (c *LocalClient) newId() string {
+	c.nextId++
+	return fmt.Sprintf("%x0", c.nextId)
+}
+
+func (c *LocalClient) TriggerTask(t *swarming.SwarmingRpcsNewTaskRequest) (*swarming.SwarmingRpcsTaskRequestMetadata, error) {
+	if t.Properties == nil {
+		return nil, fmt.Errorf("Task request has no properties.")
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := time.Now()
+	createdTs := formatTimestamp(now)
+	id := c.newId()
+	task := &localTask{
+		request: &swarming.SwarmingRpcsTaskRequest{
+			CreatedTs:      createdTs,
+			ExpirationSecs: t.ExpirationSecs,
+			Name:           t.Name,
+			ParentTaskId:   t.ParentTaskId,
+			Priority:       t.Priority,
+			Properties:     t.Properties,
+			PubsubTopic:    t.PubsubTopic,
+			PubsubUserdata: t.PubsubUserdata,
+			Tags:           t.Tags,
+			User:           t.User,
+		},
+		result: &swarming.SwarmingRpcsTaskResult{
+			CreatedTs:  createdTs,
+			ModifiedTs: createdTs,
+			Name:       t.Name,
+			State:      TASK_STATE_PENDING,
+			Tags:       t.Tags,
+			TaskId:     id,
+			User:       t.User,
+		},
+		created:         now,
+		pubsubAuthToken: t.PubsubAuthToken,
+	}
+	c.tasks[id] = task
+	c.poke()
+	return task.metadata(), nil
+}
+
+func (c *LocalClient) RetryTask(t *swarming.SwarmingRpcsTaskRequestMetadata) (*swarming.SwarmingRpcsTaskRequestMetadata, error) {
+	return c.TriggerTask(retryRequest(t))
+}
+
+func (c *LocalClient) GetTask(id string) (*swarming.SwarmingRpcsTaskResult, error) {
+	m, err := c.GetTaskMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+	return m.TaskResult, nil
+}
+
+func (c *LocalClient) GetTaskMetadata(id string) (*swarming.SwarmingRpcsTaskRequestMetadata, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t, ok := c.tasks[id]
+	if !ok {
+		return nil, fmt.Errorf("No such task: %s", id)
+	}
+	return t.metadata(), nil
+}
+
+// Make sure LocalClient fulfills the ApiClient interface.
+var _ ApiClient = (*LocalClient)(nil)
@@ -0,0 +1,300 @@
+package swarming
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	swarming "github.com/luci/luci-go/common/api/swarming/swarming/v1"
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+func setupLocalClient(t *testing.T, bots []*LocalBot) (*LocalClient, func()) {
+	workDir, err := ioutil.TempDir("", "local_swarming_")
+	assert.NoError(t, err)
+	c, err := NewLocalClient(workDir, bots, http.DefaultClient)
+	assert.NoError(t, err)
+	return c, func() {
+		assert.NoError(t, c.Close())
+		testutils.RemoveAll(t, workDir)
+	}
+}
+
+func newLocalTask(name string, dims map[string]string, cmd ...string) *swarming.SwarmingRpcsNewTaskRequest {
+	dimensions := []*swarming.SwarmingRpcsStringPair{}
+	for k, v := range dims {
+		dimensions = append(dimensions, &swarming.SwarmingRpcsStringPair{Key: k, Value: v})
+	}
+	return &swarming.SwarmingRpcsNewTaskRequest{
+		Name:           name,
+		ExpirationSecs: 60,
+		Priority:       100,
+		Properties: &swarming.SwarmingRpcsTaskProperties{
+			Command:              cmd,
+			Dimensions:           dimensions,
+			ExecutionTimeoutSecs: 60,
+		},
+		Tags: []string{"name:" + name},
+	}
+}
+
+// waitForState waits for the task to reach the given state.
+func waitForState(t *testing.T, c *LocalClient, id, state string) *swarming.SwarmingRpcsTaskResult {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		res, err := c.GetTask(id)
+		assert.NoError(t, err)
+		if res.State == state {
+			return res
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Task %s is in state %s, not %s.", id, res.State, state)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestLocalClientRun(t *testing.T) {
+	testutils.SkipIfShort(t)
+	c, cleanup := setupLocalClient(t, []*LocalBot{
+		{Id: "bot-linux", Dimensions: map[string][]string{"os": {"Linux"}, "pool": {"Skia"}}},
+	})
+	defer cleanup()
+
+	// Store an isolated input with a script which writes an output file. The
+	// script runs in the directory it is in.
+	inputDir, err := ioutil.TempDir("", "local_swarming_input_")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, inputDir)
+	assert.NoError(t, os.Mkdir(filepath.Join(inputDir, "bin"), 0755))
+	script := "#!/bin/sh\necho \"$1 $SWARMING_BOT_ID $MYVAR\"\necho result > $2/out.txt\nexit 3\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(inputDir, "bin", "run.sh"), []byte(script), 0755))
+	hash, err := c.StoreIsolated(inputDir, []string{"./run.sh"}, "bin")
+	assert.NoError(t, err)
+
+	req := newLocalTask("my-task", map[string]string{"os": "Linux"})
+	req.Properties.InputsRef = &swarming.SwarmingRpcsFilesRef{Isolated: hash}
+	req.Properties.ExtraArgs = []string{"hello", ISOLATED_OUTDIR}
+	req.Properties.Env = []*swarming.SwarmingRpcsStringPair{{Key: "MYVAR", Value: "world"}}
+	m, err := c.TriggerTask(req)
+	assert.NoError(t, err)
+	assert.Equal(t, TASK_STATE_PENDING, m.TaskResult.State)
+
+	res := waitForState(t, c, m.TaskId, TASK_STATE_COMPLETED)
+	assert.Equal(t, "bot-linux", res.BotId)
+	assert.Equal(t, int64(3), res.ExitCode)
+	assert.True(t, res.Failure)
+	assert.False(t, res.InternalFailure)
+	assert.NotEqual(t, "", res.CompletedTs)
+	out, err := c.TaskOutput(m.TaskId)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello bot-linux world\n", out)
+
+	assert.NotNil(t, res.OutputsRef)
+	b, err := ioutil.ReadFile(filepath.Join(c.IsolatedPath(res.OutputsRef.Isolated), "out.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "result\n", string(b))
+
+	// Retry the task.
+	meta, err := c.GetTaskMetadata(m.TaskId)
+	assert.NoError(t, err)
+	retry, err := c.RetryTask(meta)
+	assert.NoError(t, err)
+	assert.Equal(t, "my-task (retry)", retry.Request.Name)
+	assert.Equal(t, []string{"name:my-task", "retries:1"}, retry.Request.Tags)
+	waitForState(t, c, retry.TaskId, TASK_STATE_COMPLETED)
+
+	tasks, err := c.ListTasks(time.Time{}, time.Time{}, []string{"retries:1"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, retry.TaskId, tasks[0].TaskId)
+	tasks, err = c.ListTasks(time.Time{}, time.Time{}, []string{"name:my-task"}, TASK_STATE_COMPLETED)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(tasks))
+	tasks, err = c.ListTasks(time.Time{}, time.Time{}, nil, TASK_STATE_PENDING_RUNNING)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(tasks))
+}
+
+func TestLocalClientScheduling(t *testing.T) {
+	testutils.SkipIfShort(t)
+	c, cleanup := setupLocalClient(t, []*LocalBot{
+		{Id: "bot-linux", Dimensions: map[string][]string{"os": {"Linux"}, "pool": {"Skia"}}},
+		{Id: "bot-mac", Dimensions: map[string][]string{"os": {"Mac"}, "pool": {"Skia"}}},
+	})
+	defer cleanup()
+
+	// Occupy the Linux bot.
+	long, err := c.TriggerTask(newLocalTask("long", map[string]string{"os": "Linux"}, "sleep", "60"))
+	assert.NoError(t, err)
+	res := waitForState(t, c, long.TaskId, TASK_STATE_RUNNING)
+	assert.Equal(t, "bot-linux", res.BotId)
+
+	bots, err := c.ListBots(map[string]string{"os": "Linux"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bots))
+	assert.Equal(t, long.TaskId, bots[0].TaskId)
+	assert.Equal(t, "long", bots[0].TaskName)
+	bots, err = c.ListSkiaBots()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bots))
+
+	// Tasks which can't be scheduled stay pending until they expire.
+	noBot := newLocalTask("no-bot", map[string]string{"os": "Windows"}, "true")
+	noBot.ExpirationSecs = 1
+	expired, err := c.TriggerTask(noBot)
+	assert.NoError(t, err)
+	pending, err := c.TriggerTask(newLocalTask("pending", map[string]string{"os": "Linux"}, "true"))
+	assert.NoError(t, err)
+	res = waitForState(t, c, expired.TaskId, TASK_STATE_EXPIRED)
+	assert.NotEqual(t, "", res.AbandonedTs)
+	res, err = c.GetTask(pending.TaskId)
+	assert.NoError(t, err)
+	assert.Equal(t, TASK_STATE_PENDING, res.State)
+
+	// Cancel the pending task and kill the running one.
+	assert.NoError(t, c.CancelTask(pending.TaskId))
+	waitForState(t, c, pending.TaskId, TASK_STATE_CANCELED)
+	assert.NoError(t, c.CancelTask(long.TaskId))
+	res = waitForState(t, c, long.TaskId, TASK_STATE_CANCELED)
+	assert.True(t, res.Failure)
+	assert.Error(t, c.CancelTask(long.TaskId))
+
+	// Higher priority (lower value) tasks run first.
+	_, err = c.TriggerTask(newLocalTask("block", map[string]string{"os": "Mac"}, "sleep", "1"))
+	assert.NoError(t, err)
+	low := newLocalTask("low", map[string]string{"os": "Mac"}, "true")
+	low.Priority = 200
+	lowMeta, err := c.TriggerTask(low)
+	assert.NoError(t, err)
+	high := newLocalTask("high", map[string]string{"os": "Mac"}, "true")
+	high.Priority = 10
+	highMeta, err := c.TriggerTask(high)
+	assert.NoError(t, err)
+	lowRes := waitForState(t, c, lowMeta.TaskId, TASK_STATE_COMPLETED)
+	highRes := waitForState(t, c, highMeta.TaskId, TASK_STATE_COMPLETED)
+	lowStart, err := ParseTimestamp(lowRes.StartedTs)
+	assert.NoError(t, err)
+	highStart, err := ParseTimestamp(highRes.StartedTs)
+	assert.NoError(t, err)
+	assert.True(t, highStart.Before(lowStart))
+
+	// A terminated bot disappears once idle.
+	_, err = c.GracefullyShutdownBot("bot-mac")
+	assert.NoError(t, err)
+	bots, err = c.ListSkiaBots()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bots))
+	assert.Equal(t, "bot-linux", bots[0].BotId)
+}
+
+func TestLocalClientTimeouts(t *testing.T) {
+	testutils.SkipIfShort(t)
+	c, cleanup := setupLocalClient(t, []*LocalBot{
+		{Id: "bot-1", Dimensions: map[string][]string{"pool": {"Skia"}}},
+		{Id: "bot-2", Dimensions: map[string][]string{"pool": {"Skia"}}},
+	})
+	defer cleanup()
+
+	hard := newLocalTask("hard", nil, "sleep", "60")
+	hard.Properties.ExecutionTimeoutSecs = 1
+	hardMeta, err := c.TriggerTask(hard)
+	assert.NoError(t, err)
+
+	io := newLocalTask("io", nil, "sh", "-c", "echo start; sleep 60")
+	io.Properties.IoTimeoutSecs = 1
+	ioMeta, err := c.TriggerTask(io)
+	assert.NoError(t, err)
+
+	res := waitForState(t, c, hardMeta.TaskId, TASK_STATE_TIMED_OUT)
+	assert.True(t, res.Failure)
+	res = waitForState(t, c, ioMeta.TaskId, TASK_STATE_TIMED_OUT)
+	assert.True(t, res.Failure)
+	out, err := c.TaskOutput(ioMeta.TaskId)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "start")
+
+	// Unknown isolated inputs are an internal failure.
+	bad := newLocalTask("bad", nil)
+	bad.Properties.InputsRef = &swarming.SwarmingRpcsFilesRef{Isolated: "deadbeef"}
+	badMeta, err := c.TriggerTask(bad)
+	assert.NoError(t, err)
+	res = waitForState(t, c, badMeta.TaskId, TASK_STATE_BOT_DIED)
+	assert.True(t, res.InternalFailure)
+}
+
+func TestLocalClientPubsub(t *testing.T) {
+	testutils.SkipIfShort(t)
+	c, cleanup := setupLocalClient(t, []*LocalBot{
+		{Id: "bot-1", Dimensions: map[string][]string{"pool": {"Skia"}}},
+	})
+	defer cleanup()
+
+	type notification struct {
+		path string
+		msg  *pubsubMessage
+	}
+	notifications := make(chan *notification, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string][]*pubsubMessage{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, 1, len(req["messages"]))
+		notifications <- &notification{path: r.URL.Path, msg: req["messages"][0]}
+	}))
+	defer server.Close()
+	c.pubsubUrl = server.URL + "/"
+
+	req := newLocalTask("notify", nil, "true")
+	req.PubsubTopic = "projects/my-project/topics/tasks"
+	req.PubsubUserdata = "my-data"
+	req.PubsubAuthToken = "my-token"
+	m, err := c.TriggerTask(req)
+	assert.NoError(t, err)
+	// Tasks without a topic don't send notifications.
+	_, err = c.TriggerTask(newLocalTask("silent", nil, "true"))
+	assert.NoError(t, err)
+
+	select {
+	case n := <-notifications:
+		assert.Equal(t, "/projects/my-project/topics/tasks:publish", n.path)
+		assert.Equal(t, map[string]string{"auth_token": "my-token"}, n.msg.Attributes)
+		data, err := base64.StdEncoding.DecodeString(n.msg.Data)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"task_id":"`+m.TaskId+`","userdata":"my-data"}`, string(data))
+	case <-time.After(10 * time.Second):
+		t.Fatalf("No notification for task %s.", m.TaskId)
+	}
+	res, err := c.GetTask(m.TaskId)
+	assert.NoError(t, err)
+	assert.Equal(t, TASK_STATE_COMPLETED, res.State)
+	select {
+	case n := <-notifications:
+		t.Fatalf("Unexpected notification: %v", n)
+	case <-time.After(time.Second):
+	}
+}
+
+func TestReadLocalBots(t *testing.T) {
+	f, err := ioutil.TempFile("", "local_bots_")
+	assert.NoError(t, err)
+	defer testutils.Remove(t, f.Name())
+	_, err = f.WriteString(`[{"id": "bot-1", "dimensions": {"os": ["Linux", "Ubuntu"]}}]`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	bots, err := ReadLocalBots(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bots))
+	assert.Equal(t, "bot-1", bots[0].Id)
+	assert.Equal(t, []string{"Linux", "Ubuntu"}, bots[0].Dimensions["os"])
+
+	_, err = ReadLocalBots(filepath.Join(os.TempDir(), "no_such_file"))
+	assert.Error(t, err)
+}
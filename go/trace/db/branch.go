@@ -10,9 +10,9 @@ import (
 
 	"github.com/golang/groupcache/lru"
 	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/codereview"
 	"go.skia.org/infra/go/eventbus"
 	"go.skia.org/infra/go/gitinfo"
-	"go.skia.org/infra/go/rietveld"
 	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/go/vcsinfo"
 )
@@ -30,8 +30,10 @@ type CommitIDLong struct {
 	Author string `json:"author"`
 	Desc   string `json:"desc"`
 
-	// Details contains the related information either from git or rietveld.
-	// A nil value means Rietveld failed to respond or the issue was not available.
+	// Details contains the related information either from git, as a
+	// *vcsinfo.LongCommit, or from the code review system, as a
+	// *codereview.Issue. A nil value means the code review system failed to
+	// respond or the issue was not available.
 	Details interface{} `json:"-"`
 }
 
@@ -59,12 +61,12 @@ type cachedTile struct {
 type tileBuilder struct {
 	db        DB
 	vcs       vcsinfo.VCS
-	review    *rietveld.Rietveld
+	review    codereview.CodeReview
 	reviewURL string
 
-	// cache is a cache for rietveld.Issue's. Note that gitinfo has its own cache
+	// cache is a cache for codereview.Issue's. Note that gitinfo has its own cache
 	// for Details(), so we don't need to cache the results.
-	issueCache *rietveld.CodeReviewCache
+	issueCache *codereview.IssueCache
 
 	// tcache is a cache for tiles built from CachedTileFromCommits, it stores 'cachedTile's.
 	tcache *lru.Cache
@@ -78,13 +80,13 @@ type tileBuilder struct {
 // querying db.
 //
 // TODO(stephana): The EventBus is used to update the internal cache as commits are updated.
-func NewBranchTileBuilder(db DB, git *gitinfo.GitInfo, review *rietveld.Rietveld, evt *eventbus.EventBus) BranchTileBuilder {
+func NewBranchTileBuilder(db DB, git *gitinfo.GitInfo, review codereview.CodeReview, evt *eventbus.EventBus) BranchTileBuilder {
 	return &tileBuilder{
 		db:         db,
 		vcs:        git,
 		review:     review,
 		reviewURL:  review.Url(),
-		issueCache: rietveld.NewCodeReviewCache(review, time.Minute, MAX_ISSUE_CACHE_SIZE),
+		issueCache: codereview.NewIssueCache(review, time.Minute, MAX_ISSUE_CACHE_SIZE),
 		tcache:     lru.New(MAX_TILE_CACHE_SIZE),
 	}
 }
@@ -184,15 +186,15 @@ func (b *tileBuilder) convertToLongCommits(commitIDs []*CommitID, source string)
 		})
 	}
 
-	// Populate Author and Desc from gitinfo or rietveld as appropriate.
-	// Caching Rietveld info as needed.
+	// Populate Author and Desc from gitinfo or the code review system as
+	// appropriate. Caching code review info as needed.
 	for _, c := range results {
 		if strings.HasPrefix(c.Source, b.reviewURL) {
-			// Rietveld
+			// Code review.
 			issueInfo, err := b.getIssue(c.Source)
 			if err != nil {
-				// Only a warning since users can delete Rietveld issues.
-				glog.Warningf("Failed to get details for commit from Rietveld %s: %s", c.ID, err)
+				// Only a warning since users can delete issues.
+				glog.Warningf("Failed to get details for commit from %s %s: %s", b.reviewURL, c.ID, err)
 				continue
 			}
 			c.Author = issueInfo.Owner
@@ -216,8 +218,8 @@ func (b *tileBuilder) convertToLongCommits(commitIDs []*CommitID, source string)
 
 // getIssue parses the source, which looks like
 // "https://chromium.codereview.org/1232143243" and returns information about
-// the issue from Rietveld.
-func (b *tileBuilder) getIssue(source string) (*rietveld.Issue, error) {
+// the issue from the code review system.
+func (b *tileBuilder) getIssue(source string) (*codereview.Issue, error) {
 	u, err := url.Parse(source)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to parse trybot source: %s", err)
@@ -226,17 +228,12 @@ func (b *tileBuilder) getIssue(source string) (*rietveld.Issue, error) {
 	issueStr := u.Path[1:]
 	issueInt, err := strconv.ParseInt(issueStr, 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to convert Rietveld issue id: %s", err)
+		return nil, fmt.Errorf("Unable to convert issue id: %s", err)
 	}
 
-	var issue *rietveld.Issue
-	var ok bool
-	if issue, ok = b.issueCache.Get(issueInt); !ok {
-		issue, err = b.review.GetIssueProperties(int64(issueInt), false)
-		if err != nil {
-			return nil, fmt.Errorf("Failed to get details for review %s: %s", source, err)
-		}
-		b.issueCache.Add(issueInt, issue)
+	issue, err := b.issueCache.GetIssue(issueInt)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get details for review %s: %s", source, err)
 	}
 	return issue, nil
 }
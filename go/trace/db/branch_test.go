@@ -8,6 +8,7 @@ import (
 
 	"github.com/golang/groupcache/lru"
 	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/codereview"
 	"go.skia.org/infra/go/httputils"
 	"go.skia.org/infra/go/ingestion"
 	"go.skia.org/infra/go/mockhttpclient"
@@ -23,7 +24,7 @@ func TestPerfTrace(t *testing.T) {
 	// Mock this only once to confirm that caching works.
 	m.MockOnce("https://codereview.chromium.org/api/1490543002", mockhttpclient.MockGetDialogue(b))
 
-	review := rietveld.New(rietveld.RIETVELD_SKIA_URL, httputils.NewTimeoutClient())
+	review := codereview.NewRietveld(rietveld.New(rietveld.RIETVELD_SKIA_URL, httputils.NewTimeoutClient()))
 
 	vcsCommits := []*vcsinfo.LongCommit{
 		&vcsinfo.LongCommit{
@@ -42,7 +43,7 @@ func TestPerfTrace(t *testing.T) {
 		review:     review,
 		reviewURL:  "https://codereview.chromium.org",
 		tcache:     lru.New(2),
-		issueCache: rietveld.NewCodeReviewCache(review, time.Minute, 2),
+		issueCache: codereview.NewIssueCache(review, time.Minute, 2),
 	}
 
 	now := time.Unix(100, 0)
@@ -151,12 +152,12 @@ func TestTileFromCommits(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Now test tileBuilder.
-	review := rietveld.New(rietveld.RIETVELD_SKIA_URL, httputils.NewTimeoutClient())
+	review := codereview.NewRietveld(rietveld.New(rietveld.RIETVELD_SKIA_URL, httputils.NewTimeoutClient()))
 	builder := &tileBuilder{
 		db:         ts,
 		vcs:        vcs,
 		tcache:     lru.New(2),
-		issueCache: rietveld.NewCodeReviewCache(review, time.Minute, 2),
+		issueCache: codereview.NewIssueCache(review, time.Minute, 2),
 	}
 	tile, err := builder.CachedTileFromCommits(commitIDs)
 	assert.NoError(t, err)
@@ -3,6 +3,7 @@ package db
 
 import (
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -284,6 +285,43 @@ func (ts *TsDB) List(begin, end time.Time) ([]*CommitID, error) {
 	return ret, nil
 }
 
+// getValuesRaw loads all the values stored for the given commit, along with
+// their md5 hash. The values are streamed from the traceservice in chunks of
+// CHUNK_SIZE so that large commits don't exceed the gRPC message size limits.
+func (ts *TsDB) getValuesRaw(ctx context.Context, cid *CommitID) (*traceservice.CommitInfo, string, error) {
+	req := &traceservice.GetValuesStreamRequest{
+		Commitid:  tsCommitID(cid),
+		ChunkSize: CHUNK_SIZE,
+	}
+	stream, err := ts.traceService.GetValuesRawStream(ctx, req)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to start stream: %s", err)
+	}
+	ret := &traceservice.CommitInfo{
+		Values: map[uint64][]byte{},
+	}
+	md5 := ""
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("Failed to receive values: %s", err)
+		}
+		// Convert raw response into values.
+		ci, err := traceservice.NewCommitInfo(resp.Value)
+		if err != nil {
+			return nil, "", fmt.Errorf("Failed to convert values: %s", err)
+		}
+		for id64, value := range ci.Values {
+			ret.Values[id64] = value
+		}
+		md5 = resp.Md5
+	}
+	return ret, md5, nil
+}
+
 // TileFromCommits implements DB.TileFromCommits().
 func (ts *TsDB) TileFromCommits(commitIDs []*CommitID) (*tiling.Tile, []string, error) {
 	ts.clearMutex.RLock()
@@ -318,20 +356,11 @@ func (ts *TsDB) TileFromCommits(commitIDs []*CommitID) (*tiling.Tile, []string,
 		go func(i int, cid *CommitID) {
 			defer wg.Done()
 			// Load the values for the commit.
-			getValuesRequest := &traceservice.GetValuesRequest{
-				Commitid: tsCommitID(cid),
-			}
-			getRawValues, err := ts.traceService.GetValuesRaw(ctx, getValuesRequest)
+			ci, md5, err := ts.getValuesRaw(ctx, cid)
 			if err != nil {
 				errCh <- fmt.Errorf("Failed to get values for %d %#v: %s", i, *cid, err)
 				return
 			}
-			// Convert raw response into values.
-			ci, err := traceservice.NewCommitInfo(getRawValues.Value)
-			if err != nil {
-				errCh <- fmt.Errorf("Failed to convert values for %d %#v: %s", i, *cid, err)
-				return
-			}
 			// Now make sure we have all the traceids for the trace64ids in ci.
 			missingKeys64 := []uint64{}
 			ts.mutex.Lock()
@@ -381,7 +410,7 @@ func (ts *TsDB) TileFromCommits(commitIDs []*CommitID) (*tiling.Tile, []string,
 				}
 			}
 			// Fill in the commits hash.
-			hash[i] = getRawValues.Md5
+			hash[i] = md5
 			ts.mutex.Unlock()
 		}(i, cid)
 	}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -80,3 +81,38 @@ func TestAdd(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(foundCommits))
 }
+
+func TestTileFromCommitsLargeCommit(t *testing.T) {
+	ts, cleanup := setupClientServerForTesting(t.Fatalf)
+	defer cleanup()
+
+	commitIDs := []*CommitID{
+		&CommitID{
+			Timestamp: 100,
+			ID:        "abc123",
+			Source:    "master",
+		},
+	}
+
+	// Add more values than fit in a single chunk of the stream.
+	n := CHUNK_SIZE + 10
+	entries := map[string]*Entry{}
+	for i := 0; i < n; i++ {
+		entries[fmt.Sprintf("key:%d", i)] = &Entry{
+			Params: map[string]string{
+				"config": fmt.Sprintf("%d", i),
+			},
+			Value: types.BytesFromFloat64(float64(i)),
+		}
+	}
+	assert.NoError(t, ts.Add(commitIDs[0], entries))
+
+	tile, hashes, err := ts.TileFromCommits(commitIDs)
+	assert.NoError(t, err)
+	assert.Equal(t, n, len(tile.Traces))
+	assert.Equal(t, 1, len(hashes))
+	assert.NotEqual(t, "", hashes[0])
+	tr := tile.Traces[fmt.Sprintf("key:%d", n-1)].(*types.PerfTrace)
+	assert.Equal(t, float64(n-1), tr.Values[0])
+	assert.Equal(t, fmt.Sprintf("%d", n-1), tr.Params()["config"])
+}
@@ -8,6 +8,8 @@ import (
 	"crypto/md5"
 	"encoding/binary"
 	"fmt"
+	"net/url"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +18,7 @@ import (
 	"github.com/golang/groupcache/lru"
 	"github.com/golang/protobuf/proto"
 	"go.skia.org/infra/go/metrics2"
+	"go.skia.org/infra/go/query"
 	"golang.org/x/net/context"
 )
 
@@ -25,24 +28,31 @@ const (
 	TRACEID_BUCKET_NAME = "traceids"
 	LARGEST_TRACEID_KEY = "the largest trace64id"
 
+	// The default number of values sent in each response of GetValuesStream
+	// and GetValuesRawStream.
+	DEFAULT_STREAM_CHUNK_SIZE = 10000
+
 	// How many items to keep in the in-memory LRU cache.
 	MAX_INT64_ID_CACHED = 1024 * 1024
 )
 
 var (
-	tags               = map[string]string{"module": "tracedb"}
-	missingParamsCalls = metrics2.GetCounter("missing-params-calls", tags)
-	addParamsCalls     = metrics2.GetCounter("add-params-calls", tags)
-	addCalls           = metrics2.GetCounter("add-calls", tags)
-	addCount           = metrics2.GetCounter("added-count", tags)
-	removeCalls        = metrics2.GetCounter("remove-calls", tags)
-	listCalls          = metrics2.GetCounter("list-calls", tags)
-	listMD5Calls       = metrics2.GetCounter("list-md5-calls", tags)
-	getParamsCalls     = metrics2.GetCounter("get-params-calls", tags)
-	getValuesCalls     = metrics2.GetCounter("get-values-calls", tags)
-	getValuesRawCalls  = metrics2.GetCounter("get-values-raw-calls", tags)
-	getTraceIDsCalls   = metrics2.GetCounter("get-traceids-calls", tags)
-	pingCalls          = metrics2.GetCounter("ping-calls", tags)
+	tags                    = map[string]string{"module": "tracedb"}
+	missingParamsCalls      = metrics2.GetCounter("missing-params-calls", tags)
+	addParamsCalls          = metrics2.GetCounter("add-params-calls", tags)
+	addCalls                = metrics2.GetCounter("add-calls", tags)
+	addCount                = metrics2.GetCounter("added-count", tags)
+	removeCalls             = metrics2.GetCounter("remove-calls", tags)
+	listCalls               = metrics2.GetCounter("list-calls", tags)
+	listMD5Calls            = metrics2.GetCounter("list-md5-calls", tags)
+	getParamsCalls          = metrics2.GetCounter("get-params-calls", tags)
+	getValuesCalls          = metrics2.GetCounter("get-values-calls", tags)
+	getValuesRawCalls       = metrics2.GetCounter("get-values-raw-calls", tags)
+	getValuesStreamCalls    = metrics2.GetCounter("get-values-stream-calls", tags)
+	getValuesRawStreamCalls = metrics2.GetCounter("get-values-raw-stream-calls", tags)
+	getTraceIDsCalls        = metrics2.GetCounter("get-traceids-calls", tags)
+	pingCalls               = metrics2.GetCounter("ping-calls", tags)
+	getStatsCalls           = metrics2.GetCounter("get-stats-calls", tags)
 )
 
 // bytesFromUint64 converts a uint64 to a []byte.
@@ -87,6 +97,28 @@ type TraceServiceImpl struct {
 	// db is the BoltDB datastore we actually store the data in.
 	db *bolt.DB
 
+	// filename is the location of the BoltDB file.
+	filename string
+
+	// dbMutex protects db. It is held for reading while db is in use and for
+	// writing when db is swapped out by Compact.
+	dbMutex sync.RWMutex
+
+	// writeMutex serializes writes to db. Compact holds it while it catches up
+	// with the writes made during the copy. It must be acquired before dbMutex.
+	writeMutex sync.Mutex
+
+	// changes records the keys written to each bucket while Compact copies
+	// the datastore, nil if no compaction is running. Protected by writeMutex.
+	changes map[string]map[string]bool
+
+	// compactMutex keeps more than one compaction from running at a time.
+	compactMutex sync.Mutex
+
+	// copied, if not nil, is called by Compact once the datastore has been
+	// copied and before writes are blocked. Used in tests.
+	copied func()
+
 	// cache is an in-memory LRU cache for traceids <-> trace64ids and commitid -> md5.
 	cache *lru.Cache
 
@@ -120,11 +152,41 @@ func NewTraceServiceServer(filename string) (*TraceServiceImpl, error) {
 		return nil, fmt.Errorf("Failed to create buckets: %s", err)
 	}
 	return &TraceServiceImpl{
-		db:    d,
-		cache: lru.New(MAX_INT64_ID_CACHED),
+		db:       d,
+		filename: filename,
+		cache:    lru.New(MAX_INT64_ID_CACHED),
 	}, nil
 }
 
+// view runs fn in a read-only transaction on the datastore.
+func (ts *TraceServiceImpl) view(fn func(*bolt.Tx) error) error {
+	ts.dbMutex.RLock()
+	defer ts.dbMutex.RUnlock()
+	return ts.db.View(fn)
+}
+
+// update runs fn in a read-write transaction on the datastore. fn must report
+// every key it writes via changed, see Compact.
+func (ts *TraceServiceImpl) update(fn func(*bolt.Tx) error) error {
+	ts.writeMutex.Lock()
+	defer ts.writeMutex.Unlock()
+	ts.dbMutex.RLock()
+	defer ts.dbMutex.RUnlock()
+	return ts.db.Update(fn)
+}
+
+// changed records that key was written to the bucket while a compaction
+// is running. It must only be called from functions passed to update.
+func (ts *TraceServiceImpl) changed(bucket string, key []byte) {
+	if ts.changes == nil {
+		return
+	}
+	if ts.changes[bucket] == nil {
+		ts.changes[bucket] = map[string]bool{}
+	}
+	ts.changes[bucket][string(key)] = true
+}
+
 // addMD5 adds the md5 of the raw bytes for the given key, which should
 // be a CommitID as a byte slice.
 //
@@ -216,7 +278,7 @@ func (ts *TraceServiceImpl) atomize(ids []string) (map[string]uint64, error) {
 		return nil
 	}
 
-	if err := ts.db.View(get); err != nil {
+	if err := ts.view(get); err != nil {
 		return nil, fmt.Errorf("Error while reading trace ids: %s", err)
 	}
 
@@ -235,9 +297,15 @@ func (ts *TraceServiceImpl) atomize(ids []string) (map[string]uint64, error) {
 			largest = binary.LittleEndian.Uint64(blargest)
 		}
 
-		// Generate a new id for each traceid and store the results.
-		for i, id := range notstored {
-			value := largest + uint64(i) + 1
+		// Generate a new id for each traceid and store the results. Skip any
+		// traceids that have been stored since they were looked up.
+		for _, id := range notstored {
+			if bid64 := t.Get([]byte(id)); bid64 != nil {
+				ret[id] = binary.LittleEndian.Uint64(bid64)
+				continue
+			}
+			largest += 1
+			value := largest
 			bvalue := make([]byte, 8, 8)
 			binary.LittleEndian.PutUint64(bvalue, value)
 			if err := t.Put([]byte(id), bvalue); err != nil {
@@ -246,6 +314,8 @@ func (ts *TraceServiceImpl) atomize(ids []string) (map[string]uint64, error) {
 			if err := t.Put(bvalue, []byte(id)); err != nil {
 				return fmt.Errorf("Failed to write atomized reverse lookup value for %s: %s", id, err)
 			}
+			ts.changed(TRACEID_BUCKET_NAME, []byte(id))
+			ts.changed(TRACEID_BUCKET_NAME, bvalue)
 			ts.mutex.Lock()
 			ts.cache.Add(id, value)
 			ts.cache.Add(value, id)
@@ -253,19 +323,18 @@ func (ts *TraceServiceImpl) atomize(ids []string) (map[string]uint64, error) {
 			ret[id] = value
 		}
 
-		largest = largest + uint64(len(notstored))
-
 		// Write the new value for LARGEST_TRACEID_KEY.
 		blargest := make([]byte, 8, 8)
 		binary.LittleEndian.PutUint64(blargest, largest)
 		if err := t.Put([]byte(LARGEST_TRACEID_KEY), blargest); err != nil {
 			return fmt.Errorf("Failed to write an updated largest trace64id value: %s", err)
 		}
+		ts.changed(TRACEID_BUCKET_NAME, []byte(LARGEST_TRACEID_KEY))
 
 		return nil
 	}
 
-	if err := ts.db.Update(add); err != nil {
+	if err := ts.update(add); err != nil {
 		return nil, fmt.Errorf("Error while writing new trace ids: %s", err)
 	}
 
@@ -359,7 +428,7 @@ func (ts *TraceServiceImpl) MissingParams(ctx context.Context, in *MissingParams
 		}
 		return nil
 	}
-	if err := ts.db.View(get); err != nil {
+	if err := ts.view(get); err != nil {
 		return nil, fmt.Errorf("Failed to add values to tracedb: %s", err)
 	}
 	return resp, nil
@@ -391,10 +460,11 @@ func (ts *TraceServiceImpl) AddParams(ctx context.Context, in *AddParamsRequest)
 			if err := t.Put([]byte(p.Key), params[p.Key]); err != nil {
 				return fmt.Errorf("Failed to write the trace info for %s: %s", p.Key, err)
 			}
+			ts.changed(TRACE_BUCKET_NAME, []byte(p.Key))
 		}
 		return nil
 	}
-	if err := ts.db.Update(add); err != nil {
+	if err := ts.update(add); err != nil {
 		return nil, fmt.Errorf("Failed to add values to tracedb: %s", err)
 	}
 	return &Empty{}, nil
@@ -457,10 +527,11 @@ func (ts *TraceServiceImpl) Add(ctx context.Context, in *AddRequest) (*Empty, er
 		if err := c.Put(key, b); err != nil {
 			return fmt.Errorf("Failed to write the trace info for %s: %s", key, err)
 		}
+		ts.changed(COMMIT_BUCKET_NAME, key)
 		return nil
 	}
 
-	if err := ts.db.Update(add); err != nil {
+	if err := ts.update(add); err != nil {
 		return nil, fmt.Errorf("Failed to add values to tracedb: %s", err)
 	}
 	return &Empty{}, nil
@@ -477,9 +548,10 @@ func (ts *TraceServiceImpl) Remove(ctx context.Context, in *RemoveRequest) (*Emp
 		if err != nil {
 			return err
 		}
+		ts.changed(COMMIT_BUCKET_NAME, key)
 		return c.Delete(key)
 	}
-	if err := ts.db.Update(remove); err != nil {
+	if err := ts.update(remove); err != nil {
 		return nil, fmt.Errorf("Failed to remove values from tracedb: %s", err)
 	}
 	ret := &Empty{}
@@ -510,7 +582,7 @@ func (ts *TraceServiceImpl) List(ctx context.Context, listRequest *ListRequest)
 		return nil
 	}
 
-	if err := ts.db.View(scan); err != nil {
+	if err := ts.view(scan); err != nil {
 		return nil, fmt.Errorf("Failed to scan for commits: %s", err)
 	}
 
@@ -568,7 +640,7 @@ func (ts *TraceServiceImpl) GetValues(ctx context.Context, getValuesRequest *Get
 
 		return nil
 	}
-	if err := ts.db.View(load); err != nil {
+	if err := ts.view(load); err != nil {
 		return nil, fmt.Errorf("Failed to load data for commitid: %#v, %s", *(getValuesRequest.Commitid), err)
 	}
 
@@ -596,13 +668,165 @@ func (ts *TraceServiceImpl) GetValuesRaw(ctx context.Context, getValuesRequest *
 		ret.Md5 = ts.getMD5(key, ret.Value)
 		return nil
 	}
-	if err := ts.db.View(load); err != nil {
+	if err := ts.view(load); err != nil {
 		return nil, fmt.Errorf("Failed to load data for commitid: %#v, %s", *(getValuesRequest.Commitid), err)
 	}
 
 	return ret, nil
 }
 
+// streamValues reads the values requested by a GetValuesStreamRequest and
+// calls send with each chunk of at most chunkSize(req) values, along with the
+// md5 of all the values stored for the CommitID. Each chunk is decoded in its
+// own read transaction and copied out before it is sent, so the datastore is
+// never locked while waiting on the client and the commit is never loaded
+// into memory as a whole. send is always called at least once so the md5 is
+// returned for empty commits. If withTraceIDs is true then the traceids of
+// the values in each chunk are also passed to send.
+func (ts *TraceServiceImpl) streamValues(req *GetValuesStreamRequest, withTraceIDs bool, send func(chunk *CommitInfo, traceids map[uint64]string, hash string) error) error {
+	if req == nil || req.Commitid == nil {
+		return fmt.Errorf("Received nil request.")
+	}
+	var q *query.Query
+	if req.Query != "" {
+		values, err := url.ParseQuery(req.Query)
+		if err != nil {
+			return fmt.Errorf("Invalid query %q: %s", req.Query, err)
+		}
+		q, err = query.New(values)
+		if err != nil {
+			return fmt.Errorf("Invalid query %q: %s", req.Query, err)
+		}
+	}
+	key, err := CommitIDToBytes(req.Commitid)
+	if err != nil {
+		return err
+	}
+	size := chunkSize(req)
+
+	// offset is the position in the stored values where the next chunk starts,
+	// and hash is the md5 of the stored values when the first chunk was read.
+	offset := 0
+	hash := ""
+	sent := false
+	for {
+		chunk := &CommitInfo{Values: map[uint64][]byte{}}
+		traceids := map[uint64]string{}
+		done := false
+		load := func(tx *bolt.Tx) error {
+			c := tx.Bucket([]byte(COMMIT_BUCKET_NAME))
+			tid := tx.Bucket([]byte(TRACEID_BUCKET_NAME))
+			t := tx.Bucket([]byte(TRACE_BUCKET_NAME))
+
+			b := c.Get(key)
+			h := ts.getMD5(key, b)
+			if hash == "" {
+				hash = h
+			} else if h != hash {
+				return fmt.Errorf("The values changed while they were streamed.")
+			}
+			if offset > len(b) {
+				return fmt.Errorf("Invalid offset %d into %d bytes of stored values.", offset, len(b))
+			}
+			b = b[offset:]
+
+			// See NewCommitInfo for the format of the stored values.
+			for len(b) > 0 && len(chunk.Values) < size {
+				if len(b) < 9 {
+					return fmt.Errorf("Unable to decode stored values, not enough bytes left: %#v", b)
+				}
+				id64 := binary.LittleEndian.Uint64(b[0:8])
+				length := int(b[8])
+				if len(b) < 9+length {
+					return fmt.Errorf("Unable to decode stored values, not enough bytes left for %d: Want %d Got %d", id64, length, len(b)-9)
+				}
+				value := b[9 : 9+length]
+				b = b[9+length:]
+				offset += 9 + length
+
+				if q != nil || withTraceIDs {
+					bid := tid.Get(bytesFromUint64(id64))
+					if bid == nil {
+						return fmt.Errorf("Failed to get traceid for trace64id %d", id64)
+					}
+					if q != nil {
+						entry := &StoredEntry{}
+						if err := proto.Unmarshal(t.Get(bid), entry); err != nil {
+							return fmt.Errorf("Failed to unmarshal StoredEntry proto for %s: %s", string(bid), err)
+						}
+						if entry.Params == nil || !q.MatchesParams(entry.Params.Params) {
+							continue
+						}
+					}
+					if withTraceIDs {
+						traceids[id64] = string(bid)
+					}
+				}
+				// The value is only valid for the life of the transaction.
+				chunk.Values[id64] = append([]byte{}, value...)
+			}
+			done = len(b) == 0
+			return nil
+		}
+		if err := ts.view(load); err != nil {
+			return fmt.Errorf("Failed to load data for commitid: %#v, %s", *(req.Commitid), err)
+		}
+		// Chunks that the query filtered out completely are skipped, but the
+		// md5 is always sent at least once.
+		if len(chunk.Values) > 0 || (done && !sent) {
+			if err := send(chunk, traceids, hash); err != nil {
+				return err
+			}
+			sent = true
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// chunkSize returns the number of values to send in each response of a stream.
+func chunkSize(req *GetValuesStreamRequest) int {
+	if req.ChunkSize <= 0 {
+		return DEFAULT_STREAM_CHUNK_SIZE
+	}
+	return int(req.ChunkSize)
+}
+
+func (ts *TraceServiceImpl) GetValuesStream(req *GetValuesStreamRequest, stream TraceService_GetValuesStreamServer) error {
+	getValuesStreamCalls.Inc(1)
+	return ts.streamValues(req, true, func(chunk *CommitInfo, traceids map[uint64]string, hash string) error {
+		resp := &GetValuesResponse{
+			Values: make([]*ValuePair, 0, len(chunk.Values)),
+			Md5:    hash,
+		}
+		for id64, value := range chunk.Values {
+			resp.Values = append(resp.Values, &ValuePair{
+				Key:   traceids[id64],
+				Value: value,
+			})
+		}
+		if err := stream.Send(resp); err != nil {
+			return fmt.Errorf("Failed to send values: %s", err)
+		}
+		return nil
+	})
+}
+
+func (ts *TraceServiceImpl) GetValuesRawStream(req *GetValuesStreamRequest, stream TraceService_GetValuesRawStreamServer) error {
+	getValuesRawStreamCalls.Inc(1)
+	return ts.streamValues(req, false, func(chunk *CommitInfo, traceids map[uint64]string, hash string) error {
+		resp := &GetValuesRawResponse{
+			Value: chunk.ToBytes(),
+			Md5:   hash,
+		}
+		if err := stream.Send(resp); err != nil {
+			return fmt.Errorf("Failed to send values: %s", err)
+		}
+		return nil
+	})
+}
+
 func (ts *TraceServiceImpl) GetTraceIDs(ctx context.Context, getTraceIDsRequest *GetTraceIDsRequest) (*GetTraceIDsResponse, error) {
 	getTraceIDsCalls.Inc(1)
 	ret := &GetTraceIDsResponse{
@@ -622,7 +846,7 @@ func (ts *TraceServiceImpl) GetTraceIDs(ctx context.Context, getTraceIDsRequest
 		}
 		return nil
 	}
-	if err := ts.db.View(load); err != nil {
+	if err := ts.view(load); err != nil {
 		return nil, fmt.Errorf("Failed to load traceids: %s", err)
 	}
 
@@ -654,7 +878,7 @@ func (ts *TraceServiceImpl) GetParams(ctx context.Context, getParamsRequest *Get
 
 		return nil
 	}
-	if err := ts.db.View(load); err != nil {
+	if err := ts.view(load); err != nil {
 		return nil, fmt.Errorf("GetParams: Failed to load data: %s", err)
 	}
 
@@ -683,7 +907,7 @@ func (ts *TraceServiceImpl) ListMD5(ctx context.Context, listMD5Request *ListMD5
 				hash = ts.getMD5(key, c.Get(key))
 				return nil
 			}
-			if err := ts.db.View(load); err != nil {
+			if err := ts.view(load); err != nil {
 				return nil, fmt.Errorf("Failed to load data for commitid: %#v, %s", *commitid, err)
 			}
 		}
@@ -702,7 +926,51 @@ func (ts *TraceServiceImpl) Ping(ctx context.Context, empty *Empty) (*Empty, err
 	return &Empty{}, nil
 }
 
+func (ts *TraceServiceImpl) GetStats(ctx context.Context, empty *Empty) (*GetStatsResponse, error) {
+	getStatsCalls.Inc(1)
+	ret := &GetStatsResponse{
+		Sources: []*SourceStats{},
+	}
+	sources := map[string]*SourceStats{}
+	load := func(tx *bolt.Tx) error {
+		ret.FileSize = tx.Size()
+		if blargest := tx.Bucket([]byte(TRACEID_BUCKET_NAME)).Get([]byte(LARGEST_TRACEID_KEY)); blargest != nil {
+			ret.NumTraceids = int64(binary.LittleEndian.Uint64(blargest))
+		}
+		c := tx.Bucket([]byte(COMMIT_BUCKET_NAME)).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			cid, err := CommitIDFromBytes(k)
+			if err != nil {
+				return fmt.Errorf("Failed to deserialize a commit id: %s", err)
+			}
+			s, ok := sources[cid.Source]
+			if !ok {
+				s = &SourceStats{Source: cid.Source}
+				sources[cid.Source] = s
+				ret.Sources = append(ret.Sources, s)
+			}
+			s.NumCommits += 1
+			s.Bytes += int64(len(v))
+		}
+		return nil
+	}
+	if err := ts.view(load); err != nil {
+		return nil, fmt.Errorf("Failed to load stats: %s", err)
+	}
+	sort.Sort(sourceStatsSlice(ret.Sources))
+	return ret, nil
+}
+
+// sourceStatsSlice is a utility type for sorting SourceStats by source.
+type sourceStatsSlice []*SourceStats
+
+func (p sourceStatsSlice) Len() int           { return len(p) }
+func (p sourceStatsSlice) Less(i, j int) bool { return p[i].Source < p[j].Source }
+func (p sourceStatsSlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
 // Close closes the underlying datastore. Not part of the TraceServiceServer interface.
 func (ts *TraceServiceImpl) Close() error {
+	ts.dbMutex.Lock()
+	defer ts.dbMutex.Unlock()
 	return ts.db.Close()
 }
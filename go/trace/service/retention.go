@@ -0,0 +1,402 @@
+package traceservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/metrics2"
+	"go.skia.org/infra/go/util"
+)
+
+const (
+	// The number of keys written to the compacted datastore in each transaction.
+	COMPACT_BATCH_SIZE = 10000
+
+	// The fill percent used for buckets in the compacted datastore. Keys are
+	// copied in order so pages can be filled more than the default.
+	COMPACT_FILL_PERCENT = 0.9
+
+	// Suffix added to the datastore filename for the compacted copy while it is
+	// being written.
+	COMPACT_SUFFIX = ".compact"
+)
+
+var (
+	retentionCalls        = metrics2.GetCounter("retention-calls", tags)
+	retentionRemovedCount = metrics2.GetCounter("retention-removed-count", tags)
+	compactCalls          = metrics2.GetCounter("compact-calls", tags)
+)
+
+// RetentionRule describes how long data is kept for all CommitIDs whose source
+// starts with SourcePrefix.
+type RetentionRule struct {
+	// SourcePrefix is matched against CommitID.Source. The empty string matches
+	// all sources.
+	SourcePrefix string `json:"source_prefix"`
+
+	// MaxAge is how long CommitIDs are kept, as parsed by time.ParseDuration.
+	// CommitIDs with a timestamp older than MaxAge are removed. An empty string
+	// means CommitIDs are never removed because of their age.
+	MaxAge string `json:"max_age"`
+
+	// MaxCommits is the number of most recent CommitIDs kept for each source.
+	// Zero means there is no limit.
+	MaxCommits int `json:"max_commits"`
+
+	maxAge time.Duration
+}
+
+// Validate checks the rule and parses MaxAge.
+func (r *RetentionRule) Validate() error {
+	if r.MaxCommits < 0 {
+		return fmt.Errorf("Invalid retention rule for %q: max_commits must not be negative.", r.SourcePrefix)
+	}
+	r.maxAge = 0
+	if r.MaxAge != "" {
+		d, err := time.ParseDuration(r.MaxAge)
+		if err != nil {
+			return fmt.Errorf("Invalid retention rule for %q: %s", r.SourcePrefix, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("Invalid retention rule for %q: max_age must be positive.", r.SourcePrefix)
+		}
+		r.maxAge = d
+	}
+	return nil
+}
+
+// ReadRetentionRules reads a JSON list of RetentionRules from the given file,
+// for example:
+//
+//    [
+//      {"source_prefix": "https://codereview.chromium.org/", "max_age": "336h"},
+//      {"source_prefix": "", "max_commits": 5000}
+//    ]
+//
+func ReadRetentionRules(filename string) ([]*RetentionRule, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open retention rules %s: %s", filename, err)
+	}
+	defer util.Close(f)
+	rules := []*RetentionRule{}
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("Failed to decode retention rules %s: %s", filename, err)
+	}
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// matchRule returns the first rule whose SourcePrefix matches the source, or
+// nil if none match.
+func matchRule(rules []*RetentionRule, source string) *RetentionRule {
+	for _, r := range rules {
+		if strings.HasPrefix(source, r.SourcePrefix) {
+			return r
+		}
+	}
+	return nil
+}
+
+// ApplyRetention removes all the CommitIDs that the given rules say should no
+// longer be kept, as of the time 'now'. Each CommitID is governed by the first
+// rule whose SourcePrefix matches its source; CommitIDs that don't match any
+// rule are kept. The rules must have been validated. Returns the number of
+// CommitIDs removed.
+//
+// Note that the space freed is only returned to the filesystem by Compact.
+func (ts *TraceServiceImpl) ApplyRetention(rules []*RetentionRule, now time.Time) (int, error) {
+	retentionCalls.Inc(1)
+
+	// Find the keys to remove. Keys sort by time, so the keys for each source
+	// are collected oldest first.
+	toRemove := [][]byte{}
+	bySource := map[string][][]byte{}
+	scan := func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(COMMIT_BUCKET_NAME)).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			cid, err := CommitIDFromBytes(k)
+			if err != nil {
+				return fmt.Errorf("Failed to deserialize a commit id: %s", err)
+			}
+			r := matchRule(rules, cid.Source)
+			if r == nil {
+				continue
+			}
+			// Keys are only valid for the life of the transaction, so copy them.
+			key := make([]byte, len(k))
+			copy(key, k)
+			if r.maxAge > 0 && time.Unix(cid.Timestamp, 0).Before(now.Add(-r.maxAge)) {
+				toRemove = append(toRemove, key)
+			} else if r.MaxCommits > 0 {
+				bySource[cid.Source] = append(bySource[cid.Source], key)
+			}
+		}
+		return nil
+	}
+	if err := ts.view(scan); err != nil {
+		return 0, fmt.Errorf("Failed to scan for expired commits: %s", err)
+	}
+	for source, keys := range bySource {
+		r := matchRule(rules, source)
+		if len(keys) > r.MaxCommits {
+			toRemove = append(toRemove, keys[:len(keys)-r.MaxCommits]...)
+		}
+	}
+	if len(toRemove) == 0 {
+		return 0, nil
+	}
+
+	remove := func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(COMMIT_BUCKET_NAME))
+		for _, key := range toRemove {
+			if err := c.Delete(key); err != nil {
+				return fmt.Errorf("Failed to remove %s: %s", string(key), err)
+			}
+			ts.changed(COMMIT_BUCKET_NAME, key)
+		}
+		return nil
+	}
+	if err := ts.update(remove); err != nil {
+		return 0, fmt.Errorf("Failed to remove expired commits: %s", err)
+	}
+
+	ts.mutex.Lock()
+	for _, key := range toRemove {
+		ts.cache.Remove(string(key))
+	}
+	ts.mutex.Unlock()
+
+	retentionRemovedCount.Inc(int64(len(toRemove)))
+	glog.Infof("Retention removed %d commits.", len(toRemove))
+	return len(toRemove), nil
+}
+
+// Compact rewrites the datastore into a new file without the free pages left
+// behind by removed data, and then switches over to the new file.
+//
+// Reads and writes continue to be served from the current file while the
+// copy is made. The copy is made in batches, each read in its own short
+// transaction, and the keys written in the meantime are recorded. Writes are
+// only blocked at the end, while those keys are copied again and the files
+// are swapped.
+func (ts *TraceServiceImpl) Compact() error {
+	compactCalls.Inc(1)
+	ts.compactMutex.Lock()
+	defer ts.compactMutex.Unlock()
+
+	compactFilename := ts.filename + COMPACT_SUFFIX
+	if err := os.Remove(compactFilename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to remove stale %s: %s", compactFilename, err)
+	}
+	dst, err := bolt.Open(compactFilename, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return fmt.Errorf("Failed to open BoltDB at %s: %s", compactFilename, err)
+	}
+
+	// Start recording the keys that are written during the copy.
+	ts.writeMutex.Lock()
+	ts.changes = map[string]map[string]bool{}
+	ts.writeMutex.Unlock()
+
+	// abort is called if the compaction fails at any point before the swap.
+	// The caller must hold writeMutex.
+	abort := func() {
+		ts.changes = nil
+		util.Close(dst)
+		if err := os.Remove(compactFilename); err != nil {
+			glog.Errorf("Failed to remove %s: %s", compactFilename, err)
+		}
+	}
+
+	ts.dbMutex.RLock()
+	err = copyBuckets(ts.db, dst)
+	ts.dbMutex.RUnlock()
+	if ts.copied != nil {
+		ts.copied()
+	}
+
+	// Block writes until the files have been swapped.
+	ts.writeMutex.Lock()
+	defer ts.writeMutex.Unlock()
+	if err != nil {
+		abort()
+		return fmt.Errorf("Failed to copy datastore: %s", err)
+	}
+	ts.dbMutex.RLock()
+	err = copyKeys(ts.db, dst, ts.changes)
+	ts.dbMutex.RUnlock()
+	if err != nil {
+		abort()
+		return fmt.Errorf("Failed to copy the keys written during the compaction: %s", err)
+	}
+
+	// Block all access to the datastore while the files are swapped.
+	ts.dbMutex.Lock()
+	defer ts.dbMutex.Unlock()
+	if err := os.Rename(compactFilename, ts.filename); err != nil {
+		abort()
+		return fmt.Errorf("Failed to move %s to %s: %s", compactFilename, ts.filename, err)
+	}
+	ts.changes = nil
+	old := ts.db
+	ts.db = dst
+	if err := old.Close(); err != nil {
+		glog.Errorf("Failed to close the uncompacted datastore: %s", err)
+	}
+	glog.Infof("Compacted %s.", ts.filename)
+	return nil
+}
+
+// copyKeys copies the given keys of each top level bucket from src into dst,
+// in a single transaction. Keys that no longer exist in src are removed from
+// dst.
+func copyKeys(src, dst *bolt.DB, keys map[string]map[string]bool) error {
+	return src.View(func(srcTx *bolt.Tx) error {
+		return dst.Update(func(dstTx *bolt.Tx) error {
+			for name, bucketKeys := range keys {
+				in := srcTx.Bucket([]byte(name))
+				if in == nil {
+					return fmt.Errorf("Bucket %q disappeared during the copy.", name)
+				}
+				out, err := dstTx.CreateBucketIfNotExists([]byte(name))
+				if err != nil {
+					return err
+				}
+				for key := range bucketKeys {
+					if v := in.Get([]byte(key)); v == nil {
+						if err := out.Delete([]byte(key)); err != nil {
+							return err
+						}
+					} else if err := out.Put([]byte(key), v); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// copyBuckets copies every bucket in src into dst, including nested buckets.
+func copyBuckets(src, dst *bolt.DB) error {
+	names := [][]byte{}
+	err := src.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, copyBytes(name))
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := copyBucket(src, dst, [][]byte{name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBucket copies the bucket at the given path of bucket names from src into
+// dst, in batches of COMPACT_BATCH_SIZE keys. Each batch is read in its own
+// transaction. Nested buckets are copied after the batch they appear in.
+func copyBucket(src, dst *bolt.DB, path [][]byte) error {
+	var last []byte
+	for {
+		n := 0
+		nested := [][]byte{}
+		copyBatch := func(srcTx *bolt.Tx) error {
+			in := bucketAt(srcTx, path)
+			if in == nil {
+				return fmt.Errorf("Bucket %q disappeared during the copy.", path)
+			}
+			return dst.Update(func(dstTx *bolt.Tx) error {
+				out, err := createBucketAt(dstTx, path)
+				if err != nil {
+					return err
+				}
+				out.FillPercent = COMPACT_FILL_PERCENT
+				c := in.Cursor()
+				k, v := c.First()
+				if last != nil {
+					k, v = c.Seek(last)
+					if k != nil && bytes.Equal(k, last) {
+						k, v = c.Next()
+					}
+				}
+				for ; k != nil && n < COMPACT_BATCH_SIZE; k, v = c.Next() {
+					if v == nil {
+						// Nested buckets have no value.
+						nested = append(nested, copyBytes(k))
+						if _, err := out.CreateBucketIfNotExists(k); err != nil {
+							return fmt.Errorf("Failed to create bucket %q: %s", append(path, k), err)
+						}
+					} else if err := out.Put(k, v); err != nil {
+						return fmt.Errorf("Failed to write key in bucket %q: %s", path, err)
+					}
+					last = copyBytes(k)
+					n++
+				}
+				return nil
+			})
+		}
+		if err := src.View(copyBatch); err != nil {
+			return err
+		}
+		for _, name := range nested {
+			if err := copyBucket(src, dst, append(append([][]byte{}, path...), name)); err != nil {
+				return err
+			}
+		}
+		if n < COMPACT_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+// bucketAt returns the bucket at the given path of bucket names, or nil if it
+// doesn't exist.
+func bucketAt(tx *bolt.Tx, path [][]byte) *bolt.Bucket {
+	b := tx.Bucket(path[0])
+	for _, name := range path[1:] {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket(name)
+	}
+	return b
+}
+
+// createBucketAt returns the bucket at the given path of bucket names, creating
+// any missing buckets along the way.
+func createBucketAt(tx *bolt.Tx, path [][]byte) (*bolt.Bucket, error) {
+	b, err := tx.CreateBucketIfNotExists(path[0])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create bucket %q: %s", path[0], err)
+	}
+	for i, name := range path[1:] {
+		if b, err = b.CreateBucketIfNotExists(name); err != nil {
+			return nil, fmt.Errorf("Failed to create bucket %q: %s", path[:i+2], err)
+		}
+	}
+	return b, nil
+}
+
+// copyBytes returns a copy of b. Keys and values returned by BoltDB are only
+// valid for the life of the transaction.
+func copyBytes(b []byte) []byte {
+	ret := make([]byte, len(b))
+	copy(ret, b)
+	return ret
+}
@@ -23,6 +23,7 @@ It has these top-level messages:
 	ListRequest
 	ListResponse
 	GetValuesRequest
+	GetValuesStreamRequest
 	GetValuesResponse
 	GetParamsRequest
 	GetParamsResponse
@@ -33,6 +34,8 @@ It has these top-level messages:
 	ListMD5Request
 	CommitMD5
 	ListMD5Response
+	SourceStats
+	GetStatsResponse
 */
 package traceservice
 
@@ -265,6 +268,30 @@ func (m *GetValuesRequest) GetCommitid() *CommitID {
 	return nil
 }
 
+type GetValuesStreamRequest struct {
+	Commitid *CommitID `protobuf:"bytes,1,opt,name=commitid" json:"commitid,omitempty"`
+	// A query in the format of url.Values.Encode(), for example
+	// "config=8888&config=565&arch=x86", see go/query. Only the values of the
+	// traces whose Params match the query are returned. If empty all values
+	// are returned.
+	Query string `protobuf:"bytes,2,opt,name=query" json:"query,omitempty"`
+	// The maximum number of values in each response. If zero then
+	// DEFAULT_STREAM_CHUNK_SIZE is used.
+	ChunkSize int32 `protobuf:"varint,3,opt,name=chunk_size" json:"chunk_size,omitempty"`
+}
+
+func (m *GetValuesStreamRequest) Reset()                    { *m = GetValuesStreamRequest{} }
+func (m *GetValuesStreamRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetValuesStreamRequest) ProtoMessage()               {}
+func (*GetValuesStreamRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *GetValuesStreamRequest) GetCommitid() *CommitID {
+	if m != nil {
+		return m.Commitid
+	}
+	return nil
+}
+
 type GetValuesResponse struct {
 	Values []*ValuePair `protobuf:"bytes,4,rep,name=values" json:"values,omitempty"`
 	Md5    string       `protobuf:"bytes,5,opt,name=md5" json:"md5,omitempty"`
@@ -273,7 +300,7 @@ type GetValuesResponse struct {
 func (m *GetValuesResponse) Reset()                    { *m = GetValuesResponse{} }
 func (m *GetValuesResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetValuesResponse) ProtoMessage()               {}
-func (*GetValuesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+func (*GetValuesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 func (m *GetValuesResponse) GetValues() []*ValuePair {
 	if m != nil {
@@ -290,7 +317,7 @@ type GetParamsRequest struct {
 func (m *GetParamsRequest) Reset()                    { *m = GetParamsRequest{} }
 func (m *GetParamsRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetParamsRequest) ProtoMessage()               {}
-func (*GetParamsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+func (*GetParamsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 type GetParamsResponse struct {
 	Params []*ParamsPair `protobuf:"bytes,4,rep,name=params" json:"params,omitempty"`
@@ -299,7 +326,7 @@ type GetParamsResponse struct {
 func (m *GetParamsResponse) Reset()                    { *m = GetParamsResponse{} }
 func (m *GetParamsResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetParamsResponse) ProtoMessage()               {}
-func (*GetParamsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+func (*GetParamsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
 
 func (m *GetParamsResponse) GetParams() []*ParamsPair {
 	if m != nil {
@@ -317,7 +344,7 @@ type GetValuesRawResponse struct {
 func (m *GetValuesRawResponse) Reset()                    { *m = GetValuesRawResponse{} }
 func (m *GetValuesRawResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetValuesRawResponse) ProtoMessage()               {}
-func (*GetValuesRawResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+func (*GetValuesRawResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
 
 type GetTraceIDsRequest struct {
 	Id []uint64 `protobuf:"varint,1,rep,name=id" json:"id,omitempty"`
@@ -326,7 +353,7 @@ type GetTraceIDsRequest struct {
 func (m *GetTraceIDsRequest) Reset()                    { *m = GetTraceIDsRequest{} }
 func (m *GetTraceIDsRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetTraceIDsRequest) ProtoMessage()               {}
-func (*GetTraceIDsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+func (*GetTraceIDsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
 
 type TraceIDPair struct {
 	Id64 uint64 `protobuf:"varint,1,opt,name=id64" json:"id64,omitempty"`
@@ -336,7 +363,7 @@ type TraceIDPair struct {
 func (m *TraceIDPair) Reset()                    { *m = TraceIDPair{} }
 func (m *TraceIDPair) String() string            { return proto.CompactTextString(m) }
 func (*TraceIDPair) ProtoMessage()               {}
-func (*TraceIDPair) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+func (*TraceIDPair) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
 
 type GetTraceIDsResponse struct {
 	Ids []*TraceIDPair `protobuf:"bytes,1,rep,name=ids" json:"ids,omitempty"`
@@ -345,7 +372,7 @@ type GetTraceIDsResponse struct {
 func (m *GetTraceIDsResponse) Reset()                    { *m = GetTraceIDsResponse{} }
 func (m *GetTraceIDsResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetTraceIDsResponse) ProtoMessage()               {}
-func (*GetTraceIDsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+func (*GetTraceIDsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
 
 func (m *GetTraceIDsResponse) GetIds() []*TraceIDPair {
 	if m != nil {
@@ -361,7 +388,7 @@ type ListMD5Request struct {
 func (m *ListMD5Request) Reset()                    { *m = ListMD5Request{} }
 func (m *ListMD5Request) String() string            { return proto.CompactTextString(m) }
 func (*ListMD5Request) ProtoMessage()               {}
-func (*ListMD5Request) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+func (*ListMD5Request) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
 
 func (m *ListMD5Request) GetCommitid() []*CommitID {
 	if m != nil {
@@ -378,7 +405,7 @@ type CommitMD5 struct {
 func (m *CommitMD5) Reset()                    { *m = CommitMD5{} }
 func (m *CommitMD5) String() string            { return proto.CompactTextString(m) }
 func (*CommitMD5) ProtoMessage()               {}
-func (*CommitMD5) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+func (*CommitMD5) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
 
 func (m *CommitMD5) GetCommitid() *CommitID {
 	if m != nil {
@@ -394,7 +421,7 @@ type ListMD5Response struct {
 func (m *ListMD5Response) Reset()                    { *m = ListMD5Response{} }
 func (m *ListMD5Response) String() string            { return proto.CompactTextString(m) }
 func (*ListMD5Response) ProtoMessage()               {}
-func (*ListMD5Response) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+func (*ListMD5Response) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
 
 func (m *ListMD5Response) GetCommitmd5() []*CommitMD5 {
 	if m != nil {
@@ -403,6 +430,41 @@ func (m *ListMD5Response) GetCommitmd5() []*CommitMD5 {
 	return nil
 }
 
+// SourceStats is the storage used by all the commits with the same source.
+type SourceStats struct {
+	Source string `protobuf:"bytes,1,opt,name=source" json:"source,omitempty"`
+	// The number of CommitIDs with this source.
+	NumCommits int64 `protobuf:"varint,2,opt,name=num_commits" json:"num_commits,omitempty"`
+	// The total number of bytes of values stored for all the CommitIDs.
+	Bytes int64 `protobuf:"varint,3,opt,name=bytes" json:"bytes,omitempty"`
+}
+
+func (m *SourceStats) Reset()                    { *m = SourceStats{} }
+func (m *SourceStats) String() string            { return proto.CompactTextString(m) }
+func (*SourceStats) ProtoMessage()               {}
+func (*SourceStats) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+type GetStatsResponse struct {
+	// The size of the BoltDB file in bytes.
+	FileSize int64 `protobuf:"varint,1,opt,name=file_size" json:"file_size,omitempty"`
+	// The number of traceids that have been assigned trace64ids.
+	NumTraceids int64 `protobuf:"varint,2,opt,name=num_traceids" json:"num_traceids,omitempty"`
+	// Stats for each commit source, sorted by source.
+	Sources []*SourceStats `protobuf:"bytes,3,rep,name=sources" json:"sources,omitempty"`
+}
+
+func (m *GetStatsResponse) Reset()                    { *m = GetStatsResponse{} }
+func (m *GetStatsResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetStatsResponse) ProtoMessage()               {}
+func (*GetStatsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *GetStatsResponse) GetSources() []*SourceStats {
+	if m != nil {
+		return m.Sources
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Empty)(nil), "traceservice.Empty")
 	proto.RegisterType((*CommitID)(nil), "traceservice.CommitID")
@@ -418,6 +480,7 @@ func init() {
 	proto.RegisterType((*ListRequest)(nil), "traceservice.ListRequest")
 	proto.RegisterType((*ListResponse)(nil), "traceservice.ListResponse")
 	proto.RegisterType((*GetValuesRequest)(nil), "traceservice.GetValuesRequest")
+	proto.RegisterType((*GetValuesStreamRequest)(nil), "traceservice.GetValuesStreamRequest")
 	proto.RegisterType((*GetValuesResponse)(nil), "traceservice.GetValuesResponse")
 	proto.RegisterType((*GetParamsRequest)(nil), "traceservice.GetParamsRequest")
 	proto.RegisterType((*GetParamsResponse)(nil), "traceservice.GetParamsResponse")
@@ -428,6 +491,8 @@ func init() {
 	proto.RegisterType((*ListMD5Request)(nil), "traceservice.ListMD5Request")
 	proto.RegisterType((*CommitMD5)(nil), "traceservice.CommitMD5")
 	proto.RegisterType((*ListMD5Response)(nil), "traceservice.ListMD5Response")
+	proto.RegisterType((*SourceStats)(nil), "traceservice.SourceStats")
+	proto.RegisterType((*GetStatsResponse)(nil), "traceservice.GetStatsResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -467,6 +532,16 @@ type TraceServiceClient interface {
 	// Ping should always succeed. Used to test if the service is up and
 	// running.
 	Ping(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	// GetValuesStream returns the trace values stored for the given CommitID
+	// that match the query, as a stream of chunks. Use this in place of
+	// GetValues for commits with a large number of values.
+	GetValuesStream(ctx context.Context, in *GetValuesStreamRequest, opts ...grpc.CallOption) (TraceService_GetValuesStreamClient, error)
+	// GetValuesRawStream returns the trace values stored for the given CommitID
+	// that match the query, as a stream of chunks in the raw format stored in
+	// BoltDB. Each chunk can be decoded with NewCommitInfo().
+	GetValuesRawStream(ctx context.Context, in *GetValuesStreamRequest, opts ...grpc.CallOption) (TraceService_GetValuesRawStreamClient, error)
+	// GetStats returns the size of the datastore, broken down by commit source.
+	GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetStatsResponse, error)
 }
 
 type traceServiceClient struct {
@@ -576,6 +651,79 @@ func (c *traceServiceClient) Ping(ctx context.Context, in *Empty, opts ...grpc.C
 	return out, nil
 }
 
+func (c *traceServiceClient) GetValuesStream(ctx context.Context, in *GetValuesStreamRequest, opts ...grpc.CallOption) (TraceService_GetValuesStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TraceService_serviceDesc.Streams[0], c.cc, "/traceservice.TraceService/GetValuesStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &traceServiceGetValuesStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TraceService_GetValuesStreamClient interface {
+	Recv() (*GetValuesResponse, error)
+	grpc.ClientStream
+}
+
+type traceServiceGetValuesStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *traceServiceGetValuesStreamClient) Recv() (*GetValuesResponse, error) {
+	m := new(GetValuesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *traceServiceClient) GetValuesRawStream(ctx context.Context, in *GetValuesStreamRequest, opts ...grpc.CallOption) (TraceService_GetValuesRawStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TraceService_serviceDesc.Streams[1], c.cc, "/traceservice.TraceService/GetValuesRawStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &traceServiceGetValuesRawStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TraceService_GetValuesRawStreamClient interface {
+	Recv() (*GetValuesRawResponse, error)
+	grpc.ClientStream
+}
+
+type traceServiceGetValuesRawStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *traceServiceGetValuesRawStreamClient) Recv() (*GetValuesRawResponse, error) {
+	m := new(GetValuesRawResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *traceServiceClient) GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	out := new(GetStatsResponse)
+	err := grpc.Invoke(ctx, "/traceservice.TraceService/GetStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for TraceService service
 
 type TraceServiceServer interface {
@@ -605,6 +753,16 @@ type TraceServiceServer interface {
 	// Ping should always succeed. Used to test if the service is up and
 	// running.
 	Ping(context.Context, *Empty) (*Empty, error)
+	// GetValuesStream returns the trace values stored for the given CommitID
+	// that match the query, as a stream of chunks. Use this in place of
+	// GetValues for commits with a large number of values.
+	GetValuesStream(*GetValuesStreamRequest, TraceService_GetValuesStreamServer) error
+	// GetValuesRawStream returns the trace values stored for the given CommitID
+	// that match the query, as a stream of chunks in the raw format stored in
+	// BoltDB. Each chunk can be decoded with NewCommitInfo().
+	GetValuesRawStream(*GetValuesStreamRequest, TraceService_GetValuesRawStreamServer) error
+	// GetStats returns the size of the datastore, broken down by commit source.
+	GetStats(context.Context, *Empty) (*GetStatsResponse, error)
 }
 
 func RegisterTraceServiceServer(s *grpc.Server, srv TraceServiceServer) {
@@ -809,6 +967,66 @@ func _TraceService_Ping_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TraceService_GetValuesStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetValuesStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TraceServiceServer).GetValuesStream(m, &traceServiceGetValuesStreamServer{stream})
+}
+
+type TraceService_GetValuesStreamServer interface {
+	Send(*GetValuesResponse) error
+	grpc.ServerStream
+}
+
+type traceServiceGetValuesStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *traceServiceGetValuesStreamServer) Send(m *GetValuesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TraceService_GetValuesRawStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetValuesStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TraceServiceServer).GetValuesRawStream(m, &traceServiceGetValuesRawStreamServer{stream})
+}
+
+type TraceService_GetValuesRawStreamServer interface {
+	Send(*GetValuesRawResponse) error
+	grpc.ServerStream
+}
+
+type traceServiceGetValuesRawStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *traceServiceGetValuesRawStreamServer) Send(m *GetValuesRawResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TraceService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TraceServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/traceservice.TraceService/GetStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TraceServiceServer).GetStats(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _TraceService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "traceservice.TraceService",
 	HandlerType: (*TraceServiceServer)(nil),
@@ -857,58 +1075,83 @@ var _TraceService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Ping",
 			Handler:    _TraceService_Ping_Handler,
 		},
+		{
+			MethodName: "GetStats",
+			Handler:    _TraceService_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetValuesStream",
+			Handler:       _TraceService_GetValuesStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetValuesRawStream",
+			Handler:       _TraceService_GetValuesRawStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: fileDescriptor0,
 }
 
 func init() { proto.RegisterFile("traceservice.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 711 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xa4, 0x56, 0x5d, 0x4f, 0xd4, 0x40,
-	0x14, 0x65, 0xb7, 0xa5, 0xd0, 0xdb, 0x82, 0x30, 0xa0, 0xd6, 0xfa, 0xb5, 0x8e, 0x44, 0x16, 0x13,
-	0x89, 0x29, 0x1f, 0x51, 0x02, 0x31, 0x2a, 0x44, 0x49, 0xc4, 0x10, 0x20, 0x3e, 0xf8, 0x62, 0xca,
-	0x76, 0x42, 0x26, 0xda, 0xed, 0xda, 0x16, 0x0c, 0x2f, 0xfe, 0x25, 0xff, 0xa2, 0xd3, 0x99, 0x76,
-	0xb6, 0xd3, 0x6d, 0x17, 0x37, 0x3e, 0xd1, 0x76, 0xee, 0xb9, 0xe7, 0x9c, 0xb9, 0x67, 0x86, 0x05,
-	0x94, 0xc6, 0x7e, 0x8f, 0x24, 0x24, 0xbe, 0xa2, 0x3d, 0xb2, 0x3e, 0x88, 0xa3, 0x34, 0x42, 0x76,
-	0xf9, 0x1b, 0x9e, 0x81, 0xe9, 0x83, 0x70, 0x90, 0x5e, 0xe3, 0xd7, 0x30, 0xfb, 0x3e, 0x0a, 0x43,
-	0x9a, 0x1e, 0xee, 0x23, 0x80, 0x36, 0x0d, 0x9c, 0x56, 0xa7, 0xd5, 0x35, 0xd1, 0x3c, 0x18, 0x49,
-	0x74, 0x19, 0xf7, 0x88, 0xd3, 0xe6, 0xef, 0x8b, 0x60, 0xa6, 0x34, 0x24, 0x49, 0xea, 0x87, 0x03,
-	0x47, 0x63, 0x9f, 0x34, 0x4c, 0xc1, 0x38, 0xf6, 0x63, 0x3f, 0x4c, 0xd0, 0x4b, 0x30, 0x06, 0xfc,
-	0x89, 0x81, 0xb5, 0xae, 0xe5, 0x75, 0xd6, 0x15, 0x01, 0xa2, 0x2a, 0xff, 0x73, 0xd0, 0x4f, 0xe3,
-	0x6b, 0xf7, 0x05, 0x58, 0xa5, 0x57, 0x64, 0x81, 0xf6, 0x9d, 0x5c, 0xe7, 0xd4, 0x73, 0x30, 0x7d,
-	0xe5, 0xff, 0xb8, 0xcc, 0x99, 0x77, 0xda, 0xaf, 0x5a, 0xb8, 0x0b, 0xcb, 0x47, 0x34, 0x49, 0x68,
-	0xff, 0x42, 0xa0, 0x4e, 0xc8, 0xcf, 0x4b, 0xa6, 0x05, 0x2d, 0xc0, 0x2c, 0x67, 0xa2, 0x81, 0xa0,
-	0x36, 0xf1, 0x1a, 0xdc, 0xae, 0x54, 0x26, 0x83, 0xa8, 0x9f, 0x90, 0x9a, 0xd2, 0xdf, 0x00, 0xa2,
-	0xe6, 0xd8, 0xa7, 0xb1, 0x2a, 0x61, 0x53, 0x1a, 0x6a, 0x73, 0x43, 0x2b, 0x75, 0x86, 0x32, 0xd8,
-	0xff, 0x98, 0xda, 0x85, 0x85, 0xb7, 0x41, 0xa0, 0x1a, 0xea, 0x4a, 0x62, 0x9d, 0x13, 0x3b, 0x4d,
-	0xc4, 0x78, 0x03, 0xac, 0xd3, 0x34, 0x8a, 0x49, 0x20, 0xc8, 0x56, 0x4a, 0x8a, 0x5b, 0x0c, 0xb8,
-	0x5c, 0x07, 0xc4, 0xab, 0x60, 0x7e, 0xc9, 0x54, 0x8c, 0x3a, 0x56, 0xf4, 0xd9, 0xf8, 0x1b, 0x00,
-	0xd3, 0x36, 0x54, 0x35, 0xdb, 0xe3, 0x21, 0xc9, 0xe3, 0x61, 0x79, 0x77, 0xd4, 0xf6, 0x32, 0x42,
-	0xab, 0x60, 0xf0, 0x36, 0x09, 0xcb, 0x48, 0xa6, 0xff, 0xae, 0x5a, 0x27, 0xc9, 0x59, 0xee, 0xe6,
-	0x4e, 0x48, 0x18, 0x5d, 0x91, 0x89, 0x39, 0xd8, 0x88, 0xad, 0x4f, 0x34, 0x49, 0x0b, 0x20, 0x53,
-	0x7e, 0x4e, 0x2e, 0x68, 0x9f, 0xa3, 0xb4, 0xcc, 0x15, 0xe9, 0x07, 0xdc, 0x86, 0xc6, 0x58, 0x6c,
-	0x51, 0x9a, 0x87, 0x60, 0x0d, 0xcc, 0x82, 0xa4, 0x50, 0xd8, 0xc4, 0xc2, 0xa6, 0xf3, 0x81, 0xa4,
-	0x5c, 0x70, 0x32, 0xb9, 0xc6, 0x43, 0x58, 0x2c, 0xa1, 0x73, 0xf6, 0xe1, 0xe6, 0xe8, 0x63, 0x37,
-	0x27, 0xf3, 0x10, 0x06, 0x5b, 0xce, 0x74, 0x36, 0x19, 0xbc, 0xc2, 0x85, 0xdc, 0x94, 0xfb, 0x3d,
-	0x4e, 0x58, 0xc9, 0xfc, 0xbf, 0xa7, 0xc9, 0x83, 0xe5, 0xa1, 0x5e, 0xff, 0x97, 0xec, 0x20, 0x63,
-	0x91, 0xd9, 0xb5, 0x0b, 0x61, 0x3c, 0xc3, 0xb8, 0x03, 0x88, 0x61, 0xce, 0xb2, 0x8e, 0x87, 0xfb,
-	0x52, 0x5a, 0x71, 0x89, 0x68, 0x5d, 0x9d, 0xc5, 0xcd, 0xca, 0x97, 0xb9, 0x2d, 0x1b, 0x74, 0x1a,
-	0x6c, 0x6f, 0xf2, 0x5e, 0x7a, 0x5e, 0x28, 0x5a, 0xed, 0xc1, 0x92, 0xd2, 0x2a, 0x67, 0x7f, 0x06,
-	0x5a, 0xe1, 0xd0, 0xf2, 0xee, 0xa9, 0xe2, 0x4b, 0x8d, 0xf1, 0x0e, 0xcc, 0x67, 0x63, 0x3e, 0xda,
-	0xdf, 0xaa, 0x9f, 0xd4, 0xb8, 0x39, 0xbf, 0x03, 0x53, 0x3c, 0x33, 0xf4, 0x04, 0x41, 0x57, 0x76,
-	0x62, 0x0f, 0x6e, 0x49, 0xfe, 0x5c, 0xfa, 0xf3, 0x22, 0x69, 0x59, 0x55, 0xab, 0x6e, 0xdc, 0x92,
-	0xd5, 0xfb, 0x63, 0x80, 0xcd, 0xed, 0x9c, 0x8a, 0x25, 0xf4, 0x15, 0xe6, 0x94, 0x4b, 0x0c, 0x61,
-	0x15, 0x5a, 0x77, 0x17, 0xba, 0x4f, 0xc7, 0xd6, 0x08, 0x59, 0x78, 0x0a, 0x31, 0xbf, 0xf2, 0xd6,
-	0x41, 0x8f, 0x54, 0x4c, 0xf5, 0x3a, 0x72, 0x97, 0xd4, 0x75, 0xf1, 0x2f, 0x63, 0x0a, 0x6d, 0x83,
-	0xc6, 0x4a, 0x91, 0x33, 0x82, 0xbe, 0x01, 0xb7, 0x0b, 0x86, 0x38, 0xf4, 0xe8, 0xbe, 0x5a, 0xa0,
-	0x5c, 0x05, 0x4d, 0xe8, 0x37, 0xa0, 0x67, 0xbb, 0x8c, 0x2a, 0x41, 0x28, 0xdd, 0x05, 0xae, 0x5b,
-	0xb7, 0x24, 0xad, 0x7f, 0x06, 0x53, 0x86, 0xbc, 0x6a, 0xbd, 0x7a, 0xd6, 0xdd, 0xc7, 0x8d, 0xeb,
-	0x95, 0x7e, 0xf5, 0x5b, 0x59, 0x3d, 0xb2, 0x35, 0xfd, 0x46, 0x46, 0x73, 0x06, 0x76, 0xf9, 0x10,
-	0xde, 0x28, 0x11, 0x37, 0xad, 0x0f, 0x0f, 0x30, 0xef, 0x6a, 0x95, 0xce, 0x16, 0xea, 0x8c, 0x80,
-	0x2a, 0x27, 0xd8, 0x7d, 0x32, 0xa6, 0x42, 0x76, 0xfd, 0x08, 0x33, 0x79, 0xe4, 0xd1, 0x83, 0xd1,
-	0x4d, 0x1f, 0x9e, 0x44, 0xf7, 0x61, 0xc3, 0xaa, 0xec, 0xe4, 0x81, 0x7e, 0xcc, 0x82, 0x8a, 0xea,
-	0xa6, 0xde, 0x10, 0x85, 0x73, 0x83, 0xff, 0xa6, 0xd9, 0xf8, 0x1b, 0x00, 0x00, 0xff, 0xff, 0x32,
-	0xf5, 0xa4, 0x14, 0xe9, 0x08, 0x00, 0x00,
+	// 870 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0xef, 0x4f, 0xe3, 0x46,
+	0x10, 0xc5, 0x71, 0x12, 0xe2, 0xb1, 0x73, 0x47, 0x96, 0xf4, 0xea, 0xba, 0x3f, 0x2e, 0xdd, 0x46,
+	0x25, 0x9c, 0xd4, 0x13, 0x0a, 0x3f, 0x54, 0x10, 0xa8, 0x0a, 0x0d, 0xa2, 0x48, 0xa5, 0x42, 0x04,
+	0xf5, 0x43, 0x55, 0x09, 0x99, 0x78, 0x9b, 0x5a, 0x60, 0x27, 0xd8, 0x1b, 0x50, 0xfa, 0xa1, 0x7f,
+	0x55, 0xff, 0xc0, 0xca, 0xbb, 0xf6, 0xc6, 0xeb, 0xd8, 0x81, 0xe8, 0x3e, 0x91, 0xb0, 0x33, 0xef,
+	0xbd, 0x99, 0x9d, 0x79, 0x1b, 0x40, 0x34, 0xb0, 0x87, 0x24, 0x24, 0xc1, 0x93, 0x3b, 0x24, 0x1f,
+	0x27, 0xc1, 0x98, 0x8e, 0x91, 0x91, 0xfe, 0x1f, 0x5e, 0x87, 0xca, 0x99, 0x37, 0xa1, 0x33, 0x7c,
+	0x08, 0xb5, 0x9f, 0xc7, 0x9e, 0xe7, 0xd2, 0x8b, 0x3e, 0x02, 0x28, 0xb9, 0x8e, 0xa9, 0xb4, 0x94,
+	0x8e, 0x86, 0xde, 0x40, 0x35, 0x1c, 0x4f, 0x83, 0x21, 0x31, 0x4b, 0xec, 0x7b, 0x03, 0x34, 0xea,
+	0x7a, 0x24, 0xa4, 0xb6, 0x37, 0x31, 0xd5, 0x96, 0xd2, 0x51, 0xb1, 0x0b, 0xd5, 0x2b, 0x3b, 0xb0,
+	0xbd, 0x10, 0xed, 0x40, 0x75, 0xc2, 0x3e, 0x99, 0x4a, 0x4b, 0xed, 0xe8, 0xdd, 0xd6, 0x47, 0x49,
+	0x00, 0x8f, 0x8a, 0xff, 0x9c, 0xf9, 0x34, 0x98, 0x59, 0x3f, 0x80, 0x9e, 0xfa, 0x8a, 0x74, 0x50,
+	0xef, 0xc9, 0x2c, 0xa6, 0xae, 0x43, 0xe5, 0xc9, 0x7e, 0x98, 0xc6, 0xcc, 0x47, 0xa5, 0x1f, 0x15,
+	0xdc, 0x81, 0xe6, 0xa5, 0x1b, 0x86, 0xae, 0x3f, 0xe2, 0x59, 0xd7, 0xe4, 0x71, 0x4a, 0x42, 0x8a,
+	0x36, 0xa0, 0xc6, 0x98, 0x5c, 0x87, 0x53, 0x6b, 0x78, 0x1b, 0x3e, 0xcb, 0x44, 0x86, 0x93, 0xb1,
+	0x1f, 0x92, 0x9c, 0xd0, 0x7f, 0x01, 0x78, 0xcc, 0x95, 0xed, 0x06, 0xb2, 0x84, 0x3d, 0x51, 0x50,
+	0x89, 0x15, 0xd4, 0xce, 0x2b, 0x28, 0x4a, 0xfb, 0x94, 0xa2, 0x8e, 0x61, 0xa3, 0xe7, 0x38, 0x72,
+	0x41, 0x1d, 0x41, 0x5c, 0x66, 0xc4, 0x66, 0x11, 0x31, 0xde, 0x05, 0x7d, 0x40, 0xc7, 0x01, 0x71,
+	0x38, 0x59, 0x3b, 0xa5, 0x58, 0xe9, 0xe8, 0xdd, 0x66, 0x5e, 0x22, 0xde, 0x02, 0xed, 0xf7, 0x48,
+	0xc5, 0x62, 0xc5, 0x92, 0x3e, 0x03, 0xdf, 0x02, 0xf4, 0x1c, 0x67, 0xae, 0xaa, 0x36, 0x64, 0x43,
+	0x12, 0x8f, 0x87, 0xde, 0x7d, 0x27, 0xc3, 0x8b, 0x11, 0xda, 0x82, 0x2a, 0x83, 0x09, 0x4d, 0x95,
+	0xe9, 0xff, 0x5c, 0x8e, 0x13, 0xe4, 0xf8, 0x10, 0xea, 0xd7, 0xc4, 0x1b, 0x3f, 0x91, 0x95, 0x39,
+	0xf0, 0x36, 0xe8, 0xbf, 0xba, 0x21, 0x4d, 0x12, 0xeb, 0x50, 0xb9, 0x23, 0x23, 0xd7, 0x67, 0x59,
+	0x6a, 0x54, 0x15, 0xf1, 0x1d, 0x56, 0x86, 0x8a, 0x0f, 0xc1, 0xe0, 0xa1, 0xf1, 0x10, 0x6c, 0x83,
+	0x96, 0x90, 0x24, 0x0a, 0x8b, 0x58, 0x8e, 0x61, 0xe3, 0x9c, 0x50, 0x26, 0x38, 0x5c, 0x5d, 0x23,
+	0x81, 0x77, 0x22, 0x7b, 0x40, 0x03, 0x62, 0x7b, 0xab, 0xf7, 0xb2, 0x0e, 0x95, 0xc7, 0x29, 0x09,
+	0x66, 0xf1, 0x06, 0x22, 0x80, 0xe1, 0xdf, 0x53, 0xff, 0xfe, 0x36, 0x74, 0xff, 0x21, 0x6c, 0x05,
+	0x2b, 0xf8, 0x02, 0x1a, 0x29, 0x91, 0x71, 0x91, 0xf3, 0x3b, 0x28, 0x2f, 0xbd, 0x83, 0xa8, 0x55,
+	0x9e, 0xb3, 0x6f, 0x56, 0x22, 0x78, 0xdc, 0x66, 0xf5, 0xbe, 0xb4, 0x5e, 0x27, 0xd0, 0x48, 0x45,
+	0xc5, 0x84, 0xaf, 0x1f, 0xda, 0x2e, 0x34, 0xe7, 0x7a, 0xed, 0x67, 0x81, 0x20, 0xa6, 0x2f, 0xea,
+	0x88, 0x91, 0x08, 0x63, 0x75, 0xe3, 0x16, 0xa0, 0x73, 0x42, 0x6f, 0x22, 0xc4, 0x8b, 0xbe, 0x90,
+	0x96, 0x78, 0x95, 0xda, 0x29, 0xe3, 0x2d, 0xd0, 0xe3, 0x63, 0x56, 0x96, 0x01, 0x65, 0xd7, 0x39,
+	0xd8, 0x63, 0x58, 0xe5, 0x38, 0x90, 0x43, 0x9d, 0xc0, 0xa6, 0x04, 0x15, 0xb3, 0x7f, 0x0f, 0x6a,
+	0x52, 0xa1, 0xde, 0xfd, 0x42, 0x16, 0x9f, 0x02, 0xc6, 0x47, 0xf0, 0x26, 0x9a, 0xa6, 0xcb, 0xfe,
+	0x7e, 0xfe, 0x65, 0x2e, 0x1b, 0xa7, 0x53, 0xd0, 0xf8, 0xe7, 0xcb, 0xfe, 0xfe, 0x0a, 0x33, 0x20,
+	0x75, 0xe2, 0x04, 0xde, 0x0a, 0xfe, 0x58, 0xfa, 0x87, 0x64, 0xa0, 0xa3, 0x28, 0x25, 0xef, 0xba,
+	0x05, 0x2b, 0xee, 0x81, 0x3e, 0x60, 0x96, 0x3e, 0xa0, 0x36, 0x0d, 0x53, 0x0e, 0xcf, 0x1d, 0x60,
+	0x13, 0x74, 0x7f, 0xea, 0xdd, 0x72, 0x38, 0x6e, 0x23, 0x2a, 0x5b, 0xae, 0x19, 0x65, 0xeb, 0x1c,
+	0xed, 0xd3, 0x88, 0x0d, 0x09, 0xcb, 0x17, 0x12, 0x1a, 0xa0, 0xfd, 0xe5, 0x3e, 0x10, 0x3e, 0x96,
+	0x7c, 0x07, 0x9b, 0x60, 0x44, 0x50, 0x62, 0x76, 0x38, 0xd6, 0x07, 0x58, 0xe7, 0x84, 0xc9, 0xea,
+	0x65, 0x5a, 0x9d, 0x12, 0xd7, 0xfd, 0xaf, 0x06, 0x06, 0x6b, 0xfd, 0x80, 0x1f, 0xa2, 0x3f, 0xa0,
+	0x2e, 0xf9, 0x3a, 0xc2, 0x72, 0x72, 0xde, 0xf3, 0x60, 0x7d, 0xb7, 0x34, 0x86, 0xeb, 0xc7, 0x6b,
+	0xe8, 0x14, 0x34, 0x61, 0xc4, 0xe8, 0x1b, 0x39, 0x27, 0xeb, 0xd0, 0xd6, 0xa6, 0x7c, 0xce, 0x5f,
+	0xd1, 0x35, 0x74, 0x00, 0x6a, 0xcf, 0x71, 0x90, 0xb9, 0x90, 0xfd, 0x42, 0xde, 0x31, 0x54, 0xb9,
+	0x0f, 0xa2, 0x2f, 0xe5, 0x00, 0xc9, 0x1d, 0x8b, 0xb2, 0x7f, 0x82, 0x72, 0x34, 0x11, 0x28, 0xd3,
+	0xc9, 0x94, 0x3d, 0x5a, 0x56, 0xde, 0x91, 0x28, 0xfd, 0x37, 0xd0, 0xc4, 0x42, 0x66, 0x4b, 0xcf,
+	0xda, 0x9f, 0xf5, 0xbe, 0xf0, 0x3c, 0x83, 0x97, 0xdf, 0xca, 0xac, 0xbd, 0x58, 0xef, 0x0b, 0xcf,
+	0x05, 0xde, 0x0d, 0x18, 0x69, 0xc3, 0x78, 0x51, 0x22, 0x2e, 0x3a, 0xb7, 0x9f, 0x25, 0x54, 0x3d,
+	0xe5, 0x03, 0xa8, 0xb5, 0x90, 0x94, 0x71, 0x1b, 0xeb, 0xdb, 0x25, 0x11, 0x02, 0xf5, 0x17, 0x58,
+	0x8f, 0xd7, 0x13, 0x7d, 0xb5, 0xd8, 0xf4, 0xb9, 0x6b, 0x58, 0x5f, 0x17, 0x9c, 0x0a, 0xa4, 0x2e,
+	0x94, 0xaf, 0x5c, 0x7f, 0x84, 0xf2, 0x6e, 0xbd, 0x68, 0x14, 0xfe, 0x84, 0xb7, 0x99, 0x17, 0x07,
+	0xb5, 0x0b, 0x9a, 0x21, 0x3d, 0x48, 0xaf, 0xb8, 0xd5, 0x1d, 0x05, 0xdd, 0x01, 0x9a, 0x1f, 0xd8,
+	0xcf, 0x2b, 0x11, 0xbc, 0xea, 0x4e, 0x76, 0x14, 0xd4, 0x83, 0x5a, 0x62, 0x2e, 0xf9, 0x95, 0x2f,
+	0x5e, 0xbe, 0xe4, 0x44, 0x78, 0xed, 0xae, 0xca, 0x7e, 0xeb, 0xee, 0xfe, 0x3f, 0x00, 0x96, 0xbf,
+	0x13, 0x53, 0x01, 0x0b, 0x00, 0x00,
 }
@@ -3,15 +3,19 @@ package traceservice
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
+	"net"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/boltdb/bolt"
 	"github.com/stretchr/testify/assert"
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/perf/go/types"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -289,3 +293,444 @@ func TestCommitInfo(t *testing.T) {
 	_, err = NewCommitInfo(b[:len(b)-1])
 	assert.Error(t, err)
 }
+
+// addCommits adds one value for the trace "key:1" to each of the given commits.
+func addCommits(t *testing.T, ts *TraceServiceImpl, commitIDs []*CommitID) {
+	for _, cid := range commitIDs {
+		_, err := ts.Add(context.Background(), &AddRequest{
+			Commitid: cid,
+			Values: []*ValuePair{
+				&ValuePair{
+					Key:   "key:1",
+					Value: []byte("foo"),
+				},
+			},
+		})
+		assert.NoError(t, err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	ts, err := NewTraceServiceServer(FILENAME)
+	assert.NoError(t, err)
+	defer util.Close(ts)
+	defer cleanup()
+
+	now := time.Unix(100000, 0)
+	commitIDs := []*CommitID{
+		&CommitID{Timestamp: now.Add(-3 * time.Hour).Unix(), Id: "1", Source: "master"},
+		&CommitID{Timestamp: now.Add(-2 * time.Hour).Unix(), Id: "2", Source: "master"},
+		&CommitID{Timestamp: now.Add(-1 * time.Hour).Unix(), Id: "3", Source: "master"},
+		&CommitID{Timestamp: now.Add(-3 * time.Hour).Unix(), Id: "1", Source: "https://codereview.chromium.org/1"},
+		&CommitID{Timestamp: now.Add(-1 * time.Minute).Unix(), Id: "2", Source: "https://codereview.chromium.org/1"},
+		&CommitID{Timestamp: now.Add(-2 * time.Hour).Unix(), Id: "1", Source: "https://codereview.chromium.org/2"},
+		&CommitID{Timestamp: now.Add(-3 * time.Hour).Unix(), Id: "1", Source: "other"},
+	}
+	addCommits(t, ts, commitIDs)
+
+	rules := []*RetentionRule{
+		&RetentionRule{SourcePrefix: "https://codereview.chromium.org/", MaxAge: "90m"},
+		&RetentionRule{SourcePrefix: "master", MaxCommits: 2},
+	}
+	for _, r := range rules {
+		assert.NoError(t, r.Validate())
+	}
+	assert.Error(t, (&RetentionRule{MaxAge: "-1h"}).Validate())
+	assert.Error(t, (&RetentionRule{MaxAge: "fred"}).Validate())
+	assert.Error(t, (&RetentionRule{MaxCommits: -1}).Validate())
+
+	n, err := ts.ApplyRetention(rules, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	listResp, err := ts.List(context.Background(), &ListRequest{Begin: 0, End: now.Unix()})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(listResp.Commitids))
+	found := map[string]bool{}
+	for _, cid := range listResp.Commitids {
+		found[cid.Source+"/"+cid.Id] = true
+	}
+	assert.Equal(t, map[string]bool{
+		"master/2":                            true,
+		"master/3":                            true,
+		"https://codereview.chromium.org/1/2": true,
+		"other/1":                             true,
+	}, found)
+
+	// Applying the rules again is a no-op.
+	n, err = ts.ApplyRetention(rules, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestGetStatsAndCompact(t *testing.T) {
+	ts, err := NewTraceServiceServer(FILENAME)
+	assert.NoError(t, err)
+	defer util.Close(ts)
+	defer cleanup()
+
+	commitIDs := []*CommitID{}
+	for i := 0; i < 1000; i++ {
+		commitIDs = append(commitIDs, &CommitID{
+			Timestamp: int64(i),
+			Id:        fmt.Sprintf("%d", i),
+			Source:    "https://codereview.chromium.org/1",
+		})
+	}
+	commitIDs = append(commitIDs, &CommitID{Timestamp: 2000, Id: "abc", Source: "master"})
+	addCommits(t, ts, commitIDs)
+
+	stats, err := ts.GetStats(context.Background(), &Empty{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.NumTraceids)
+	assert.Equal(t, 2, len(stats.Sources))
+	assert.Equal(t, "https://codereview.chromium.org/1", stats.Sources[0].Source)
+	assert.Equal(t, int64(1000), stats.Sources[0].NumCommits)
+	// Each value is 8 bytes of trace64id, 1 byte of length and 3 bytes of data.
+	assert.Equal(t, int64(12000), stats.Sources[0].Bytes)
+	assert.Equal(t, &SourceStats{Source: "master", NumCommits: 1, Bytes: 12}, stats.Sources[1])
+	sizeBefore := stats.FileSize
+
+	rules := []*RetentionRule{&RetentionRule{SourcePrefix: "https://", MaxCommits: 1}}
+	assert.NoError(t, rules[0].Validate())
+	n, err := ts.ApplyRetention(rules, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 999, n)
+
+	assert.NoError(t, ts.Compact())
+	_, err = os.Stat(FILENAME + COMPACT_SUFFIX)
+	assert.True(t, os.IsNotExist(err))
+
+	stats, err = ts.GetStats(context.Background(), &Empty{})
+	assert.NoError(t, err)
+	assert.True(t, stats.FileSize < sizeBefore)
+	assert.Equal(t, int64(1), stats.Sources[0].NumCommits)
+
+	// The compacted datastore is still readable and writable, and new traceids
+	// don't collide with existing ones.
+	resp, err := ts.GetValues(context.Background(), &GetValuesRequest{Commitid: commitIDs[1000]})
+	assert.NoError(t, err)
+	assert.Equal(t, []*ValuePair{&ValuePair{Key: "key:1", Value: []byte("foo")}}, resp.Values)
+
+	ids, err := ts.atomize([]string{"key:1", "key:2"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"key:1": 1, "key:2": 2}, ids)
+}
+
+func TestCompactConcurrentWrites(t *testing.T) {
+	ts, err := NewTraceServiceServer(FILENAME)
+	assert.NoError(t, err)
+	defer util.Close(ts)
+	defer cleanup()
+
+	commitIDs := []*CommitID{}
+	for i := 0; i < 200; i++ {
+		commitIDs = append(commitIDs, &CommitID{
+			Timestamp: int64(i),
+			Id:        fmt.Sprintf("%d", i),
+			Source:    "master",
+		})
+	}
+	addCommits(t, ts, commitIDs[:100])
+
+	// Write the rest of the commits while compacting, all of them must be
+	// present afterwards.
+	done := make(chan bool)
+	go func() {
+		addCommits(t, ts, commitIDs[100:])
+		done <- true
+	}()
+	assert.NoError(t, ts.Compact())
+	<-done
+
+	listResp, err := ts.List(context.Background(), &ListRequest{Begin: 0, End: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, len(listResp.Commitids))
+}
+
+func TestWritesDuringCompact(t *testing.T) {
+	ts, err := NewTraceServiceServer(FILENAME)
+	assert.NoError(t, err)
+	defer util.Close(ts)
+	defer cleanup()
+
+	commitIDs := []*CommitID{}
+	for i := 0; i < 20; i++ {
+		commitIDs = append(commitIDs, &CommitID{
+			Timestamp: int64(i),
+			Id:        fmt.Sprintf("%d", i),
+			Source:    "master",
+		})
+	}
+	addCommits(t, ts, commitIDs[:10])
+
+	// Writes made after the copy must not wait for the compaction, and must
+	// be in the compacted datastore.
+	ts.copied = func() {
+		done := make(chan bool)
+		go func() {
+			addCommits(t, ts, commitIDs[10:])
+			_, err := ts.Remove(context.Background(), &RemoveRequest{Commitid: commitIDs[0]})
+			assert.NoError(t, err)
+			done <- true
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			assert.FailNow(t, "Writes were blocked by the compaction.")
+		}
+	}
+	assert.NoError(t, ts.Compact())
+
+	listResp, err := ts.List(context.Background(), &ListRequest{Begin: 0, End: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, 19, len(listResp.Commitids))
+	resp, err := ts.GetValues(context.Background(), &GetValuesRequest{Commitid: commitIDs[15]})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(resp.Values))
+	assert.Equal(t, "key:1", resp.Values[0].Key)
+}
+
+func TestCopyBuckets(t *testing.T) {
+	src, err := bolt.Open(FILENAME, 0600, nil)
+	assert.NoError(t, err)
+	defer util.Close(src)
+	defer cleanup()
+	dst, err := bolt.Open(FILENAME+COMPACT_SUFFIX, 0600, nil)
+	assert.NoError(t, err)
+	defer util.Close(dst)
+	defer func() {
+		assert.NoError(t, os.Remove(FILENAME+COMPACT_SUFFIX))
+	}()
+
+	// A bucket large enough to be copied in several batches, with nested
+	// buckets in the first and the last batch.
+	numKeys := 2*COMPACT_BATCH_SIZE + 10
+	err = src.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("large"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < numKeys; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("%06d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+				return err
+			}
+		}
+		first, err := b.CreateBucket([]byte("000000a"))
+		if err != nil {
+			return err
+		}
+		if err := first.Put([]byte("foo"), []byte("bar")); err != nil {
+			return err
+		}
+		inner, err := first.CreateBucket([]byte("inner"))
+		if err != nil {
+			return err
+		}
+		if err := inner.Put([]byte("baz"), []byte("quux")); err != nil {
+			return err
+		}
+		last, err := b.CreateBucket([]byte("zzz"))
+		if err != nil {
+			return err
+		}
+		if err := last.Put([]byte("x"), []byte("y")); err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket([]byte("empty"))
+		return err
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, copyBuckets(src, dst))
+
+	err = dst.View(func(tx *bolt.Tx) error {
+		assert.NotNil(t, tx.Bucket([]byte("empty")))
+		b := tx.Bucket([]byte("large"))
+		assert.NotNil(t, b)
+		n := 0
+		assert.NoError(t, b.ForEach(func(k, v []byte) error {
+			if v != nil {
+				n++
+			}
+			return nil
+		}))
+		assert.Equal(t, numKeys, n)
+		assert.Equal(t, []byte("value123"), b.Get([]byte("000123")))
+		assert.Equal(t, []byte("bar"), b.Bucket([]byte("000000a")).Get([]byte("foo")))
+		assert.Equal(t, []byte("quux"), b.Bucket([]byte("000000a")).Bucket([]byte("inner")).Get([]byte("baz")))
+		assert.Equal(t, []byte("y"), b.Bucket([]byte("zzz")).Get([]byte("x")))
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+// startServerForTesting starts an in-process gRPC server for ts and returns a
+// client connected to it, along with a func that stops both.
+func startServerForTesting(t *testing.T, ts *TraceServiceImpl) (TraceServiceClient, func()) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	s := grpc.NewServer()
+	RegisterTraceServiceServer(s, ts)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	return NewTraceServiceClient(conn), func() {
+		util.Close(conn)
+		s.Stop()
+	}
+}
+
+func TestGetValuesStream(t *testing.T) {
+	ts, err := NewTraceServiceServer(FILENAME)
+	assert.NoError(t, err)
+	defer util.Close(ts)
+	defer cleanup()
+
+	client, stop := startServerForTesting(t, ts)
+	defer stop()
+	ctx := context.Background()
+
+	// Add five traces, three with config=8888 and two with config=565.
+	cid := &CommitID{Timestamp: 100, Id: "abc123", Source: "master"}
+	params := &AddParamsRequest{Params: []*ParamsPair{}}
+	values := &AddRequest{Commitid: cid, Values: []*ValuePair{}}
+	for i := 0; i < 5; i++ {
+		config := "8888"
+		if i%2 == 1 {
+			config = "565"
+		}
+		key := fmt.Sprintf(",config=%s,name=t%d,", config, i)
+		params.Params = append(params.Params, &ParamsPair{
+			Key:    key,
+			Params: map[string]string{"config": config, "name": fmt.Sprintf("t%d", i)},
+		})
+		values.Values = append(values.Values, &ValuePair{Key: key, Value: []byte{byte(i)}})
+	}
+	_, err = client.AddParams(ctx, params)
+	assert.NoError(t, err)
+	_, err = client.Add(ctx, values)
+	assert.NoError(t, err)
+	full, err := client.GetValues(ctx, &GetValuesRequest{Commitid: cid})
+	assert.NoError(t, err)
+
+	// readAll reads all the chunks of a GetValuesStream call.
+	readAll := func(req *GetValuesStreamRequest) ([]*GetValuesResponse, map[string][]byte) {
+		stream, err := client.GetValuesStream(ctx, req)
+		assert.NoError(t, err)
+		chunks := []*GetValuesResponse{}
+		found := map[string][]byte{}
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			chunks = append(chunks, resp)
+			for _, v := range resp.Values {
+				found[v.Key] = v.Value
+			}
+		}
+		return chunks, found
+	}
+
+	chunks, found := readAll(&GetValuesStreamRequest{Commitid: cid, ChunkSize: 2})
+	assert.Equal(t, 3, len(chunks))
+	assert.Equal(t, 5, len(found))
+	assert.Equal(t, []byte{4}, found[",config=8888,name=t4,"])
+	for _, c := range chunks {
+		assert.Equal(t, full.Md5, c.Md5)
+	}
+
+	chunks, found = readAll(&GetValuesStreamRequest{Commitid: cid, Query: "config=565"})
+	assert.Equal(t, 1, len(chunks))
+	assert.Equal(t, map[string][]byte{
+		",config=565,name=t1,": []byte{1},
+		",config=565,name=t3,": []byte{3},
+	}, found)
+
+	chunks, found = readAll(&GetValuesStreamRequest{Commitid: cid, Query: "config=565&name=~t[0-2]"})
+	assert.Equal(t, map[string][]byte{",config=565,name=t1,": []byte{1}}, found)
+
+	// A query that matches nothing still returns the md5.
+	chunks, found = readAll(&GetValuesStreamRequest{Commitid: cid, Query: "config=gpu"})
+	assert.Equal(t, 1, len(chunks))
+	assert.Equal(t, 0, len(found))
+	assert.Equal(t, full.Md5, chunks[0].Md5)
+
+	// Invalid queries are rejected.
+	stream, err := client.GetValuesStream(ctx, &GetValuesStreamRequest{Commitid: cid, Query: "config=~["})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Error(t, err)
+
+	// The raw stream returns the same values, in chunks that decode with NewCommitInfo.
+	rawStream, err := client.GetValuesRawStream(ctx, &GetValuesStreamRequest{Commitid: cid, Query: "config=8888", ChunkSize: 2})
+	assert.NoError(t, err)
+	numChunks := 0
+	rawValues := map[uint64][]byte{}
+	for {
+		resp, err := rawStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		numChunks += 1
+		assert.Equal(t, full.Md5, resp.Md5)
+		ci, err := NewCommitInfo(resp.Value)
+		assert.NoError(t, err)
+		for k, v := range ci.Values {
+			rawValues[k] = v
+		}
+	}
+	assert.Equal(t, 2, numChunks)
+	assert.Equal(t, 3, len(rawValues))
+	ids := []uint64{}
+	for id64, _ := range rawValues {
+		ids = append(ids, id64)
+	}
+	traceids, err := client.GetTraceIDs(ctx, &GetTraceIDsRequest{Id: ids})
+	assert.NoError(t, err)
+	for _, tid := range traceids.Ids {
+		assert.Contains(t, tid.Id, ",config=8888,")
+	}
+}
+
+func TestStreamValuesUnlocked(t *testing.T) {
+	ts, err := NewTraceServiceServer(FILENAME)
+	assert.NoError(t, err)
+	defer util.Close(ts)
+	defer cleanup()
+	ctx := context.Background()
+
+	cid := &CommitID{Timestamp: 100, Id: "abc123", Source: "master"}
+	values := &AddRequest{Commitid: cid, Values: []*ValuePair{}}
+	for i := 0; i < 5; i++ {
+		values.Values = append(values.Values, &ValuePair{Key: fmt.Sprintf(",name=t%d,", i), Value: []byte{byte(i)}})
+	}
+	_, err = ts.Add(ctx, values)
+	assert.NoError(t, err)
+
+	// The datastore isn't locked while a chunk is sent, so writes can go
+	// ahead, and the stream fails once it notices the change.
+	numChunks := 0
+	err = ts.streamValues(&GetValuesStreamRequest{Commitid: cid, ChunkSize: 2}, false, func(chunk *CommitInfo, traceids map[uint64]string, hash string) error {
+		numChunks += 1
+		added := make(chan error)
+		go func() {
+			_, err := ts.Add(ctx, &AddRequest{Commitid: cid, Values: []*ValuePair{&ValuePair{Key: ",name=new,", Value: []byte{9}}}})
+			added <- err
+		}()
+		select {
+		case err := <-added:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "Write blocked while a chunk was sent.")
+		}
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, numChunks)
+}
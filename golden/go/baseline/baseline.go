@@ -0,0 +1,102 @@
+// baseline contains functions to export the expectations as a baseline that
+// can be consumed by test harnesses. A harness can use the baseline to skip
+// uploading digests that are already known to be positive and to fail early
+// on digests that are known to be negative.
+package baseline
+
+import (
+	"crypto/md5"
+	"fmt"
+	"sort"
+
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// FORMAT_VERSION is the version of the Baseline format. It needs to be
+// incremented whenever the format changes in a way that is not backwards
+// compatible.
+const FORMAT_VERSION = 1
+
+// Baseline contains the positive and negative digests of each test.
+type Baseline struct {
+	// FormatVersion is the version of the format, see FORMAT_VERSION.
+	FormatVersion int `json:"formatVersion"`
+
+	// ExpVersion is the version of the expectations the baseline was derived
+	// from, see expstorage.ExpectationsStore.Version.
+	ExpVersion int64 `json:"expVersion"`
+
+	// Issue is the tryjob issue the baseline is scoped to. Empty if the
+	// baseline contains all tests.
+	Issue string `json:"issue,omitempty"`
+
+	// Positive and Negative map test names to sorted lists of digests.
+	Positive map[string][]string `json:"positive"`
+	Negative map[string][]string `json:"negative"`
+}
+
+// New returns the baseline for the given expectations. If tests is not nil,
+// only the given tests are included in the baseline.
+func New(exp *expstorage.Expectations, expVersion int64, issue string, tests util.StringSet) *Baseline {
+	ret := &Baseline{
+		FormatVersion: FORMAT_VERSION,
+		ExpVersion:    expVersion,
+		Issue:         issue,
+		Positive:      map[string][]string{},
+		Negative:      map[string][]string{},
+	}
+
+	for testName, digests := range exp.Tests {
+		if (tests != nil) && !tests[testName] {
+			continue
+		}
+		for digest, label := range digests {
+			switch label {
+			case types.POSITIVE:
+				ret.Positive[testName] = append(ret.Positive[testName], digest)
+			case types.NEGATIVE:
+				ret.Negative[testName] = append(ret.Negative[testName], digest)
+			}
+		}
+	}
+
+	for _, digests := range ret.Positive {
+		sort.Strings(digests)
+	}
+	for _, digests := range ret.Negative {
+		sort.Strings(digests)
+	}
+	return ret
+}
+
+// ETag returns the entity tag of the baseline that New returns for the given
+// arguments. It can be calculated without retrieving the expectations, which
+// allows to answer conditional requests cheaply.
+func ETag(expVersion int64, issue string, tests util.StringSet) string {
+	if tests == nil {
+		return fmt.Sprintf(`"%d-%d"`, FORMAT_VERSION, expVersion)
+	}
+
+	// The tests of an issue change while its tryjobs are ingested, so they
+	// need to be part of the tag.
+	testNames := tests.Keys()
+	sort.Strings(testNames)
+	hash := md5.New()
+	for _, testName := range testNames {
+		_, _ = hash.Write([]byte(testName))
+		_, _ = hash.Write([]byte{0})
+	}
+	return fmt.Sprintf(`"%d-%d-%s-%x"`, FORMAT_VERSION, expVersion, issue, hash.Sum(nil))
+}
+
+// TestsInTile returns the names of all tests that appear in the given tile.
+func TestsInTile(tile *tiling.Tile) util.StringSet {
+	ret := util.StringSet{}
+	for _, trace := range tile.Traces {
+		ret[trace.Params()[types.PRIMARY_KEY_FIELD]] = true
+	}
+	return ret
+}
@@ -0,0 +1,61 @@
+package baseline
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/types"
+)
+
+func TestBaseline(t *testing.T) {
+	exp := &expstorage.Expectations{
+		Tests: map[string]types.TestClassification{
+			"test1": types.TestClassification{
+				"d12": types.POSITIVE,
+				"d11": types.POSITIVE,
+				"d13": types.NEGATIVE,
+				"d14": types.UNTRIAGED,
+			},
+			"test2": types.TestClassification{
+				"d21": types.NEGATIVE,
+			},
+		},
+	}
+
+	b := New(exp, 5, "", nil)
+	assert.Equal(t, FORMAT_VERSION, b.FormatVersion)
+	assert.Equal(t, int64(5), b.ExpVersion)
+	assert.Equal(t, map[string][]string{"test1": []string{"d11", "d12"}}, b.Positive)
+	assert.Equal(t, map[string][]string{
+		"test1": []string{"d13"},
+		"test2": []string{"d21"},
+	}, b.Negative)
+
+	tests := util.NewStringSet([]string{"test2"})
+	b = New(exp, 5, "1234", tests)
+	assert.Equal(t, "1234", b.Issue)
+	assert.Equal(t, map[string][]string{}, b.Positive)
+	assert.Equal(t, map[string][]string{"test2": []string{"d21"}}, b.Negative)
+
+	// The tag must change with the version of the expectations, the issue
+	// and the included tests.
+	assert.Equal(t, ETag(5, "", nil), ETag(5, "", nil))
+	assert.NotEqual(t, ETag(5, "", nil), ETag(6, "", nil))
+	assert.Equal(t, ETag(5, "1234", tests), ETag(5, "1234", util.NewStringSet([]string{"test2"})))
+	assert.NotEqual(t, ETag(5, "1234", tests), ETag(5, "1235", tests))
+	assert.NotEqual(t, ETag(5, "1234", tests), ETag(5, "1234", util.NewStringSet([]string{"test1", "test2"})))
+	assert.NotEqual(t, ETag(5, "", nil), ETag(5, "1234", util.StringSet{}))
+}
+
+func TestTestsInTile(t *testing.T) {
+	tile := tiling.NewTile()
+	for traceID, testName := range map[string]string{"a": "test1", "b": "test2", "c": "test1"} {
+		trace := types.NewGoldenTraceN(1)
+		trace.Params_[types.PRIMARY_KEY_FIELD] = testName
+		tile.Traces[traceID] = trace
+	}
+	assert.Equal(t, util.NewStringSet([]string{"test1", "test2"}), TestsInTile(tile))
+}
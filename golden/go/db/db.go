@@ -169,6 +169,20 @@ var migrationSteps = []database.MigrationStep{
 		},
 	},
 
+	// Add the reason of triage changes and an index to query the triage
+	// history of a digest.
+	// version 11
+	{
+		MySQLUp: []string{
+			`ALTER TABLE exp_change ADD reason VARCHAR(1024) NOT NULL DEFAULT ''`,
+			`CREATE INDEX name_digest_idx ON exp_test_change (name, digest)`,
+		},
+		MySQLDown: []string{
+			`DROP INDEX name_digest_idx ON exp_test_change`,
+			`ALTER TABLE exp_change DROP reason`,
+		},
+	},
+
 	// Use this is a template for more migration steps.
 	// version x
 	// {
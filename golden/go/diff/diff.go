@@ -11,6 +11,7 @@ import (
 
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/perdiff/go/yee"
 )
 
 var (
@@ -64,6 +65,13 @@ type DiffMetrics struct {
 	MaxRGBADiffs []int
 	// True if the dimensions of the compared images are different.
 	DimDiffer bool
+	// NumPerceptualDiffPixels is the number of pixels whose difference is
+	// visible to the human eye, see PerceptualDiffPixels. Only valid if
+	// HasPerceptualDiff is true.
+	NumPerceptualDiffPixels int
+	// True if NumPerceptualDiffPixels has been calculated. It is false for
+	// DiffMetrics that were cached before the perceptual diff was added.
+	HasPerceptualDiff bool
 }
 
 // Diff error to indicate different error conditions during diffing.
@@ -311,3 +319,23 @@ func Diff(img1, img2 image.Image) (*DiffMetrics, *image.NRGBA) {
 		MaxRGBADiffs:     maxRGBADiffs,
 		DimDiffer:        (cmpWidth != resultWidth) || (cmpHeight != resultHeight)}, resultImg
 }
+
+// PerceptualDiffPixels returns the number of pixels that are perceptually
+// different between the two images, using the Yee perceptual metric. Unlike
+// the number of different pixels it ignores differences that are not visible,
+// like sub-pixel shifts of anti-aliased edges. If the dimensions of the images
+// differ all pixels of the larger bounds are counted as different.
+func PerceptualDiffPixels(img1, img2 image.Image) int {
+	img1Bounds := img1.Bounds()
+	img2Bounds := img2.Bounds()
+	if !img1Bounds.Eq(img2Bounds) {
+		return util.MaxInt(img1Bounds.Dx(), img2Bounds.Dx()) * util.MaxInt(img1Bounds.Dy(), img2Bounds.Dy())
+	}
+
+	_, ret, err := yee.Compare(img1, img2, yee.DefaultOptions())
+	if err != nil {
+		glog.Errorf("Perceptual diff failed: %s", err)
+		return img1Bounds.Dx() * img1Bounds.Dy()
+	}
+	return ret
+}
@@ -282,6 +282,32 @@ func assertDiffs(t *testing.T, d1, d2 string, expectedDiffMetrics *DiffMetrics)
 	}
 }
 
+func TestPerceptualDiffPixels(t *testing.T) {
+	testCases := []struct {
+		d1   string
+		d2   string
+		want int
+	}{
+		// The same image.
+		{"5024150605949408692", "5024150605949408692", 0},
+		// Pixel differences that are not visible.
+		{"4029959456464745507", "16465366847175223174", 0},
+		{"5024150605949408692", "11069776588985027208", 0},
+		// Inverted colors.
+		{"4029959456464745507", "4029959456464745507-inverted", 1069},
+		// Different dimensions, 800x800 vs 288x480.
+		{"ffce5042b4ac4a57bd7c8657b557d495", "fffbcca7e8913ec45b88cc2c6a3a73ad", 640000},
+	}
+
+	for _, tc := range testCases {
+		img1, err := OpenImage(filepath.Join(TESTDATA_DIR, tc.d1+".png"))
+		assert.NoError(t, err)
+		img2, err := OpenImage(filepath.Join(TESTDATA_DIR, tc.d2+".png"))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, PerceptualDiffPixels(img1, img2), "%s vs %s", tc.d1, tc.d2)
+	}
+}
+
 func TestDeltaOffset(t *testing.T) {
 	testCases := []struct {
 		offset int
@@ -3,7 +3,7 @@ package diffstore
 import (
 	"bytes"
 	"fmt"
-	"net/http"
+	"image"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -15,6 +15,7 @@ import (
 	"go.skia.org/infra/go/fileutil"
 	"go.skia.org/infra/go/rtcache"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/imagesource"
 )
 
 const (
@@ -81,13 +82,18 @@ type MemDiffStore struct {
 
 	// wg is used to synchronize background operations like saving files. Used for testing.
 	wg sync.WaitGroup
+
+	// perceptual is true if METRIC_PERCEPTUAL is calculated.
+	perceptual bool
 }
 
-// New returns a new instance of MemDiffStore.
-func New(client *http.Client, baseDir, gsBucketName, gsImageBaseDir string) (*MemDiffStore, error) {
+// New returns a new instance of MemDiffStore that retrieves images from the
+// given image source. If perceptual is true the expensive METRIC_PERCEPTUAL is
+// calculated as well, including for diff records that were stored without it.
+func New(source imagesource.ImageSource, baseDir string, perceptual bool) (*MemDiffStore, error) {
 	// Set up image retrieval, caching and serving.
 	imgDir := fileutil.Must(fileutil.EnsureDirExists(filepath.Join(baseDir, DEFAULT_IMG_DIR_NAME)))
-	imgLoader, err := newImgLoader(client, imgDir, gsBucketName, gsImageBaseDir)
+	imgLoader, err := newImgLoader(source, imgDir)
 	if err != err {
 		return nil, err
 	}
@@ -102,6 +108,7 @@ func New(client *http.Client, baseDir, gsBucketName, gsImageBaseDir string) (*Me
 		imgLoader:        imgLoader,
 		metricsDB:        metricsDB,
 		diffMetricsCodec: util.JSONCodec(&DiffRecord{}),
+		perceptual:       perceptual,
 	}
 
 	ret.diffMetricsCache = rtcache.New(ret.diffMetricsWorker, runtime.NumCPU())
@@ -130,6 +137,51 @@ func (d *MemDiffStore) Warm(priority int64, leftDigests []string, rightDigests [
 	}
 }
 
+// HasMetric returns true if the store calculates the given diff metric.
+func (d *MemDiffStore) HasMetric(metric string) bool {
+	if metric == METRIC_PERCEPTUAL {
+		return d.perceptual
+	}
+	return util.In(metric, diffMetricIds)
+}
+
+// DiffImage compares the given image against the given digests. This allows
+// to compare images that were not produced by a test, e.g. an image uploaded
+// by a user, against known digests. Neither the image nor the diffs are
+// stored. Digests that cannot be retrieved are missing from the result.
+func (d *MemDiffStore) DiffImage(priority int64, img *image.NRGBA, rightDigests []string) map[string]*DiffRecord {
+	digestCh := make(chan string, len(rightDigests))
+	for _, right := range rightDigests {
+		digestCh <- right
+	}
+	close(digestCh)
+
+	// Limit the number of concurrent diffs, since each of them holds a copy
+	// of the image.
+	diffMap := make(map[string]*DiffRecord, len(rightDigests))
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for right := range digestCh {
+				imgs, err := d.imgLoader.Get(priority, []string{right})
+				if err != nil {
+					glog.Errorf("Unable to retrieve digest %s. Got error: %s", right, err)
+					continue
+				}
+				diffRec, _ := CalcDiff(img, imgs[0], d.perceptual)
+				mutex.Lock()
+				diffMap[right] = diffRec
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return diffMap
+}
+
 func (d *MemDiffStore) sync() {
 	d.wg.Wait()
 }
@@ -174,10 +226,11 @@ func (d *MemDiffStore) Get(priority int64, mainDigest string, rightDigests []str
 func (d *MemDiffStore) diffMetricsWorker(priority int64, id string) (interface{}, error) {
 	leftDigest, rightDigest := splitDigests(id)
 
-	// Load it from disk cache if necessary.
+	// Load it from disk cache if necessary. Records that were stored before
+	// the perceptual metric was enabled are recalculated.
 	if dm, err := d.loadDiffMetric(id); err != nil {
 		glog.Errorf("Error trying to load diff metric: %s", err)
-	} else if dm != nil {
+	} else if (dm != nil) && (!d.perceptual || dm.HasMetric(METRIC_PERCEPTUAL)) {
 		return dm, nil
 	}
 
@@ -188,7 +241,7 @@ func (d *MemDiffStore) diffMetricsWorker(priority int64, id string) (interface{}
 	}
 
 	// We are guaranteed to have two images at this point.
-	diffRec, diffImg := CalcDiff(imgs[0], imgs[1])
+	diffRec, diffImg := CalcDiff(imgs[0], imgs[1], d.perceptual)
 
 	// encode the result image and save it to disk. If encoding causes an error
 	// we return an error.
@@ -28,10 +28,10 @@ const TEST_N_DIGESTS = 20
 func TestDiffStore(t *testing.T) {
 	// Get a small tile and get them cached.
 	baseDir := TEST_DATA_BASE_DIR + "-diffstore"
-	client, tile := getSetupAndTile(t, baseDir)
+	source, tile := getSetupAndTile(t, baseDir)
 	defer testutils.RemoveAll(t, baseDir)
 
-	diffStore, err := New(client, baseDir, TEST_GS_BUCKET_NAME, TEST_GS_IMAGE_DIR)
+	diffStore, err := New(source, baseDir, false)
 	assert.NoError(t, err)
 
 	// Pick the test with highest number of digests.
@@ -2,8 +2,9 @@
 //
 // It consists of multiple components:
 //
-// - ImageLoader: Downloads images from Google storage and caches them on local
-//                disk and RAM. It aims that proactively fetching images
+// - ImageLoader: Downloads images from an ImageSource (e.g. Google storage)
+//                and caches them on local disk and RAM. It aims that
+//                proactively fetching images
 //                so that they are always in RAM when they are needed for
 //                calculating diffs. Making real time diffs fast, because we
 //                don't have to load anything from disk.
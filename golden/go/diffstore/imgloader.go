@@ -2,46 +2,31 @@ package diffstore
 
 import (
 	"bytes"
-	"crypto/md5"
 	"errors"
 	"fmt"
 	"image"
-	"io"
-	"net/http"
-	"path/filepath"
+	"image/png"
 	"sync"
 
-	"cloud.google.com/go/storage"
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/fileutil"
 	"go.skia.org/infra/go/rtcache"
-	"go.skia.org/infra/go/util"
-	"golang.org/x/net/context"
-	"google.golang.org/api/option"
+	"go.skia.org/infra/golden/go/imagesource"
 )
 
 const (
-	// MAX_URI_GET_TRIES is the number of tries we do to load an image.
-	MAX_URI_GET_TRIES = 4
-
 	// Number of concurrent workers downloading images.
 	N_IMG_WORKERS = 10
 )
 
 // ImageLoader facilitates to continously download images and cache them in RAM.
 type ImageLoader struct {
-	// client is the Google storage client to local content form GS.
-	storageClient *storage.Client
+	// source is where images are retrieved from.
+	source imagesource.ImageSource
 
 	// localImgDir is the local directory where images should be written to.
 	localImgDir string
 
-	// gsBucketName is the GS bucket where images are stored.
-	gsBucketName string
-
-	// gsImageBaseDir is the GS directory (prefix) where images are stored.
-	gsImageBaseDir string
-
 	// imageCache caches and calculates images.
 	imageCache rtcache.ReadThroughCache
 
@@ -52,17 +37,10 @@ type ImageLoader struct {
 }
 
 // Creates a new instance of ImageLoader.
-func newImgLoader(client *http.Client, imgDir, gsBucketName, gsImageBaseDir string) (*ImageLoader, error) {
-	storageClient, err := storage.NewClient(context.Background(), option.WithHTTPClient(client))
-	if err != nil {
-		return nil, err
-	}
-
+func newImgLoader(source imagesource.ImageSource, imgDir string) (*ImageLoader, error) {
 	ret := &ImageLoader{
-		storageClient:  storageClient,
-		localImgDir:    imgDir,
-		gsBucketName:   gsBucketName,
-		gsImageBaseDir: gsImageBaseDir,
+		source:      source,
+		localImgDir: imgDir,
 	}
 
 	// Set up the work queues that balance the load.
@@ -135,7 +113,7 @@ func (il *ImageLoader) Get(priority int64, digests []string) ([]*image.NRGBA, er
 }
 
 // imageLoadWorker implements the rtcache.ReadThroughFunc signature.
-// It loads an image file either from disk or from Google storage.
+// It loads an image file either from disk or from the image source.
 func (il *ImageLoader) imageLoadWorker(priority int64, digest string) (interface{}, error) {
 	// Check if the image is in the disk cache.
 	imageFileName := getDigestImageFileName(digest)
@@ -169,55 +147,32 @@ func (il *ImageLoader) saveImgInfoAsync(imageFileName string, imgBytes []byte) {
 	}()
 }
 
-// downloadImg retrieves the given image from Google storage.
-func (il *ImageLoader) downloadImg(digest string) ([]byte, error) {
-	glog.Infof("Starting download for for: %s", digest)
-	objLocation := filepath.Join(il.gsImageBaseDir, getDigestImageFileName(digest))
-	ctx := context.Background()
-
-	// Retrieve the attributes.
-	attrs, err := il.storageClient.Bucket(il.gsBucketName).Object(objLocation).Attrs(ctx)
+// DecodeImage decodes the given PNG encoded image, e.g. an image uploaded by a
+// user that is compared against known digests via MemDiffStore.DiffImage.
+// Images with more than maxPixels pixels are rejected before they are decoded.
+func DecodeImage(imgBytes []byte, maxPixels int) (*image.NRGBA, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(imgBytes))
 	if err != nil {
-		return nil, fmt.Errorf("Unable to retrieve attributes for %s/%s: %s", il.gsBucketName, objLocation, err)
+		return nil, fmt.Errorf("Unable to decode image: %s", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("Image too large: %dx%d pixels exceeds the maximum of %d pixels.", config.Width, config.Height, maxPixels)
 	}
 
-	var buf *bytes.Buffer
-	for i := 0; i < MAX_URI_GET_TRIES; i++ {
-		err = func() error {
-			reader, err := il.storageClient.Bucket(il.gsBucketName).Object(objLocation).NewReader(ctx)
-			if err != nil {
-				return fmt.Errorf("New reader failed for %s/%s: %s", il.gsBucketName, objLocation, err)
-			}
-			defer util.Close(reader)
-
-			size := reader.Size()
-			buf = bytes.NewBuffer(make([]byte, 0, size))
-			md5Hash := md5.New()
-			multiOut := io.MultiWriter(md5Hash, buf)
-
-			if _, err = io.Copy(multiOut, reader); err != nil {
-				return err
-			}
-
-			// Check the MD5.
-			if !bytes.Equal(md5Hash.Sum(nil), attrs.MD5) {
-				return fmt.Errorf("MD5 hash for digest %s incorrect.", digest)
-			}
-
-			return nil
-		}()
-
-		if err == nil {
-			break
-		}
-		glog.Errorf("Error fetching file for digest %s: %s", digest, err)
+	img, err := decodeImg(bytes.NewReader(imgBytes))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode image: %s", err)
 	}
+	return img, nil
+}
 
+// downloadImg retrieves the given image from the image source.
+func (il *ImageLoader) downloadImg(digest string) ([]byte, error) {
+	glog.Infof("Starting download for for: %s", digest)
+	ret, err := il.source.Get(digest)
 	if err != nil {
-		glog.Errorf("Failed fetching file after %d attempts", MAX_URI_GET_TRIES)
 		return nil, err
 	}
-
-	glog.Infof("Done downloading image for: %s. Length: %d", digest, buf.Len())
-	return buf.Bytes(), err
+	glog.Infof("Done downloading image for: %s. Length: %d", digest, len(ret))
+	return ret, nil
 }
@@ -1,6 +1,8 @@
 package diffstore
 
 import (
+	"bytes"
+	"image"
 	"os"
 	"path/filepath"
 	"testing"
@@ -49,14 +51,32 @@ func TestImageLoader(t *testing.T) {
 	ti.Stop()
 }
 
+func TestDecodeImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 5, 4))
+	img.Pix[0] = 255
+	var buf bytes.Buffer
+	assert.NoError(t, encodeImg(&buf, img))
+
+	decoded, err := DecodeImage(buf.Bytes(), 20)
+	assert.NoError(t, err)
+	assert.Equal(t, img.Pix, decoded.Pix)
+
+	// Too many pixels.
+	_, err = DecodeImage(buf.Bytes(), 19)
+	assert.Error(t, err)
+
+	_, err = DecodeImage([]byte("not a png"), 20)
+	assert.Error(t, err)
+}
+
 func getImageLoaderAndTile(t assert.TestingT) (string, string, *tiling.Tile, *ImageLoader) {
 	baseDir := TEST_DATA_BASE_DIR + "-imgloader"
-	client, tile := getSetupAndTile(t, baseDir)
+	source, tile := getSetupAndTile(t, baseDir)
 
 	workingDir := filepath.Join(baseDir, "images")
 	assert.Nil(t, os.Mkdir(workingDir, 0777))
 
-	imgLoader, err := newImgLoader(client, workingDir, TEST_GS_BUCKET_NAME, TEST_GS_IMAGE_DIR)
+	imgLoader, err := newImgLoader(source, workingDir)
 	assert.NoError(t, err)
 	return baseDir, workingDir, tile, imgLoader
 }
@@ -8,8 +8,9 @@ import (
 )
 
 const (
-	METRIC_COMBINED = "combined"
-	METRIC_PERCENT  = "percent"
+	METRIC_COMBINED   = "combined"
+	METRIC_PERCENT    = "percent"
+	METRIC_PERCEPTUAL = "perceptual"
 )
 
 // MetricsFn is the signature a custom diff metric has to implmente.
@@ -17,8 +18,9 @@ type MetricFn func(*DiffRecord, *image.NRGBA, *image.NRGBA) float32
 
 // metrics contains the custom diff metrics.
 var metrics = map[string]MetricFn{
-	METRIC_COMBINED: combinedDiffMetric,
-	METRIC_PERCENT:  percentDiffMetric,
+	METRIC_COMBINED:   combinedDiffMetric,
+	METRIC_PERCENT:    percentDiffMetric,
+	METRIC_PERCEPTUAL: perceptualDiffMetric,
 }
 
 var diffMetricIds []string
@@ -42,12 +44,20 @@ type DiffRecord struct {
 	MaxRGBADiffs     []int
 	DimDiffer        bool
 
-	// Diffs contains the diff metrics defined in 'metrics'.
+	// Diffs contains the diff metrics defined in 'metrics'. METRIC_PERCEPTUAL
+	// is missing if it was not calculated.
 	Diffs map[string]float32
 }
 
+// HasMetric returns true if the given diff metric was calculated.
+func (d *DiffRecord) HasMetric(metric string) bool {
+	_, ok := d.Diffs[metric]
+	return ok
+}
+
 // CalcDiff calculates the basic difference and then then custom diff metrics.
-func CalcDiff(leftImg *image.NRGBA, rightImg *image.NRGBA) (*DiffRecord, *image.NRGBA) {
+// METRIC_PERCEPTUAL is expensive and only calculated if perceptual is true.
+func CalcDiff(leftImg *image.NRGBA, rightImg *image.NRGBA, perceptual bool) (*DiffRecord, *image.NRGBA) {
 	basicDiff, diffImg := diff.Diff(leftImg, rightImg)
 	ret := &DiffRecord{
 		NumDiffPixels:    basicDiff.NumDiffPixels,
@@ -59,6 +69,9 @@ func CalcDiff(leftImg *image.NRGBA, rightImg *image.NRGBA) (*DiffRecord, *image.
 	// Calcluate the metrics.
 	diffs := make(map[string]float32, len(diffMetricIds))
 	for _, id := range diffMetricIds {
+		if (id == METRIC_PERCEPTUAL) && !perceptual {
+			continue
+		}
 		diffs[id] = metrics[id](ret, leftImg, rightImg)
 	}
 	ret.Diffs = diffs
@@ -90,3 +103,9 @@ func combinedDiffMetric(basic *DiffRecord, one *image.NRGBA, two *image.NRGBA) f
 func percentDiffMetric(basic *DiffRecord, one *image.NRGBA, two *image.NRGBA) float32 {
 	return basic.PixelDiffPercent
 }
+
+// perceptualDiffMetric returns the number of perceptually different pixels as
+// the metric, see diff.PerceptualDiffPixels. Implements the MetricFn signature.
+func perceptualDiffMetric(basic *DiffRecord, one *image.NRGBA, two *image.NRGBA) float32 {
+	return float32(diff.PerceptualDiffPixels(one, two))
+}
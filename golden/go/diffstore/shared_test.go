@@ -1,7 +1,6 @@
 package diffstore
 
 import (
-	"net/http"
 	"path/filepath"
 
 	"cloud.google.com/go/storage"
@@ -10,6 +9,7 @@ import (
 	"go.skia.org/infra/go/auth"
 	"go.skia.org/infra/go/gs"
 	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/golden/go/imagesource"
 	"go.skia.org/infra/golden/go/mocks"
 )
 
@@ -33,7 +33,7 @@ var (
 	TEST_GS_IMAGE_DIR   = "dm-images-v1"
 )
 
-func getSetupAndTile(t assert.TestingT, baseDir string) (*http.Client, *tiling.Tile) {
+func getSetupAndTile(t assert.TestingT, baseDir string) (imagesource.ImageSource, *tiling.Tile) {
 	testDataPath := filepath.Join(baseDir, TEST_DATA_FILE_NAME)
 	assert.NoError(t, gs.DownloadTestDataFile(t, TEST_DATA_STORAGE_BUCKET, TEST_DATA_STORAGE_PATH, testDataPath))
 
@@ -43,5 +43,7 @@ func getSetupAndTile(t assert.TestingT, baseDir string) (*http.Client, *tiling.T
 	client, err := auth.NewJWTServiceAccountClient("", auth.DEFAULT_JWT_FILENAME, nil, storage.ScopeFullControl)
 	assert.NoError(t, err)
 
-	return client, tile
+	source, err := imagesource.NewGSImageSource(client, TEST_GS_BUCKET_NAME, TEST_GS_IMAGE_DIR)
+	assert.NoError(t, err)
+	return source, tile
 }
@@ -6,17 +6,26 @@ import (
 
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/diffstore"
 	"go.skia.org/infra/golden/go/expstorage"
 	"go.skia.org/infra/golden/go/tally"
 	"go.skia.org/infra/golden/go/types"
 )
 
+// IsValidMetric returns true if the closest digest can be found by the given
+// metric, i.e. if it is diffstore.METRIC_COMBINED or
+// diffstore.METRIC_PERCEPTUAL.
+func IsValidMetric(metric string) bool {
+	return metric == diffstore.METRIC_COMBINED || metric == diffstore.METRIC_PERCEPTUAL
+}
+
 // Closest describes one digest that is the closest another digest.
 type Closest struct {
-	Digest     string  `json:"digest"`     // The closest digest, empty if there are no digests to compare to.
-	Diff       float32 `json:"diff"`       // A percent value.
-	DiffPixels float32 `json:"diffPixels"` // A percent value.
-	MaxRGBA    []int   `json:"maxRGBA"`
+	Digest           string  `json:"digest"`           // The closest digest, empty if there are no digests to compare to.
+	Diff             float32 `json:"diff"`             // The value of the metric used to find the closest digest.
+	DiffPixels       float32 `json:"diffPixels"`       // A percent value.
+	PerceptualPixels int     `json:"perceptualPixels"` // The number of perceptually different pixels, -1 if not calculated.
+	MaxRGBA          []int   `json:"maxRGBA"`
 }
 
 func newClosest() *Closest {
@@ -31,6 +40,14 @@ func newClosest() *Closest {
 //
 // If no digest of type 'label' is found then Closest.Digest is the empty string.
 func ClosestDigest(test string, digest string, exp *expstorage.Expectations, tallies tally.Tally, diffStore diff.DiffStore, label types.Label) *Closest {
+	return ClosestDigestByMetric(test, digest, exp, tallies, diffStore, label, diffstore.METRIC_COMBINED)
+}
+
+// ClosestDigestByMetric is like ClosestDigest, but the closest digest is the
+// one with the smallest value of the given metric, either
+// diffstore.METRIC_COMBINED or diffstore.METRIC_PERCEPTUAL. Digests without
+// a perceptual diff are never the closest by diffstore.METRIC_PERCEPTUAL.
+func ClosestDigestByMetric(test string, digest string, exp *expstorage.Expectations, tallies tally.Tally, diffStore diff.DiffStore, label types.Label, metric string) *Closest {
 	ret := newClosest()
 	unavailableDigests := diffStore.UnavailableDigests()
 
@@ -54,10 +71,11 @@ func ClosestDigest(test string, digest string, exp *expstorage.Expectations, tal
 		return ret
 	} else {
 		for digest, diff := range diffMetrics {
-			if delta := combinedDiffMetric(diff.PixelDiffPercent, diff.MaxRGBADiffs); delta < ret.Diff {
+			if delta := metricValue(metric, diff); delta < ret.Diff {
 				ret.Digest = digest
 				ret.Diff = delta
 				ret.DiffPixels = diff.PixelDiffPercent
+				ret.PerceptualPixels = perceptualPixels(diff)
 				ret.MaxRGBA = diff.MaxRGBADiffs
 			}
 		}
@@ -69,10 +87,33 @@ func ClosestDigest(test string, digest string, exp *expstorage.Expectations, tal
 // given diff.DiffMetrics. The Digest field will be left empty.
 func ClosestFromDiffMetrics(diff *diff.DiffMetrics) *Closest {
 	return &Closest{
-		Diff:       combinedDiffMetric(diff.PixelDiffPercent, diff.MaxRGBADiffs),
-		DiffPixels: diff.PixelDiffPercent,
-		MaxRGBA:    diff.MaxRGBADiffs,
+		Diff:             combinedDiffMetric(diff.PixelDiffPercent, diff.MaxRGBADiffs),
+		DiffPixels:       diff.PixelDiffPercent,
+		PerceptualPixels: perceptualPixels(diff),
+		MaxRGBA:          diff.MaxRGBADiffs,
+	}
+}
+
+// metricValue returns the value of the given metric for the diff. Smaller
+// values mean more similar images. A perceptual diff that was not calculated
+// is treated as the largest possible value.
+func metricValue(metric string, diff *diff.DiffMetrics) float32 {
+	if metric == diffstore.METRIC_PERCEPTUAL {
+		if !diff.HasPerceptualDiff {
+			return math.MaxFloat32
+		}
+		return float32(diff.NumPerceptualDiffPixels)
+	}
+	return combinedDiffMetric(diff.PixelDiffPercent, diff.MaxRGBADiffs)
+}
+
+// perceptualPixels returns the number of perceptually different pixels of the
+// diff or -1 if they were not calculated.
+func perceptualPixels(diff *diff.DiffMetrics) int {
+	if !diff.HasPerceptualDiff {
+		return -1
 	}
+	return diff.NumPerceptualDiffPixels
 }
 
 // combinedDiffMetric returns a value in [0, 1] that represents how large
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/diffstore"
 	"go.skia.org/infra/golden/go/expstorage"
 	"go.skia.org/infra/golden/go/tally"
 	"go.skia.org/infra/golden/go/types"
@@ -18,17 +19,29 @@ func (m MockDiffStore) UnavailableDigests() map[string]*diff.DigestFailure
 func (m MockDiffStore) PurgeDigests(digests []string, purgeGS bool) error        { return nil }
 func (m MockDiffStore) SetDigestSets(namedDigestSets map[string]map[string]bool) {}
 
-// Get always finds that digest "eee" is closest to dMain.
+// Get always finds that digest "eee" is closest to dMain, unless the
+// perceptual metric is used, which finds "aaa". The perceptual diff of "eee"
+// was not calculated, like for diff metrics that were cached before it was
+// added.
 func (m MockDiffStore) Get(dMain string, dRest []string) (map[string]*diff.DiffMetrics, error) {
 	result := map[string]*diff.DiffMetrics{}
 	for i, d := range dRest {
 		diffPercent := float32(i + 2)
-		if d == "eee" {
+		perceptualPixels := 50
+		hasPerceptualDiff := true
+		switch d {
+		case "aaa":
+			perceptualPixels = 3
+		case "eee":
 			diffPercent = 0.1
+			perceptualPixels = 0
+			hasPerceptualDiff = false
 		}
 		result[d] = &diff.DiffMetrics{
-			PixelDiffPercent: diffPercent,
-			MaxRGBADiffs:     []int{5, 3, 4, 0},
+			PixelDiffPercent:        diffPercent,
+			MaxRGBADiffs:            []int{5, 3, 4, 0},
+			NumPerceptualDiffPixels: perceptualPixels,
+			HasPerceptualDiff:       hasPerceptualDiff,
 		}
 	}
 	return result, nil
@@ -75,6 +88,40 @@ func TestClosestDigest(t *testing.T) {
 	assert.Equal(t, []int{5, 3, 4, 0}, c.MaxRGBA)
 }
 
+func TestClosestDigestByMetric(t *testing.T) {
+	diffStore := MockDiffStore{}
+	exp := &expstorage.Expectations{
+		Tests: map[string]types.TestClassification{
+			"foo": map[string]types.Label{
+				"aaa": types.POSITIVE,
+				"bbb": types.NEGATIVE,
+				"eee": types.POSITIVE,
+			},
+		},
+	}
+	tallies := tally.Tally{
+		"aaa": 2,
+		"bbb": 2,
+		"eee": 2,
+	}
+
+	c := ClosestDigestByMetric("foo", "fff", exp, tallies, diffStore, types.POSITIVE, diffstore.METRIC_COMBINED)
+	assert.Equal(t, "eee", c.Digest)
+	assert.Equal(t, -1, c.PerceptualPixels)
+
+	// "eee" is not the closest digest although its perceptual diff is 0,
+	// because it was not calculated.
+	c = ClosestDigestByMetric("foo", "fff", exp, tallies, diffStore, types.POSITIVE, diffstore.METRIC_PERCEPTUAL)
+	assert.Equal(t, "aaa", c.Digest)
+	assert.Equal(t, float32(3), c.Diff)
+	assert.Equal(t, 3, c.PerceptualPixels)
+
+	assert.True(t, IsValidMetric(diffstore.METRIC_COMBINED))
+	assert.True(t, IsValidMetric(diffstore.METRIC_PERCEPTUAL))
+	assert.False(t, IsValidMetric(diffstore.METRIC_PERCENT))
+	assert.False(t, IsValidMetric("unknown"))
+}
+
 func TestCombinedDiffMetric(t *testing.T) {
 	assert.InDelta(t, 1.0, combinedDiffMetric(0.0, []int{}), 0.000001)
 	assert.InDelta(t, 1.0, combinedDiffMetric(1.0, []int{255, 255, 255, 255}), 0.000001)
@@ -1,10 +1,12 @@
 package expstorage
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/eventbus"
+	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/golden/go/types"
 )
 
@@ -118,10 +120,19 @@ type ExpectationsStore interface {
 	// expectations map are the test names.
 	Get() (exp *Expectations, err error)
 
+	// Version returns a counter that increases whenever the expectations
+	// change. It allows clients to cheaply detect whether the values returned
+	// by Get have changed.
+	Version() (int64, error)
+
 	// AddChange writes the given classified digests to the database and records the
 	// user that made the change.
 	AddChange(changes map[string]types.TestClassification, userId string) error
 
+	// AddChangeWithReason is the same as AddChange, but also records a free
+	// text reason for the change.
+	AddChangeWithReason(changes map[string]types.TestClassification, userId, reason string) error
+
 	// RemoveChange removes the given digests from the expectations store.
 	// The key in changes is the test name which maps to a list of digests
 	// to remove.
@@ -138,6 +149,11 @@ type ExpectationsStore interface {
 	// undone.
 	UndoChange(changeID int, userID string) (map[string]types.TestClassification, error)
 
+	// TriageHistory returns the changes of the label of the given digest of
+	// the given test, the most recent change first. If digest is empty the
+	// changes of all digests of the test are returned.
+	TriageHistory(testName, digest string) ([]*TriageHistoryEntry, error)
+
 	// CanonicalTraceIDs returns the cannonical trace IDs for the given list
 	// of test names.
 	CanonicalTraceIDs(testNames []string) (map[string]string, error)
@@ -163,15 +179,47 @@ type TriageLogEntry struct {
 	ChangeCount  int             `json:"changeCount"`
 	Details      []*TriageDetail `json:"details"`
 	UndoChangeID int             `json:"undoChangeId"`
+	Reason       string          `json:"reason"`
+}
+
+// TriageHistoryEntry is one change of the label of a digest.
+type TriageHistoryEntry struct {
+	ChangeID     int    `json:"changeId"`
+	TestName     string `json:"test_name"`
+	Digest       string `json:"digest"`
+	Label        string `json:"label"`
+	Name         string `json:"name"`
+	TS           int64  `json:"ts"`
+	UndoChangeID int    `json:"undoChangeId"`
+	Reason       string `json:"reason"`
+
+	// Removed is the time in ms since the epoch when the digest was removed
+	// from the expectations or 0 if it was not removed.
+	Removed int64 `json:"removed"`
 }
 
+// TriageHistorySlice is a utility type to sort TriageHistoryEntry instances
+// by descending time and change id.
+type TriageHistorySlice []*TriageHistoryEntry
+
+func (t TriageHistorySlice) Len() int { return len(t) }
+func (t TriageHistorySlice) Less(i, j int) bool {
+	if t[i].TS == t[j].TS {
+		return t[i].ChangeID > t[j].ChangeID
+	}
+	return t[i].TS > t[j].TS
+}
+func (t TriageHistorySlice) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+
 // Implements ExpectationsStore in memory for prototyping and testing.
 type MemExpectationsStore struct {
 	expectations *Expectations
 	readCopy     *Expectations
 	eventBus     *eventbus.EventBus
+	version      int64
+	history      []*TriageHistoryEntry
 
-	// Protects expectations.
+	// Protects expectations, version and history.
 	mutex sync.Mutex
 }
 
@@ -193,11 +241,26 @@ func (m *MemExpectationsStore) Get() (*Expectations, error) {
 	return m.readCopy, nil
 }
 
+// See ExpectationsStore interface.
+func (m *MemExpectationsStore) Version() (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.version, nil
+}
+
 // See ExpectationsStore interface.
 func (m *MemExpectationsStore) AddChange(changedTests map[string]types.TestClassification, userId string) error {
+	return m.AddChangeWithReason(changedTests, userId, "")
+}
+
+// See ExpectationsStore interface.
+func (m *MemExpectationsStore) AddChangeWithReason(changedTests map[string]types.TestClassification, userId, reason string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	changeID := int(m.version) + 1
+	now := util.TimeStampMs()
 	testNames := make([]string, 0, len(changedTests))
 	for testName, digests := range changedTests {
 		if _, ok := m.expectations.Tests[testName]; !ok {
@@ -205,6 +268,15 @@ func (m *MemExpectationsStore) AddChange(changedTests map[string]types.TestClass
 		}
 		for d, label := range digests {
 			m.expectations.Tests[testName][d] = label
+			m.history = append(m.history, &TriageHistoryEntry{
+				ChangeID: changeID,
+				TestName: testName,
+				Digest:   d,
+				Label:    label.String(),
+				Name:     userId,
+				TS:       now,
+				Reason:   reason,
+			})
 		}
 		testNames = append(testNames, testName)
 	}
@@ -213,6 +285,7 @@ func (m *MemExpectationsStore) AddChange(changedTests map[string]types.TestClass
 	}
 
 	m.readCopy = m.expectations.DeepCopy()
+	m.version++
 	return nil
 }
 
@@ -223,8 +296,10 @@ func (m *MemExpectationsStore) RemoveChange(changedDigests map[string][]string)
 
 	testNames := make([]string, 0, len(changedDigests))
 
+	now := util.TimeStampMs()
 	for testName, digests := range changedDigests {
 		for _, digest := range digests {
+			m.markRemoved(testName, digest, now)
 			delete(m.expectations.Tests[testName], digest)
 			if len(m.expectations.Tests[testName]) == 0 {
 				delete(m.expectations.Tests, testName)
@@ -237,9 +312,20 @@ func (m *MemExpectationsStore) RemoveChange(changedDigests map[string][]string)
 	}
 
 	m.readCopy = m.expectations.DeepCopy()
+	m.version++
 	return nil
 }
 
+// markRemoved marks the history of the given digest as removed at the given
+// time, unless it was removed before. Assumes the caller holds the mutex.
+func (m *MemExpectationsStore) markRemoved(testName, digest string, ts int64) {
+	for _, entry := range m.history {
+		if (entry.TestName == testName) && (entry.Digest == digest) && (entry.Removed == 0) {
+			entry.Removed = ts
+		}
+	}
+}
+
 // See ExpectationsStore interface.
 func (m *MemExpectationsStore) QueryLog(offset, size int, details bool) ([]*TriageLogEntry, int, error) {
 	glog.Fatal("MemExpectation store does not support querying the logs.")
@@ -252,6 +338,22 @@ func (m *MemExpectationsStore) UndoChange(changeID int, userID string) (map[stri
 	return nil, nil
 }
 
+// See ExpectationsStore interface.
+func (m *MemExpectationsStore) TriageHistory(testName, digest string) ([]*TriageHistoryEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ret := []*TriageHistoryEntry{}
+	for _, entry := range m.history {
+		if (entry.TestName == testName) && ((digest == "") || (entry.Digest == digest)) {
+			copied := *entry
+			ret = append(ret, &copied)
+		}
+	}
+	sort.Sort(TriageHistorySlice(ret))
+	return ret, nil
+}
+
 // See ExpectationsStore interface.
 // TODO(stephana): Implement once API is defined.
 func (m *MemExpectationsStore) CanonicalTraceIDs(testNames []string) (map[string]string, error) {
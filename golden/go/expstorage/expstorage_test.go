@@ -42,6 +42,8 @@ func testExpectationStore(t *testing.T, store ExpectationsStore, eventBus *event
 	initialLogRecs, initialLogTotal, err := store.QueryLog(0, 100, true)
 	assert.NoError(t, err)
 	initialLogRecsLen := len(initialLogRecs)
+	lastVersion, err := store.Version()
+	assert.NoError(t, err)
 
 	// If we have an event bus then keep gathering events.
 	callbackCh := make(chan []string, 3)
@@ -89,6 +91,7 @@ func testExpectationStore(t *testing.T, store ExpectationsStore, eventBus *event
 	assert.Equal(t, expChange_1, foundExps.Tests)
 	assert.False(t, &expChange_1 == &foundExps.Tests)
 	checkLogEntry(t, store, expChange_1)
+	lastVersion = checkVersionIncreased(t, store, lastVersion)
 
 	// Update digests.
 	expChange_2 := map[string]types.TestClassification{
@@ -104,7 +107,7 @@ func testExpectationStore(t *testing.T, store ExpectationsStore, eventBus *event
 		&TriageDetail{TEST_2, DIGEST_22, "untriaged"},
 	}
 
-	assert.NoError(t, store.AddChange(expChange_2, "user-1"))
+	assert.NoError(t, store.AddChangeWithReason(expChange_2, "user-1", "Blurry text"))
 	if eventBus != nil {
 		eventBus.Wait(EV_EXPSTORAGE_CHANGED)
 		assert.Equal(t, 1, len(callbackCh))
@@ -116,6 +119,25 @@ func testExpectationStore(t *testing.T, store ExpectationsStore, eventBus *event
 	assert.Equal(t, types.NEGATIVE, foundExps.Tests[TEST_1][DIGEST_11])
 	assert.Equal(t, types.UNTRIAGED, foundExps.Tests[TEST_2][DIGEST_22])
 	checkLogEntry(t, store, expChange_2)
+	lastVersion = checkVersionIncreased(t, store, lastVersion)
+
+	// Check the history of the changed digests.
+	history, err := store.TriageHistory(TEST_1, DIGEST_11)
+	assert.NoError(t, err)
+	assert.True(t, len(history) >= 2)
+	assert.Equal(t, "negative", history[0].Label)
+	assert.Equal(t, "user-1", history[0].Name)
+	assert.Equal(t, "Blurry text", history[0].Reason)
+	assert.Equal(t, int64(0), history[0].Removed)
+	assert.Equal(t, "positive", history[1].Label)
+	assert.Equal(t, "user-0", history[1].Name)
+	assert.Equal(t, "", history[1].Reason)
+
+	history, err = store.TriageHistory(TEST_2, "")
+	assert.NoError(t, err)
+	assert.True(t, len(history) >= 3)
+	assert.Equal(t, DIGEST_22, history[0].Digest)
+	assert.Equal(t, "untriaged", history[0].Label)
 
 	// Send empty changes to test the event bus.
 	emptyChanges := map[string]types.TestClassification{}
@@ -144,6 +166,11 @@ func testExpectationStore(t *testing.T, store ExpectationsStore, eventBus *event
 
 	assert.Equal(t, types.TestClassification(map[string]types.Label{DIGEST_12: types.NEGATIVE}), foundExps.Tests[TEST_1])
 	assert.Equal(t, types.TestClassification(map[string]types.Label{DIGEST_21: types.POSITIVE}), foundExps.Tests[TEST_2])
+	lastVersion = checkVersionIncreased(t, store, lastVersion)
+
+	history, err = store.TriageHistory(TEST_1, DIGEST_11)
+	assert.NoError(t, err)
+	assert.True(t, history[0].Removed > 0)
 
 	removeDigests_2 := map[string][]string{TEST_1: []string{DIGEST_12}}
 	assert.NoError(t, store.RemoveChange(removeDigests_2))
@@ -175,6 +202,7 @@ func testExpectationStore(t *testing.T, store ExpectationsStore, eventBus *event
 
 	assert.Equal(t, 0, len(logEntries[0].Details))
 	assert.Equal(t, logEntry_2, logEntries[1].Details)
+	assert.Equal(t, "Blurry text", logEntries[1].Reason)
 	assert.Equal(t, logEntry_1, logEntries[2].Details)
 
 	logEntries, total, err = store.QueryLog(100, 5, true)
@@ -190,6 +218,7 @@ func testExpectationStore(t *testing.T, store ExpectationsStore, eventBus *event
 	changes, err = store.UndoChange(secondToLastRec.ID, "user-1")
 	assert.NoError(t, err)
 	checkLogEntry(t, store, changes)
+	checkVersionIncreased(t, store, lastVersion)
 
 	addedRecs += 2
 	logEntries, total, err = store.QueryLog(0, 2, true)
@@ -233,6 +262,15 @@ func testExpectationStore(t *testing.T, store ExpectationsStore, eventBus *event
 	}
 }
 
+// checkVersionIncreased asserts that the version of the store is larger than
+// lastVersion and returns the current version.
+func checkVersionIncreased(t *testing.T, store ExpectationsStore, lastVersion int64) int64 {
+	version, err := store.Version()
+	assert.NoError(t, err)
+	assert.True(t, version > lastVersion)
+	return version
+}
+
 func checkExpectationsAt(t *testing.T, sqlStore *SQLExpectationsStore, changeInfo *TriageLogEntry, name string) {
 	changeInfo.TS++
 	changes, err := sqlStore.getExpectationsAt(changeInfo)
@@ -259,3 +297,50 @@ func checkLogEntry(t *testing.T, store ExpectationsStore, changes map[string]typ
 		assert.Equal(t, changes[d.TestName][d.Digest].String(), d.Label)
 	}
 }
+
+func TestMemExpectationsStoreVersion(t *testing.T) {
+	store := NewMemExpectationsStore(nil)
+	lastVersion, err := store.Version()
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.AddChange(map[string]types.TestClassification{
+		"test1": types.TestClassification{"d11": types.POSITIVE},
+	}, "user-0"))
+	lastVersion = checkVersionIncreased(t, store, lastVersion)
+
+	assert.NoError(t, store.RemoveChange(map[string][]string{"test1": []string{"d11"}}))
+	checkVersionIncreased(t, store, lastVersion)
+}
+
+func TestMemExpectationsStoreTriageHistory(t *testing.T) {
+	store := NewMemExpectationsStore(nil)
+	assert.NoError(t, store.AddChange(map[string]types.TestClassification{
+		"test1": types.TestClassification{"d11": types.POSITIVE, "d12": types.POSITIVE},
+	}, "user-0"))
+	assert.NoError(t, store.AddChangeWithReason(map[string]types.TestClassification{
+		"test1": types.TestClassification{"d11": types.NEGATIVE},
+	}, "user-1", "Blurry text"))
+
+	history, err := store.TriageHistory("test1", "d11")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, "negative", history[0].Label)
+	assert.Equal(t, "user-1", history[0].Name)
+	assert.Equal(t, "Blurry text", history[0].Reason)
+	assert.Equal(t, "positive", history[1].Label)
+	assert.Equal(t, "user-0", history[1].Name)
+
+	history, err = store.TriageHistory("test1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(history))
+
+	assert.NoError(t, store.RemoveChange(map[string][]string{"test1": []string{"d11"}}))
+	history, err = store.TriageHistory("test1", "d11")
+	assert.NoError(t, err)
+	assert.True(t, history[0].Removed > 0)
+	assert.True(t, history[1].Removed > 0)
+
+	history, err = store.TriageHistory("test2", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(history))
+}
@@ -57,9 +57,30 @@ func (s *SQLExpectationsStore) Get() (exp *Expectations, err error) {
 	}, nil
 }
 
+// See ExpectationsStore interface.
+// Every change adds a record to exp_change and every removal marks a row in
+// exp_test_change as removed. Neither is ever undone, so the sum of the
+// largest change id and the number of removed rows increases with every
+// modification of the expectations.
+func (s *SQLExpectationsStore) Version() (int64, error) {
+	const stmt = `SELECT (SELECT IFNULL(MAX(id), 0) FROM exp_change) +
+	                     (SELECT COUNT(*) FROM exp_test_change WHERE removed IS NOT NULL)`
+
+	var version int64
+	if err := s.vdb.DB.QueryRow(stmt).Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
 // See ExpectationsStore interface.
 func (s *SQLExpectationsStore) AddChange(changedTests map[string]types.TestClassification, userId string) error {
-	return s.AddChangeWithTimeStamp(changedTests, userId, 0, util.TimeStampMs())
+	return s.AddChangeWithReason(changedTests, userId, "")
+}
+
+// See ExpectationsStore interface.
+func (s *SQLExpectationsStore) AddChangeWithReason(changedTests map[string]types.TestClassification, userId, reason string) error {
+	return s.addChange(changedTests, userId, 0, util.TimeStampMs(), reason)
 }
 
 // TOOD(stephana): Remove the AddChangeWithTimeStamp if we remove the
@@ -67,7 +88,13 @@ func (s *SQLExpectationsStore) AddChange(changedTests map[string]types.TestClass
 
 // AddChangeWithTimeStamp adds changed tests to the database with the
 // given time stamp. This is primarily for migration purposes.
-func (s *SQLExpectationsStore) AddChangeWithTimeStamp(changedTests map[string]types.TestClassification, userId string, undoID int, timeStamp int64) (retErr error) {
+func (s *SQLExpectationsStore) AddChangeWithTimeStamp(changedTests map[string]types.TestClassification, userId string, undoID int, timeStamp int64) error {
+	return s.addChange(changedTests, userId, undoID, timeStamp, "")
+}
+
+// addChange adds changed tests to the database with the given time stamp and
+// reason. If undoID is not 0 the change is an undo of the change with that id.
+func (s *SQLExpectationsStore) addChange(changedTests map[string]types.TestClassification, userId string, undoID int, timeStamp int64, reason string) (retErr error) {
 	defer timer.New("adding exp change").Stop()
 
 	// Count the number of values to add.
@@ -77,7 +104,7 @@ func (s *SQLExpectationsStore) AddChangeWithTimeStamp(changedTests map[string]ty
 	}
 
 	const (
-		insertChange = `INSERT INTO exp_change (userid, ts, undo_changeid) VALUES (?, ?, ?)`
+		insertChange = `INSERT INTO exp_change (userid, ts, undo_changeid, reason) VALUES (?, ?, ?, ?)`
 		insertDigest = `INSERT INTO exp_test_change (changeid, name, digest, label) VALUES`
 	)
 
@@ -90,7 +117,7 @@ func (s *SQLExpectationsStore) AddChangeWithTimeStamp(changedTests map[string]ty
 	defer func() { retErr = database.CommitOrRollback(tx, retErr) }()
 
 	// create the change record
-	result, err := tx.Exec(insertChange, userId, timeStamp, undoID)
+	result, err := tx.Exec(insertChange, userId, timeStamp, undoID, reason)
 	if err != nil {
 		return err
 	}
@@ -223,7 +250,7 @@ func (s *SQLExpectationsStore) queryChanges(offset, size, changeID int, details
 
 		stmtTotal = `SELECT count(*) FROM exp_change`
 
-		stmtListTmpl = `SELECT ec.id, ec.userid, ec.ts, (IFNULL( COUNT( tc.changeid ) , 0 )) AS detailsCount, undo_changeid
+		stmtListTmpl = `SELECT ec.id, ec.userid, ec.ts, (IFNULL( COUNT( tc.changeid ) , 0 )) AS detailsCount, undo_changeid, reason
 					  FROM %s AS ec
 						LEFT OUTER JOIN exp_test_change AS tc
 							ON ec.id=tc.changeid
@@ -274,7 +301,7 @@ func (s *SQLExpectationsStore) queryChanges(offset, size, changeID int, details
 	result := make([]*TriageLogEntry, 0, size)
 	for rows.Next() {
 		entry := &TriageLogEntry{}
-		if err = rows.Scan(&entry.ID, &entry.Name, &entry.TS, &entry.ChangeCount, &entry.UndoChangeID); err != nil {
+		if err = rows.Scan(&entry.ID, &entry.Name, &entry.TS, &entry.ChangeCount, &entry.UndoChangeID, &entry.Reason); err != nil {
 			return nil, 0, err
 		}
 
@@ -337,6 +364,37 @@ func (s *SQLExpectationsStore) UndoChange(changeID int, userID string) (map[stri
 	return changes, s.AddChangeWithTimeStamp(changes, userID, changeID, util.TimeStampMs())
 }
 
+// See ExpectationsStore interface.
+func (s *SQLExpectationsStore) TriageHistory(testName, digest string) ([]*TriageHistoryEntry, error) {
+	const stmtTmpl = `SELECT ec.id, tc.name, tc.digest, tc.label, ec.userid, ec.ts, ec.undo_changeid, ec.reason, IFNULL(tc.removed, 0)
+	                  FROM exp_change AS ec, exp_test_change AS tc
+	                  WHERE (ec.id = tc.changeid) AND (tc.name = ?) %s
+	                  ORDER BY ec.ts DESC, ec.id DESC`
+
+	args := []interface{}{testName}
+	digestCond := ""
+	if digest != "" {
+		digestCond = "AND (tc.digest = ?)"
+		args = append(args, digest)
+	}
+
+	rows, err := s.vdb.DB.Query(fmt.Sprintf(stmtTmpl, digestCond), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer util.Close(rows)
+
+	ret := []*TriageHistoryEntry{}
+	for rows.Next() {
+		e := &TriageHistoryEntry{}
+		if err = rows.Scan(&e.ChangeID, &e.TestName, &e.Digest, &e.Label, &e.Name, &e.TS, &e.UndoChangeID, &e.Reason, &e.Removed); err != nil {
+			return nil, err
+		}
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
+
 // Loads a single change entry with all details from the DB.
 func (s *SQLExpectationsStore) loadChangeEntry(changeID int) (*TriageLogEntry, error) {
 	changeInfo, _, err := s.queryChanges(0, 5, changeID, true)
@@ -394,9 +452,19 @@ func (c *CachingExpectationStore) Get() (exp *Expectations, err error) {
 	return c.cache.Get()
 }
 
+// See ExpectationsStore interface.
+func (c *CachingExpectationStore) Version() (int64, error) {
+	return c.store.Version()
+}
+
 // See ExpectationsStore interface.
 func (c *CachingExpectationStore) AddChange(changedTests map[string]types.TestClassification, userId string) error {
-	if err := c.store.AddChange(changedTests, userId); err != nil {
+	return c.AddChangeWithReason(changedTests, userId, "")
+}
+
+// See ExpectationsStore interface.
+func (c *CachingExpectationStore) AddChangeWithReason(changedTests map[string]types.TestClassification, userId, reason string) error {
+	if err := c.store.AddChangeWithReason(changedTests, userId, reason); err != nil {
 		return err
 	}
 	return c.addChangeToCache(changedTests, userId)
@@ -446,6 +514,11 @@ func (c *CachingExpectationStore) UndoChange(changeID int, userID string) (map[s
 	return changedTests, c.addChangeToCache(changedTests, userID)
 }
 
+// See ExpectationsStore interface.
+func (c *CachingExpectationStore) TriageHistory(testName, digest string) ([]*TriageHistoryEntry, error) {
+	return c.store.TriageHistory(testName, digest)
+}
+
 // See ExpectationsStore interface.
 // TODO(stephana): Implement once API is defined.
 func (c *CachingExpectationStore) CanonicalTraceIDs(testNames []string) (map[string]string, error) {
@@ -1,16 +1,11 @@
 package filediffstore
 
 import (
-	"bytes"
-	"crypto/md5"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"image"
 	"image/png"
-	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -21,12 +16,10 @@ import (
 	"github.com/hashicorp/golang-lru"
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/fileutil"
-	"go.skia.org/infra/go/gs"
-	"go.skia.org/infra/go/httputils"
 	"go.skia.org/infra/go/metrics2"
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/golden/go/diff"
-	storage "google.golang.org/api/storage/v1"
+	"go.skia.org/infra/golden/go/imagesource"
 )
 
 const (
@@ -44,9 +37,6 @@ const (
 	RECOMMENDED_WORKER_POOL_SIZE = 2000
 	IMAGE_LRU_CACHE_SIZE         = 500
 	METRIC_LRU_CACHE_SIZE        = 100000
-
-	// Limit the number of times diffstore tries to get a file before giving up.
-	MAX_URI_GET_TRIES = 4
 )
 
 // Interface that the cacheFactory argument must implement.
@@ -74,8 +64,8 @@ func (d DiffMetricsCodec) Decode(data []byte) (interface{}, error) {
 }
 
 type FileDiffStore struct {
-	// The client used to connect to Google Storage.
-	client *http.Client
+	// The source images are retrieved from.
+	source imagesource.ImageSource
 
 	// The local directory where image digests should be written to.
 	localImgDir string
@@ -95,12 +85,6 @@ type FileDiffStore struct {
 	// LRU cache for images.
 	imageCache util.LRUCache
 
-	// The GS bucket where images are stored.
-	gsBucketName string
-
-	// The complete GS URL where images are stored.
-	storageBaseDir string
-
 	// The channels workers pick up tasks from.
 	absPathCh chan *WorkerReq
 	getCh     chan *WorkerReq
@@ -122,32 +106,27 @@ type FileDiffStore struct {
 	failureDB *bolt.DB
 
 	// Contains the number of times digests were successfully downloaded from
-	// Google Storage.
+	// the image source.
 	downloadSuccessCount *metrics2.Counter
 	// Contains the number of times digests failed to download from
-	// Google Storage.
+	// the image source.
 	downloadFailureCount *metrics2.Counter
+
+	// perceptual is true if the perceptual diff is calculated, see
+	// diff.PerceptualDiffPixels.
+	perceptual bool
 }
 
 // NewFileDiffStore intializes and returns a file based implementation of
-// DiffStore. The optional http.Client is used to make HTTP requests to Google
-// Storage. If nil is supplied then a default client is used. The baseDir is
+// DiffStore. Images are downloaded from the given image source. The baseDir is
 // the local base directory where the DEFAULT_IMG_DIR_NAME,
 // DEFAULT_DIFF_DIR_NAME and the DEFAULT_DIFFMETRICS_DIR_NAME directories
-// exist. gsBucketName is the bucket images will be downloaded from.
-// storageBaseDir is the directory in the bucket (if empty
-// DEFAULT_GS_IMG_DIR_NAME is used).  workerPoolSize is the max number of
-// simultaneous goroutines that will be created when running Get or AbsPath.
-// Use RECOMMENDED_WORKER_POOL_SIZE if unsure what this value should be.
-func NewFileDiffStore(client *http.Client, baseDir, gsBucketName string, storageBaseDir string, cacheFactory CacheFactory, workerPoolSize int) (diff.DiffStore, error) {
-	if client == nil {
-		client = httputils.NewTimeoutClient()
-	}
-
-	if storageBaseDir == "" {
-		storageBaseDir = DEFAULT_GS_IMG_DIR_NAME
-	}
-
+// exist. workerPoolSize is the max number of simultaneous goroutines that will
+// be created when running Get or AbsPath. Use RECOMMENDED_WORKER_POOL_SIZE if
+// unsure what this value should be. If perceptual is true the expensive
+// perceptual diff is calculated as well, including for diff metrics that were
+// cached without it.
+func NewFileDiffStore(source imagesource.ImageSource, baseDir string, cacheFactory CacheFactory, workerPoolSize int, perceptual bool) (diff.DiffStore, error) {
 	imageCache, err := lru.New(IMAGE_LRU_CACHE_SIZE)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to alloace image LRU cache: %s", err)
@@ -163,13 +142,11 @@ func NewFileDiffStore(client *http.Client, baseDir, gsBucketName string, storage
 	}
 
 	fs := &FileDiffStore{
-		client:               client,
+		source:               source,
 		localImgDir:          fileutil.Must(fileutil.EnsureDirExists(filepath.Join(baseDir, DEFAULT_IMG_DIR_NAME))),
 		localDiffDir:         fileutil.Must(fileutil.EnsureDirExists(filepath.Join(baseDir, DEFAULT_DIFF_DIR_NAME))),
 		localDiffMetricsDir:  fileutil.Must(fileutil.EnsureDirExists(filepath.Join(baseDir, DEFAULT_DIFFMETRICS_DIR_NAME))),
 		localTempFileDir:     fileutil.Must(fileutil.EnsureDirExists(filepath.Join(baseDir, DEFAULT_TEMPFILE_DIR_NAME))),
-		gsBucketName:         gsBucketName,
-		storageBaseDir:       storageBaseDir,
 		imageCache:           imageCache,
 		diffCache:            diffCache,
 		unavailableDigests:   map[string]*diff.DigestFailure{},
@@ -177,6 +154,7 @@ func NewFileDiffStore(client *http.Client, baseDir, gsBucketName string, storage
 		failureDB:            failureDB,
 		downloadSuccessCount: metrics2.GetCounter("gold.gsdownload", map[string]string{"result": "success"}),
 		downloadFailureCount: metrics2.GetCounter("gold.gsdownload", map[string]string{"result": "failure"}),
+		perceptual:           perceptual,
 	}
 
 	if err := fs.loadDigestFailures(); err != nil {
@@ -267,10 +245,10 @@ func (f *FileDiffStore) loadDigestFailures() error {
 }
 
 func (f *FileDiffStore) PurgeDigests(digests []string, purgeGS bool) error {
-	// Remove from GS if requested.
+	// Remove from the image source if requested.
 	if purgeGS {
 		for _, d := range digests {
-			if err := f.removeImageFromGS(d); err != nil {
+			if err := f.source.Delete(d); err != nil {
 				return err
 			}
 		}
@@ -394,6 +372,26 @@ func (fs *FileDiffStore) getOne(dMain, dOther string) interface{} {
 		}
 	}
 
+	// Diff metrics that were cached before the perceptual diff was enabled
+	// don't contain it yet. Add it and update the caches.
+	if fs.perceptual && !diffMetrics.HasPerceptualDiff {
+		updated := *diffMetrics
+		if err := fs.addPerceptualDiff(dMain, dOther, &updated); err != nil {
+			glog.Errorf("Failed to calculate perceptual diff for digest %s and digest %s: %s", dMain, dOther, err)
+			return nil
+		}
+		updated.PixelDiffFilePath = fmt.Sprintf("%s.%s", baseName, DIFF_EXTENSION)
+		diffMetrics = &updated
+		fs.diffCache.Add(baseName, diffMetrics)
+
+		writeCopy := *diffMetrics
+		go func() {
+			if err := fs.writeDiffMetricsToFileCache(baseName, &writeCopy); err != nil {
+				glog.Errorf("Failed to write diff metrics to cache for digest %s and digest %s: %s", dMain, dOther, err)
+			}
+		}()
+	}
+
 	// Expand the path of the diff images.
 	diffMetrics.PixelDiffFilePath = filepath.Join(fs.localDiffDir, diffMetrics.PixelDiffFilePath)
 	return diffMetrics
@@ -643,15 +641,15 @@ func (fs *FileDiffStore) getDiffMetricsFromFileCache(baseName string) (*diff.Dif
 }
 
 // ensureDigestInCache checks if the image corresponding to digest is cached
-// localy. If not it will download it from GS.
+// localy. If not it will download it from the image source.
 func (fs *FileDiffStore) ensureDigestInCache(d string) error {
 	exists, err := fs.isDigestInCache(d)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		// Digest does not exist locally, get it from Google Storage.
-		if err := fs.cacheImageFromGS(d); err != nil {
+		// Digest does not exist locally, get it from the image source.
+		if err := fs.cacheImageFromSource(d); err != nil {
 			fs.unavailableChan <- &diff.DigestFailure{
 				Digest: d,
 				Reason: diff.HTTP,
@@ -685,106 +683,47 @@ func (fs *FileDiffStore) isDigestInCache(d string) (bool, error) {
 	return true, nil
 }
 
-// Downloads image file from Google Storage and caches it in a local directory. It
-// is thread safe because it locks the diff store's mutext before accessing the
-// digest cache. If the provided digest does not exist in Google Storage then
+// Retrieves the image file from the image source and caches it in a local
+// directory. It is thread safe because it locks the diff store's mutext before
+// accessing the digest cache. If the image can't be retrieved then
 // downloadFailureCount is incremented.
-//
-func (fs *FileDiffStore) cacheImageFromGS(d string) error {
-	storage, err := storage.New(fs.client)
+func (fs *FileDiffStore) cacheImageFromSource(d string) error {
+	imgBytes, err := fs.source.Get(d)
 	if err != nil {
-		return fmt.Errorf("Failed to create interface to Google Storage: %s\n", err)
+		fs.downloadFailureCount.Inc(1)
+		return err
 	}
 
-	objLocation := filepath.Join(fs.storageBaseDir, fmt.Sprintf("%s.%s", d, IMG_EXTENSION))
-	res, err := storage.Objects.Get(fs.gsBucketName, objLocation).Do()
+	// TODO(stephana): Creating and renaming temporary files this way
+	// should be made into a generic utility function.
+	// See also FileTileStore for a similar implementation.
+	// Create a temporary file.
+	tempOut, err := ioutil.TempFile(fs.localTempFileDir, fmt.Sprintf("tempfile-%s", d))
 	if err != nil {
-		fs.downloadFailureCount.Inc(1)
-		return fmt.Errorf("Unable to retrieve: %s/%s:  %s", fs.gsBucketName, objLocation, err)
+		return fmt.Errorf("Unable to create temp file: %s", err)
 	}
-
-	for i := 0; i < MAX_URI_GET_TRIES; i++ {
-		if i > 0 {
-			glog.Warningf("%d. retry for digest %s", i, d)
-		}
-
-		err = func() error {
-			respBody, err := fs.getRespBody(res)
-			if err != nil {
-				return err
-			}
-			defer util.Close(respBody)
-
-			// TODO(stephana): Creating and renaming temporary files this way
-			// should be made into a generic utility function.
-			// See also FileTileStore for a similar implementation.
-			// Create a temporary file.
-			tempOut, err := ioutil.TempFile(fs.localTempFileDir, fmt.Sprintf("tempfile-%s", d))
-			if err != nil {
-				return fmt.Errorf("Unable to create temp file: %s", err)
-			}
-
-			md5Hash := md5.New()
-			multiOut := io.MultiWriter(md5Hash, tempOut)
-
-			if _, err = io.Copy(multiOut, respBody); err != nil {
-				return err
-			}
-			err = tempOut.Close()
-			if err != nil {
-				return fmt.Errorf("Error closing temp file: %s", err)
-			}
-
-			// Check the MD5.
-			objMD5, err := base64.StdEncoding.DecodeString(res.Md5Hash)
-			if err != nil {
-				return fmt.Errorf("Unable to decode MD5 hash from %s", d)
-			}
-
-			if !bytes.Equal(md5Hash.Sum(nil), objMD5) {
-				return fmt.Errorf("MD5 hash for digest %s incorrect.", d)
-			}
-
-			// Rename the file after we acquired a lock
-			outputBaseName := fs.getImageBaseName(d)
-			outputFile, err := fs.createRadixPath(fs.localImgDir, outputBaseName)
-			if err != nil {
-				return fmt.Errorf("Error creating output file: %s", err)
-			}
-
-			fs.digestDirLock.Lock()
-			defer fs.digestDirLock.Unlock()
-			if err := os.Rename(tempOut.Name(), outputFile); err != nil {
-				return fmt.Errorf("Unable to move file: %s", err)
-			}
-
-			fs.downloadSuccessCount.Inc(1)
-			return nil
-		}()
-
-		if err == nil {
-			break
-		}
-		glog.Errorf("Error fetching file for digest %s: %s", d, err)
+	if _, err := tempOut.Write(imgBytes); err != nil {
+		util.Close(tempOut)
+		return fmt.Errorf("Error writing temp file: %s", err)
 	}
-
-	if err != nil {
-		glog.Errorf("Failed fetching file after %d attempts", MAX_URI_GET_TRIES)
-		fs.downloadFailureCount.Inc(1)
+	if err := tempOut.Close(); err != nil {
+		return fmt.Errorf("Error closing temp file: %s", err)
 	}
-	return err
-}
 
-func (fs *FileDiffStore) removeImageFromGS(d string) error {
-	storage, err := storage.New(fs.client)
+	// Rename the file after we acquired a lock
+	outputBaseName := fs.getImageBaseName(d)
+	outputFile, err := fs.createRadixPath(fs.localImgDir, outputBaseName)
 	if err != nil {
-		return fmt.Errorf("Failed to create interface to Google Storage: %s\n", err)
+		return fmt.Errorf("Error creating output file: %s", err)
 	}
 
-	objLocation := filepath.Join(fs.storageBaseDir, fmt.Sprintf("%s.%s", d, IMG_EXTENSION))
-	if err := storage.Objects.Delete(fs.gsBucketName, objLocation).Do(); err != nil {
-		return fmt.Errorf("Unable to delete %s/%s:  %s", fs.gsBucketName, objLocation, err)
+	fs.digestDirLock.Lock()
+	defer fs.digestDirLock.Unlock()
+	if err := os.Rename(tempOut.Name(), outputFile); err != nil {
+		return fmt.Errorf("Unable to move file: %s", err)
 	}
+
+	fs.downloadSuccessCount.Inc(1)
 	return nil
 }
 
@@ -798,26 +737,6 @@ func (fs *FileDiffStore) removeImageFromCache(d string) error {
 	return os.Remove(path)
 }
 
-// Returns the response body of the specified GS object. Tries MAX_URI_GET_TRIES
-// times if download is unsuccessful. Client must close the response body when
-// finished with it.
-func (fs *FileDiffStore) getRespBody(res *storage.Object) (io.ReadCloser, error) {
-	request, err := gs.RequestForStorageURL(res.MediaLink)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to create Storage MediaURI request: %s\n", err)
-	}
-
-	resp, err := fs.client.Do(request)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to retrieve Storage MediaURI: %s", err)
-	}
-	if resp.StatusCode != 200 {
-		defer util.Close(resp.Body)
-		return nil, fmt.Errorf("Failed to retrieve: %d  %s", resp.StatusCode, resp.Status)
-	}
-	return resp.Body, nil
-}
-
 // Calculate the DiffMetrics for the provided digests.
 func (fs *FileDiffStore) diff(d1, d2 string) (*diff.DiffMetrics, error) {
 	img1, err := fs.getDigestImage(d1)
@@ -830,6 +749,10 @@ func (fs *FileDiffStore) diff(d1, d2 string) (*diff.DiffMetrics, error) {
 		return nil, err
 	}
 	dm, resultImg := diff.Diff(img1, img2)
+	if fs.perceptual {
+		dm.NumPerceptualDiffPixels = diff.PerceptualDiffPixels(img1, img2)
+		dm.HasPerceptualDiff = true
+	}
 
 	baseName := getDiffBasename(d1, d2)
 
@@ -866,6 +789,29 @@ func (fs *FileDiffStore) diff(d1, d2 string) (*diff.DiffMetrics, error) {
 	return dm, nil
 }
 
+// addPerceptualDiff calculates the perceptual diff of the given digests and
+// adds it to dm.
+func (fs *FileDiffStore) addPerceptualDiff(d1, d2 string, dm *diff.DiffMetrics) error {
+	for _, d := range []string{d1, d2} {
+		if err := fs.ensureDigestInCache(d); err != nil {
+			return err
+		}
+	}
+
+	img1, err := fs.getDigestImage(d1)
+	if err != nil {
+		return err
+	}
+
+	img2, err := fs.getDigestImage(d2)
+	if err != nil {
+		return err
+	}
+	dm.NumPerceptualDiffPixels = diff.PerceptualDiffPixels(img1, img2)
+	dm.HasPerceptualDiff = true
+	return nil
+}
+
 // getDigestImage returns the image corresponding to the digest either from
 // RAM or disk.
 func (fs *FileDiffStore) getDigestImage(d string) (image.Image, error) {
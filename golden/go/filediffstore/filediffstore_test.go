@@ -16,6 +16,7 @@ import (
 	"go.skia.org/infra/go/testutils"
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/imagesource"
 )
 
 const (
@@ -63,8 +64,12 @@ func getTestFileDiffStore(t *testing.T, storageDir, testDir string, cleanTestDir
 		assert.NoError(t, os.RemoveAll(filepath.Join(testDir, "diffs")))
 	}
 
-	gsBucketName := "chromium-skia-gm"
-	temp, err := NewFileDiffStore(nil, testDir, gsBucketName, storageDir, MemCacheFactory, RECOMMENDED_WORKER_POOL_SIZE)
+	if storageDir == "" {
+		storageDir = DEFAULT_GS_IMG_DIR_NAME
+	}
+	source, err := imagesource.NewGSImageSource(nil, "chromium-skia-gm", storageDir)
+	assert.NoError(t, err)
+	temp, err := NewFileDiffStore(source, testDir, MemCacheFactory, RECOMMENDED_WORKER_POOL_SIZE, true)
 	assert.NoError(t, err)
 	ret := temp.(*FileDiffStore)
 	// Override the counters to avoid collisions between parallel tests.
@@ -80,6 +85,7 @@ func getTestFileDiffStore(t *testing.T, storageDir, testDir string, cleanTestDir
 		PixelDiffFilePath: diffpath1_2,
 		MaxRGBADiffs:      []int{0, 0, 1, 0},
 		DimDiffer:         false,
+		HasPerceptualDiff: true,
 	}
 	relExpectedDiffMetrics1_2 = &diff.DiffMetrics{}
 	*relExpectedDiffMetrics1_2 = *expectedDiffMetrics1_2
@@ -88,11 +94,13 @@ func getTestFileDiffStore(t *testing.T, storageDir, testDir string, cleanTestDir
 	// DiffMetrics between TEST_DIGEST1 and TEST_DIGEST3.
 	diffpath1_3 := filepath.Join(ret.localDiffDir, fmt.Sprintf("%s-%s.%s", TEST_DIGEST3, TEST_DIGEST1, DIFF_EXTENSION))
 	expectedDiffMetrics1_3 = &diff.DiffMetrics{
-		NumDiffPixels:     250000,
-		PixelDiffPercent:  100,
-		PixelDiffFilePath: diffpath1_3,
-		MaxRGBADiffs:      []int{248, 90, 113, 0},
-		DimDiffer:         true,
+		NumDiffPixels:           250000,
+		PixelDiffPercent:        100,
+		PixelDiffFilePath:       diffpath1_3,
+		MaxRGBADiffs:            []int{248, 90, 113, 0},
+		DimDiffer:               true,
+		NumPerceptualDiffPixels: 250000,
+		HasPerceptualDiff:       true,
 	}
 
 	return ret
@@ -142,7 +150,7 @@ func TestCacheImageFromGS(t *testing.T) {
 	imgFilePath := filepath.Join(fds.localImgDir, TEST_DIGEST3[0:2], TEST_DIGEST3[2:4], fmt.Sprintf("%s.%s", TEST_DIGEST3, IMG_EXTENSION))
 	defer testutils.Remove(t, imgFilePath)
 
-	err := fds.cacheImageFromGS(TEST_DIGEST3)
+	err := fds.cacheImageFromSource(TEST_DIGEST3)
 	assert.NoError(t, err)
 
 	if _, err := os.Stat(imgFilePath); err != nil {
@@ -152,7 +160,7 @@ func TestCacheImageFromGS(t *testing.T) {
 
 	// Test error and assert the download failures map.
 	for i := 1; i < 6; i++ {
-		if err := fds.cacheImageFromGS(MISSING_DIGEST); err == nil {
+		if err := fds.cacheImageFromSource(MISSING_DIGEST); err == nil {
 			t.Error("Was expecting 404 error for missing digest")
 		}
 		assert.Equal(t, int64(1), fds.downloadSuccessCount.Get())
@@ -0,0 +1,260 @@
+// flaky identifies traces that oscillate between digests. These are usually
+// produced by non-deterministic tests and cause a steady stream of untriaged
+// digests.
+package flaky
+
+import (
+	"net/url"
+	"sort"
+
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/timer"
+	"go.skia.org/infra/golden/go/types"
+)
+
+const (
+	// MIN_CHANGES is the minimum number of digest changes in a trace before it
+	// can be considered flaky.
+	MIN_CHANGES = 3
+
+	// SCORE_THRESHOLD is the minimum score of a flaky trace, see
+	// TraceFlakiness.Score.
+	SCORE_THRESHOLD = 0.2
+)
+
+// TraceFlakiness describes how often the digest of a trace changes.
+type TraceFlakiness struct {
+	TraceID string            `json:"traceId"`
+	Params  map[string]string `json:"params"`
+
+	// Changes is the number of commits at which the digest differs from the
+	// previous digest in the trace.
+	Changes int `json:"changes"`
+
+	// Digests is the number of distinct digests in the trace.
+	Digests int `json:"digests"`
+
+	// Score is the number of changes per commit with data in the range [0, 1].
+	// A trace that produces a different digest at every commit has a score of 1.
+	Score float64 `json:"score"`
+
+	// Ignored is true if the trace matches an ignore rule.
+	Ignored bool `json:"ignored"`
+}
+
+// TraceFlakinessSlice is a utility type to sort TraceFlakiness instances by
+// descending score.
+type TraceFlakinessSlice []*TraceFlakiness
+
+func (t TraceFlakinessSlice) Len() int { return len(t) }
+func (t TraceFlakinessSlice) Less(i, j int) bool {
+	if t[i].Score == t[j].Score {
+		return t[i].TraceID < t[j].TraceID
+	}
+	return t[i].Score > t[j].Score
+}
+func (t TraceFlakinessSlice) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+
+// TestFlakiness summarizes the flaky traces of a test.
+type TestFlakiness struct {
+	Test        string            `json:"test"`
+	FlakyTraces int               `json:"flakyTraces"`
+	TotalTraces int               `json:"totalTraces"`
+	Traces      []*TraceFlakiness `json:"traces"`
+
+	// SuggestedIgnore is a query that matches all flaky traces of the test that
+	// are not ignored yet. It can be used as the query of an ignore rule. It is
+	// empty if all flaky traces are ignored.
+	SuggestedIgnore string `json:"suggestedIgnore"`
+
+	// SuggestedIgnoreCollateral is the number of traces that are not flaky,
+	// but would be ignored by SuggestedIgnore as well.
+	SuggestedIgnoreCollateral int `json:"suggestedIgnoreCollateral"`
+}
+
+// TestFlakinessSlice is a utility type to sort TestFlakiness instances by
+// descending number of flaky traces.
+type TestFlakinessSlice []*TestFlakiness
+
+func (t TestFlakinessSlice) Len() int { return len(t) }
+func (t TestFlakinessSlice) Less(i, j int) bool {
+	if t[i].FlakyTraces == t[j].FlakyTraces {
+		return t[i].Test < t[j].Test
+	}
+	return t[i].FlakyTraces > t[j].FlakyTraces
+}
+func (t TestFlakinessSlice) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+
+// Flakiness contains the flaky traces of a tile.
+// It is not thread safe. The client of this package needs to make sure there
+// are no conflicts.
+type Flakiness struct {
+	// traces contains the flaky traces keyed by trace id.
+	traces map[string]*TraceFlakiness
+
+	// tests contains the tests with at least one flaky trace.
+	tests []*TestFlakiness
+
+	// paramCounts maps parameter names to parameter values to the number of
+	// flaky traces with that value.
+	paramCounts map[string]map[string]int
+}
+
+// New creates a new Flakiness instance.
+func New() *Flakiness {
+	return &Flakiness{
+		traces:      map[string]*TraceFlakiness{},
+		tests:       []*TestFlakiness{},
+		paramCounts: map[string]map[string]int{},
+	}
+}
+
+// Calculate finds the flaky traces in the given tiles.
+func (f *Flakiness) Calculate(tilePair *types.TilePair) {
+	defer timer.New("flakiness").Stop()
+
+	traces := map[string]*TraceFlakiness{}
+	paramCounts := map[string]map[string]int{}
+	testTraces := map[string]map[string]tiling.Trace{}
+	for id, tr := range tilePair.TileWithIgnores.Traces {
+		test := tr.Params()[types.PRIMARY_KEY_FIELD]
+		if _, ok := testTraces[test]; !ok {
+			testTraces[test] = map[string]tiling.Trace{}
+		}
+		testTraces[test][id] = tr
+
+		tf := scoreTrace(id, tr.(*types.GoldenTrace))
+		if !isFlaky(tf) {
+			continue
+		}
+		_, inTile := tilePair.Tile.Traces[id]
+		tf.Ignored = !inTile
+		traces[id] = tf
+
+		for k, v := range tf.Params {
+			if _, ok := paramCounts[k]; !ok {
+				paramCounts[k] = map[string]int{}
+			}
+			paramCounts[k][v]++
+		}
+	}
+
+	tests := []*TestFlakiness{}
+	for test, trs := range testTraces {
+		testFlakiness := &TestFlakiness{
+			Test:        test,
+			TotalTraces: len(trs),
+			Traces:      []*TraceFlakiness{},
+		}
+		for id := range trs {
+			if tf, ok := traces[id]; ok {
+				testFlakiness.Traces = append(testFlakiness.Traces, tf)
+			}
+		}
+		if len(testFlakiness.Traces) == 0 {
+			continue
+		}
+		testFlakiness.FlakyTraces = len(testFlakiness.Traces)
+		sort.Sort(TraceFlakinessSlice(testFlakiness.Traces))
+		suggestIgnore(testFlakiness, trs, traces)
+		tests = append(tests, testFlakiness)
+	}
+	sort.Sort(TestFlakinessSlice(tests))
+
+	f.traces = traces
+	f.tests = tests
+	f.paramCounts = paramCounts
+}
+
+// IsFlaky returns true if the trace with the given id is flaky.
+func (f *Flakiness) IsFlaky(traceID string) bool {
+	_, ok := f.traces[traceID]
+	return ok
+}
+
+// Tests returns the tests with flaky traces, the test with the most flaky
+// traces first.
+func (f *Flakiness) Tests() []*TestFlakiness {
+	return f.tests
+}
+
+// ParamCounts returns the number of flaky traces for each parameter value.
+// The result maps parameter names to parameter values to counts. It allows to
+// identify configurations that are especially prone to flakiness.
+func (f *Flakiness) ParamCounts() map[string]map[string]int {
+	return f.paramCounts
+}
+
+// scoreTrace calculates the flakiness of the given trace.
+func scoreTrace(id string, tr *types.GoldenTrace) *TraceFlakiness {
+	ret := &TraceFlakiness{
+		TraceID: id,
+		Params:  tr.Params_,
+	}
+
+	digests := map[string]bool{}
+	prev := ""
+	nValues := 0
+	for _, digest := range tr.Values {
+		if digest == types.MISSING_DIGEST {
+			continue
+		}
+		nValues++
+		digests[digest] = true
+		if (prev != "") && (digest != prev) {
+			ret.Changes++
+		}
+		prev = digest
+	}
+
+	ret.Digests = len(digests)
+	if nValues > 1 {
+		ret.Score = float64(ret.Changes) / float64(nValues-1)
+	}
+	return ret
+}
+
+// isFlaky returns true if the given flakiness qualifies a trace as flaky.
+func isFlaky(tf *TraceFlakiness) bool {
+	return (tf.Changes >= MIN_CHANGES) && (tf.Score >= SCORE_THRESHOLD)
+}
+
+// suggestIgnore sets the suggested ignore rule of the given test. The query of
+// the rule consists of the parameters that all flaky traces of the test that
+// are not yet ignored have in common. testTraces are all traces of the test and
+// flakyTraces all flaky traces of the tile.
+func suggestIgnore(testFlakiness *TestFlakiness, testTraces map[string]tiling.Trace, flakyTraces map[string]*TraceFlakiness) {
+	var common map[string]string = nil
+	for _, tf := range testFlakiness.Traces {
+		if tf.Ignored {
+			continue
+		}
+		if common == nil {
+			common = make(map[string]string, len(tf.Params))
+			for k, v := range tf.Params {
+				common[k] = v
+			}
+			continue
+		}
+		for k, v := range common {
+			if tf.Params[k] != v {
+				delete(common, k)
+			}
+		}
+	}
+	if common == nil {
+		return
+	}
+
+	query := make(url.Values, len(common))
+	for k, v := range common {
+		query[k] = []string{v}
+	}
+	testFlakiness.SuggestedIgnore = query.Encode()
+
+	for id, tr := range testTraces {
+		if _, ok := flakyTraces[id]; !ok && tiling.Matches(tr, query) {
+			testFlakiness.SuggestedIgnoreCollateral++
+		}
+	}
+}
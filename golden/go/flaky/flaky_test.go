@@ -0,0 +1,71 @@
+package flaky
+
+import (
+	"net/url"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/golden/go/types"
+)
+
+func TestCalculate(t *testing.T) {
+	tileWithIgnores := tiling.NewTile()
+	tile := tiling.NewTile()
+	addTrace := func(id string, params map[string]string, ignored bool, values ...string) {
+		trace := types.NewGoldenTraceN(len(values))
+		copy(trace.Values, values)
+		for k, v := range params {
+			trace.Params_[k] = v
+		}
+		tileWithIgnores.Traces[id] = trace
+		if !ignored {
+			tile.Traces[id] = trace
+		}
+	}
+
+	// Stable trace and a trace with a single change.
+	addTrace("a", map[string]string{types.PRIMARY_KEY_FIELD: "test1", "config": "8888", "os": "linux"}, false, "d1", "d1", "d1", "d1", "d1")
+	addTrace("b", map[string]string{types.PRIMARY_KEY_FIELD: "test1", "config": "gpu", "os": "linux"}, false, "d1", "d1", "d2", "d2", "d2")
+
+	// Flaky traces, missing digests are skipped.
+	addTrace("c", map[string]string{types.PRIMARY_KEY_FIELD: "test1", "config": "gpu", "os": "win"}, false, "d1", "d2", "d1", "d2", "d1")
+	addTrace("d", map[string]string{types.PRIMARY_KEY_FIELD: "test1", "config": "gpu", "os": "mac"}, false, "d1", "", "d3", "", "d1", "d3")
+	addTrace("e", map[string]string{types.PRIMARY_KEY_FIELD: "test2", "config": "gpu", "os": "win"}, true, "d4", "d5", "d4", "d5", "d4")
+
+	f := New()
+	f.Calculate(&types.TilePair{Tile: tile, TileWithIgnores: tileWithIgnores})
+
+	assert.False(t, f.IsFlaky("a"))
+	assert.False(t, f.IsFlaky("b"))
+	assert.True(t, f.IsFlaky("c"))
+	assert.True(t, f.IsFlaky("d"))
+	assert.True(t, f.IsFlaky("e"))
+
+	tests := f.Tests()
+	assert.Equal(t, 2, len(tests))
+	assert.Equal(t, "test1", tests[0].Test)
+	assert.Equal(t, 2, tests[0].FlakyTraces)
+	assert.Equal(t, 4, tests[0].TotalTraces)
+	assert.Equal(t, "c", tests[0].Traces[0].TraceID)
+	assert.Equal(t, 4, tests[0].Traces[0].Changes)
+	assert.Equal(t, 2, tests[0].Traces[0].Digests)
+	assert.Equal(t, 1.0, tests[0].Traces[0].Score)
+	assert.Equal(t, "d", tests[0].Traces[1].TraceID)
+	assert.Equal(t, 3, tests[0].Traces[1].Changes)
+	assert.False(t, tests[0].Traces[1].Ignored)
+
+	// The suggested rule matches both flaky traces and also trace "b".
+	query, err := url.ParseQuery(tests[0].SuggestedIgnore)
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{types.PRIMARY_KEY_FIELD: []string{"test1"}, "config": []string{"gpu"}}, query)
+	assert.Equal(t, 1, tests[0].SuggestedIgnoreCollateral)
+
+	// All flaky traces of test2 are ignored already.
+	assert.Equal(t, "test2", tests[1].Test)
+	assert.True(t, tests[1].Traces[0].Ignored)
+	assert.Equal(t, "", tests[1].SuggestedIgnore)
+
+	assert.Equal(t, map[string]int{"gpu": 3}, f.ParamCounts()["config"])
+	assert.Equal(t, map[string]int{"win": 2, "mac": 1}, f.ParamCounts()["os"])
+}
@@ -0,0 +1,593 @@
+// goldctl is a command-line client for Gold. It allows to create, check and
+// upload test results and to triage digests without a browser.
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/auth"
+	"go.skia.org/infra/go/common"
+	"go.skia.org/infra/go/fileutil"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/login"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/baseline"
+	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/goldingestion"
+	"go.skia.org/infra/golden/go/imagesource"
+	"go.skia.org/infra/golden/go/types"
+	"golang.org/x/net/context"
+	"google.golang.org/api/option"
+)
+
+const (
+	// GS_PREFIX is the prefix of destinations in Google Storage.
+	GS_PREFIX = "gs://"
+
+	// IMAGES_URL_PATH is the path under which Gold serves the images of digests.
+	IMAGES_URL_PATH = "/img/images"
+)
+
+// flags
+var (
+	baselineFile   = flag.String("baseline_file", "", "File to cache the baseline in. If set, the baseline is only downloaded when it has changed.")
+	builder        = flag.String("builder", "", "Name of the builder that produced the results.")
+	dest           = flag.String("dest", "", "Directory or gs://bucket/path the ingestion of the Gold instance reads results from.")
+	digests        = flag.String("digest", "", "Comma separated list of digests to triage.")
+	failUntriaged  = flag.Bool("fail_untriaged", false, "If true then compare also fails if there are untriaged digests.")
+	gitHash        = flag.String("git_hash", "", "Git hash of the commit the results were produced at.")
+	goldURL        = flag.String("url", "https://gold.skia.org", "URL of the Gold instance.")
+	imageDest      = flag.String("image_dest", "", "Directory or gs://bucket/path the Gold instance reads images from, e.g. gs://chromium-skia-gm/dm-images-v1.")
+	imagesDir      = flag.String("images_dir", "", "Directory containing the PNG images of the results.")
+	issue          = flag.Int64("issue", 0, "Code review issue the results belong to, if any.")
+	keys           = flag.String("keys", "", "Comma separated list of key=value pairs that identify the configuration that produced the results, e.g. os=Ubuntu,gpu=nvidia.")
+	oauthCacheFile = flag.String("oauth_cache_file", "goldctl_token.data", "Path to the file where to cache the OAuth credentials for requests to Gold.")
+	out            = flag.String("out", "", "File to write the output to. Defaults to stdout for JSON output.")
+	patchStorage   = flag.String("patch_storage", "", "Code review system of the issue. Set to 'gerrit' for Gerrit changes, otherwise Rietveld is assumed.")
+	patchset       = flag.Int64("patchset", 0, "Patchset of the code review issue the results belong to, if any.")
+	reason         = flag.String("reason", "", "Optional reason for the triage change, which is shown in the triage history.")
+	results        = flag.String("results", "dm.json", "The JSON file containing the results in the DM format.")
+	sourceType     = flag.String("source_type", "gm", "The source_type of the results created from images.")
+	status         = flag.String("status", "", "The label to assign when triaging: positive, negative or untriaged.")
+	storageCache   = flag.String("storage_oauth_cache_file", "goldctl_storage_token.data", "Path to the file where to cache the OAuth credentials for uploads to Google Storage.")
+	test           = flag.String("test", "", "Name of the test to triage.")
+	useAuth        = flag.Bool("auth", false, "If true then requests to Gold are authenticated, e.g. because it requires a login. Triage requests are always authenticated.")
+)
+
+var Usage = func() {
+	fmt.Printf(`Usage: goldctl <command> [OPTIONS]...
+Create, check and upload Gold results and triage digests.
+
+Commands:
+
+  dmjson    	Create results in the DM JSON format from the PNG images in a directory.
+            	The name of each image file (without extension) is used as the test
+            	name and the MD5 hash of its content as the digest.
+
+            	Flags: --images_dir --keys --source_type --git_hash --builder
+            	       --issue --patchset --patch_storage --out
+
+  compare   	Compare results against the baseline of a Gold instance. Lists all
+            	digests that are not known to be positive. Exits with a non-zero
+            	status if any digest is negative.
+
+            	Flags: --results --url --issue --baseline_file --fail_untriaged --auth
+
+  diff      	Diff two images and print the diff metrics. The arguments are either
+            	paths to PNG files or digests that are fetched from Gold.
+
+            	Flags: --url --out --auth
+
+            	Example: goldctl diff --out=diff.png <left> <right>
+
+  upload    	Upload results and their images so that Gold ingests them. The images
+            	are looked up in --images_dir by digest and by test name.
+
+            	Flags: --results --images_dir --dest --image_dest
+
+  triage    	Assign a label to digests of a test. Requires a client_secret.json file
+            	in the current directory and the client id to be accepted by Gold.
+
+            	Flags: --url --test --digest --status --reason
+
+Flags:
+
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Grab the first argument off of os.Args, the command, before we call flag.Parse.
+	if len(os.Args) < 2 {
+		Usage()
+		os.Exit(1)
+	}
+	cmd := os.Args[1]
+	os.Args = append([]string{os.Args[0]}, os.Args[2:]...)
+
+	// Now parse the flags.
+	common.Init()
+
+	var err error
+	switch cmd {
+	case "dmjson":
+		err = dmJSON()
+	case "compare":
+		err = compare()
+	case "diff":
+		err = diffImages()
+	case "upload":
+		err = upload()
+	case "triage":
+		err = triage()
+	default:
+		fmt.Printf("Unknown command: %s\n", cmd)
+		Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		glog.Fatalf("%s failed: %s", cmd, err)
+	}
+}
+
+// dmJSON creates a DMResults instance from the images in imagesDir.
+func dmJSON() error {
+	if *imagesDir == "" {
+		return fmt.Errorf("--images_dir is required.")
+	}
+	key, err := parseKeys(*keys)
+	if err != nil {
+		return err
+	}
+
+	fileNames, err := filepath.Glob(filepath.Join(*imagesDir, "*."+imagesource.IMG_EXTENSION))
+	if err != nil {
+		return err
+	}
+	sort.Strings(fileNames)
+
+	dmResults := &goldingestion.DMResults{
+		Builder:      *builder,
+		GitHash:      *gitHash,
+		Key:          key,
+		Issue:        *issue,
+		Patchset:     *patchset,
+		PatchStorage: *patchStorage,
+		Results:      make([]*goldingestion.Result, 0, len(fileNames)),
+	}
+	for _, fileName := range fileNames {
+		digest, err := fileDigest(fileName)
+		if err != nil {
+			return err
+		}
+		testName := strings.TrimSuffix(filepath.Base(fileName), "."+imagesource.IMG_EXTENSION)
+		dmResults.Results = append(dmResults.Results, &goldingestion.Result{
+			Key: map[string]string{
+				types.PRIMARY_KEY_FIELD: testName,
+				"source_type":           *sourceType,
+			},
+			Options: map[string]string{"ext": imagesource.IMG_EXTENSION},
+			Digest:  digest,
+		})
+	}
+
+	b, err := json.MarshalIndent(dmResults, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to encode results: %s", err)
+	}
+	if *out == "" {
+		fmt.Println(string(b))
+		return nil
+	}
+	return ioutil.WriteFile(*out, b, 0644)
+}
+
+// compare classifies the results against the baseline of the Gold instance.
+func compare() error {
+	dmResults, err := readResults(*results)
+	if err != nil {
+		return err
+	}
+
+	issueID := ""
+	if *issue != 0 {
+		issueID = fmt.Sprintf("%d", *issue)
+	}
+	b, err := fetchBaseline(issueID)
+	if err != nil {
+		return err
+	}
+
+	positive := toLookup(b.Positive)
+	negative := toLookup(b.Negative)
+	var nPositive, nNegative, nUntriaged int
+	for _, result := range dmResults.Results {
+		if result.Options["ext"] != imagesource.IMG_EXTENSION {
+			continue
+		}
+		testName := result.Key[types.PRIMARY_KEY_FIELD]
+		switch {
+		case positive[testName][result.Digest]:
+			nPositive++
+		case negative[testName][result.Digest]:
+			nNegative++
+			fmt.Printf("%-10s %s %s\n", types.NEGATIVE.String(), testName, result.Digest)
+		default:
+			nUntriaged++
+			fmt.Printf("%-10s %s %s\n", types.UNTRIAGED.String(), testName, result.Digest)
+		}
+	}
+	fmt.Printf("Positive: %d  Negative: %d  Untriaged: %d\n", nPositive, nNegative, nUntriaged)
+
+	if nNegative > 0 {
+		return fmt.Errorf("Found %d negative digests.", nNegative)
+	}
+	if *failUntriaged && (nUntriaged > 0) {
+		return fmt.Errorf("Found %d untriaged digests.", nUntriaged)
+	}
+	return nil
+}
+
+// cachedBaseline is the content of baselineFile.
+type cachedBaseline struct {
+	ETag     string             `json:"etag"`
+	Baseline *baseline.Baseline `json:"baseline"`
+}
+
+// fetchBaseline retrieves the baseline from the Gold instance. If
+// baselineFile is set the baseline is cached there and only downloaded again
+// if it has changed.
+func fetchBaseline(issueID string) (*baseline.Baseline, error) {
+	cached := &cachedBaseline{}
+	if *baselineFile != "" && fileutil.FileExists(*baselineFile) {
+		b, err := ioutil.ReadFile(*baselineFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, cached); err != nil {
+			glog.Warningf("Ignoring invalid baseline file %s: %s", *baselineFile, err)
+			cached = &cachedBaseline{}
+		}
+		if (cached.Baseline == nil) || (cached.Baseline.Issue != issueID) {
+			cached.ETag = ""
+		}
+	}
+
+	baselineURL := *goldURL + "/json/baseline"
+	if issueID != "" {
+		baselineURL += "?issue=" + issueID
+	}
+	req, err := http.NewRequest("GET", baselineURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	client, err := goldClient(*useAuth)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve baseline: %s", err)
+	}
+	defer util.Close(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return cached.Baseline, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("Failed to retrieve baseline. Got status: %s", resp.Status)
+	}
+
+	ret := &baseline.Baseline{}
+	if err := json.NewDecoder(resp.Body).Decode(ret); err != nil {
+		return nil, fmt.Errorf("Failed to decode baseline: %s", err)
+	}
+	if ret.FormatVersion != baseline.FORMAT_VERSION {
+		return nil, fmt.Errorf("Unsupported baseline format version %d. Expected %d.", ret.FormatVersion, baseline.FORMAT_VERSION)
+	}
+
+	if *baselineFile != "" {
+		b, err := json.Marshal(&cachedBaseline{ETag: resp.Header.Get("ETag"), Baseline: ret})
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(*baselineFile, b, 0644); err != nil {
+			return nil, fmt.Errorf("Failed to write baseline file %s: %s", *baselineFile, err)
+		}
+	}
+	return ret, nil
+}
+
+// diffImages diffs the two images given as arguments.
+func diffImages() error {
+	if flag.NArg() != 2 {
+		return fmt.Errorf("Expected exactly two images or digests, got %d.", flag.NArg())
+	}
+
+	var source imagesource.ImageSource = nil
+	imgs := make([]image.Image, 2)
+	for i, arg := range flag.Args() {
+		if fileutil.FileExists(arg) {
+			img, err := diff.OpenImage(arg)
+			if err != nil {
+				return err
+			}
+			imgs[i] = img
+			continue
+		}
+
+		if source == nil {
+			client, err := goldClient(*useAuth)
+			if err != nil {
+				return err
+			}
+			source = imagesource.NewHTTPImageSource(client, *goldURL+IMAGES_URL_PATH)
+		}
+		b, err := source.Get(arg)
+		if err != nil {
+			return err
+		}
+		img, err := png.Decode(bytes.NewBuffer(b))
+		if err != nil {
+			return fmt.Errorf("Failed to decode image of digest %s: %s", arg, err)
+		}
+		imgs[i] = img
+	}
+
+	metrics, d := diff.Diff(imgs[0], imgs[1])
+	fmt.Printf("Dimensions are different: %v\n", metrics.DimDiffer)
+	fmt.Printf("Number of pixels different: %v\n", metrics.NumDiffPixels)
+	fmt.Printf("Pixel diff percent: %v\n", metrics.PixelDiffPercent)
+	fmt.Printf("Max RGBA: %v\n", metrics.MaxRGBADiffs)
+	fmt.Printf("Number of perceptually different pixels: %v\n", diff.PerceptualDiffPixels(imgs[0], imgs[1]))
+	if *out == "" {
+		return nil
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer util.Close(f)
+	return png.Encode(f, d)
+}
+
+// upload copies the results to dest and their images to imageDest.
+func upload() error {
+	if (*dest == "") || (*imageDest == "") || (*imagesDir == "") {
+		return fmt.Errorf("--dest, --image_dest and --images_dir are required.")
+	}
+	dmResults, err := readResults(*results)
+	if err != nil {
+		return err
+	}
+
+	var storageClient *storage.Client = nil
+	if strings.HasPrefix(*dest, GS_PREFIX) || strings.HasPrefix(*imageDest, GS_PREFIX) {
+		client, err := authClient(*storageCache, storage.ScopeReadWrite)
+		if err != nil {
+			return err
+		}
+		if storageClient, err = storage.NewClient(context.Background(), option.WithHTTPClient(client)); err != nil {
+			return fmt.Errorf("Failed to create Google Storage client: %s", err)
+		}
+	}
+
+	// Upload the images first, so they are available once the results are
+	// ingested.
+	uploaded := util.StringSet{}
+	for _, result := range dmResults.Results {
+		if (result.Options["ext"] != imagesource.IMG_EXTENSION) || uploaded[result.Digest] {
+			continue
+		}
+		b, err := findImage(result)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(storageClient, *imageDest, imagesource.ImageFileName(result.Digest), b); err != nil {
+			return err
+		}
+		uploaded[result.Digest] = true
+	}
+
+	b, err := ioutil.ReadFile(*results)
+	if err != nil {
+		return err
+	}
+	// Ingestion polls the results in directories of the form YYYY/MM/DD/HH.
+	now := time.Now().UTC()
+	name := filepath.Join(now.Format("2006/01/02/15"), fmt.Sprintf("goldctl-%d.json", now.UnixNano()))
+	if err := writeFile(storageClient, *dest, name, b); err != nil {
+		return err
+	}
+	fmt.Printf("Uploaded %d images and %s/%s\n", len(uploaded), *dest, name)
+	return nil
+}
+
+// findImage returns the content of the image of the given result. The image
+// is looked up by digest and by test name in imagesDir.
+func findImage(result *goldingestion.Result) ([]byte, error) {
+	candidates := []string{
+		filepath.Join(*imagesDir, imagesource.ImageFileName(result.Digest)),
+		filepath.Join(*imagesDir, result.Key[types.PRIMARY_KEY_FIELD]+"."+imagesource.IMG_EXTENSION),
+	}
+	for _, path := range candidates {
+		if !fileutil.FileExists(path) {
+			continue
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if digest := fmt.Sprintf("%x", md5.Sum(b)); digest != result.Digest {
+			return nil, fmt.Errorf("Digest of %s is %s, but the results contain %s.", path, digest, result.Digest)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("Unable to find the image of digest %s in %s.", result.Digest, *imagesDir)
+}
+
+// writeFile writes the content to the file with the given name under the
+// given destination, which is either a local directory or a location in
+// Google Storage. Existing images in Google Storage are not overwritten.
+func writeFile(storageClient *storage.Client, destination, name string, content []byte) error {
+	if !strings.HasPrefix(destination, GS_PREFIX) {
+		path := filepath.Join(destination, name)
+		if _, err := fileutil.EnsureDirExists(filepath.Dir(path)); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, content, 0644)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(destination, GS_PREFIX), "/", 2)
+	objPath := name
+	if (len(parts) == 2) && (parts[1] != "") {
+		objPath = strings.TrimSuffix(parts[1], "/") + "/" + name
+	}
+	obj := storageClient.Bucket(parts[0]).Object(objPath)
+	ctx := context.Background()
+	if _, err := obj.Attrs(ctx); err == nil {
+		return nil
+	}
+	w := obj.NewWriter(ctx)
+	if _, err := w.Write(content); err != nil {
+		util.Close(w)
+		return fmt.Errorf("Failed to write %s to %s: %s", name, destination, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Failed to write %s to %s: %s", name, destination, err)
+	}
+	return nil
+}
+
+// TriageRequest is the JSON body of a request to the /json/triage endpoint.
+type TriageRequest struct {
+	Test   string   `json:"test"`
+	Digest []string `json:"digest"`
+	Status string   `json:"status"`
+	Reason string   `json:"reason"`
+}
+
+// triage assigns status to the given digests of the given test.
+func triage() error {
+	if (*test == "") || (*digests == "") {
+		return fmt.Errorf("--test and --digest are required.")
+	}
+	if (*status != types.POSITIVE.String()) && (*status != types.NEGATIVE.String()) && (*status != types.UNTRIAGED.String()) {
+		return fmt.Errorf("Invalid status: %q", *status)
+	}
+
+	body, err := json.Marshal(&TriageRequest{
+		Test:   *test,
+		Digest: strings.Split(*digests, ","),
+		Status: *status,
+		Reason: *reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	client, err := goldClient(true)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(*goldURL+"/json/triage", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("Failed to triage: %s", err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Failed to triage. Got status %s: %s", resp.Status, string(msg))
+	}
+	fmt.Printf("Triaged %d digests of %s as %s.\n", len(strings.Split(*digests, ",")), *test, *status)
+	return nil
+}
+
+// goldClient returns the client used for requests to the Gold instance. If
+// authenticated is true the client adds the OAuth 2.0 access token of the
+// user to the requests, which Gold accepts in place of a login.
+func goldClient(authenticated bool) (*http.Client, error) {
+	if !authenticated {
+		return httputils.NewTimeoutClient(), nil
+	}
+	return authClient(*oauthCacheFile, login.DEFAULT_SCOPE...)
+}
+
+// authClient returns a client that is authenticated as the user via the 3-legged
+// OAuth 2.0 flow for the given scopes. The token is cached in cacheFile, which
+// must only be used with the same scopes.
+func authClient(cacheFile string, scopes ...string) (*http.Client, error) {
+	client, err := auth.NewClient(true, cacheFile, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to authenticate: %s", err)
+	}
+	return client, nil
+}
+
+// readResults reads the results in the DM format from the given file.
+func readResults(path string) (*goldingestion.DMResults, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open results %s: %s", path, err)
+	}
+	defer util.Close(f)
+	return goldingestion.ParseDMResultsFromReader(f)
+}
+
+// parseKeys parses a comma separated list of key=value pairs.
+func parseKeys(keyStr string) (map[string]string, error) {
+	ret := map[string]string{}
+	if keyStr == "" {
+		return ret, nil
+	}
+	for _, pair := range strings.Split(keyStr, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if (len(parts) != 2) || (parts[0] == "") {
+			return nil, fmt.Errorf("Invalid key=value pair: %q", pair)
+		}
+		ret[parts[0]] = parts[1]
+	}
+	return ret, nil
+}
+
+// fileDigest returns the MD5 hash of the content of the given file.
+func fileDigest(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", md5.Sum(b)), nil
+}
+
+// toLookup converts a map of test names to digests into a lookup table.
+func toLookup(testDigests map[string][]string) map[string]map[string]bool {
+	ret := make(map[string]map[string]bool, len(testDigests))
+	for testName, digests := range testDigests {
+		ret[testName] = make(map[string]bool, len(digests))
+		for _, digest := range digests {
+			ret[testName][digest] = true
+		}
+	}
+	return ret
+}
@@ -121,8 +121,6 @@ type DMResults struct {
 	PatchStorage string            `json:"patch_storage"`
 }
 
-// TODO(stephana): Remove isGerritIssue once we switch to Gerrit.
-
 // isGerritIssue returns true if the issue comes from an instance of the Gerrit
 // code review system.
 func (d *DMResults) isGerritIssue() bool {
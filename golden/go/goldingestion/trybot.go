@@ -9,6 +9,8 @@ import (
 
 	"github.com/skia-dev/glog"
 
+	"go.skia.org/infra/go/codereview"
+	"go.skia.org/infra/go/gerrit"
 	"go.skia.org/infra/go/ingestion"
 	"go.skia.org/infra/go/rietveld"
 	"go.skia.org/infra/go/sharedconfig"
@@ -19,6 +21,7 @@ import (
 
 const (
 	CONFIG_CODE_REVIEW_URL   = "CodeReviewURL"
+	CONFIG_GERRIT_URL        = "GerritURL"
 	TIMESTAMP_LRU_CACHE_SIZE = 1000
 )
 
@@ -28,9 +31,10 @@ func init() {
 
 type goldTrybotProcessor struct {
 	*goldProcessor
-	review *rietveld.Rietveld
+	review       codereview.CodeReview
+	gerritReview codereview.CodeReview
 
-	//    map[issue:patchset] -> timestamp.
+	//    map[reviewURL:issue:patchset] -> timestamp.
 	cache map[string]time.Time
 }
 
@@ -48,9 +52,14 @@ func newGoldTrybotProcessor(vcs vcsinfo.VCS, config *sharedconfig.IngesterConfig
 	// Get the underlying goldProcessor.
 	gProcessor := processor.(*goldProcessor)
 	gProcessor.ingestionStore = ingestionStore
+	gerritURL := config.ExtraParams[CONFIG_GERRIT_URL]
+	if gerritURL == "" {
+		gerritURL = gerrit.GERRIT_SKIA_URL
+	}
 	ret := &goldTrybotProcessor{
 		goldProcessor: gProcessor,
-		review:        rietveld.New(config.ExtraParams[CONFIG_CODE_REVIEW_URL], nil),
+		review:        codereview.NewRietveld(rietveld.New(config.ExtraParams[CONFIG_CODE_REVIEW_URL], nil)),
+		gerritReview:  codereview.NewGerrit(gerrit.New(gerritURL, nil)),
 		cache:         map[string]time.Time{},
 	}
 
@@ -61,17 +70,16 @@ func newGoldTrybotProcessor(vcs vcsinfo.VCS, config *sharedconfig.IngesterConfig
 
 // getCommitID overrides the function with the same name in goldProcessor.
 func (g *goldTrybotProcessor) getCommitID(commit *vcsinfo.LongCommit, dmResults *DMResults) (*tracedb.CommitID, error) {
-	// Ignore results from Gerrit for now.
+	review := g.review
 	if dmResults.isGerritIssue() {
-		glog.Infof("Ignoring Gerrit issue  %d/%d for now.", dmResults.Issue, dmResults.Patchset)
-		return nil, ingestion.IgnoreResultsFileErr
+		review = g.gerritReview
 	}
 
 	var ts time.Time
 	var ok bool
-	var cacheId = fmt.Sprintf("%d:%d", dmResults.Issue, dmResults.Patchset)
+	var cacheId = fmt.Sprintf("%s:%d:%d", review.Url(), dmResults.Issue, dmResults.Patchset)
 	if ts, ok = g.cache[cacheId]; !ok {
-		patchinfo, err := g.getPatchset(dmResults.Issue, dmResults.Patchset)
+		patchinfo, err := g.getPatchset(review, dmResults.Issue, dmResults.Patchset)
 		if err != nil {
 			return nil, err
 		}
@@ -84,25 +92,25 @@ func (g *goldTrybotProcessor) getCommitID(commit *vcsinfo.LongCommit, dmResults
 		g.cache[cacheId] = ts
 	}
 
-	source := fmt.Sprintf("%s/%d", g.review.Url(), dmResults.Issue)
 	return &tracedb.CommitID{
 		Timestamp: ts.Unix(),
 		ID:        strconv.FormatInt(dmResults.Patchset, 10),
-		Source:    source,
+		Source:    codereview.IssueSource(review, dmResults.Issue),
 	}, nil
 }
 
-// getPatchset retrieves the patchset. If it does not exist (but the Rietveld issue exists)
-// it will return a ingestion.IgnoreResultsFileErr indicating that this input file should be ignored.
-func (g *goldTrybotProcessor) getPatchset(issueID int64, patchsetID int64) (*rietveld.Patchset, error) {
-	patchinfo, err := g.review.GetPatchset(issueID, patchsetID)
+// getPatchset retrieves the patchset from the given code review system. If it does not exist
+// (but the issue exists) it will return a ingestion.IgnoreResultsFileErr indicating that this
+// input file should be ignored.
+func (g *goldTrybotProcessor) getPatchset(review codereview.CodeReview, issueID int64, patchsetID int64) (*codereview.Patchset, error) {
+	patchinfo, err := review.GetPatchset(issueID, patchsetID)
 	if err == nil {
 		return patchinfo, nil
 	}
 
 	// If we can find the issue, check if the patchset has been removed.
-	var issueInfo *rietveld.Issue
-	if issueInfo, err = g.review.GetIssueProperties(issueID, false); err == nil {
+	var issueInfo *codereview.Issue
+	if issueInfo, err = review.GetIssue(issueID); err == nil {
 		found := false
 		for _, pset := range issueInfo.Patchsets {
 			if pset == patchsetID {
@@ -113,7 +121,7 @@ func (g *goldTrybotProcessor) getPatchset(issueID int64, patchsetID int64) (*rie
 
 		// This patchset is no longer available ignore the result file.
 		if !found {
-			glog.Warningf("Rietveld issue/patchset (%d/%d) does not exist.", issueID, patchsetID)
+			glog.Warningf("Issue/patchset (%d/%d) does not exist in %s.", issueID, patchsetID, review.Url())
 			return nil, ingestion.IgnoreResultsFileErr
 		}
 		// We should not reach this point. Investigate manually if we do.
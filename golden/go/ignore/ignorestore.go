@@ -36,6 +36,15 @@ type IgnoreStore interface {
 	// BuildRuleMatcher returns a RuleMatcher based on the current content
 	// of the ignore store.
 	BuildRuleMatcher() (RuleMatcher, error)
+
+	// Stale returns the ignore rules that do not match any trace in the
+	// current tile. These are candidates for deletion.
+	Stale() ([]*IgnoreRule, error)
+
+	// TraceCounts returns the number of traces in the current tile that are
+	// matched by each ignore rule, keyed by the rule id. The returned map must
+	// not be modified.
+	TraceCounts() (map[int]int, error)
 }
 
 // IgnoreRule is the GUI struct for dealing with Ignore rules.
@@ -48,6 +57,7 @@ type IgnoreRule struct {
 	Note           string    `json:"note"`
 	Count          int       `json:"count"`
 	ExclusiveCount int       `json:"exclusiveCount"`
+	TraceCount     int       `json:"traceCount"` // Number of traces in the current tile matched by the rule.
 }
 
 // ToQuery makes a slice of url.Values from the given slice of IngoreRules.
@@ -119,8 +129,8 @@ func (m *MemIgnoreStore) Update(id int, updated *IgnoreRule) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	for i, _ := range m.rules {
-		if updated.ID == id {
+	for i, rule := range m.rules {
+		if rule.ID == id {
 			m.rules[i] = updated
 			m.inc()
 			return nil
@@ -169,6 +179,18 @@ func (m *MemIgnoreStore) BuildRuleMatcher() (RuleMatcher, error) {
 	return buildRuleMatcher(m)
 }
 
+// Stale, see IgnoreStore interface. MemIgnoreStore does not know about tiles
+// and therefore never considers a rule stale.
+func (m *MemIgnoreStore) Stale() ([]*IgnoreRule, error) {
+	return []*IgnoreRule{}, nil
+}
+
+// TraceCounts, see IgnoreStore interface. MemIgnoreStore does not know about
+// tiles and therefore cannot count traces.
+func (m *MemIgnoreStore) TraceCounts() (map[int]int, error) {
+	return nil, fmt.Errorf("MemIgnoreStore cannot count traces.")
+}
+
 // TODO(stephana): Factor out QueryRule into the shared library and consolidate
 // with the Matches function in the ./go/tiling package.
 
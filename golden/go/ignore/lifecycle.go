@@ -0,0 +1,140 @@
+package ignore
+
+import (
+	"fmt"
+	"html"
+	"time"
+)
+
+const (
+	// EXTEND_DURATION is how far into the future an ignore rule is extended
+	// via Extend.
+	EXTEND_DURATION = 7 * 24 * time.Hour
+
+	// NOTIFICATION_SENDER is the display name of the sender of the emails
+	// sent by ExpiryNotifier.
+	NOTIFICATION_SENDER = "Gold"
+)
+
+// EmailSender sends an email. It is implemented by email.GMail.
+type EmailSender interface {
+	Send(senderDisplayName string, to []string, subject string, body string) error
+}
+
+// ExpiryNotifier notifies the authors of ignore rules by email before and
+// when their rules expire.
+type ExpiryNotifier struct {
+	sender     EmailSender
+	baseURL    string
+	warnBefore time.Duration
+
+	// lastCheck is the end of the time window covered by the last call
+	// to Notify. It is not persisted, notifications that became due while the
+	// server was not running are not sent.
+	lastCheck time.Time
+}
+
+// NewExpiryNotifier creates a new ExpiryNotifier. baseURL is the URL of the
+// Gold instance used to build links, warnBefore is how long before the
+// expiration of a rule its author is warned.
+func NewExpiryNotifier(sender EmailSender, baseURL string, warnBefore time.Duration) *ExpiryNotifier {
+	return &ExpiryNotifier{
+		sender:     sender,
+		baseURL:    baseURL,
+		warnBefore: warnBefore,
+		lastCheck:  time.Now(),
+	}
+}
+
+// Notify sends the notifications for the given rules that became due since
+// the last call. Notifications that fail are not retried.
+func (e *ExpiryNotifier) Notify(rules []*IgnoreRule) error {
+	now := time.Now()
+	notifications := dueNotifications(rules, e.warnBefore, e.lastCheck, now)
+	e.lastCheck = now
+
+	failed := 0
+	var lastErr error = nil
+	for _, n := range notifications {
+		subject, body := e.message(n)
+		if err := e.sender.Send(NOTIFICATION_SENDER, []string{n.rule.UpdatedBy}, subject, body); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("Failed to send %d of %d notifications. Last error: %s", failed, len(notifications), lastErr)
+	}
+	return nil
+}
+
+// message returns the subject and the HTML body of the email for the given
+// notification.
+func (e *ExpiryNotifier) message(n *expiryNotification) (string, string) {
+	var subject, status string
+	if n.expired {
+		subject = fmt.Sprintf("Gold ignore rule expired: %s", n.rule.Query)
+		status = "has expired"
+	} else {
+		subject = fmt.Sprintf("Gold ignore rule expires soon: %s", n.rule.Query)
+		status = "expires on " + n.rule.Expires.UTC().Format(time.RFC1123)
+	}
+
+	body := fmt.Sprintf(`<p>Your ignore rule <b>%s</b> (%s) %s.</p>
+<p><a href="%s/ignores?extend=%d">Extend the rule by %d days</a> or review all rules at <a href="%s/ignores">%s/ignores</a>.</p>`,
+		html.EscapeString(n.rule.Query), html.EscapeString(n.rule.Note), status,
+		e.baseURL, n.rule.ID, int(EXTEND_DURATION.Hours()/24), e.baseURL, e.baseURL)
+	return subject, body
+}
+
+// expiryNotification is a notification that is due for a rule.
+type expiryNotification struct {
+	rule *IgnoreRule
+
+	// expired is true if the rule expired, false if it is about to expire.
+	expired bool
+}
+
+// dueNotifications returns the notifications that became due in the time
+// window (from, to]. If a warning and the expiration fall into the same window
+// only the expiration is reported.
+func dueNotifications(rules []*IgnoreRule, warnBefore time.Duration, from, to time.Time) []*expiryNotification {
+	inWindow := func(ts time.Time) bool {
+		return ts.After(from) && !ts.After(to)
+	}
+
+	ret := []*expiryNotification{}
+	for _, rule := range rules {
+		if inWindow(rule.Expires) {
+			ret = append(ret, &expiryNotification{rule: rule, expired: true})
+		} else if inWindow(rule.Expires.Add(-warnBefore)) {
+			ret = append(ret, &expiryNotification{rule: rule, expired: false})
+		}
+	}
+	return ret
+}
+
+// Extend moves the expiration of the rule with the given id to
+// EXTEND_DURATION from now, unless it already expires later. Extending a rule
+// repeatedly therefore has the same effect as extending it once.
+func Extend(store IgnoreStore, id int, userId string) (*IgnoreRule, error) {
+	rules, err := store.List(false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if rule.ID == id {
+			updated := *rule
+			updated.UpdatedBy = userId
+			if expires := time.Now().Add(EXTEND_DURATION); expires.After(updated.Expires) {
+				updated.Expires = expires
+			}
+			if err := store.Update(id, &updated); err != nil {
+				return nil, err
+			}
+			return &updated, nil
+		}
+	}
+	return nil, fmt.Errorf("Did not find an IgnoreRule with id: %d", id)
+}
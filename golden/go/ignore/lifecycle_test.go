@@ -0,0 +1,101 @@
+package ignore
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+// mockSender records the sent emails.
+type mockSender struct {
+	to       []string
+	subjects []string
+	bodies   []string
+	err      error
+}
+
+func (m *mockSender) Send(senderDisplayName string, to []string, subject string, body string) error {
+	m.to = append(m.to, to...)
+	m.subjects = append(m.subjects, subject)
+	m.bodies = append(m.bodies, body)
+	return m.err
+}
+
+func TestDueNotifications(t *testing.T) {
+	now := time.Now()
+	from := now.Add(-time.Minute)
+	warnBefore := 24 * time.Hour
+
+	rules := []*IgnoreRule{
+		NewIgnoreRule("a@example.com", now.Add(-30*time.Second), "config=gpu", "expired"),
+		NewIgnoreRule("b@example.com", now.Add(warnBefore-30*time.Second), "config=8888", "warning"),
+		NewIgnoreRule("c@example.com", now.Add(-2*time.Minute), "config=565", "expired before"),
+		NewIgnoreRule("d@example.com", now.Add(warnBefore+time.Minute), "config=pdf", "warning later"),
+	}
+
+	notifications := dueNotifications(rules, warnBefore, from, now)
+	assert.Equal(t, 2, len(notifications))
+	assert.Equal(t, rules[0], notifications[0].rule)
+	assert.True(t, notifications[0].expired)
+	assert.Equal(t, rules[1], notifications[1].rule)
+	assert.False(t, notifications[1].expired)
+
+	// If the warning and the expiration fall into the same window only the
+	// expiration is reported.
+	notifications = dueNotifications(rules[:1], 10*time.Second, from, now)
+	assert.Equal(t, 1, len(notifications))
+	assert.True(t, notifications[0].expired)
+}
+
+func TestExpiryNotifier(t *testing.T) {
+	sender := &mockSender{}
+	notifier := NewExpiryNotifier(sender, "https://gold.example.com", time.Hour)
+	notifier.lastCheck = time.Now().Add(-time.Minute)
+
+	rule := NewIgnoreRule("a@example.com", time.Now().Add(-time.Second), "config=<gpu>", "note")
+	rule.ID = 5
+	assert.NoError(t, notifier.Notify([]*IgnoreRule{rule}))
+	assert.Equal(t, []string{"a@example.com"}, sender.to)
+	assert.Equal(t, "Gold ignore rule expired: config=<gpu>", sender.subjects[0])
+	assert.True(t, strings.Contains(sender.bodies[0], "https://gold.example.com/ignores?extend=5"))
+	assert.True(t, strings.Contains(sender.bodies[0], "config=&lt;gpu&gt;"))
+
+	// The same notification is not sent again.
+	assert.NoError(t, notifier.Notify([]*IgnoreRule{rule}))
+	assert.Equal(t, 1, len(sender.to))
+
+	// Errors are reported.
+	sender.err = fmt.Errorf("Not sent.")
+	notifier.lastCheck = time.Now().Add(-time.Minute)
+	assert.Error(t, notifier.Notify([]*IgnoreRule{rule}))
+}
+
+func TestExtend(t *testing.T) {
+	store := NewMemIgnoreStore()
+	r1 := NewIgnoreRule("jon@example.com", time.Now().Add(time.Hour), "config=gpu", "reason")
+	r2 := NewIgnoreRule("jon@example.com", time.Now().Add(2*EXTEND_DURATION), "config=8888", "reason")
+	assert.NoError(t, store.Create(r1))
+	assert.NoError(t, store.Create(r2))
+
+	updated, err := Extend(store, r1.ID, "jim@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "jim@example.com", updated.UpdatedBy)
+	assert.True(t, updated.Expires.After(time.Now().Add(EXTEND_DURATION-time.Minute)))
+
+	// A rule that expires after the extension is not changed.
+	updated, err = Extend(store, r2.ID, "jim@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, r2.Expires, updated.Expires)
+
+	rules, err := store.List(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(rules))
+	assert.Equal(t, "config=gpu", rules[0].Query)
+	assert.True(t, rules[0].Expires.After(time.Now().Add(time.Hour)))
+
+	_, err = Extend(store, 1000, "jim@example.com")
+	assert.Error(t, err)
+}
@@ -2,6 +2,7 @@ package ignore
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"go.skia.org/infra/go/metrics2"
@@ -9,8 +10,20 @@ import (
 	"github.com/skia-dev/glog"
 )
 
-func oneStep(store IgnoreStore, metric *metrics2.Int64Metric) error {
-	list, err := store.List(false)
+// monitor pushes metrics about the ignore rules and notifies their authors
+// about expiring rules.
+type monitor struct {
+	store    IgnoreStore
+	notifier *ExpiryNotifier
+
+	numExpired *metrics2.Int64Metric
+
+	// tracesPerRule contains the number of ignored traces per rule id.
+	tracesPerRule map[int]*metrics2.Int64Metric
+}
+
+func (m *monitor) oneStep() error {
+	list, err := m.store.List(false)
 	if err != nil {
 		return err
 	}
@@ -20,24 +33,68 @@ func oneStep(store IgnoreStore, metric *metrics2.Int64Metric) error {
 			n += 1
 		}
 	}
-	metric.Update(int64(n))
+	m.numExpired.Update(int64(n))
+
+	// Update the number of traces per rule and remove the metrics of rules
+	// that have been deleted. The store only counts the traces again when
+	// the tile or the rules have changed.
+	if traceCounts, err := m.store.TraceCounts(); err != nil {
+		glog.Errorf("Unable to count ignored traces: %s", err)
+	} else {
+		m.updateTraceCounts(list, traceCounts)
+	}
+
+	// Failed notifications must not stop the monitoring.
+	if m.notifier != nil {
+		if err := m.notifier.Notify(list); err != nil {
+			glog.Errorf("Failed to send ignore rule notifications: %s", err)
+		}
+	}
 	return nil
 }
 
+// updateTraceCounts updates the metrics with the number of traces ignored by
+// each of the given rules.
+func (m *monitor) updateTraceCounts(list []*IgnoreRule, traceCounts map[int]int) {
+	current := make(map[int]*metrics2.Int64Metric, len(list))
+	for _, rule := range list {
+		metric, ok := m.tracesPerRule[rule.ID]
+		if !ok {
+			metric = metrics2.GetInt64Metric("gold.ignored-traces", map[string]string{"rule": strconv.Itoa(rule.ID)})
+		}
+		metric.Update(int64(traceCounts[rule.ID]))
+		current[rule.ID] = metric
+	}
+	for id, metric := range m.tracesPerRule {
+		if _, ok := current[id]; !ok {
+			if err := metric.Delete(); err != nil {
+				glog.Errorf("Unable to delete metric for ignore rule %d: %s", id, err)
+			}
+		}
+	}
+	m.tracesPerRule = current
+}
+
 // StartMonitoring starts a new monitoring routine for the given
-// ignore store that counts expired ignore rules and pushes
-// that info into a metric.
-func Init(store IgnoreStore) error {
-	numExpired := metrics2.GetInt64Metric("gold.num-expired-ignore-rules", nil)
+// ignore store that counts expired ignore rules and the traces ignored by
+// each rule and pushes that info into metrics. If notifier is not nil it is
+// used to notify the authors of expiring rules.
+func Init(store IgnoreStore, notifier *ExpiryNotifier) error {
+	m := &monitor{
+		store:         store,
+		notifier:      notifier,
+		numExpired:    metrics2.GetInt64Metric("gold.num-expired-ignore-rules", nil),
+		tracesPerRule: map[int]*metrics2.Int64Metric{},
+	}
 	liveness := metrics2.NewLiveness("gold.expired-ignore-rules-monitoring")
 
-	err := oneStep(store, numExpired)
+	err := m.oneStep()
 	if err != nil {
 		return fmt.Errorf("Unable to start monitoring ignore rules: %s", err)
 	}
 	go func() {
 		for _ = range time.Tick(time.Minute) {
-			err = oneStep(store, numExpired)
+			err = m.oneStep()
 			if err != nil {
 				glog.Errorf("Failed one step of monitoring ignore rules: %s", err)
 				continue
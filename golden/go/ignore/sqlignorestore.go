@@ -21,6 +21,14 @@ type SQLIgnoreStore struct {
 	tileStream   <-chan *types.TilePair
 	lastTilePair *types.TilePair
 	expStore     expstorage.ExpectationsStore
+
+	// countsMutex protects traceCounts, which caches the result of
+	// TraceCounts for the tile countsTile and the ignore rules at
+	// countsRevision.
+	countsMutex    sync.Mutex
+	traceCounts    map[int]int
+	countsTile     *types.TilePair
+	countsRevision int64
 }
 
 // NewSQLIgnoreStore creates a new SQL based IgnoreStore.
@@ -129,25 +137,22 @@ func (m *SQLIgnoreStore) addIgnoreCounts(rules []*IgnoreRule) error {
 		return err
 	}
 
-	// Get the next tile.
-	var tilePair *types.TilePair = nil
-	select {
-	case tilePair = <-m.tileStream:
-	default:
-		tilePair = m.lastTilePair
-	}
-	if tilePair == nil {
-		return fmt.Errorf("No tile available to count ignores")
+	tilePair, err := m.getTilePair()
+	if err != nil {
+		return err
 	}
-	m.lastTilePair = tilePair
 
-	// Count the untriaged digests in HEAD.
+	// Count the untriaged digests in HEAD and the matching traces.
 	// matchingDigests[rule.ID]map[digest]bool
 	matchingDigests := make(map[int]map[string]bool, len(rules))
 	rulesByDigest := map[string]map[int]bool{}
+	traceCounts := make(map[int]int, len(rules))
 	for _, trace := range tilePair.TileWithIgnores.Traces {
 		gTrace := trace.(*types.GoldenTrace)
 		if matchRules, ok := ignoreMatcher(gTrace.Params_); ok {
+			for _, r := range matchRules {
+				traceCounts[r.ID]++
+			}
 			testName := gTrace.Params_[types.PRIMARY_KEY_FIELD]
 			if digest := gTrace.LastDigest(); digest != types.MISSING_DIGEST && (exp.Classification(testName, digest) == types.UNTRIAGED) {
 				k := testName + ":" + digest
@@ -171,6 +176,7 @@ func (m *SQLIgnoreStore) addIgnoreCounts(rules []*IgnoreRule) error {
 	}
 
 	for _, r := range rules {
+		r.TraceCount = traceCounts[r.ID]
 		r.Count = len(matchingDigests[r.ID])
 		r.ExclusiveCount = 0
 		for testDigestKey := range matchingDigests[r.ID] {
@@ -183,6 +189,81 @@ func (m *SQLIgnoreStore) addIgnoreCounts(rules []*IgnoreRule) error {
 	return nil
 }
 
+// getTilePair returns the most recent tile from the tile stream.
+func (m *SQLIgnoreStore) getTilePair() (*types.TilePair, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	select {
+	case tilePair := <-m.tileStream:
+		m.lastTilePair = tilePair
+	default:
+	}
+	if m.lastTilePair == nil {
+		return nil, fmt.Errorf("No tile available to count ignores")
+	}
+	return m.lastTilePair, nil
+}
+
+// TraceCounts, see IgnoreStore interface. The counts are only recomputed
+// when a new tile arrives or the ignore rules change.
+func (m *SQLIgnoreStore) TraceCounts() (map[int]int, error) {
+	if m.tileStream == nil {
+		return nil, fmt.Errorf("tileStream is nil. Cannot count ignored traces.")
+	}
+	tilePair, err := m.getTilePair()
+	if err != nil {
+		return nil, err
+	}
+	revision := m.Revision()
+
+	m.countsMutex.Lock()
+	defer m.countsMutex.Unlock()
+	if m.traceCounts != nil && m.countsTile == tilePair && m.countsRevision == revision {
+		return m.traceCounts, nil
+	}
+
+	ignoreMatcher, err := m.BuildRuleMatcher()
+	if err != nil {
+		return nil, err
+	}
+	traceCounts := map[int]int{}
+	for _, trace := range tilePair.TileWithIgnores.Traces {
+		if matchRules, ok := ignoreMatcher(trace.Params()); ok {
+			for _, r := range matchRules {
+				traceCounts[r.ID]++
+			}
+		}
+	}
+	m.traceCounts = traceCounts
+	m.countsTile = tilePair
+	m.countsRevision = revision
+	return traceCounts, nil
+}
+
+// Stale, see IgnoreStore interface.
+func (m *SQLIgnoreStore) Stale() ([]*IgnoreRule, error) {
+	rules, err := m.List(false)
+	if err != nil {
+		return nil, err
+	}
+
+	// In contrast to List we fail if the counts are not available, otherwise
+	// every rule would appear stale.
+	traceCounts, err := m.TraceCounts()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to count ignores: %s", err)
+	}
+
+	ret := []*IgnoreRule{}
+	for _, rule := range rules {
+		if traceCounts[rule.ID] == 0 {
+			ret = append(ret, rule)
+		}
+	}
+	return ret, nil
+}
+
 // Delete, see IgnoreStore interface.
 func (m *SQLIgnoreStore) Delete(id int, userId string) (int, error) {
 	stmt := "DELETE FROM ignorerule WHERE id=?"
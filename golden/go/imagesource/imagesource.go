@@ -0,0 +1,216 @@
+// imagesource contains the sources the diffstores load the images of digests
+// from. Images are stored as PNG files named <digest>.png. Besides Google
+// Storage, images can be served from a local directory or any HTTP server,
+// which allows to run Gold without access to Google Storage, e.g. for local
+// development and integration tests.
+package imagesource
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/util"
+	"golang.org/x/net/context"
+	"google.golang.org/api/option"
+)
+
+const (
+	// IMG_EXTENSION is the extension of the image files.
+	IMG_EXTENSION = "png"
+
+	// MAX_URI_GET_TRIES is the number of tries we do to load an image.
+	MAX_URI_GET_TRIES = 4
+)
+
+// ImageSource is the interface to a store of images.
+type ImageSource interface {
+	// Get returns the PNG encoded image of the given digest.
+	Get(digest string) ([]byte, error)
+
+	// Delete removes the image of the given digest.
+	Delete(digest string) error
+}
+
+// ImageFileName returns the name of the image file of the given digest.
+func ImageFileName(digest string) string {
+	return fmt.Sprintf("%s.%s", digest, IMG_EXTENSION)
+}
+
+// gsImageSource retrieves images from a Google Storage bucket.
+type gsImageSource struct {
+	storageClient *storage.Client
+	bucketName    string
+	imageDir      string
+}
+
+// NewGSImageSource returns an ImageSource that retrieves images from the
+// imageDir directory of the given Google Storage bucket. If client is nil a
+// default client is used.
+func NewGSImageSource(client *http.Client, bucketName, imageDir string) (ImageSource, error) {
+	if client == nil {
+		client = httputils.NewTimeoutClient()
+	}
+	storageClient, err := storage.NewClient(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create Google Storage client: %s", err)
+	}
+	return &gsImageSource{
+		storageClient: storageClient,
+		bucketName:    bucketName,
+		imageDir:      imageDir,
+	}, nil
+}
+
+// See ImageSource interface.
+func (g *gsImageSource) Get(digest string) ([]byte, error) {
+	objLocation := filepath.Join(g.imageDir, ImageFileName(digest))
+	ctx := context.Background()
+
+	// Retrieve the attributes.
+	attrs, err := g.storageClient.Bucket(g.bucketName).Object(objLocation).Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve attributes for %s/%s: %s", g.bucketName, objLocation, err)
+	}
+
+	var buf *bytes.Buffer
+	for i := 0; i < MAX_URI_GET_TRIES; i++ {
+		err = func() error {
+			reader, err := g.storageClient.Bucket(g.bucketName).Object(objLocation).NewReader(ctx)
+			if err != nil {
+				return fmt.Errorf("New reader failed for %s/%s: %s", g.bucketName, objLocation, err)
+			}
+			defer util.Close(reader)
+
+			size := reader.Size()
+			buf = bytes.NewBuffer(make([]byte, 0, size))
+			md5Hash := md5.New()
+			multiOut := io.MultiWriter(md5Hash, buf)
+
+			if _, err = io.Copy(multiOut, reader); err != nil {
+				return err
+			}
+
+			// Check the MD5.
+			if !bytes.Equal(md5Hash.Sum(nil), attrs.MD5) {
+				return fmt.Errorf("MD5 hash for digest %s incorrect.", digest)
+			}
+
+			return nil
+		}()
+
+		if err == nil {
+			break
+		}
+		glog.Errorf("Error fetching file for digest %s: %s", digest, err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed fetching file after %d attempts: %s", MAX_URI_GET_TRIES, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// See ImageSource interface.
+func (g *gsImageSource) Delete(digest string) error {
+	objLocation := filepath.Join(g.imageDir, ImageFileName(digest))
+	if err := g.storageClient.Bucket(g.bucketName).Object(objLocation).Delete(context.Background()); err != nil {
+		return fmt.Errorf("Unable to delete %s/%s: %s", g.bucketName, objLocation, err)
+	}
+	return nil
+}
+
+// dirImageSource retrieves images from a local directory.
+type dirImageSource struct {
+	dir string
+}
+
+// NewDirImageSource returns an ImageSource that reads the images from the
+// given directory.
+func NewDirImageSource(dir string) ImageSource {
+	return &dirImageSource{dir: dir}
+}
+
+// See ImageSource interface.
+func (d *dirImageSource) Get(digest string) ([]byte, error) {
+	ret, err := ioutil.ReadFile(filepath.Join(d.dir, ImageFileName(digest)))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read image for digest %s: %s", digest, err)
+	}
+	return ret, nil
+}
+
+// See ImageSource interface.
+func (d *dirImageSource) Delete(digest string) error {
+	if err := os.Remove(filepath.Join(d.dir, ImageFileName(digest))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to delete image for digest %s: %s", digest, err)
+	}
+	return nil
+}
+
+// httpImageSource retrieves images from an HTTP server.
+type httpImageSource struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewHTTPImageSource returns an ImageSource that retrieves images via
+// GET requests to baseURL/<digest>.png. If client is nil a default client is
+// used. Images can't be deleted via HTTP.
+func NewHTTPImageSource(client *http.Client, baseURL string) ImageSource {
+	if client == nil {
+		client = httputils.NewTimeoutClient()
+	}
+	return &httpImageSource{
+		client:  client,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// See ImageSource interface.
+func (h *httpImageSource) Get(digest string) ([]byte, error) {
+	imgURL := h.baseURL + "/" + ImageFileName(digest)
+	var ret []byte
+	var err error
+	for i := 0; i < MAX_URI_GET_TRIES; i++ {
+		var notFound bool
+		ret, notFound, err = h.get(imgURL)
+		if err == nil || notFound {
+			break
+		}
+		glog.Errorf("Error fetching file for digest %s: %s", digest, err)
+	}
+	return ret, err
+}
+
+// get retrieves the given URL. The returned bool is true if the server
+// responded with 404, i.e. there is no need to try again.
+func (h *httpImageSource) get(imgURL string) ([]byte, bool, error) {
+	resp, err := h.client.Get(imgURL)
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to retrieve %s: %s", imgURL, err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode == http.StatusNotFound, fmt.Errorf("Failed to retrieve %s: %s", imgURL, resp.Status)
+	}
+	ret, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to read %s: %s", imgURL, err)
+	}
+	return ret, false, nil
+}
+
+// See ImageSource interface.
+func (h *httpImageSource) Delete(digest string) error {
+	return fmt.Errorf("Deleting images is not supported via HTTP.")
+}
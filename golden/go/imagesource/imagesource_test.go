@@ -0,0 +1,78 @@
+package imagesource
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+
+	"go.skia.org/infra/go/testutils"
+)
+
+const TEST_DIGEST = "aaaabbbbccccdddd"
+
+// writeTestImage writes a PNG for TEST_DIGEST to dir and returns its content.
+func writeTestImage(t *testing.T, dir string) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 5, 5))))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ImageFileName(TEST_DIGEST)), buf.Bytes(), 0644))
+	return buf.Bytes()
+}
+
+func TestDirImageSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagesource")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	expected := writeTestImage(t, dir)
+
+	src := NewDirImageSource(dir)
+	found, err := src.Get(TEST_DIGEST)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, found)
+
+	_, err = src.Get("missing")
+	assert.Error(t, err)
+
+	assert.NoError(t, src.Delete(TEST_DIGEST))
+	_, err = os.Stat(filepath.Join(dir, ImageFileName(TEST_DIGEST)))
+	assert.True(t, os.IsNotExist(err))
+	_, err = src.Get(TEST_DIGEST)
+	assert.Error(t, err)
+
+	// Deleting a missing image is not an error.
+	assert.NoError(t, src.Delete(TEST_DIGEST))
+}
+
+func TestHTTPImageSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagesource")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	expected := writeTestImage(t, dir)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.FileServer(http.Dir(dir)).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	src := NewHTTPImageSource(nil, server.URL+"/")
+	found, err := src.Get(TEST_DIGEST)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, found)
+	assert.Equal(t, 1, requests)
+
+	// Missing images are not retried.
+	_, err = src.Get("missing")
+	assert.Error(t, err)
+	assert.Equal(t, 2, requests)
+
+	assert.Error(t, src.Delete(TEST_DIGEST))
+}
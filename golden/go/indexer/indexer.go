@@ -13,6 +13,7 @@ import (
 	"go.skia.org/infra/go/timer"
 	"go.skia.org/infra/golden/go/blame"
 	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/flaky"
 	"go.skia.org/infra/golden/go/paramsets"
 	"go.skia.org/infra/golden/go/pdag"
 	"go.skia.org/infra/golden/go/storage"
@@ -39,6 +40,7 @@ type SearchIndex struct {
 	paramsetSummary *paramsets.ParamSummary
 	blamer          *blame.Blamer
 	warmer          *warmer.Warmer
+	flakiness       *flaky.Flakiness
 
 	// Used by the pdag pipeline.
 	testNames []string
@@ -55,6 +57,7 @@ func newSearchIndex(storages *storage.Storage, tilePair *types.TilePair) *Search
 		paramsetSummary: paramsets.New(),
 		blamer:          blame.New(storages),
 		warmer:          warmer.New(storages),
+		flakiness:       flaky.New(),
 	}
 }
 
@@ -104,6 +107,16 @@ func (idx *SearchIndex) GetBlame(test, digest string, commits []*tiling.Commit)
 	return idx.blamer.GetBlame(test, digest, commits)
 }
 
+// Proxy to flaky.Flakiness.IsFlaky.
+func (idx *SearchIndex) IsFlakyTrace(traceID string) bool {
+	return idx.flakiness.IsFlaky(traceID)
+}
+
+// GetFlakiness returns the flakiness of the traces in the current tile.
+func (idx *SearchIndex) GetFlakiness() *flaky.Flakiness {
+	return idx.flakiness
+}
+
 // Indexer is the type that drive continously indexing as the underlying
 // data change. It uses a DAG that encodes the dependencies of the
 // different components of an index and creates a processing pipeline on top
@@ -141,8 +154,11 @@ func New(storages *storage.Storage, interval time.Duration) (*Indexer, error) {
 	// The warmer depends on tallies and summaries.
 	pdag.NewNode(runWarmer, summaryNode, tallyNode)
 
+	// Flakiness only depends on the tile.
+	flakyNode := root.Child(calcFlakiness)
+
 	// Set the result on the Indexer instance.
-	pdag.NewNode(ret.setIndex, summaryNode)
+	pdag.NewNode(ret.setIndex, summaryNode, flakyNode)
 
 	ret.pipeline = root
 	ret.blamerNode = blamerNode
@@ -233,6 +249,7 @@ func (ixr *Indexer) indexTests(testNames []string) {
 		paramsetSummary: lastIdx.paramsetSummary,
 		blamer:          blame.New(ixr.storages),
 		warmer:          warmer.New(ixr.storages),
+		flakiness:       lastIdx.flakiness,
 		testNames:       testNames,
 	}
 
@@ -281,6 +298,13 @@ func calcBlame(state interface{}) error {
 	return err
 }
 
+// calcFlakiness is the pipeline function to find the flaky traces.
+func calcFlakiness(state interface{}) error {
+	idx := state.(*SearchIndex)
+	idx.flakiness.Calculate(idx.tilePair)
+	return nil
+}
+
 // runWamer is the pipeline function to run the wamer. It runs it
 // asynchronously since its results are not relevant for the searchIndex.
 func runWarmer(state interface{}) error {
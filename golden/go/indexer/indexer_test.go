@@ -174,7 +174,7 @@ func setupStorages(t assert.TestingT) (*storage.Storage, expstorage.Expectations
 		NCommits:          N_COMMITS,
 		EventBus:          evt,
 		TrybotResults:     nil,
-		RietveldAPI:       nil,
+		CodeReview:        nil,
 	}
 
 	ret.IgnoreStore = ignore.NewSQLIgnoreStore(vdb, ret.ExpectationsStore, ret.GetTileStreamNow(time.Minute))
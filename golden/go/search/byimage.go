@@ -0,0 +1,208 @@
+package search
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/diffstore"
+	"go.skia.org/infra/golden/go/indexer"
+	"go.skia.org/infra/golden/go/storage"
+	"go.skia.org/infra/golden/go/types"
+)
+
+const (
+	// MAX_IMAGE_SEARCH_CANDIDATES is the maximum number of digests an image
+	// is compared against. Larger searches need to be restricted by a query.
+	MAX_IMAGE_SEARCH_CANDIDATES = 5000
+
+	// DEFAULT_IMAGE_SEARCH_LIMIT is the default number of matches returned by
+	// SearchByImage.
+	DEFAULT_IMAGE_SEARCH_LIMIT = 10
+
+	// MAX_IMAGE_SEARCH_PIXELS is the maximum number of pixels of a searched
+	// image.
+	MAX_IMAGE_SEARCH_PIXELS = 4096 * 4096
+)
+
+// ImageQuery restricts the digests an image is compared against in
+// SearchByImage.
+type ImageQuery struct {
+	// Query selects the traces whose digests are compared against the image.
+	Query          url.Values
+	IncludeIgnores bool
+
+	// Metric is the diff metric used to rank the matches, see
+	// diffstore.METRIC_*. Defaults to diffstore.METRIC_COMBINED.
+	Metric string
+
+	// Limit is the maximum number of matches returned. Defaults to
+	// DEFAULT_IMAGE_SEARCH_LIMIT.
+	Limit int
+}
+
+// ImageTrace is a trace that produced the searched image.
+type ImageTrace struct {
+	Test    string            `json:"test"`
+	TraceID string            `json:"traceId"`
+	Params  map[string]string `json:"params"`
+}
+
+// ImageTraceSlice is a utility type to sort ImageTrace instances by test and
+// trace id.
+type ImageTraceSlice []*ImageTrace
+
+func (p ImageTraceSlice) Len() int { return len(p) }
+func (p ImageTraceSlice) Less(i, j int) bool {
+	if p[i].Test == p[j].Test {
+		return p[i].TraceID < p[j].TraceID
+	}
+	return p[i].Test < p[j].Test
+}
+func (p ImageTraceSlice) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// ImageMatch is a known digest that resembles the searched image.
+type ImageMatch struct {
+	Test     string                `json:"test"`
+	Digest   string                `json:"digest"`
+	Status   string                `json:"status"`
+	Diff     float32               `json:"diff"` // Value of the query metric.
+	Diffs    *diffstore.DiffRecord `json:"diffs"`
+	ParamSet map[string][]string   `json:"paramset"`
+}
+
+// ImageMatchSlice is a utility type to sort ImageMatch instances with the
+// closest match first.
+type ImageMatchSlice []*ImageMatch
+
+func (p ImageMatchSlice) Len() int { return len(p) }
+func (p ImageMatchSlice) Less(i, j int) bool {
+	if p[i].Diff == p[j].Diff {
+		if p[i].Digest == p[j].Digest {
+			return p[i].Test < p[j].Test
+		}
+		return p[i].Digest < p[j].Digest
+	}
+	return p[i].Diff < p[j].Diff
+}
+func (p ImageMatchSlice) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// ImageSearchResponse is the result of SearchByImage.
+type ImageSearchResponse struct {
+	// Digest is the digest of the searched image.
+	Digest string `json:"digest"`
+
+	// Known is true if the digest was produced by a trace in the current tile.
+	// In that case Traces contains these traces and Matches is empty.
+	Known  bool          `json:"known"`
+	Traces []*ImageTrace `json:"traces"`
+
+	// Matches contains the closest digests if the digest is not known.
+	Matches []*ImageMatch `json:"matches"`
+}
+
+// SearchByImage finds the traces that produced the given PNG encoded image.
+// If the image is not known it is compared to the digests of the traces that
+// match the query and the closest digests are returned. The image is only kept
+// in memory for the duration of the search.
+func SearchByImage(imgBytes []byte, q *ImageQuery, diffStore *diffstore.MemDiffStore, storages *storage.Storage, idx *indexer.SearchIndex) (*ImageSearchResponse, error) {
+	metric := q.Metric
+	if metric == "" {
+		metric = diffstore.METRIC_COMBINED
+	}
+	if !diffStore.HasMetric(metric) {
+		return nil, fmt.Errorf("Unknown or disabled diff metric: %q", metric)
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DEFAULT_IMAGE_SEARCH_LIMIT
+	}
+
+	img, err := diffstore.DecodeImage(imgBytes, MAX_IMAGE_SEARCH_PIXELS)
+	if err != nil {
+		return nil, err
+	}
+	digest := fmt.Sprintf("%x", md5.Sum(imgBytes))
+
+	// Find the traces that produced the digest and collect the candidate
+	// digests with the tests they belong to.
+	traceTally := idx.TalliesByTrace()
+	tile := idx.GetTile(false)
+	traces := []*ImageTrace{}
+	candidates := map[string]util.StringSet{}
+	for id, tr := range idx.GetTile(true).Traces {
+		test := tr.Params()[types.PRIMARY_KEY_FIELD]
+		if _, ok := traceTally[id][digest]; ok {
+			traces = append(traces, &ImageTrace{
+				Test:    test,
+				TraceID: id,
+				Params:  tr.Params(),
+			})
+			continue
+		}
+
+		if _, ok := tile.Traces[id]; !ok && !q.IncludeIgnores {
+			continue
+		}
+		if !tiling.Matches(tr, q.Query) {
+			continue
+		}
+		for d := range traceTally[id] {
+			if _, ok := candidates[d]; !ok {
+				candidates[d] = util.StringSet{}
+			}
+			candidates[d][test] = true
+		}
+	}
+
+	ret := &ImageSearchResponse{
+		Digest:  digest,
+		Traces:  traces,
+		Matches: []*ImageMatch{},
+	}
+	if len(traces) > 0 {
+		ret.Known = true
+		sort.Sort(ImageTraceSlice(traces))
+		return ret, nil
+	}
+
+	if len(candidates) > MAX_IMAGE_SEARCH_CANDIDATES {
+		return nil, fmt.Errorf("Too many candidate digests (%d > %d). Please restrict the query.", len(candidates), MAX_IMAGE_SEARCH_CANDIDATES)
+	}
+
+	candidateDigests := make([]string, 0, len(candidates))
+	for d := range candidates {
+		candidateDigests = append(candidateDigests, d)
+	}
+	diffs := diffStore.DiffImage(diffstore.PRIORITY_NOW, img, candidateDigests)
+
+	exp, err := storages.ExpectationsStore.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	for d, diffRec := range diffs {
+		for test := range candidates[d] {
+			ret.Matches = append(ret.Matches, &ImageMatch{
+				Test:   test,
+				Digest: d,
+				Status: exp.Classification(test, d).String(),
+				Diff:   diffRec.Diffs[metric],
+				Diffs:  diffRec,
+			})
+		}
+	}
+	sort.Sort(ImageMatchSlice(ret.Matches))
+	if len(ret.Matches) > limit {
+		ret.Matches = ret.Matches[:limit]
+	}
+
+	// Only look up the paramsets of the returned matches.
+	for _, match := range ret.Matches {
+		match.ParamSet = idx.GetParamsetSummary(match.Test, match.Digest, q.IncludeIgnores)
+	}
+	return ret, nil
+}
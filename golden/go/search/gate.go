@@ -0,0 +1,183 @@
+package search
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/indexer"
+	"go.skia.org/infra/golden/go/storage"
+	"go.skia.org/infra/golden/go/trybot"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// GateResponse is the answer to the question whether the patchsets of an issue
+// introduce images that need attention before the issue can land.
+type GateResponse struct {
+	Issue     string          `json:"issue"`
+	Patchsets []*PatchsetGate `json:"patchsets"`
+}
+
+// PatchsetGate summarizes the new untriaged and negative digests of a
+// patchset, i.e. digests that have not been seen on master.
+type PatchsetGate struct {
+	Patchset  string `json:"patchset"`
+	Untriaged int    `json:"untriaged"`
+	Negative  int    `json:"negative"`
+
+	// Pass is true if the patchset has tryjobs and produced neither
+	// untriaged nor negative digests.
+	Pass bool `json:"pass"`
+
+	// Complete is true once the results of all tryjobs of the patchset have
+	// been ingested. Until then Pass might change. A patchset without
+	// tryjobs is never complete.
+	Complete bool  `json:"complete"`
+	JobDone  int64 `json:"jobDone"`
+	JobTotal int64 `json:"jobTotal"`
+
+	URL   string      `json:"url"`
+	Tests []*TestGate `json:"tests"`
+}
+
+// TestGate contains the counts of new untriaged and negative digests of one
+// test in one corpus.
+type TestGate struct {
+	Test      string `json:"test"`
+	Corpus    string `json:"corpus"`
+	Untriaged int    `json:"untriaged"`
+	Negative  int    `json:"negative"`
+	URL       string `json:"url"`
+}
+
+// TestGateSlice is a utility type for sorting slices of TestGate by corpus
+// and test name.
+type TestGateSlice []*TestGate
+
+func (p TestGateSlice) Len() int { return len(p) }
+func (p TestGateSlice) Less(i, j int) bool {
+	if p[i].Corpus == p[j].Corpus {
+		return p[i].Test < p[j].Test
+	}
+	return p[i].Corpus < p[j].Corpus
+}
+func (p TestGateSlice) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// Gate returns the new untriaged and negative digests of the given patchsets
+// of an issue, grouped by test and corpus. If no patchsets are given the last
+// patchset of the issue is used. Ignored traces are not counted. The links in
+// the response point to the search page and are prefixed with baseURL.
+func Gate(issueID string, patchsets []string, baseURL string, storages *storage.Storage, idx *indexer.SearchIndex) (*GateResponse, error) {
+	exp, err := storages.ExpectationsStore.Get()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't get expectations: %s", err)
+	}
+
+	issue, tile, err := storages.TrybotResults.GetIssue(issueID, patchsets)
+	if err != nil {
+		return nil, err
+	}
+
+	if issue == nil {
+		return nil, fmt.Errorf("Issue not found.")
+	}
+
+	q := &Query{
+		Unt:   true,
+		Neg:   true,
+		Issue: issueID,
+	}
+	ret := &GateResponse{
+		Issue:     issueID,
+		Patchsets: make([]*PatchsetGate, 0, len(issue.TargetPatchsets)),
+	}
+	for _, pid := range issue.TargetPatchsets {
+		digestMap, err := issueDigests(issue, tile, util.NewStringSet([]string{pid}), q, exp, nil, storages, idx)
+		if err != nil {
+			return nil, err
+		}
+
+		var detail *trybot.PatchsetDetail
+		if intPid, err := strconv.ParseInt(pid, 10, 64); err == nil {
+			detail = issue.PatchsetDetails[intPid]
+		}
+		pGate := newPatchsetGate(baseURL, issueID, pid, detail, digestMap)
+		ret.Patchsets = append(ret.Patchsets, pGate)
+	}
+	return ret, nil
+}
+
+// newPatchsetGate summarizes the given new untriaged and negative digests of
+// a patchset. detail may be nil if the tryjobs of the patchset are unknown.
+func newPatchsetGate(baseURL, issueID, pid string, detail *trybot.PatchsetDetail, digestMap map[string]*Digest) *PatchsetGate {
+	pGate := &PatchsetGate{
+		Patchset: pid,
+		URL:      gateURL(baseURL, issueID, pid, nil),
+	}
+	if detail != nil {
+		pGate.JobDone = detail.JobDone
+		pGate.JobTotal = detail.JobTotal
+	}
+
+	tests := map[string]*TestGate{}
+	for _, d := range digestMap {
+		isNeg := d.Status == types.NEGATIVE.String()
+		if isNeg {
+			pGate.Negative++
+		} else {
+			pGate.Untriaged++
+		}
+
+		for _, corpus := range d.ParamSet[types.CORPUS_FIELD] {
+			key := corpus + ":" + d.Test
+			tGate, ok := tests[key]
+			if !ok {
+				tGate = &TestGate{
+					Test:   d.Test,
+					Corpus: corpus,
+					URL: gateURL(baseURL, issueID, pid, url.Values{
+						types.PRIMARY_KEY_FIELD: []string{d.Test},
+						types.CORPUS_FIELD:      []string{corpus},
+					}),
+				}
+				tests[key] = tGate
+			}
+			if isNeg {
+				tGate.Negative++
+			} else {
+				tGate.Untriaged++
+			}
+		}
+	}
+
+	pGate.Tests = make([]*TestGate, 0, len(tests))
+	for _, tGate := range tests {
+		pGate.Tests = append(pGate.Tests, tGate)
+	}
+	sort.Sort(TestGateSlice(pGate.Tests))
+
+	// A patchset without tryjobs has not produced any images yet, so it can
+	// neither be complete nor pass.
+	hasJobs := pGate.JobTotal > 0
+	pGate.Complete = hasJobs && (pGate.JobDone == pGate.JobTotal)
+	pGate.Pass = hasJobs && (pGate.Untriaged == 0) && (pGate.Negative == 0)
+	return pGate
+}
+
+// gateURL returns the link to the search page that lists the new untriaged
+// and negative digests of a patchset that match the given query.
+func gateURL(baseURL, issueID, patchset string, query url.Values) string {
+	params := url.Values{
+		"issue":     []string{issueID},
+		"patchsets": []string{patchset},
+		"unt":       []string{"true"},
+		"neg":       []string{"true"},
+		"master":    []string{"false"},
+	}
+	if len(query) > 0 {
+		params.Set("query", query.Encode())
+	}
+	return baseURL + "/search?" + params.Encode()
+}
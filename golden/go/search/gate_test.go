@@ -0,0 +1,81 @@
+package search
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+
+	"go.skia.org/infra/golden/go/trybot"
+	"go.skia.org/infra/golden/go/types"
+)
+
+func TestNewPatchsetGate(t *testing.T) {
+	digests := map[string]*Digest{
+		"aaa": &Digest{
+			Test:     "test1",
+			Digest:   "aaa",
+			Status:   types.UNTRIAGED.String(),
+			ParamSet: map[string][]string{types.CORPUS_FIELD: []string{"gm"}},
+		},
+		"bbb": &Digest{
+			Test:     "test1",
+			Digest:   "bbb",
+			Status:   types.NEGATIVE.String(),
+			ParamSet: map[string][]string{types.CORPUS_FIELD: []string{"gm", "image"}},
+		},
+		"ccc": &Digest{
+			Test:     "test0",
+			Digest:   "ccc",
+			Status:   types.UNTRIAGED.String(),
+			ParamSet: map[string][]string{types.CORPUS_FIELD: []string{"gm"}},
+		},
+	}
+
+	detail := &trybot.PatchsetDetail{ID: 2, JobTotal: 3, JobDone: 3}
+	pGate := newPatchsetGate("https://gold.example.com", "1234", "2", detail, digests)
+	assert.Equal(t, "2", pGate.Patchset)
+	assert.Equal(t, 2, pGate.Untriaged)
+	assert.Equal(t, 1, pGate.Negative)
+	assert.True(t, pGate.Complete)
+	assert.False(t, pGate.Pass)
+	assert.Equal(t, int64(3), pGate.JobDone)
+	assert.Equal(t, int64(3), pGate.JobTotal)
+
+	// The tests are sorted by corpus and test name.
+	assert.Equal(t, 3, len(pGate.Tests))
+	assert.Equal(t, "gm", pGate.Tests[0].Corpus)
+	assert.Equal(t, "test0", pGate.Tests[0].Test)
+	assert.Equal(t, 1, pGate.Tests[0].Untriaged)
+	assert.Equal(t, 0, pGate.Tests[0].Negative)
+	assert.Equal(t, "gm", pGate.Tests[1].Corpus)
+	assert.Equal(t, "test1", pGate.Tests[1].Test)
+	assert.Equal(t, 1, pGate.Tests[1].Untriaged)
+	assert.Equal(t, 1, pGate.Tests[1].Negative)
+	assert.Equal(t, "image", pGate.Tests[2].Corpus)
+	assert.Equal(t, "test1", pGate.Tests[2].Test)
+	assert.Equal(t, 0, pGate.Tests[2].Untriaged)
+	assert.Equal(t, 1, pGate.Tests[2].Negative)
+	assert.Equal(t, "https://gold.example.com/search?issue=1234&master=false&neg=true&patchsets=2&query=name%3Dtest1%26source_type%3Dimage&unt=true", pGate.Tests[2].URL)
+
+	// A complete patchset without new digests passes.
+	pGate = newPatchsetGate("https://gold.example.com", "1234", "2", detail, map[string]*Digest{})
+	assert.True(t, pGate.Complete)
+	assert.True(t, pGate.Pass)
+	assert.Equal(t, 0, len(pGate.Tests))
+	assert.Equal(t, "https://gold.example.com/search?issue=1234&master=false&neg=true&patchsets=2&unt=true", pGate.URL)
+
+	// Results might still change while tryjobs are running.
+	pGate = newPatchsetGate("https://gold.example.com", "1234", "2", &trybot.PatchsetDetail{ID: 2, JobTotal: 3, JobDone: 1}, map[string]*Digest{})
+	assert.False(t, pGate.Complete)
+	assert.True(t, pGate.Pass)
+
+	// Patchsets without tryjobs neither pass nor are complete.
+	pGate = newPatchsetGate("https://gold.example.com", "1234", "2", &trybot.PatchsetDetail{ID: 2}, map[string]*Digest{})
+	assert.False(t, pGate.Complete)
+	assert.False(t, pGate.Pass)
+
+	pGate = newPatchsetGate("https://gold.example.com", "1234", "2", nil, map[string]*Digest{})
+	assert.False(t, pGate.Complete)
+	assert.False(t, pGate.Pass)
+	assert.Equal(t, int64(0), pGate.JobTotal)
+}
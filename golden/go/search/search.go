@@ -12,6 +12,7 @@ import (
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/golden/go/blame"
 	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/diffstore"
 	"go.skia.org/infra/golden/go/digesttools"
 	"go.skia.org/infra/golden/go/expstorage"
 	"go.skia.org/infra/golden/go/goldingestion"
@@ -102,6 +103,11 @@ type Query struct {
 	CommitRange    CommitRange
 	Limit          int  // Only return this many items.
 	IncludeMaster  bool // Include digests from master when searching Rietveld issues.
+	ExcludeFlaky   bool // Exclude traces that are flaky, see flaky.Flakiness.
+
+	// Metric is the diff metric used to find the closest positive and negative
+	// digests, see digesttools.IsValidMetric. Defaults to diffstore.METRIC_COMBINED.
+	Metric string
 }
 
 // SearchResponse is the standard search response. Depending on the query some fields
@@ -163,6 +169,13 @@ func (p DigestSlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 // Search returns a slice of Digests that match the input query, and the total number of Digests
 // that matched the query. It also returns a slice of Commits that were used in the calculations.
 func Search(q *Query, storages *storage.Storage, idx *indexer.SearchIndex) (*SearchResponse, error) {
+	if q.Metric == "" {
+		q.Metric = diffstore.METRIC_COMBINED
+	}
+	if !digesttools.IsValidMetric(q.Metric) {
+		return nil, fmt.Errorf("Invalid metric: %s", q.Metric)
+	}
+
 	tile := idx.GetTile(q.IncludeIgnores)
 
 	e, err := storages.ExpectationsStore.Get()
@@ -232,12 +245,43 @@ func searchByIssue(issueID string, q *Query, exp *expstorage.Expectations, parse
 		return nil, nil, fmt.Errorf("Issue not found.")
 	}
 
+	digestMap, err := issueDigests(issue, tile, util.NewStringSet(issue.TargetPatchsets), q, exp, parsedQuery, storages, idx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	talliesByTest := idx.TalliesByTest()
+	ret := make([]*Digest, 0, len(digestMap))
+	allDigests := make([]string, len(digestMap))
+	emptyTraces := &Traces{}
+	for _, digestEntry := range digestMap {
+		digestEntry.Diff = buildDiff(digestEntry.Test, digestEntry.Digest, exp, nil, talliesByTest, storages.DiffStore, idx, q.IncludeIgnores, q.Metric)
+		digestEntry.Traces = emptyTraces
+		ret = append(ret, digestEntry)
+		allDigests = append(allDigests, digestEntry.Digest)
+	}
+
+	loadDigests(storages.DiffStore, allDigests)
+
+	issueResponse := &IssueResponse{
+		IssueDetails:   issue,
+		QueryPatchsets: issue.TargetPatchsets,
+	}
+
+	return ret, issueResponse, nil
+}
+
+// issueDigests returns the digests produced by the given patchsets of an issue
+// that match the query, keyed by test and digest. The returned Digests have
+// neither Diff nor Traces set.
+func issueDigests(issue *trybot.IssueDetails, tile *tiling.Tile, pidMap util.StringSet, q *Query, exp *expstorage.Expectations, parsedQuery url.Values, storages *storage.Storage, idx *indexer.SearchIndex) (map[string]*Digest, error) {
 	// Get a matcher for the ignore rules if we filter ignores.
 	var ignoreMatcher ignore.RuleMatcher = nil
 	if !q.IncludeIgnores {
+		var err error
 		ignoreMatcher, err = storages.IgnoreStore.BuildRuleMatcher()
 		if err != nil {
-			return nil, nil, fmt.Errorf("Unable to build rules matcher: %s", err)
+			return nil, fmt.Errorf("Unable to build rules matcher: %s", err)
 		}
 	}
 
@@ -247,10 +291,9 @@ func searchByIssue(issueID string, q *Query, exp *expstorage.Expectations, parse
 		queryRule = ignore.NewQueryRule(parsedQuery)
 	}
 
-	pidMap := util.NewStringSet(issue.TargetPatchsets)
 	talliesByTest := idx.TalliesByTest()
 	digestMap := map[string]*Digest{}
-	reviewURL := storages.RietveldAPI.Url()
+	reviewURL := storages.CodeReview.Url()
 
 	for idx, cid := range issue.CommitIDs {
 		_, pid := goldingestion.ExtractIssueInfo(cid.CommitID, reviewURL)
@@ -302,25 +345,7 @@ func searchByIssue(issueID string, q *Query, exp *expstorage.Expectations, parse
 			}
 		}
 	}
-
-	ret := make([]*Digest, 0, len(digestMap))
-	allDigests := make([]string, len(digestMap))
-	emptyTraces := &Traces{}
-	for _, digestEntry := range digestMap {
-		digestEntry.Diff = buildDiff(digestEntry.Test, digestEntry.Digest, exp, nil, talliesByTest, storages.DiffStore, idx, q.IncludeIgnores)
-		digestEntry.Traces = emptyTraces
-		ret = append(ret, digestEntry)
-		allDigests = append(allDigests, digestEntry.Digest)
-	}
-
-	loadDigests(storages.DiffStore, allDigests)
-
-	issueResponse := &IssueResponse{
-		IssueDetails:   issue,
-		QueryPatchsets: issue.TargetPatchsets,
-	}
-
-	return ret, issueResponse, nil
+	return digestMap, nil
 }
 
 // searchTile queries across a tile.
@@ -338,6 +363,9 @@ func searchTile(q *Query, e *expstorage.Expectations, parsedQuery url.Values, st
 	// map [test:digest] *intermediate
 	inter := map[string]*intermediate{}
 	for id, tr := range tile.Traces {
+		if q.ExcludeFlaky && idx.IsFlakyTrace(id) {
+			continue
+		}
 		if tiling.Matches(tr, parsedQuery) {
 			test := tr.Params()[types.PRIMARY_KEY_FIELD]
 			// Get all the digests
@@ -372,12 +400,12 @@ func searchTile(q *Query, e *expstorage.Expectations, parsedQuery url.Values, st
 	ret := make([]*Digest, 0, len(inter))
 	for key, i := range inter {
 		parts := strings.Split(key, ":")
-		ret = append(ret, digestFromIntermediate(parts[0], parts[1], i, e, tile, idx, storages.DiffStore, q.IncludeIgnores))
+		ret = append(ret, digestFromIntermediate(parts[0], parts[1], i, e, tile, idx, storages.DiffStore, q.IncludeIgnores, q.Metric))
 	}
 	return ret, tile.Commits, nil
 }
 
-func digestFromIntermediate(test, digest string, inter *intermediate, e *expstorage.Expectations, tile *tiling.Tile, idx *indexer.SearchIndex, diffStore diff.DiffStore, includeIgnores bool) *Digest {
+func digestFromIntermediate(test, digest string, inter *intermediate, e *expstorage.Expectations, tile *tiling.Tile, idx *indexer.SearchIndex, diffStore diff.DiffStore, includeIgnores bool, metric string) *Digest {
 	traceTally := idx.TalliesByTrace()
 	ret := &Digest{
 		Test:     test,
@@ -385,13 +413,14 @@ func digestFromIntermediate(test, digest string, inter *intermediate, e *expstor
 		Status:   e.Classification(test, digest).String(),
 		ParamSet: idx.GetParamsetSummary(test, digest, includeIgnores),
 		Traces:   buildTraces(test, digest, inter.Traces, e, tile, traceTally),
-		Diff:     buildDiff(test, digest, e, tile, idx.TalliesByTest(), diffStore, idx, includeIgnores),
+		Diff:     buildDiff(test, digest, e, tile, idx.TalliesByTest(), diffStore, idx, includeIgnores, metric),
 	}
 	return ret
 }
 
-// buildDiff creates a Diff for the given intermediate.
-func buildDiff(test, digest string, e *expstorage.Expectations, tile *tiling.Tile, testTally map[string]tally.Tally, diffStore diff.DiffStore, idx *indexer.SearchIndex, includeIgnores bool) *Diff {
+// buildDiff creates a Diff for the given intermediate. The closest positive
+// and negative digests are found using the given metric.
+func buildDiff(test, digest string, e *expstorage.Expectations, tile *tiling.Tile, testTally map[string]tally.Tally, diffStore diff.DiffStore, idx *indexer.SearchIndex, includeIgnores bool, metric string) *Diff {
 	ret := &Diff{
 		Diff: math.MaxFloat32,
 		Pos:  nil,
@@ -408,7 +437,7 @@ func buildDiff(test, digest string, e *expstorage.Expectations, tile *tiling.Til
 	}
 
 	var diffVal float32 = 0
-	if closest := digesttools.ClosestDigest(test, digest, e, t, diffStore, types.POSITIVE); closest.Digest != "" {
+	if closest := digesttools.ClosestDigestByMetric(test, digest, e, t, diffStore, types.POSITIVE, metric); closest.Digest != "" {
 		ret.Pos = &DiffDigest{
 			Closest: closest,
 		}
@@ -416,7 +445,7 @@ func buildDiff(test, digest string, e *expstorage.Expectations, tile *tiling.Til
 		diffVal = closest.Diff
 	}
 
-	if closest := digesttools.ClosestDigest(test, digest, e, t, diffStore, types.NEGATIVE); closest.Digest != "" {
+	if closest := digesttools.ClosestDigestByMetric(test, digest, e, t, diffStore, types.NEGATIVE, metric); closest.Digest != "" {
 		ret.Neg = &DiffDigest{
 			Closest: closest,
 		}
@@ -607,12 +636,21 @@ func CompareDigests(test, left, right string, storages *storage.Storage, idx *in
 
 // DigestDetails contains details about a digest.
 type DigestDetails struct {
-	Digest  *Digest          `json:"digest"`
-	Commits []*tiling.Commit `json:"commits"`
+	Digest  *Digest                          `json:"digest"`
+	Commits []*tiling.Commit                 `json:"commits"`
+	History []*expstorage.TriageHistoryEntry `json:"history"`
 }
 
 // GetDigestDetails returns details about a digest as an instance of DigestDetails.
-func GetDigestDetails(test, digest string, storages *storage.Storage, idx *indexer.SearchIndex) (*DigestDetails, error) {
+// The closest positive and negative digests are found using the given metric.
+func GetDigestDetails(test, digest, metric string, storages *storage.Storage, idx *indexer.SearchIndex) (*DigestDetails, error) {
+	if metric == "" {
+		metric = diffstore.METRIC_COMBINED
+	}
+	if !digesttools.IsValidMetric(metric) {
+		return nil, fmt.Errorf("Invalid metric: %s", metric)
+	}
+
 	tile := idx.GetTile(true)
 
 	exp, err := storages.ExpectationsStore.Get()
@@ -620,6 +658,11 @@ func GetDigestDetails(test, digest string, storages *storage.Storage, idx *index
 		return nil, err
 	}
 
+	history, err := storages.ExpectationsStore.TriageHistory(test, digest)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve triage history: %s", err)
+	}
+
 	traces := map[string]tiling.Trace{}
 	for traceId, trace := range tile.Traces {
 		if trace.Params()[types.PRIMARY_KEY_FIELD] != test {
@@ -640,9 +683,10 @@ func GetDigestDetails(test, digest string, storages *storage.Storage, idx *index
 			Status:   exp.Classification(test, digest).String(),
 			ParamSet: idx.GetParamsetSummary(test, digest, true),
 			Traces:   buildTraces(test, digest, traces, exp, tile, idx.TalliesByTrace()),
-			Diff:     buildDiff(test, digest, exp, nil, idx.TalliesByTest(), storages.DiffStore, idx, true),
+			Diff:     buildDiff(test, digest, exp, nil, idx.TalliesByTest(), storages.DiffStore, idx, true, metric),
 		},
 		Commits: tile.Commits,
+		History: history,
 	}, nil
 }
 
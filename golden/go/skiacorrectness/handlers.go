@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"sort"
@@ -19,9 +20,12 @@ import (
 	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/go/timer"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/go/webhook"
+	"go.skia.org/infra/golden/go/baseline"
 	"go.skia.org/infra/golden/go/blame"
 	"go.skia.org/infra/golden/go/diff"
 	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/flaky"
 	"go.skia.org/infra/golden/go/ignore"
 	"go.skia.org/infra/golden/go/indexer"
 	"go.skia.org/infra/golden/go/search"
@@ -36,6 +40,10 @@ const (
 
 	// MAX_PAGE_SIZE is the maximum page size used for pagination.
 	MAX_PAGE_SIZE = 100
+
+	// MAX_SEARCH_IMAGE_SIZE is the maximum size of a request to
+	// jsonSearchByImageHandler.
+	MAX_SEARCH_IMAGE_SIZE = 10 * 1024 * 1024
 )
 
 // TODO(stephana): once the byBlameHandler is removed, refactor this to
@@ -229,6 +237,58 @@ func jsonSearchHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// jsonSearchByImageHandler finds the digests that match or resemble an
+// uploaded image. It expects a multipart form with the PNG image in the
+// 'image' field. The 'query', 'test', 'include', 'metric' and 'limit'
+// parameters restrict the digests the image is compared against, see
+// search.SearchByImage. The user must be logged in.
+func jsonSearchByImageHandler(w http.ResponseWriter, r *http.Request) {
+	if login.LoggedInAs(r) == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to search by image.")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_SEARCH_IMAGE_SIZE)
+	if err := r.ParseMultipartForm(MAX_SEARCH_IMAGE_SIZE); err != nil {
+		httputils.ReportError(w, r, err, "Failed to parse form.")
+		return
+	}
+
+	query := search.Query{}
+	if err := parseQuery(r, &query); err != nil {
+		httputils.ReportError(w, r, err, "Search by image failed.")
+		return
+	}
+	if test := r.FormValue("test"); test != "" {
+		query.Query.Set(types.PRIMARY_KEY_FIELD, test)
+	}
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		httputils.ReportError(w, r, err, "Missing image.")
+		return
+	}
+	defer util.Close(file)
+	imgBytes, err := ioutil.ReadAll(file)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to read image.")
+		return
+	}
+
+	imgQuery := &search.ImageQuery{
+		Query:          query.Query,
+		IncludeIgnores: query.IncludeIgnores,
+		Metric:         query.Metric,
+		Limit:          query.Limit,
+	}
+	resp, err := search.SearchByImage(imgBytes, imgQuery, imageSearchStore, storages, ixr.GetIndex())
+	if err != nil {
+		httputils.ReportError(w, r, err, "Search by image failed.")
+		return
+	}
+	sendJsonResponse(w, resp)
+}
+
 // SearchResult encapsulates the results of a search request.
 type SearchResult struct {
 	Digests    []*search.Digest   `json:"digests"`
@@ -271,6 +331,177 @@ func adaptIssueResponse(ir *search.IssueResponse) *IssueSearchResult {
 	}
 }
 
+// jsonGateHandler returns the new untriaged and negative digests of the
+// patchsets of an issue. It expects the 'issue' parameter and optionally a
+// comma separated list of patchsets in 'patchsets'.
+func jsonGateHandler(w http.ResponseWriter, r *http.Request) {
+	issueID := r.FormValue("issue")
+	if issueID == "" {
+		httputils.ReportError(w, r, nil, "No issue provided.")
+		return
+	}
+	var patchsets []string = nil
+	if temp := r.FormValue("patchsets"); temp != "" {
+		patchsets = strings.Split(temp, ",")
+	}
+	writeGateResponse(w, r, issueID, patchsets)
+}
+
+// GateRequest is the body of the requests to gateWebhookHandler.
+type GateRequest struct {
+	Issue     string   `json:"issue"`
+	Patchsets []string `json:"patchsets"`
+}
+
+// gateWebhookHandler is the same as jsonGateHandler, but the parameters are
+// a GateRequest in the JSON body. The request must be authenticated via the
+// protocol implemented in the webhook package, which allows bots, e.g. the
+// commit queue, to use it without logging in.
+func gateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := webhook.AuthenticateRequest(r)
+	if err != nil {
+		glog.Errorf("Failed authentication in gateWebhookHandler: %s", err)
+		httputils.ReportError(w, r, nil, "Failed authentication.")
+		return
+	}
+	var req GateRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		httputils.ReportError(w, r, err, "Failed to parse request.")
+		return
+	}
+	if req.Issue == "" {
+		httputils.ReportError(w, r, nil, "No issue provided.")
+		return
+	}
+	writeGateResponse(w, r, req.Issue, req.Patchsets)
+}
+
+// writeGateResponse computes the gating information for the given issue and
+// patchsets and writes it to the response.
+func writeGateResponse(w http.ResponseWriter, r *http.Request, issueID string, patchsets []string) {
+	scheme := "https"
+	if *local {
+		scheme = "http"
+	}
+	baseURL := fmt.Sprintf("%s://%s", scheme, r.Host)
+	resp, err := search.Gate(issueID, patchsets, baseURL, storages, ixr.GetIndex())
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to compute gating information.")
+		return
+	}
+	sendJsonResponse(w, resp)
+}
+
+// jsonBaselineHandler returns the positive and negative digests of all
+// tests as a baseline.Baseline. If the 'issue' parameter is given, the
+// baseline only contains the tests that were run by the tryjobs of that issue.
+// The response carries an ETag and a matching If-None-Match header results in
+// a 304 (Not Modified) response.
+func jsonBaselineHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the version before the expectations. If they change in between, the
+	// response contains newer expectations than the tag indicates, which only
+	// causes an additional download on the next request.
+	expVersion, err := storages.ExpectationsStore.Version()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to retrieve expectations version.")
+		return
+	}
+
+	var tests util.StringSet = nil
+	issueID := r.FormValue("issue")
+	if issueID != "" {
+		issue, tile, err := storages.TrybotResults.GetIssue(issueID, nil)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Failed to retrieve issue.")
+			return
+		}
+		if issue == nil {
+			http.Error(w, "Issue not found.", http.StatusNotFound)
+			return
+		}
+		tests = baseline.TestsInTile(tile)
+	}
+
+	eTag := baseline.ETag(expVersion, issueID, tests)
+	w.Header().Set("ETag", eTag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == eTag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	exp, err := storages.ExpectationsStore.Get()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to retrieve expectations.")
+		return
+	}
+	sendJsonResponse(w, baseline.New(exp, expVersion, issueID, tests))
+}
+
+// FlakyResponse is the response of jsonFlakyHandler.
+type FlakyResponse struct {
+	Tests       []*flaky.TestFlakiness    `json:"tests"`
+	ParamCounts map[string]map[string]int `json:"paramCounts"`
+}
+
+// jsonFlakyHandler returns the tests with flaky traces in the current tile and
+// the number of flaky traces per parameter value. Each test contains a
+// suggested ignore rule for its flaky traces.
+func jsonFlakyHandler(w http.ResponseWriter, r *http.Request) {
+	flakiness := ixr.GetIndex().GetFlakiness()
+	sendJsonResponse(w, &FlakyResponse{
+		Tests:       flakiness.Tests(),
+		ParamCounts: flakiness.ParamCounts(),
+	})
+}
+
+// FlakyIgnoreRequest is the JSON posted to jsonFlakyIgnoreHandler.
+type FlakyIgnoreRequest struct {
+	Test     string `json:"test"`
+	Duration string `json:"duration"`
+}
+
+// jsonFlakyIgnoreHandler creates the suggested ignore rule for the flaky
+// traces of a test, see flaky.TestFlakiness.SuggestedIgnore.
+func jsonFlakyIgnoreHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to add an ignore rule.")
+		return
+	}
+	req := &FlakyIgnoreRequest{}
+	if err := parseJson(r, req); err != nil {
+		httputils.ReportError(w, r, err, "Failed to parse submitted data.")
+		return
+	}
+	d, err := human.ParseDuration(req.Duration)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to parse duration")
+		return
+	}
+
+	var testFlakiness *flaky.TestFlakiness = nil
+	for _, tf := range ixr.GetIndex().GetFlakiness().Tests() {
+		if tf.Test == req.Test {
+			testFlakiness = tf
+			break
+		}
+	}
+	if (testFlakiness == nil) || (testFlakiness.SuggestedIgnore == "") {
+		httputils.ReportError(w, r, fmt.Errorf("No ignore rule suggested for test %q", req.Test), "Test has no flaky traces that are not ignored.")
+		return
+	}
+
+	note := fmt.Sprintf("Flaky: %d of %d traces.", testFlakiness.FlakyTraces, testFlakiness.TotalTraces)
+	ignoreRule := ignore.NewIgnoreRule(user, time.Now().Add(d), testFlakiness.SuggestedIgnore, note)
+	if err = storages.IgnoreStore.Create(ignoreRule); err != nil {
+		httputils.ReportError(w, r, err, "Failed to create ignore rule.")
+		return
+	}
+
+	jsonIgnoresHandler(w, r)
+}
+
 // jsonDetailsHandler returns the details about a single digest.
 func jsonDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract: test, digest.
@@ -285,7 +516,7 @@ func jsonDetailsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ret, err := search.GetDigestDetails(test, digest, storages, ixr.GetIndex())
+	ret, err := search.GetDigestDetails(test, digest, r.Form.Get("metric"), storages, ixr.GetIndex())
 	if err != nil {
 		httputils.ReportError(w, r, err, "Unable to get digest details.")
 		return
@@ -407,6 +638,80 @@ func jsonIgnoresDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// jsonIgnoresExtendHandler extends the expiration of an ignore rule. The
+// ignores page calls it once the user confirmed the extension requested by
+// the link in an expiry notification, see ignore.ExpiryNotifier.
+func jsonIgnoresExtendHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to extend an ignore rule.")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		httputils.ReportError(w, r, err, "ID must be valid integer.")
+		return
+	}
+
+	if _, err := ignore.Extend(storages.IgnoreStore, int(id), user); err != nil {
+		httputils.ReportError(w, r, err, "Unable to extend ignore rule.")
+		return
+	}
+	jsonIgnoresHandler(w, r)
+}
+
+// jsonIgnoresStaleHandler returns the ignore rules that do not match any
+// trace in the current tile.
+func jsonIgnoresStaleHandler(w http.ResponseWriter, r *http.Request) {
+	stale, err := storages.IgnoreStore.Stale()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to retrieve stale ignore rules.")
+		return
+	}
+	sendJsonResponse(w, stale)
+}
+
+// StaleDeleteRequest is the JSON posted to jsonIgnoresStaleDeleteHandler.
+type StaleDeleteRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// jsonIgnoresStaleDeleteHandler deletes the given ignore rules if they are
+// still stale, i.e. rules that started to match traces again are kept.
+func jsonIgnoresStaleDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to delete ignore rules.")
+		return
+	}
+	req := &StaleDeleteRequest{}
+	if err := parseJson(r, req); err != nil {
+		httputils.ReportError(w, r, err, "Failed to parse submitted data.")
+		return
+	}
+
+	stale, err := storages.IgnoreStore.Stale()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to retrieve stale ignore rules.")
+		return
+	}
+	staleIDs := make(map[int]bool, len(stale))
+	for _, rule := range stale {
+		staleIDs[rule.ID] = true
+	}
+
+	for _, id := range req.IDs {
+		if !staleIDs[id] {
+			continue
+		}
+		if _, err := storages.IgnoreStore.Delete(id, user); err != nil {
+			httputils.ReportError(w, r, err, "Unable to delete ignore rule.")
+			return
+		}
+	}
+	jsonIgnoresHandler(w, r)
+}
+
 // jsonIgnoresAddHandler is for adding a new ignore rule.
 func jsonIgnoresAddHandler(w http.ResponseWriter, r *http.Request) {
 	user := login.LoggedInAs(r)
@@ -452,6 +757,7 @@ type TriageRequest struct {
 	Filter  string   `json:"filter"`
 	Include bool     `json:"include"` // Include ignored digests.
 	Head    bool     `json:"head"`    // Only include digests at head if true.
+	Reason  string   `json:"reason"`  // Optional free text reason for the change.
 }
 
 // jsonTriageHandler handles a request to change the triage status of one or more
@@ -501,7 +807,7 @@ func jsonTriageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Otherwise update the expectations directly.
-	if err := storages.ExpectationsStore.AddChange(tc, user); err != nil {
+	if err := storages.ExpectationsStore.AddChangeWithReason(tc, user, req.Reason); err != nil {
 		httputils.ReportError(w, r, err, "Failed to store the updated expectations.")
 		return
 	}
@@ -806,6 +1112,8 @@ func parseQuery(r *http.Request, query *search.Query) error {
 	query.IncludeIgnores = r.FormValue("include") == "true"
 	query.Issue = r.FormValue("issue")
 	query.IncludeMaster = r.FormValue("master") == "true"
+	query.ExcludeFlaky = r.FormValue("noflaky") == "true"
+	query.Metric = r.FormValue("metric")
 
 	return nil
 }
@@ -884,6 +1192,24 @@ func jsonTriageLogHandler(w http.ResponseWriter, r *http.Request) {
 	sendResponse(w, logEntries, http.StatusOK, pagination)
 }
 
+// jsonTriageHistoryHandler returns the triage history of a test. It expects
+// the 'test' parameter and optionally a 'digest' parameter to limit the
+// history to a single digest.
+func jsonTriageHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	test := r.FormValue("test")
+	if test == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Missing test parameter."), "No test provided.")
+		return
+	}
+
+	history, err := storages.ExpectationsStore.TriageHistory(test, r.FormValue("digest"))
+	if err != nil {
+		httputils.ReportError(w, r, err, "Unable to retrieve triage history.")
+		return
+	}
+	sendJsonResponse(w, history)
+}
+
 // jsonTriageUndoHandler performs an "undo" for a given change id.
 // The change id's are returned in the result of jsonTriageLogHandler.
 // It accepts one query parameter 'id' which is the id if the change
@@ -10,15 +10,19 @@ import (
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/auth"
+	"go.skia.org/infra/go/codereview"
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/database"
+	"go.skia.org/infra/go/email"
 	"go.skia.org/infra/go/eventbus"
 	"go.skia.org/infra/go/fileutil"
+	"go.skia.org/infra/go/gerrit"
 	"go.skia.org/infra/go/gitinfo"
 	"go.skia.org/infra/go/httputils"
 	"go.skia.org/infra/go/influxdb"
@@ -31,13 +35,16 @@ import (
 	"go.skia.org/infra/go/timer"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/go/webhook"
 	"go.skia.org/infra/golden/go/db"
+	"go.skia.org/infra/golden/go/diffstore"
 	"go.skia.org/infra/golden/go/digeststore"
 	"go.skia.org/infra/golden/go/expstorage"
 	"go.skia.org/infra/golden/go/filediffstore"
 	"go.skia.org/infra/golden/go/goldingestion"
 	"go.skia.org/infra/golden/go/history"
 	"go.skia.org/infra/golden/go/ignore"
+	"go.skia.org/infra/golden/go/imagesource"
 	"go.skia.org/infra/golden/go/indexer"
 	"go.skia.org/infra/golden/go/status"
 	"go.skia.org/infra/golden/go/storage"
@@ -48,29 +55,40 @@ import (
 
 // Command line flags.
 var (
-	authWhiteList      = flag.String("auth_whitelist", login.DEFAULT_DOMAIN_WHITELIST, "White space separated list of domains and email addresses that are allowed to login.")
-	cpuProfile         = flag.Duration("cpu_profile", 0, "Duration for which to profile the CPU usage. After this duration the program writes the CPU profile and exits.")
-	doOauth            = flag.Bool("oauth", true, "Run through the OAuth 2.0 flow on startup, otherwise use a GCE service account.")
-	forceLogin         = flag.Bool("force_login", false, "Force the user to be authenticated for all requests.")
-	gsBucketName       = flag.String("gs_bucket", "chromium-skia-gm", "Name of the google storage bucket that holds uploaded images.")
-	imageDir           = flag.String("image_dir", "/tmp/imagedir", "What directory to store test and diff images in.")
-	issueTrackerKey    = flag.String("issue_tracker_key", "", "API Key for accessing the project hosting API.")
-	local              = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
-	memProfile         = flag.Duration("memprofile", 0, "Duration for which to profile memory. After this duration the program writes the memory profile and exits.")
-	nCommits           = flag.Int("n_commits", 50, "Number of recent commits to include in the analysis.")
-	nTilesToBackfill   = flag.Int("backfill_tiles", 0, "Number of tiles to backfill in our history of tiles.")
-	oauthCacheFile     = flag.String("oauth_cache_file", "/home/perf/google_storage_token.data", "Path to the file where to cache cache the oauth credentials.")
-	port               = flag.String("port", ":9000", "HTTP service address (e.g., ':9000')")
-	redirectURL        = flag.String("redirect_url", "https://gold.skia.org/oauth2callback/", "OAuth2 redirect url. Only used when local=false.")
-	redisDB            = flag.Int("redis_db", 0, "The index of the Redis database we should use. Default will work fine in most cases.")
-	redisHost          = flag.String("redis_host", "", "The host and port (e.g. 'localhost:6379') of the Redis data store that will be used for caching.")
-	resourcesDir       = flag.String("resources_dir", "", "The directory to find templates, JS, and CSS files. If blank the directory relative to the source code files will be used.")
-	rietveldURL        = flag.String("rietveld_url", "https://codereview.chromium.org/", "URL of the Rietveld instance where we retrieve CL metadata.")
-	storageDir         = flag.String("storage_dir", "/tmp/gold-storage", "Directory to store reproducible application data.")
-	gitRepoDir         = flag.String("git_repo_dir", "../../../skia", "Directory location for the Skia repo.")
-	gitRepoURL         = flag.String("git_repo_url", "https://skia.googlesource.com/skia", "The URL to pass to git clone for the source repository.")
-	serviceAccountFile = flag.String("service_account_file", "", "Credentials file for service account.")
-	traceservice       = flag.String("trace_service", "localhost:10000", "The address of the traceservice endpoint.")
+	authWhiteList       = flag.String("auth_whitelist", login.DEFAULT_DOMAIN_WHITELIST, "White space separated list of domains and email addresses that are allowed to login.")
+	bearerClientIDs     = flag.String("bearer_client_ids", "", "Comma separated list of OAuth 2.0 client ids whose access tokens are accepted as bearer tokens, e.g. the client id used by goldctl.")
+	cpuProfile          = flag.Duration("cpu_profile", 0, "Duration for which to profile the CPU usage. After this duration the program writes the CPU profile and exits.")
+	doOauth             = flag.Bool("oauth", true, "Run through the OAuth 2.0 flow on startup, otherwise use a GCE service account.")
+	emailClientID       = flag.String("email_client_id", "", "OAuth 2.0 client id of the email account used to send notifications.")
+	emailClientSecret   = flag.String("email_client_secret", "", "OAuth 2.0 client secret of the email account used to send notifications.")
+	emailTokenCacheFile = flag.String("email_token_cache_file", "", "Token cache file of the email account used to send notifications. If empty, no notifications are sent.")
+	forceLogin          = flag.Bool("force_login", false, "Force the user to be authenticated for all requests.")
+	gateWebhook         = flag.Bool("gate_webhook", false, "Serve the webhook authenticated pre-submit gating endpoint /_/gate. The request salt is read from the project metadata unless running locally.")
+	gerritURL           = flag.String("gerrit_url", "", "URL of the Gerrit instance where we retrieve CL metadata. If set, trybot results come from Gerrit instead of Rietveld.")
+	gsBucketName        = flag.String("gs_bucket", "chromium-skia-gm", "Name of the google storage bucket that holds uploaded images.")
+	ignoreExpiryWarning = flag.Duration("ignore_expiry_warning", 3*24*time.Hour, "How long before an ignore rule expires its author is notified.")
+	imageDir            = flag.String("image_dir", "/tmp/imagedir", "What directory to store test and diff images in.")
+	imageSourceDir      = flag.String("image_source_dir", "", "If set, images are read from <digest>.png files in this directory instead of the gs_bucket.")
+	imageSourceURL      = flag.String("image_source_url", "", "If set, images are retrieved from <image_source_url>/<digest>.png instead of the gs_bucket.")
+	issueTrackerKey     = flag.String("issue_tracker_key", "", "API Key for accessing the project hosting API.")
+	local               = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
+	memProfile          = flag.Duration("memprofile", 0, "Duration for which to profile memory. After this duration the program writes the memory profile and exits.")
+	nCommits            = flag.Int("n_commits", 50, "Number of recent commits to include in the analysis.")
+	nTilesToBackfill    = flag.Int("backfill_tiles", 0, "Number of tiles to backfill in our history of tiles.")
+	oauthCacheFile      = flag.String("oauth_cache_file", "/home/perf/google_storage_token.data", "Path to the file where to cache cache the oauth credentials.")
+	perceptualDiff      = flag.Bool("perceptual_diff", false, "Calculate the perceptual diff metric. It is expensive and required to find the closest digests by metric=perceptual.")
+	port                = flag.String("port", ":9000", "HTTP service address (e.g., ':9000')")
+	redirectURL         = flag.String("redirect_url", "https://gold.skia.org/oauth2callback/", "OAuth2 redirect url. Only used when local=false.")
+	redisDB             = flag.Int("redis_db", 0, "The index of the Redis database we should use. Default will work fine in most cases.")
+	redisHost           = flag.String("redis_host", "", "The host and port (e.g. 'localhost:6379') of the Redis data store that will be used for caching.")
+	resourcesDir        = flag.String("resources_dir", "", "The directory to find templates, JS, and CSS files. If blank the directory relative to the source code files will be used.")
+	rietveldURL         = flag.String("rietveld_url", "https://codereview.chromium.org/", "URL of the Rietveld instance where we retrieve CL metadata.")
+	storageDir          = flag.String("storage_dir", "/tmp/gold-storage", "Directory to store reproducible application data.")
+	gitRepoDir          = flag.String("git_repo_dir", "../../../skia", "Directory location for the Skia repo.")
+	gitRepoURL          = flag.String("git_repo_url", "https://skia.googlesource.com/skia", "The URL to pass to git clone for the source repository.")
+	serviceAccountFile  = flag.String("service_account_file", "", "Credentials file for service account.")
+	siteURL             = flag.String("site_url", "https://gold.skia.org", "URL of this Gold instance, used to build links in notifications.")
+	traceservice        = flag.String("trace_service", "localhost:10000", "The address of the traceservice endpoint.")
 
 	influxHost     = flag.String("influxdb_host", influxdb.DEFAULT_HOST, "The InfluxDB hostname.")
 	influxUser     = flag.String("influxdb_name", influxdb.DEFAULT_USER, "The InfluxDB username.")
@@ -107,6 +125,7 @@ var (
 	pathToURLConverter PathToURLConverter
 	statusWatcher      *status.StatusWatcher
 	ixr                *indexer.Indexer
+	imageSearchStore   *diffstore.MemDiffStore
 	issueTracker       issues.IssueTracker
 )
 
@@ -270,6 +289,9 @@ func main() {
 		useRedirectURL = *redirectURL
 	}
 	login.Init(clientID, clientSecret, useRedirectURL, cookieSalt, login.DEFAULT_SCOPE, *authWhiteList, *local)
+	if *bearerClientIDs != "" {
+		login.EnableBearerTokens(strings.Split(*bearerClientIDs, ","))
+	}
 
 	// Get the client to be used to access GS and the Monorail issue tracker.
 	client, err := auth.NewJWTServiceAccountClient("", *serviceAccountFile, nil, gstorage.CloudPlatformScope, "https://www.googleapis.com/auth/userinfo.email")
@@ -286,12 +308,34 @@ func main() {
 		}
 	}
 
+	// Set up the source of the images.
+	var imgSource imagesource.ImageSource
+	switch {
+	case *imageSourceDir != "" && *imageSourceURL != "":
+		glog.Fatal("Only one of --image_source_dir and --image_source_url can be set.")
+	case *imageSourceDir != "":
+		imgSource = imagesource.NewDirImageSource(*imageSourceDir)
+	case *imageSourceURL != "":
+		imgSource = imagesource.NewHTTPImageSource(httputils.NewTimeoutClient(), *imageSourceURL)
+	default:
+		if imgSource, err = imagesource.NewGSImageSource(client, *gsBucketName, filediffstore.DEFAULT_GS_IMG_DIR_NAME); err != nil {
+			glog.Fatalf("Failed to create image source: %s", err)
+		}
+	}
+
 	// Get the expecations storage, the filediff storage and the tilestore.
-	diffStore, err := filediffstore.NewFileDiffStore(client, *imageDir, *gsBucketName, filediffstore.DEFAULT_GS_IMG_DIR_NAME, cacheFactory, filediffstore.RECOMMENDED_WORKER_POOL_SIZE)
+	diffStore, err := filediffstore.NewFileDiffStore(imgSource, *imageDir, cacheFactory, filediffstore.RECOMMENDED_WORKER_POOL_SIZE, *perceptualDiff)
 	if err != nil {
 		glog.Fatalf("Allocating DiffStore failed: %s", err)
 	}
 
+	// The store used to search by image shares the image directory with the
+	// diff store, so images only need to be downloaded once.
+	imageSearchStore, err = diffstore.New(imgSource, *imageDir, *perceptualDiff)
+	if err != nil {
+		glog.Fatalf("Allocating image search store failed: %s", err)
+	}
+
 	if !*local {
 		if err := dbConf.GetPasswordFromMetadata(); err != nil {
 			glog.Fatal(err)
@@ -318,7 +362,12 @@ func main() {
 
 	evt := eventbus.New(nil)
 
-	rietveldAPI := rietveld.New(rietveld.RIETVELD_SKIA_URL, httputils.NewTimeoutClient())
+	var review codereview.CodeReview
+	if *gerritURL != "" {
+		review = codereview.NewGerrit(gerrit.New(*gerritURL, httputils.NewTimeoutClient()))
+	} else {
+		review = codereview.NewRietveld(rietveld.New(*rietveldURL, httputils.NewTimeoutClient()))
+	}
 
 	// Connect to traceDB and create the builders.
 	db, err := tracedb.NewTraceServiceDBFromAddress(*traceservice, types.GoldenTraceBuilder)
@@ -330,7 +379,7 @@ func main() {
 	if err != nil {
 		glog.Fatalf("Failed to build trace/db.DB: %s", err)
 	}
-	branchTileBuilder := tracedb.NewBranchTileBuilder(db, git, rietveldAPI, evt)
+	branchTileBuilder := tracedb.NewBranchTileBuilder(db, git, review, evt)
 
 	ingestionStore, err := goldingestion.NewIngestionStore(*traceservice)
 	if err != nil {
@@ -344,8 +393,8 @@ func main() {
 		DigestStore:       digestStore,
 		NCommits:          *nCommits,
 		EventBus:          evt,
-		TrybotResults:     trybot.NewTrybotResults(branchTileBuilder, rietveldAPI, ingestionStore),
-		RietveldAPI:       rietveldAPI,
+		TrybotResults:     trybot.NewTrybotResults(branchTileBuilder, review, ingestionStore),
+		CodeReview:        review,
 	}
 
 	// TODO(stephana): Remove this workaround to avoid circular dependencies once the 'storage' module is cleaned up.
@@ -355,7 +404,18 @@ func main() {
 		glog.Fatalf("Unable to initialize history package: %s", err)
 	}
 
-	if err := ignore.Init(storages.IgnoreStore); err != nil {
+	// Notify the authors of ignore rules about expiring rules if an email
+	// account is configured.
+	var expiryNotifier *ignore.ExpiryNotifier = nil
+	if *emailTokenCacheFile != "" {
+		gmail, err := email.NewGMail(*emailClientID, *emailClientSecret, *emailTokenCacheFile)
+		if err != nil {
+			glog.Fatalf("Failed to create email client: %s", err)
+		}
+		expiryNotifier = ignore.NewExpiryNotifier(gmail, *siteURL, *ignoreExpiryWarning)
+	}
+
+	if err := ignore.Init(storages.IgnoreStore, expiryNotifier); err != nil {
 		glog.Fatalf("Failed to start monitoring for expired ignore rules: %s", err)
 	}
 
@@ -408,19 +468,28 @@ func main() {
 	router.HandleFunc("/json/list", jsonListTestsHandler).Methods("GET")
 	router.HandleFunc("/json/paramset", jsonParamsHandler).Methods("GET")
 	router.HandleFunc("/json/search", jsonSearchHandler).Methods("GET")
+	router.HandleFunc("/json/searchbyimage", jsonSearchByImageHandler).Methods("POST")
 	router.HandleFunc("/json/diff", jsonDiffHandler).Methods("GET")
 	router.HandleFunc("/json/details", jsonDetailsHandler).Methods("GET")
 	router.HandleFunc("/json/ignores", jsonIgnoresHandler).Methods("GET")
 	router.HandleFunc("/json/ignores/add/", jsonIgnoresAddHandler).Methods("POST")
 	router.HandleFunc("/json/ignores/del/{id}", jsonIgnoresDeleteHandler).Methods("POST")
 	router.HandleFunc("/json/ignores/save/{id}", jsonIgnoresUpdateHandler).Methods("POST")
+	router.HandleFunc("/json/ignores/stale", jsonIgnoresStaleHandler).Methods("GET")
+	router.HandleFunc("/json/ignores/stale/del", jsonIgnoresStaleDeleteHandler).Methods("POST")
+	router.HandleFunc("/json/ignores/extend/{id}", jsonIgnoresExtendHandler).Methods("POST")
 	router.HandleFunc("/json/triage", jsonTriageHandler).Methods("POST")
 	router.HandleFunc("/json/clusterdiff", jsonClusterDiffHandler).Methods("GET")
 	router.HandleFunc("/json/triagelog", jsonTriageLogHandler).Methods("GET")
 	router.HandleFunc("/json/triagelog/undo", jsonTriageUndoHandler).Methods("POST")
+	router.HandleFunc("/json/triagehistory", jsonTriageHistoryHandler).Methods("GET")
 	router.HandleFunc("/json/trybot", jsonListTrybotsHandler).Methods("GET")
 	router.HandleFunc("/json/failure", jsonListFailureHandler).Methods("GET")
 	router.HandleFunc("/json/failure/clear", jsonClearFailureHandler).Methods("POST")
+	router.HandleFunc("/json/gate", jsonGateHandler).Methods("GET")
+	router.HandleFunc("/json/baseline", jsonBaselineHandler).Methods("GET")
+	router.HandleFunc("/json/flaky", jsonFlakyHandler).Methods("GET")
+	router.HandleFunc("/json/flaky/ignore", jsonFlakyIgnoreHandler).Methods("POST")
 
 	// For everything else serve the same markup.
 	indexFile := *resourcesDir + "/index.html"
@@ -438,6 +507,17 @@ func main() {
 
 	// The jsonStatusHandler is being polled, so we exclude it from logging.
 	http.HandleFunc("/json/trstatus", jsonStatusHandler)
+
+	// The gating webhook authenticates its requests itself, so it does not
+	// require a login.
+	if *gateWebhook {
+		if *local {
+			webhook.InitRequestSaltForTesting()
+		} else {
+			webhook.MustInitRequestSaltFromMetadata()
+		}
+		http.HandleFunc("/_/gate", gateWebhookHandler)
+	}
 	http.Handle("/", rootHandler)
 
 	// Start the server
@@ -6,8 +6,8 @@ import (
 	"time"
 
 	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/codereview"
 	"go.skia.org/infra/go/eventbus"
-	"go.skia.org/infra/go/rietveld"
 	"go.skia.org/infra/go/tiling"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/golden/go/diff"
@@ -29,7 +29,7 @@ type Storage struct {
 	DigestStore       digeststore.DigestStore
 	EventBus          *eventbus.EventBus
 	TrybotResults     *trybot.TrybotResults
-	RietveldAPI       *rietveld.Rietveld
+	CodeReview        codereview.CodeReview
 
 	// NCommits is the number of commits we should consider. If NCommits is
 	// 0 or smaller all commits in the last tile will be considered.
@@ -12,7 +12,7 @@ import (
 
 	cache "github.com/patrickmn/go-cache"
 	"github.com/skia-dev/glog"
-	"go.skia.org/infra/go/rietveld"
+	"go.skia.org/infra/go/codereview"
 	"go.skia.org/infra/go/tiling"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/util"
@@ -25,17 +25,17 @@ var (
 )
 
 const (
-	TRYJOB_SCHEDULED = "scheduled"
-	TRYJOB_RUNNING   = "running"
-	TRYJOB_COMPLETE  = "complete"
+	TRYJOB_SCHEDULED = codereview.TRYJOB_SCHEDULED
+	TRYJOB_RUNNING   = codereview.TRYJOB_RUNNING
+	TRYJOB_COMPLETE  = codereview.TRYJOB_COMPLETE
 	TRYJOB_INGESTED  = "ingested"
-	TRYJOB_FAILED    = "failed"
+	TRYJOB_FAILED    = codereview.TRYJOB_FAILED
 
 	PATCHSET_CACHE_EXPIRATION       = 5 * time.Minute
 	PATCHSET_CACHE_CLEANUP_INTERVAL = 30 * time.Second
 )
 
-// Issue captures information about a single Rietveld issued.
+// Issue captures information about a single code review issue.
 type Issue struct {
 	ID        string  `json:"id"`
 	Subject   string  `json:"subject"`
@@ -73,17 +73,19 @@ type Tryjob struct {
 type TrybotResults struct {
 	tileBuilder    tracedb.BranchTileBuilder
 	reviewURL      string
-	rietveldAPI    *rietveld.Rietveld
+	review         codereview.CodeReview
 	ingestionStore *goldingestion.IngestionStore
 	timeFrame      time.Duration
 	patchsetCache  *cache.Cache
 }
 
-func NewTrybotResults(tileBuilder tracedb.BranchTileBuilder, rietveldAPI *rietveld.Rietveld, ingestionStore *goldingestion.IngestionStore) *TrybotResults {
+// NewTrybotResults returns a new TrybotResults for the issues of the given code
+// review system, which must be the one tileBuilder uses.
+func NewTrybotResults(tileBuilder tracedb.BranchTileBuilder, review codereview.CodeReview, ingestionStore *goldingestion.IngestionStore) *TrybotResults {
 	ret := &TrybotResults{
 		tileBuilder:    tileBuilder,
-		reviewURL:      rietveldAPI.Url(),
-		rietveldAPI:    rietveldAPI,
+		reviewURL:      review.Url(),
+		review:         review,
 		ingestionStore: ingestionStore,
 		timeFrame:      TIME_FRAME,
 		patchsetCache:  cache.New(PATCHSET_CACHE_EXPIRATION, PATCHSET_CACHE_CLEANUP_INTERVAL),
@@ -192,10 +194,10 @@ func (t *TrybotResults) getPatchsetDetails(issue *Issue, targetPatchsets []strin
 				return
 			}
 
-			nTryjobs := len(crPatchSet.TryjobResults)
+			nTryjobs := len(crPatchSet.Tryjobs)
 			tryjobs := make([]*Tryjob, 0, nTryjobs)
 			var tjIngested int64 = 0
-			for _, tj := range crPatchSet.TryjobResults {
+			for _, tj := range crPatchSet.Tryjobs {
 				// Filter out tryjobs we want to ignore. This includes compile bots, since we'll never
 				// ingest any results from them. We count the bot as ingested.
 				if filterTryjob(tj) {
@@ -203,28 +205,10 @@ func (t *TrybotResults) getPatchsetDetails(issue *Issue, targetPatchsets []strin
 					continue
 				}
 
-				var status string
-				checkIngested := false
-				switch tj.Result {
-				// scheduled but not yet started.
-				case 6:
-					status = TRYJOB_SCHEDULED
-				// currently running.
-				case -1:
-					status = TRYJOB_RUNNING
-					checkIngested = true
-					// Finished.
-				case 0:
-					status = TRYJOB_COMPLETE
-					checkIngested = true
-				// failed.
-				case 2:
-					status = TRYJOB_FAILED
-				default:
-					status = TRYJOB_FAILED
-				}
-
 				// Check if the job has been ingested if the job has at least been started.
+				status := tj.Status
+				checkIngested := (status == TRYJOB_RUNNING) || (status == TRYJOB_COMPLETE)
+
 				if checkIngested && t.ingestionStore.IsIngested(config.CONSTRUCTOR_GOLD, tj.Master, tj.Builder, tj.BuildNumber) {
 					status = TRYJOB_INGESTED
 					tjIngested++
@@ -252,15 +236,15 @@ func (t *TrybotResults) getPatchsetDetails(issue *Issue, targetPatchsets []strin
 	return ret, targetPatchsets, nil
 }
 
-func (t *TrybotResults) getCachedPatchset(intIssueID, pid int64, targetPatchsets map[int64]bool) (*rietveld.Patchset, error) {
+func (t *TrybotResults) getCachedPatchset(intIssueID, pid int64, targetPatchsets map[int64]bool) (*codereview.Patchset, error) {
 	key := strconv.FormatInt(intIssueID, 10) + ":" + strconv.FormatInt(pid, 10)
 
 	// Check for the key.
 	if val, ok := t.patchsetCache.Get(key); ok {
-		return val.(*rietveld.Patchset), nil
+		return val.(*codereview.Patchset), nil
 	}
 
-	val, err := t.rietveldAPI.GetPatchset(intIssueID, pid)
+	val, err := t.review.GetPatchset(intIssueID, pid)
 	if err != nil {
 		return nil, err
 	}
@@ -270,7 +254,7 @@ func (t *TrybotResults) getCachedPatchset(intIssueID, pid int64, targetPatchsets
 }
 
 // filterTryjob returns true if the given tryjob should be ignored.
-func filterTryjob(tj *rietveld.TryjobResult) bool {
+func filterTryjob(tj *codereview.Tryjob) bool {
 	return !strings.HasPrefix(tj.Builder, "Test")
 }
 
@@ -301,7 +285,7 @@ func (t *TrybotResults) getCommits(startTime, endTime time.Time, prefix string)
 }
 
 // getIssuesFromCommits returns instances of Issue based on the provided commits and the set
-// of issue ids. It is assumed that issueIDs only contains issues that have Rietveld details
+// of issue ids. It is assumed that issueIDs only contains issues that have code review details
 // attached to them. Any commit that is not in issueIDs will be ommitted.
 func (t *TrybotResults) getIssuesFromCommits(commits []*tracedb.CommitIDLong, issueIDs map[string]bool) []*Issue {
 	codeReviewURL := strings.TrimSuffix(t.reviewURL, "/")
@@ -316,7 +300,7 @@ func (t *TrybotResults) getIssuesFromCommits(commits []*tracedb.CommitIDLong, is
 
 		issue, ok := issueMap[iid]
 		if !ok {
-			details := cid.Details.(*rietveld.Issue)
+			details := cid.Details.(*codereview.Issue)
 			issue = &Issue{
 				ID:        iid,
 				Subject:   cid.Desc,
@@ -339,7 +323,7 @@ func (t *TrybotResults) getIssuesFromCommits(commits []*tracedb.CommitIDLong, is
 }
 
 // uniqueIssues returns the set of all issues contained in the given list of commit ids. If an issue does not
-// have Rietveld information associated with it (i.e. the Details file is nil) it will be ommitted from the
+// have code review information associated with it (i.e. the Details file is nil) it will be ommitted from the
 // returned list of commit ids and the set of commit issue ids.
 func (t *TrybotResults) uniqueIssues(commitIDs []*tracedb.CommitIDLong) ([]*tracedb.CommitIDLong, map[string]bool, time.Time) {
 	minTime := time.Now()
@@ -353,7 +337,7 @@ func (t *TrybotResults) uniqueIssues(commitIDs []*tracedb.CommitIDLong) ([]*trac
 		ret = append(ret, cid)
 		iid, _ := goldingestion.ExtractIssueInfo(cid.CommitID, t.reviewURL)
 		issueIDs[iid] = true
-		rIssue := cid.Details.(*rietveld.Issue)
+		rIssue := cid.Details.(*codereview.Issue)
 		if minTime.After(rIssue.Created) {
 			minTime = rIssue.Created
 		}
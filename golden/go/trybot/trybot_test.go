@@ -5,6 +5,7 @@ import (
 	"time"
 
 	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/codereview"
 	"go.skia.org/infra/go/eventbus"
 	"go.skia.org/infra/go/ingestion"
 	"go.skia.org/infra/go/rietveld"
@@ -30,7 +31,7 @@ var (
 func TestTrybotResults(t *testing.T) {
 	testutils.SkipIfShort(t)
 
-	rietveldAPI := rietveld.New(TEST_CODE_REVIEW_URL, nil)
+	review := codereview.NewRietveld(rietveld.New(TEST_CODE_REVIEW_URL, nil))
 	server, serverAddress := goldingestion.RunGoldTrybotProcessor(t, TEST_TRACE_DB_FILE, TEST_SHAREDB_DIR, TEST_INGESTION_FILE, TEST_DATA_DIR, TEST_CODE_REVIEW_URL)
 	defer util.RemoveAll(TEST_SHAREDB_DIR)
 	defer testutils.Remove(t, TEST_TRACE_DB_FILE)
@@ -44,8 +45,8 @@ func TestTrybotResults(t *testing.T) {
 	assert.NoError(t, err)
 	defer func() { assert.NoError(t, ingestionStore.Close()) }()
 
-	tileBuilder := tracedb.NewBranchTileBuilder(db, nil, rietveldAPI, eventbus.New(nil))
-	tr := NewTrybotResults(tileBuilder, rietveldAPI, ingestionStore)
+	tileBuilder := tracedb.NewBranchTileBuilder(db, nil, review, eventbus.New(nil))
+	tr := NewTrybotResults(tileBuilder, review, ingestionStore)
 	tr.timeFrame = time.Now().Sub(BEGINNING_OF_TIME)
 
 	issues, total, err := tr.ListTrybotIssues(0, 20)
@@ -56,6 +56,10 @@ type GoogleStorageEventData struct {
 	Owner          map[string]string `json:"owner"`
 	Crc32C         string            `json:"crc32c"`
 	ETag           string            `json:"etag"`
+
+	// Seq is the sequence number of the event in grandcentral's event log,
+	// zero if it wasn't recorded.
+	Seq uint64 `json:"seq,omitempty"`
 }
 
 // BotBilter is a container for chainable filters for BuildBotEvents.
@@ -151,6 +155,10 @@ type BuildbotEventData struct {
 	Event   string                 `json:"event"`
 	Payload map[string]interface{} `json:"payload"`
 	Project string                 `json:"project"`
+
+	// Seq is the sequence number of the event in grandcentral's event log,
+	// zero if it wasn't recorded.
+	Seq uint64 `json:"seq,omitempty"`
 }
 
 // getStepName robustly extracts the step name if one is present in Payload.
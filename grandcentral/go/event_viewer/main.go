@@ -4,13 +4,16 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/eventbus"
 	"go.skia.org/infra/go/geventbus"
+	"go.skia.org/infra/go/httputils"
 	"go.skia.org/infra/go/skiaversion"
 	"go.skia.org/infra/grandcentral/go/event"
+	"go.skia.org/infra/grandcentral/go/eventlog"
 )
 
 // flags
@@ -20,6 +23,11 @@ var (
 	gsPrefix     = flag.String("gs_prefix", "dm-json-v1", "prefix to listen to for storage events.")
 	botEventType = flag.String("bot_event_type", "", "bot event type to filter for.")
 	botStepName  = flag.String("bot_step_name", "", "name of the step name we are interested in.")
+
+	grandcentralURL = flag.String("grandcentral_url", "https://grandcentral.skia.org", "The grandcentral server whose event log the history command browses.")
+	topic           = flag.String("topic", "", "The topic to show the history of. Lists the topics if empty.")
+	since           = flag.Uint64("since", 0, "Only show the events with a sequence number greater than this.")
+	limit           = flag.Int("limit", 100, "The most events to show.")
 )
 
 func init() {
@@ -27,7 +35,8 @@ func init() {
 		fmt.Fprintf(os.Stderr, "Usage: %s <command> \n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Valid Commands:\n")
 		fmt.Fprintf(os.Stderr, "   storage - Follow storage events.\n")
-		fmt.Fprintf(os.Stderr, "   bot     - Follow build bot events.\n\n")
+		fmt.Fprintf(os.Stderr, "   bot     - Follow build bot events.\n")
+		fmt.Fprintf(os.Stderr, "   history - Show past events from the event log of grandcentral.\n\n")
 		flag.PrintDefaults()
 	}
 }
@@ -50,6 +59,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	if args[0] == "history" {
+		if err := history(); err != nil {
+			glog.Fatal(err)
+		}
+		return
+	}
+
 	if *nsqdAddress == "" {
 		glog.Fatal("Missing address of nsqd server.")
 	}
@@ -79,3 +95,23 @@ func main() {
 
 	select {}
 }
+
+// history prints the events of --topic from the event log of grandcentral, or
+// the topics in the log if no topic is given.
+func history() error {
+	resp, err := eventlog.Replay(httputils.NewTimeoutClient(), *grandcentralURL, *topic, *since, *limit)
+	if err != nil {
+		return err
+	}
+	if *topic == "" {
+		fmt.Printf("Last sequence number: %d\nTopics:\n", resp.LastSeq)
+		for _, t := range resp.Topics {
+			fmt.Printf("  %s\n", t)
+		}
+		return nil
+	}
+	for _, e := range resp.Entries {
+		fmt.Printf("%d %s %s\n", e.Seq, e.Timestamp.Format(time.RFC3339), string(e.Data))
+	}
+	return nil
+}
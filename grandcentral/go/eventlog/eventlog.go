@@ -0,0 +1,263 @@
+// eventlog is a durable log of the events that grandcentral receives, so that
+// subscribers which were down when an event was published can replay it.
+//
+// Every event gets a sequence number which is unique across all topics and
+// grows over time. A subscriber remembers the sequence number of the last event
+// it handled and asks for the events of its topic since then after a restart:
+//
+//    resp, err := eventlog.Replay(client, "https://grandcentral.skia.org", event.GLOBAL_GOOGLE_STORAGE, lastSeq, 0)
+//
+// Events older than the retention period are removed by Trim. Replay returns
+// the oldest sequence number still in the log, so a subscriber can tell when
+// it was down for too long and has missed events.
+package eventlog
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	// DEFAULT_RETENTION is how long events are kept by default.
+	DEFAULT_RETENTION = 7 * 24 * time.Hour
+
+	// MAX_LIMIT is the most entries returned by a single call to Since.
+	MAX_LIMIT = 1000
+
+	// META_BUCKET holds the sequence counter. All other buckets are topics.
+	META_BUCKET = "_meta"
+
+	// LAST_SEQ_KEY is the key in META_BUCKET of the last sequence number.
+	LAST_SEQ_KEY = "last_seq"
+
+	// TRIMMED_PREFIX is the prefix of the keys in META_BUCKET that hold the
+	// sequence number of the last event of each topic removed by Trim.
+	TRIMMED_PREFIX = "trimmed:"
+)
+
+// Entry is a single event in the log.
+type Entry struct {
+	Seq       uint64          `json:"seq"`
+	Topic     string          `json:"topic"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Decode unmarshals the data of the event into v.
+func (e *Entry) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// EventLog stores the events in a BoltDB file with one bucket per topic, keyed
+// by sequence number.
+type EventLog struct {
+	db        *bolt.DB
+	retention time.Duration
+}
+
+// New opens or creates the event log in the given file. Events older than
+// retention are removed by Trim.
+func New(filename string, retention time.Duration) (*EventLog, error) {
+	db, err := bolt.Open(filename, 0644, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open event log %s: %s", filename, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(META_BUCKET))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize event log: %s", err)
+	}
+	return &EventLog{
+		db:        db,
+		retention: retention,
+	}, nil
+}
+
+// Close closes the underlying database.
+func (l *EventLog) Close() error {
+	return l.db.Close()
+}
+
+// seqKey returns the key of a sequence number, which sorts like the number.
+func seqKey(seq uint64) []byte {
+	ret := make([]byte, 8)
+	binary.BigEndian.PutUint64(ret, seq)
+	return ret
+}
+
+// Append adds the JSON encoding of data to the log under the given topic, and
+// returns the new Entry.
+func (l *EventLog) Append(topic string, data interface{}) (*Entry, error) {
+	entries, err := l.AppendAll(topic, []interface{}{data})
+	if err != nil {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// AppendAll adds the JSON encoding of every item of data to the log under the
+// given topic in a single transaction, so either all or none of them are
+// logged. It returns the new entries in the same order.
+func (l *EventLog) AppendAll(topic string, data []interface{}) ([]*Entry, error) {
+	if topic == "" || topic == META_BUCKET {
+		return nil, fmt.Errorf("Invalid topic %q", topic)
+	}
+	entries := make([]*Entry, 0, len(data))
+	for _, d := range data {
+		b, err := json.Marshal(d)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode event: %s", err)
+		}
+		entries = append(entries, &Entry{
+			Topic: topic,
+			Data:  json.RawMessage(b),
+		})
+	}
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket([]byte(META_BUCKET))
+		bucket, err := tx.CreateBucketIfNotExists([]byte(topic))
+		if err != nil {
+			return err
+		}
+		// Take the timestamp inside the transaction so that the entries are
+		// in the order of their timestamps, which Trim relies on.
+		now := time.Now().UTC()
+		for _, entry := range entries {
+			seq, err := meta.NextSequence()
+			if err != nil {
+				return err
+			}
+			entry.Seq = seq
+			entry.Timestamp = now
+			value, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(seqKey(seq), value); err != nil {
+				return err
+			}
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return meta.Put([]byte(LAST_SEQ_KEY), seqKey(entries[len(entries)-1].Seq))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to append events: %s", err)
+	}
+	return entries, nil
+}
+
+// Since returns up to limit entries of the topic with a sequence number
+// greater than seq, oldest first. A limit of zero, or one above MAX_LIMIT,
+// means MAX_LIMIT.
+//
+// It also returns the oldest sequence number of the topic that is still
+// retained. The events of the topic before it have been removed by Trim, so
+// if seq+1 is smaller then the caller has missed events.
+func (l *EventLog) Since(topic string, seq uint64, limit int) ([]*Entry, uint64, error) {
+	if limit <= 0 || limit > MAX_LIMIT {
+		limit = MAX_LIMIT
+	}
+	ret := []*Entry{}
+	var firstSeq uint64 = 1
+	err := l.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(topic))
+		if bucket == nil || topic == META_BUCKET {
+			return nil
+		}
+		if v := tx.Bucket([]byte(META_BUCKET)).Get([]byte(TRIMMED_PREFIX + topic)); v != nil {
+			firstSeq = binary.BigEndian.Uint64(v) + 1
+		}
+		c := bucket.Cursor()
+		for k, v := c.Seek(seqKey(seq + 1)); k != nil && len(ret) < limit; k, v = c.Next() {
+			entry := &Entry{}
+			if err := json.Unmarshal(v, entry); err != nil {
+				return fmt.Errorf("Corrupt entry %d: %s", binary.BigEndian.Uint64(k), err)
+			}
+			ret = append(ret, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("Failed to read events: %s", err)
+	}
+	return ret, firstSeq, nil
+}
+
+// Topics returns the sorted names of the topics that have been logged.
+func (l *EventLog) Topics() ([]string, error) {
+	ret := []string{}
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if string(name) != META_BUCKET {
+				ret = append(ret, string(name))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read topics: %s", err)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// LastSeq returns the sequence number of the most recent event of any topic,
+// or zero if nothing has been logged.
+func (l *EventLog) LastSeq() (uint64, error) {
+	var ret uint64
+	err := l.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte(META_BUCKET)).Get([]byte(LAST_SEQ_KEY)); v != nil {
+			ret = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// Trim removes the events that are older than the retention period at now,
+// and returns how many were removed. The sequence number of the last removed
+// event of each topic is kept, see Since.
+func (l *EventLog) Trim(now time.Time) (int, error) {
+	cutoff := now.Add(-l.retention)
+	removed := 0
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket([]byte(META_BUCKET))
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			if string(name) == META_BUCKET {
+				return nil
+			}
+			// Entries are in the order they were appended, so stop at the
+			// first one that is recent enough.
+			var last []byte
+			c := bucket.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.First() {
+				entry := &Entry{}
+				if err := json.Unmarshal(v, entry); err == nil && !entry.Timestamp.Before(cutoff) {
+					break
+				}
+				last = append([]byte{}, k...)
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				removed++
+			}
+			if last == nil {
+				return nil
+			}
+			return meta.Put(append([]byte(TRIMMED_PREFIX), name...), last)
+		})
+	})
+	if err != nil {
+		return removed, fmt.Errorf("Failed to trim event log: %s", err)
+	}
+	return removed, nil
+}
@@ -0,0 +1,186 @@
+package eventlog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+type testEvent struct {
+	Name string `json:"name"`
+}
+
+func newLog(t *testing.T) (*EventLog, string) {
+	dir, err := ioutil.TempDir("", "eventlog")
+	assert.NoError(t, err)
+	l, err := New(filepath.Join(dir, "events.db"), time.Hour)
+	assert.NoError(t, err)
+	return l, dir
+}
+
+func TestEventLog(t *testing.T) {
+	l, dir := newLog(t)
+	defer testutils.RemoveAll(t, dir)
+	defer testutils.CloseInTest(t, l)
+
+	seq, err := l.LastSeq()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), seq)
+
+	for i := 0; i < 3; i++ {
+		_, err := l.Append("storage", &testEvent{Name: fmt.Sprintf("s%d", i)})
+		assert.NoError(t, err)
+		_, err = l.Append("buildbot", &testEvent{Name: fmt.Sprintf("b%d", i)})
+		assert.NoError(t, err)
+	}
+	_, err = l.Append(META_BUCKET, &testEvent{})
+	assert.Error(t, err)
+
+	topics, err := l.Topics()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"buildbot", "storage"}, topics)
+	seq, err = l.LastSeq()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), seq)
+
+	// Sequence numbers are shared by all topics.
+	entries, firstSeq, err := l.Since("storage", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), firstSeq)
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, []uint64{1, 3, 5}, []uint64{entries[0].Seq, entries[1].Seq, entries[2].Seq})
+	e := &testEvent{}
+	assert.NoError(t, entries[2].Decode(e))
+	assert.Equal(t, "s2", e.Name)
+
+	entries, _, err = l.Since("storage", 3, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, uint64(5), entries[0].Seq)
+	entries, _, err = l.Since("buildbot", 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	entries, _, err = l.Since("unknown", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	// Only events older than the retention are removed.
+	n, err := l.Trim(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = l.Trim(time.Now().Add(2 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+	entries, firstSeq, err = l.Since("storage", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+	// The oldest retained event of each topic follows its last removed one.
+	assert.Equal(t, uint64(6), firstSeq)
+	_, firstSeq, err = l.Since("buildbot", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), firstSeq)
+
+	// Sequence numbers keep growing after a trim.
+	entry, err := l.Append("storage", &testEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), entry.Seq)
+
+	// A batch is appended in a single transaction.
+	batch, err := l.AppendAll("buildbot", []interface{}{&testEvent{Name: "x"}, &testEvent{Name: "y"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{8, 9}, []uint64{batch[0].Seq, batch[1].Seq})
+	entries, firstSeq, err = l.Since("buildbot", 6, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), firstSeq)
+	assert.Equal(t, 2, len(entries))
+	assert.NoError(t, entries[1].Decode(e))
+	assert.Equal(t, "y", e.Name)
+	seq, err = l.LastSeq()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(9), seq)
+	_, err = l.AppendAll(META_BUCKET, []interface{}{&testEvent{}})
+	assert.Error(t, err)
+}
+
+func TestReplayAndFollow(t *testing.T) {
+	l, dir := newLog(t)
+	defer testutils.RemoveAll(t, dir)
+	defer testutils.CloseInTest(t, l)
+	ts := httptest.NewServer(http.HandlerFunc(l.ReplayHandler))
+	defer ts.Close()
+
+	_, err := l.Append("storage", &testEvent{Name: "old"})
+	assert.NoError(t, err)
+
+	resp, err := Replay(http.DefaultClient, ts.URL, "storage", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(resp.Entries))
+	assert.Equal(t, uint64(1), resp.LastSeq)
+	assert.Equal(t, []string{"storage"}, resp.Topics)
+
+	// A new Follower skips the events from before it started.
+	filename := filepath.Join(dir, "seq")
+	f, err := NewFollower(http.DefaultClient, ts.URL, "storage", filename)
+	assert.NoError(t, err)
+	names := []string{}
+	handle := func(e *Entry) error {
+		ev := &testEvent{}
+		assert.NoError(t, e.Decode(ev))
+		if ev.Name == "bad" {
+			return fmt.Errorf("Can't handle it")
+		}
+		names = append(names, ev.Name)
+		return nil
+	}
+	assert.NoError(t, f.Step(handle))
+	assert.Equal(t, []string{}, names)
+
+	for _, name := range []string{"a", "b", "bad", "c"} {
+		_, err := l.Append("storage", &testEvent{Name: name})
+		assert.NoError(t, err)
+	}
+	_, err = l.Append("buildbot", &testEvent{Name: "other"})
+	assert.NoError(t, err)
+
+	// Handling stops at the first failure, and a restarted Follower continues
+	// from there.
+	assert.Error(t, f.Step(handle))
+	assert.Equal(t, []string{"a", "b"}, names)
+	f, err = NewFollower(http.DefaultClient, ts.URL, "storage", filename)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), f.Seq())
+	assert.Error(t, f.Step(handle))
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	names = []string{}
+	assert.NoError(t, f.Step(func(e *Entry) error {
+		names = append(names, fmt.Sprintf("%d", e.Seq))
+		return nil
+	}))
+	assert.Equal(t, []string{"4", "5"}, names)
+	assert.Equal(t, uint64(5), f.Seq())
+
+	// A Follower that was down for longer than the retention skips the
+	// removed events, and the response says which ones they were.
+	_, err = l.Append("storage", &testEvent{Name: "d"})
+	assert.NoError(t, err)
+	_, err = l.Trim(time.Now().Add(2 * time.Hour))
+	assert.NoError(t, err)
+	_, err = l.Append("storage", &testEvent{Name: "e"})
+	assert.NoError(t, err)
+	resp, err = Replay(http.DefaultClient, ts.URL, "storage", 5, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), resp.FirstSeq)
+	assert.Equal(t, 1, len(resp.Entries))
+	names = []string{}
+	assert.NoError(t, f.Step(handle))
+	assert.Equal(t, []string{"e"}, names)
+	assert.Equal(t, uint64(8), f.Seq())
+}
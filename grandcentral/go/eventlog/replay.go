@@ -0,0 +1,200 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/util"
+)
+
+// REPLAY_PATH is the path of the replay endpoint on the grandcentral server.
+const REPLAY_PATH = "/json/events"
+
+// ReplayResponse is the JSON returned by the replay endpoint.
+type ReplayResponse struct {
+	// Entries are the requested entries, oldest first.
+	Entries []*Entry `json:"entries"`
+
+	// FirstSeq is the oldest sequence number of the topic that is still in
+	// the log, see EventLog.Since. If it is greater than since+1 then events
+	// were removed before they could be replayed.
+	FirstSeq uint64 `json:"firstSeq"`
+
+	// LastSeq is the sequence number of the most recent event of any topic.
+	LastSeq uint64 `json:"lastSeq"`
+
+	// Topics are all the topics in the log.
+	Topics []string `json:"topics"`
+}
+
+// ReplayHandler serves the entries of the log as a ReplayResponse. The
+// 'topic', 'since' and 'limit' query parameters are passed to Since. Without a
+// topic no entries are returned, which is useful to find the topics and the
+// current sequence number.
+func (l *EventLog) ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	var limit int
+	var err error
+	if s := r.FormValue("since"); s != "" {
+		if since, err = strconv.ParseUint(s, 10, 64); err != nil {
+			httputils.ReportError(w, r, err, "Invalid value for 'since'.")
+			return
+		}
+	}
+	if s := r.FormValue("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil {
+			httputils.ReportError(w, r, err, "Invalid value for 'limit'.")
+			return
+		}
+	}
+	resp := &ReplayResponse{Entries: []*Entry{}}
+	if topic := r.FormValue("topic"); topic != "" {
+		if resp.Entries, resp.FirstSeq, err = l.Since(topic, since, limit); err != nil {
+			httputils.ReportError(w, r, err, "Failed to read events.")
+			return
+		}
+	}
+	if resp.LastSeq, err = l.LastSeq(); err != nil {
+		httputils.ReportError(w, r, err, "Failed to read the last sequence number.")
+		return
+	}
+	if resp.Topics, err = l.Topics(); err != nil {
+		httputils.ReportError(w, r, err, "Failed to read topics.")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		glog.Errorf("Failed to write or encode output: %s", err)
+	}
+}
+
+// Replay asks the grandcentral server at serverURL for up to limit entries of
+// the topic with sequence numbers greater than since. See ReplayHandler.
+func Replay(c *http.Client, serverURL, topic string, since uint64, limit int) (*ReplayResponse, error) {
+	q := url.Values{}
+	q.Set("topic", topic)
+	q.Set("since", strconv.FormatUint(since, 10))
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	resp, err := c.Get(strings.TrimRight(serverURL, "/") + REPLAY_PATH + "?" + q.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request events: %s", err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to request events: %s", resp.Status)
+	}
+	ret := &ReplayResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(ret); err != nil {
+		return nil, fmt.Errorf("Failed to decode events: %s", err)
+	}
+	return ret, nil
+}
+
+// Follower hands every event of a topic to a func exactly once, even across
+// restarts, by keeping the sequence number of the last handled event in a
+// file.
+type Follower struct {
+	client    *http.Client
+	serverURL string
+	topic     string
+	filename  string
+	seq       uint64
+	started   bool
+}
+
+// NewFollower creates a new Follower of the topic on the grandcentral server
+// at serverURL, which keeps its position in filename. If filename doesn't
+// exist yet the Follower starts with the events published after its first
+// Step.
+func NewFollower(c *http.Client, serverURL, topic, filename string) (*Follower, error) {
+	f := &Follower{
+		client:    c,
+		serverURL: serverURL,
+		topic:     topic,
+		filename:  filename,
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err == nil {
+		if f.seq, err = strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid sequence number in %s: %s", filename, err)
+		}
+		f.started = true
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Failed to read %s: %s", filename, err)
+	}
+	return f, nil
+}
+
+// Seq returns the sequence number of the last handled event.
+func (f *Follower) Seq() uint64 {
+	return f.seq
+}
+
+// save writes the current sequence number to the file.
+func (f *Follower) save() error {
+	tmpName := filepath.Join(filepath.Dir(f.filename), "."+filepath.Base(f.filename)+".tmp")
+	if err := ioutil.WriteFile(tmpName, []byte(strconv.FormatUint(f.seq, 10)), 0644); err != nil {
+		return fmt.Errorf("Failed to save sequence number: %s", err)
+	}
+	return os.Rename(tmpName, f.filename)
+}
+
+// Step calls fn for every event since the last handled one, oldest first. It
+// stops at the first event for which fn returns an error, which is retried on
+// the next Step.
+func (f *Follower) Step(fn func(e *Entry) error) error {
+	for {
+		resp, err := Replay(f.client, f.serverURL, f.topic, f.seq, 0)
+		if err != nil {
+			return err
+		}
+		if !f.started {
+			f.seq = resp.LastSeq
+			f.started = true
+			return f.save()
+		}
+		if resp.FirstSeq > f.seq+1 {
+			glog.Errorf("Events %d to %d of %s were removed from the log before they were handled.", f.seq+1, resp.FirstSeq-1, f.topic)
+			f.seq = resp.FirstSeq - 1
+			if err := f.save(); err != nil {
+				return err
+			}
+		}
+		if len(resp.Entries) == 0 {
+			return nil
+		}
+		for _, e := range resp.Entries {
+			if err := fn(e); err != nil {
+				if saveErr := f.save(); saveErr != nil {
+					glog.Errorf("%s", saveErr)
+				}
+				return fmt.Errorf("Failed to handle event %d: %s", e.Seq, err)
+			}
+			f.seq = e.Seq
+		}
+		if err := f.save(); err != nil {
+			return err
+		}
+	}
+}
+
+// Run calls Step every period, forever.
+func (f *Follower) Run(period time.Duration, fn func(e *Entry) error) {
+	for _ = range time.Tick(period) {
+		if err := f.Step(fn); err != nil {
+			glog.Errorf("Failed to follow %s: %s", f.topic, err)
+		}
+	}
+}
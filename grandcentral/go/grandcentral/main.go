@@ -10,17 +10,21 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/eventbus"
+	"go.skia.org/infra/go/fileutil"
 	"go.skia.org/infra/go/geventbus"
 	"go.skia.org/infra/go/httputils"
 	"go.skia.org/infra/go/influxdb"
 	"go.skia.org/infra/go/skiaversion"
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/grandcentral/go/event"
+	"go.skia.org/infra/grandcentral/go/eventlog"
 )
 
 // flags
@@ -32,13 +36,19 @@ var (
 	workdir     = flag.String("workdir", ".", "Directory to use for scratch work.")
 	nsqdAddress = flag.String("nsqd", "", "Address and port of nsqd instance.")
 
+	eventLogFile   = flag.String("event_log", "", "The file of the event log. Defaults to events.db in workdir.")
+	eventRetention = flag.Duration("event_retention", eventlog.DEFAULT_RETENTION, "How long events are kept in the event log.")
+
 	influxHost     = flag.String("influxdb_host", influxdb.DEFAULT_HOST, "The InfluxDB hostname.")
 	influxUser     = flag.String("influxdb_name", influxdb.DEFAULT_USER, "The InfluxDB username.")
 	influxPassword = flag.String("influxdb_password", influxdb.DEFAULT_PASSWORD, "The InfluxDB password.")
 	influxDatabase = flag.String("influxdb_database", influxdb.DEFAULT_DATABASE, "The InfluxDB database.")
 )
 
-var eventBus *eventbus.EventBus
+var (
+	eventBus *eventbus.EventBus
+	eventLog *eventlog.EventLog
+)
 
 func mainHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err := w.Write([]byte(`
@@ -66,8 +76,15 @@ func googleStorageChangeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Log the result and fire an event.
+	// Log the result, record it and fire an event. If it can't be recorded
+	// we fail, so the sender retries.
 	glog.Infof("Google Storage notification from bucket \"%s\": %s", data.Bucket, data.Name)
+	entry, err := eventLog.Append(event.GLOBAL_GOOGLE_STORAGE, data)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to record event.")
+		return
+	}
+	data.Seq = entry.Seq
 	eventBus.Publish(event.GLOBAL_GOOGLE_STORAGE, data)
 }
 
@@ -80,17 +97,41 @@ func buildbotEventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Log the event and fire it.
+	// Record all the events before firing any of them, so a failed request
+	// can be retried without publishing events twice.
+	items := make([]interface{}, 0, len(events))
 	for _, e := range events {
+		items = append(items, e)
+	}
+	entries, err := eventLog.AppendAll(event.GLOBAL_BUILDBOT, items)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to record events.")
+		return
+	}
+	for i, e := range events {
+		e.Seq = entries[i].Seq
 		eventBus.Publish(event.GLOBAL_BUILDBOT, e)
 	}
 }
 
+// trimEventLog removes old events from the event log every hour.
+func trimEventLog() {
+	for _ = range time.Tick(time.Hour) {
+		n, err := eventLog.Trim(time.Now())
+		if err != nil {
+			glog.Errorf("Failed to trim event log: %s", err)
+			continue
+		}
+		glog.Infof("Removed %d old events from the event log.", n)
+	}
+}
+
 func runServer(serverURL string) {
 	r := mux.NewRouter()
 	r.HandleFunc("/", mainHandler)
 	r.HandleFunc("/googlestorage", googleStorageChangeHandler).Methods("POST")
 	r.HandleFunc("/buildbot", buildbotEventHandler).Methods("POST")
+	r.HandleFunc(eventlog.REPLAY_PATH, eventLog.ReplayHandler).Methods("GET")
 	r.HandleFunc("/json/version", skiaversion.JsonHandler)
 	http.Handle("/", httputils.LoggingGzipRequestResponse(r))
 	glog.Infof("Ready to serve on %s", serverURL)
@@ -115,6 +156,18 @@ func main() {
 	}
 	eventBus = eventbus.New(globalEventBus)
 
+	if *eventLogFile == "" {
+		*eventLogFile = filepath.Join(*workdir, "events.db")
+	}
+	if _, err := fileutil.EnsureDirExists(filepath.Dir(*eventLogFile)); err != nil {
+		glog.Fatalf("Unable to create event log directory: %s", err)
+	}
+	eventLog, err = eventlog.New(*eventLogFile, *eventRetention)
+	if err != nil {
+		glog.Fatalf("Unable to open event log: %s", err)
+	}
+	go trimEventLog()
+
 	// Add a subscription for the each event type. This prevents the messages
 	// to queue up if there are no other clients connected.
 	eventBus.SubscribeAsync(event.GLOBAL_GOOGLE_STORAGE, func(evData interface{}) {})
@@ -3,30 +3,171 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/BurntSushi/toml"
+	"go.skia.org/infra/go/sklog"
 )
 
+// DEFAULT_TAG is the tag GroupBy values are reported under if Tag isn't set.
+const DEFAULT_TAG = "group"
+
 // Metric is used to parse the toml entries in metrics.cfg files.
 type Metric struct {
 	// Name is the measurement name.
 	Name string
 
-	// Filter is the Google Logging V2 query string to execute.
+	// Filter is the Google Logging V2 query string to execute. When reading
+	// local log files it is instead a regular expression that the payload of
+	// a log entry must match, and an empty Filter matches every entry.
 	Filter string
+
+	// Severity, if set, only counts local log entries of that severity, such
+	// as "ERROR".
+	Severity string
+
+	// Log, if set, only counts local log entries from the Log with that name.
+	Log string
+
+	// GroupBy, if set, is a regular expression with one capturing group which
+	// is matched against the text payload of each log entry. The entries are
+	// counted separately for each value of the group, and the counts are
+	// reported with the value in the tag named Tag. Entries that GroupBy
+	// doesn't match aren't counted. For example, to count errors by bot:
+	//
+	//    groupBy = "bot_id=(\\S+)"
+	//    tag = "bot"
+	//
+	GroupBy string
+
+	// Tag is the name of the tag that holds the GroupBy value, DEFAULT_TAG if
+	// empty.
+	Tag string
+
+	filter  *regexp.Regexp
+	groupBy *regexp.Regexp
 }
 
-// ReadMetrics loads the toml file at the given location.
-func ReadMetrics(filename string) ([]Metric, error) {
-	var m struct {
-		Metrics []Metric
+// compile checks the regular expressions of the metric and fills in
+// defaults. The Filter is only compiled if local is true.
+func (m *Metric) compile(local bool) error {
+	if m.Name == "" {
+		return fmt.Errorf("Metric is missing a name.")
+	}
+	if m.Tag == "" {
+		m.Tag = DEFAULT_TAG
+	}
+	if local {
+		var err error
+		if m.filter, err = regexp.Compile(m.Filter); err != nil {
+			return fmt.Errorf("Invalid filter for metric %q: %s", m.Name, err)
+		}
+	}
+	if m.GroupBy != "" {
+		var err error
+		if m.groupBy, err = regexp.Compile(m.GroupBy); err != nil {
+			return fmt.Errorf("Invalid groupBy for metric %q: %s", m.Name, err)
+		}
+		if m.groupBy.NumSubexp() != 1 {
+			return fmt.Errorf("The groupBy of metric %q must have exactly one capturing group.", m.Name)
+		}
+	}
+	return nil
+}
+
+// Grouped returns true if the metric is counted by the value of GroupBy.
+func (m *Metric) Grouped() bool {
+	return m.GroupBy != ""
+}
+
+// Group returns the GroupBy value of the payload, or false if the entry
+// shouldn't be counted. It always returns "", true if GroupBy isn't set.
+func (m *Metric) Group(payload string) (string, bool) {
+	if m.groupBy == nil {
+		return "", true
+	}
+	match := m.groupBy.FindStringSubmatch(payload)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// Matches returns true if the local log entry from the named Log passes the
+// Filter, Severity and Log of the metric.
+func (m *Metric) Matches(log string, entry *sklog.LogPayload) bool {
+	if m.Log != "" && m.Log != log {
+		return false
+	}
+	if m.Severity != "" && m.Severity != entry.Severity {
+		return false
 	}
-	_, err := toml.DecodeFile(filename, &m)
+	return m.filter == nil || m.filter.MatchString(entry.Payload)
+}
+
+// Log is a set of local log files to read.
+type Log struct {
+	// Name identifies the log, see Metric.Log. It is also used to keep track
+	// of how much of the files has been read, so it must be unique.
+	Name string
+
+	// Format is the name of the format of the log files, such as "glog".
+	Format string
+
+	// Pattern is a filepath.Glob pattern of the log files.
+	Pattern string
+}
+
+// Config is the contents of a metrics.cfg file.
+type Config struct {
+	Metrics []Metric
+
+	// Logs are the local log files to read. If there are any, the metrics are
+	// counted from them instead of from Google Logging.
+	Logs []Log
+}
+
+// Local returns true if the metrics are counted from local log files.
+func (c *Config) Local() bool {
+	return len(c.Logs) > 0
+}
+
+// ReadConfig loads the toml file at the given location.
+func ReadConfig(filename string) (*Config, error) {
+	c := &Config{}
+	_, err := toml.DecodeFile(filename, c)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to decode metrics file %q: %s", filename, err)
 	}
-	if len(m.Metrics) == 0 {
+	if len(c.Metrics) == 0 {
 		return nil, fmt.Errorf("Didn't find any metrics in the file %q", filename)
 	}
-	return m.Metrics, nil
+	names := map[string]bool{}
+	for _, l := range c.Logs {
+		if l.Name == "" || l.Format == "" || l.Pattern == "" {
+			return nil, fmt.Errorf("Every log in %q needs a name, format and pattern.", filename)
+		}
+		if names[l.Name] {
+			return nil, fmt.Errorf("Duplicate log name %q in %q", l.Name, filename)
+		}
+		names[l.Name] = true
+	}
+	for i := range c.Metrics {
+		if err := c.Metrics[i].compile(c.Local()); err != nil {
+			return nil, err
+		}
+		if l := c.Metrics[i].Log; l != "" && !names[l] {
+			return nil, fmt.Errorf("Metric %q refers to unknown log %q", c.Metrics[i].Name, l)
+		}
+	}
+	return c, nil
+}
+
+// ReadMetrics loads the metrics of the toml file at the given location.
+func ReadMetrics(filename string) ([]Metric, error) {
+	c, err := ReadConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	return c.Metrics, nil
 }
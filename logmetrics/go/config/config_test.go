@@ -1,10 +1,13 @@
 package config
 
 import (
+	"io/ioutil"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/testutils"
 )
 
 func TestConfigRead(t *testing.T) {
@@ -14,3 +17,51 @@ func TestConfigRead(t *testing.T) {
 	assert.Equal(t, "qps", m[0].Name)
 	assert.Equal(t, "fiddle-sec-violations", m[1].Name)
 }
+
+func TestConfigReadLocal(t *testing.T) {
+	c, err := ReadConfig(filepath.Join("./testdata", "local.cfg"))
+	assert.NoError(t, err)
+	assert.True(t, c.Local())
+	assert.Equal(t, []Log{{Name: "skolo", Format: "glog", Pattern: "/var/log/logserver/*.INFO.*"}}, c.Logs)
+	assert.Equal(t, 2, len(c.Metrics))
+
+	m := c.Metrics[0]
+	assert.True(t, m.Grouped())
+	assert.Equal(t, "bot", m.Tag)
+	group, ok := m.Group("Task failed on bot_id=skia-rpi-001 after 3s")
+	assert.True(t, ok)
+	assert.Equal(t, "skia-rpi-001", group)
+	_, ok = m.Group("Task failed")
+	assert.False(t, ok)
+	assert.True(t, m.Matches("skolo", &sklog.LogPayload{Payload: "bot_id=a", Severity: sklog.ERROR}))
+	assert.False(t, m.Matches("skolo", &sklog.LogPayload{Payload: "bot_id=a", Severity: sklog.INFO}))
+
+	m = c.Metrics[1]
+	assert.False(t, m.Grouped())
+	assert.Equal(t, DEFAULT_TAG, m.Tag)
+	group, ok = m.Group("anything")
+	assert.True(t, ok)
+	assert.Equal(t, "", group)
+	assert.True(t, m.Matches("skolo", &sklog.LogPayload{Payload: "Starting up logserver"}))
+	assert.False(t, m.Matches("skolo", &sklog.LogPayload{Payload: "Shutting down"}))
+	assert.False(t, m.Matches("other", &sklog.LogPayload{Payload: "Starting up logserver"}))
+}
+
+func TestConfigReadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmetrics")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	filename := filepath.Join(dir, "metrics.cfg")
+	for _, cfg := range []string{
+		// No capturing group.
+		"[[metrics]]\nname = \"a\"\ngroupBy = \"bot\"\n",
+		// Unknown log.
+		"[[metrics]]\nname = \"a\"\nlog = \"b\"\n",
+		// Invalid local filter.
+		"[[logs]]\nname = \"a\"\nformat = \"glog\"\npattern = \"*\"\n[[metrics]]\nname = \"a\"\nfilter = \"(\"\n",
+	} {
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(cfg), 0644))
+		_, err := ReadConfig(filename)
+		assert.Error(t, err, cfg)
+	}
+}
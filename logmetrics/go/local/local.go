@@ -0,0 +1,83 @@
+// local counts the metrics of logmetrics from log files on the local disk,
+// for machines whose logs don't go to Google Logging.
+package local
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/logmetrics/go/config"
+	"go.skia.org/infra/skolo/go/logagents"
+	"go.skia.org/infra/skolo/go/logparser"
+)
+
+// Counter is a sklog.CloudLogger which counts the log entries it is sent that
+// match each metric. Give it to the Scan of the LogScanners of the local logs
+// instead of a real CloudLogger.
+type Counter struct {
+	metrics []config.Metric
+
+	mutex sync.Mutex
+	// counts maps metric names to GroupBy values to counts.
+	counts map[string]map[string]int64
+}
+
+// NewCounter returns a new Counter for the given metrics.
+func NewCounter(metrics []config.Metric) *Counter {
+	c := &Counter{metrics: metrics}
+	c.Reset()
+	return c
+}
+
+// CloudLog implements sklog.CloudLogger.
+func (c *Counter) CloudLog(reportName string, payload *sklog.LogPayload) {
+	c.BatchCloudLog(reportName, payload)
+}
+
+// BatchCloudLog implements sklog.CloudLogger.
+func (c *Counter) BatchCloudLog(reportName string, payloads ...*sklog.LogPayload) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, p := range payloads {
+		for i := range c.metrics {
+			m := &c.metrics[i]
+			if !m.Matches(reportName, p) {
+				continue
+			}
+			if group, ok := m.Group(p.Payload); ok {
+				c.counts[m.Name][group]++
+			}
+		}
+	}
+}
+
+// Reset returns the counts since the last call to Reset, keyed by metric name
+// and then by GroupBy value, and starts counting from zero again. Metrics
+// without a GroupBy have their count under "".
+func (c *Counter) Reset() map[string]map[string]int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ret := c.counts
+	c.counts = map[string]map[string]int64{}
+	for _, m := range c.metrics {
+		c.counts[m.Name] = map[string]int64{}
+	}
+	return ret
+}
+
+// NewScanners returns a LogScanner for each of the logs. Like all
+// LogScanners they keep track of how much they have read in the directory
+// set by logagents.SetPersistenceDir.
+func NewScanners(logs []config.Log) ([]logagents.LogScanner, error) {
+	ret := []logagents.LogScanner{}
+	for _, l := range logs {
+		parse, ok := logparser.Formats[l.Format]
+		if !ok {
+			return nil, fmt.Errorf("Unknown log format %q for log %q, must be one of %s", l.Format, l.Name, strings.Join(logparser.FormatNames(), ", "))
+		}
+		ret = append(ret, logagents.NewGlobScanner(parse, l.Name, l.Pattern))
+	}
+	return ret, nil
+}
@@ -0,0 +1,97 @@
+package local
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/logmetrics/go/config"
+	"go.skia.org/infra/skolo/go/logagents"
+)
+
+const CONFIG = `
+[[logs]]
+name = "bots"
+format = "glog"
+pattern = "%s"
+
+[[metrics]]
+name = "errors-by-bot"
+severity = "ERROR"
+groupBy = "bot_id=(\\S+)"
+tag = "bot"
+
+[[metrics]]
+name = "tasks"
+filter = "Task"
+`
+
+func readConfig(t *testing.T, dir string) *config.Config {
+	filename := filepath.Join(dir, "metrics.cfg")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(fmt.Sprintf(CONFIG, filepath.Join(dir, "*.log"))), 0644))
+	c, err := config.ReadConfig(filename)
+	assert.NoError(t, err)
+	return c
+}
+
+func TestCounter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmetrics")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	c := NewCounter(readConfig(t, dir).Metrics)
+
+	c.BatchCloudLog("bots",
+		&sklog.LogPayload{Payload: "Task failed bot_id=a", Severity: sklog.ERROR},
+		&sklog.LogPayload{Payload: "Task failed bot_id=b", Severity: sklog.ERROR},
+		&sklog.LogPayload{Payload: "Task failed bot_id=a", Severity: sklog.ERROR},
+		&sklog.LogPayload{Payload: "Task done bot_id=a", Severity: sklog.INFO},
+		&sklog.LogPayload{Payload: "No bot", Severity: sklog.ERROR},
+	)
+	c.CloudLog("bots", &sklog.LogPayload{Payload: "Task done", Severity: sklog.INFO})
+	assert.Equal(t, map[string]map[string]int64{
+		"errors-by-bot": {"a": 2, "b": 1},
+		"tasks":         {"": 5},
+	}, c.Reset())
+	assert.Equal(t, map[string]map[string]int64{
+		"errors-by-bot": {},
+		"tasks":         {},
+	}, c.Reset())
+}
+
+func TestScanners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmetrics")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	assert.NoError(t, logagents.SetPersistenceDir(filepath.Join(dir, "persistence")))
+	cfg := readConfig(t, dir)
+
+	_, err = NewScanners([]config.Log{{Name: "bad", Format: "unknown", Pattern: "*"}})
+	assert.Error(t, err)
+
+	scanners, err := NewScanners(cfg.Logs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(scanners))
+	c := NewCounter(cfg.Metrics)
+	assert.NoError(t, scanners[0].Scan(c))
+
+	// Files created after the first scan are read from the start. The last
+	// entry is held back until the next scan.
+	log := "E0601 12:00:00.000000  1234 bot.go:10] Task failed bot_id=skia-rpi-001\n" +
+		"E0601 12:00:01.000000  1234 bot.go:10] Task failed bot_id=skia-rpi-002\n" +
+		"I0601 12:00:02.000000  1234 bot.go:12] Task done bot_id=skia-rpi-001\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bot.log"), []byte(log), 0644))
+	assert.NoError(t, scanners[0].Scan(c))
+	assert.Equal(t, map[string]map[string]int64{
+		"errors-by-bot": {"skia-rpi-001": 1, "skia-rpi-002": 1},
+		"tasks":         {"": 2},
+	}, c.Reset())
+	assert.NoError(t, scanners[0].Scan(c))
+	assert.Equal(t, int64(1), c.Reset()["tasks"][""])
+	_, err = os.Stat(filepath.Join(dir, "persistence", "bots"))
+	assert.NoError(t, err)
+}
@@ -1,5 +1,5 @@
-// logmetrics runs queries over all the data store in Google Logging and then
-// pushes those counts into influxdb.
+// logmetrics runs queries over all the data store in Google Logging, or over
+// log files on the local disk, and then pushes those counts into influxdb.
 package main
 
 import (
@@ -13,7 +13,10 @@ import (
 	"go.skia.org/infra/go/influxdb"
 	"go.skia.org/infra/go/metrics2"
 	"go.skia.org/infra/logmetrics/go/config"
+	localmetrics "go.skia.org/infra/logmetrics/go/local"
+	"go.skia.org/infra/skolo/go/logagents"
 	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/logging/v2beta1"
 )
 
@@ -24,12 +27,41 @@ var (
 	influxUser      = flag.String("influxdb_name", influxdb.DEFAULT_USER, "The InfluxDB username.")
 	local           = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
 	metricsFilename = flag.String("metrics_filename", "metrics.toml", "The file with all the metrics and their filters.")
+	persistenceDir  = flag.String("persistence_dir", "/var/logmetrics", "The directory in which to keep track of how much of the local log files has been read.")
 	validateOnly    = flag.Bool("validate_only", false, "Exits after successfully reading the config file.")
 
 	loggingService *logging.Service
 	metrics        []config.Metric
+
+	// reported maps metric names to the GroupBy values that have been
+	// reported, so they can be reported as zero once they stop showing up.
+	reported = map[string]map[string]bool{}
 )
 
+// report pushes the counts of a metric over the last minute, keyed by GroupBy
+// value, into influxdb.
+func report(metric config.Metric, counts map[string]int64) {
+	if !metric.Grouped() {
+		count := counts[""]
+		glog.Infof("Name: %s Count: %d QPS: %0.2f\n", metric.Name, count, float32(count)/60)
+		metrics2.GetFloat64Metric(metric.Name, nil /* tags */).Update(float64(count) / 60)
+		return
+	}
+	seen, ok := reported[metric.Name]
+	if !ok {
+		seen = map[string]bool{}
+		reported[metric.Name] = seen
+	}
+	for group := range counts {
+		seen[group] = true
+	}
+	for group := range seen {
+		count := counts[group]
+		glog.Infof("Name: %s %s: %s Count: %d QPS: %0.2f\n", metric.Name, metric.Tag, group, count, float32(count)/60)
+		metrics2.GetFloat64Metric(metric.Name, map[string]string{metric.Tag: group}).Update(float64(count) / 60)
+	}
+}
+
 func oneMetric(metric config.Metric, now time.Time) {
 	ts1 := now.Add(-time.Minute).UTC().Format(time.RFC3339)
 	ts2 := now.Add(-2 * time.Minute).UTC().Format(time.RFC3339)
@@ -42,22 +74,29 @@ AND (timestamp > %q)`, metric.Filter, ts1, ts2)
 		ProjectIds: []string{"google.com:skia-buildbots"},
 		PageSize:   1000,
 	}
+	fields := "entries(timestamp),nextPageToken"
+	if metric.Grouped() {
+		fields = "entries(timestamp,textPayload),nextPageToken"
+	}
 	// Count all the results, handling paging.
-	count := 0
+	counts := map[string]int64{}
 	for {
-		resp, err := loggingService.Entries.List(req).Fields("entries(timestamp),nextPageToken").Context(context.Background()).Do()
+		resp, err := loggingService.Entries.List(req).Fields(googleapi.Field(fields)).Context(context.Background()).Do()
 		if err != nil {
 			glog.Errorf("Request Failed: %s", err)
 			return
 		}
-		count += len(resp.Entries)
+		for _, e := range resp.Entries {
+			if group, ok := metric.Group(e.TextPayload); ok {
+				counts[group]++
+			}
+		}
 		if resp.NextPageToken == "" {
 			break
 		}
 		req.PageToken = resp.NextPageToken
 	}
-	glog.Infof("Name: %s Count: %d QPS: %0.2f\n", metric.Name, count, float32(count)/60)
-	metrics2.GetFloat64Metric(metric.Name, nil /* tags */).Update(float64(count) / 60)
+	report(metric, counts)
 }
 
 func step() {
@@ -67,6 +106,37 @@ func step() {
 	}
 }
 
+// localStep scans the local log files and reports the counts of the entries
+// added since the last call.
+func localStep(scanners []logagents.LogScanner, counter *localmetrics.Counter) {
+	for _, s := range scanners {
+		if err := s.Scan(counter); err != nil {
+			glog.Errorf("Failed to scan log %s: %s", s.ReportName(), err)
+		}
+	}
+	counts := counter.Reset()
+	for _, metric := range metrics {
+		report(metric, counts[metric.Name])
+	}
+}
+
+// runLocal counts the metrics from the local log files every minute.
+func runLocal(logs []config.Log) {
+	if err := logagents.SetPersistenceDir(*persistenceDir); err != nil {
+		glog.Fatalf("Failed to set persistence dir: %s", err)
+	}
+	scanners, err := localmetrics.NewScanners(logs)
+	if err != nil {
+		glog.Fatalf("Failed to create log scanners: %s", err)
+	}
+	counter := localmetrics.NewCounter(metrics)
+	// The first scan skips the logs written before we started.
+	localStep(scanners, counter)
+	for _ = range time.Tick(time.Minute) {
+		localStep(scanners, counter)
+	}
+}
+
 func main() {
 	defer common.LogPanic()
 	if *local {
@@ -74,6 +144,24 @@ func main() {
 	} else {
 		common.InitWithMetrics2("logmetrics", influxHost, influxUser, influxPassword, influxDatabase, local)
 	}
+	cfg, err := config.ReadConfig(*metricsFilename)
+	if err != nil {
+		glog.Fatalf("Failed to read metrics file %q: %s", *metricsFilename, err)
+	}
+	if cfg.Local() {
+		if _, err := localmetrics.NewScanners(cfg.Logs); err != nil {
+			glog.Fatalf("Invalid logs in %q: %s", *metricsFilename, err)
+		}
+	}
+	if *validateOnly {
+		fmt.Printf("Successfully validated.\n")
+		return
+	}
+	metrics = cfg.Metrics
+	if cfg.Local() {
+		runLocal(cfg.Logs)
+		return
+	}
 	client, err := auth.NewDefaultJWTServiceAccountClient(logging.LoggingReadScope)
 	if err != nil {
 		glog.Fatalf("Failed to create service account client: %s", err)
@@ -82,14 +170,6 @@ func main() {
 	if err != nil {
 		glog.Fatalf("Failed to create logging client: %s", err)
 	}
-	metrics, err = config.ReadMetrics(*metricsFilename)
-	if err != nil {
-		glog.Fatalf("Failed to read metrics file %q: %s", *metricsFilename, err)
-	}
-	if *validateOnly {
-		fmt.Printf("Successfully validated.\n")
-		return
-	}
 	step()
 	for _ = range time.Tick(time.Minute) {
 		step()
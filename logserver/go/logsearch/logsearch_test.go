@@ -0,0 +1,255 @@
+package logsearch
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+const (
+	TESTDATA_DIR = "testdata"
+	INFO_LOG     = "app.host.user.log.INFO.20151231-120000.1234"
+)
+
+func TestParseLogFileName(t *testing.T) {
+	info, ok := ParseLogFileName("ingest.skia-testing-b.perf.log.ERROR.20141015-133007.3273")
+	assert.True(t, ok)
+	assert.Equal(t, "ingest", info.App)
+	assert.Equal(t, ERROR, info.Severity)
+	assert.Equal(t, time.Date(2014, 10, 15, 13, 30, 7, 0, time.Local), info.Created)
+
+	// Hostnames may contain dots.
+	info, ok = ParseLogFileName("ingest.skia-testing-b.c.google.com.perf.log.INFO.20141015-133007.3273")
+	assert.True(t, ok)
+	assert.Equal(t, "ingest", info.App)
+	assert.Equal(t, INFO, info.Severity)
+
+	for _, name := range []string{"ingest.ERROR", "README", "ingest.host.user.log.DEBUG.20141015-133007.3273", "ingest.host.user.log.INFO.yesterday.3273"} {
+		_, ok := ParseLogFileName(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestParser(t *testing.T) {
+	f, err := os.Open(filepath.Join(TESTDATA_DIR, INFO_LOG))
+	assert.NoError(t, err)
+	defer testutils.CloseInTest(t, f)
+
+	entries := []*Entry{}
+	p := NewParser(f, INFO_LOG, 2015)
+	for {
+		e, err := p.Next()
+		if err != nil {
+			break
+		}
+		entries = append(entries, e)
+	}
+	assert.Equal(t, 4, len(entries))
+
+	assert.Equal(t, &Entry{
+		Severity: INFO,
+		Time:     time.Date(2015, 12, 31, 12, 0, 0, 100000, time.Local),
+		ThreadId: 1234,
+		File:     "main.go",
+		Line:     10,
+		Message:  "Starting app.",
+		LogFile:  INFO_LOG,
+		LogLine:  4,
+	}, entries[0])
+	assert.Equal(t, WARNING, entries[1].Severity)
+
+	// The year wraps around and the stack trace is part of the message.
+	e := entries[2]
+	assert.Equal(t, ERROR, e.Severity)
+	assert.Equal(t, time.Date(2016, 1, 1, 0, 0, 1, 0, time.Local), e.Time)
+	assert.Equal(t, int64(1235), e.ThreadId)
+	assert.Equal(t, "handler.go", e.File)
+	assert.Equal(t, 33, e.Line)
+	assert.Equal(t, "Request failed: timeout\ngoroutine 1 [running]:\nmain.main()\n\t/src/main.go:40 +0x20", e.Message)
+	assert.Equal(t, 6, e.LogLine)
+
+	assert.Equal(t, 10, entries[3].LogLine)
+	assert.Equal(t, 250*time.Millisecond, time.Duration(entries[3].Time.Nanosecond()))
+}
+
+func TestParserLeapDay(t *testing.T) {
+	r := strings.NewReader("I0229 10:00:00.000000    1 main.go:1] Leap day\nI0301 00:00:01.000000    1 main.go:2] Next day\n")
+	p := NewParser(r, INFO_LOG, 2024)
+	e, err := p.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 29, 10, 0, 0, 0, time.Local), e.Time)
+	e, err = p.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 1, 0, time.Local), e.Time)
+	_, err = p.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestParserLongLine(t *testing.T) {
+	long := "I0101 00:00:00.000000    1 main.go:1] " + strings.Repeat("x", MAX_LINE_LENGTH) + "\n"
+	p := NewParser(strings.NewReader(long+"I0101 00:00:01.000000    1 main.go:2] After\n"), INFO_LOG, 2016)
+	_, err := p.Next()
+	assert.Error(t, err)
+}
+
+func TestParseLine(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 10, 0, 0, time.Local)
+	e := ParseLine("E0101 00:00:01.000000    1235 handler.go:33] Request failed", "f", now)
+	assert.NotNil(t, e)
+	assert.Equal(t, time.Date(2016, 1, 1, 0, 0, 1, 0, time.Local), e.Time)
+
+	// Entries from the end of last year.
+	e = ParseLine("W1231 23:59:59.500000    1234 main.go:20] Slow", "f", now)
+	assert.NotNil(t, e)
+	assert.Equal(t, 2015, e.Time.Year())
+
+	assert.Nil(t, ParseLine("goroutine 1 [running]:", "f", now))
+}
+
+func TestSearch(t *testing.T) {
+	// All entries of the app.
+	entries, err := Search(TESTDATA_DIR, &Query{App: "app"})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(entries))
+	for _, e := range entries {
+		assert.Equal(t, INFO_LOG, e.LogFile)
+	}
+
+	// Only the WARNING file is read for warnings and above.
+	entries, err = Search(TESTDATA_DIR, &Query{App: "app", MinSeverity: WARNING})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "app.host.user.log.WARNING.20151231-120000.1234", entries[0].LogFile)
+
+	entries, err = Search(TESTDATA_DIR, &Query{App: "app", MinSeverity: ERROR})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	// Regexps match the whole message, including stack traces.
+	entries, err = Search(TESTDATA_DIR, &Query{App: "app", Regexp: regexp.MustCompile("main.go:40")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, 6, entries[0].LogLine)
+
+	// Time ranges.
+	entries, err = Search(TESTDATA_DIR, &Query{
+		App:   "app",
+		Begin: time.Date(2015, 12, 31, 23, 0, 0, 0, time.Local),
+		End:   time.Date(2016, 1, 1, 0, 0, 2, 0, time.Local),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, WARNING, entries[0].Severity)
+	assert.Equal(t, ERROR, entries[1].Severity)
+
+	// The limit keeps the most recent entries.
+	entries, err = Search(TESTDATA_DIR, &Query{App: "app", Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "Request succeeded", entries[0].Message)
+
+	entries, err = Search(TESTDATA_DIR, &Query{App: "other", Regexp: regexp.MustCompile("failed")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	_, err = Search(TESTDATA_DIR, &Query{App: "app", MinSeverity: "DEBUG"})
+	assert.Error(t, err)
+}
+
+func TestReadEntries(t *testing.T) {
+	path := filepath.Join(TESTDATA_DIR, INFO_LOG)
+	entries, err := ReadEntries(path, 1, 2, &Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, 4, entries[0].LogLine)
+
+	// Lines in the middle of an entry return the whole entry.
+	entries, err = ReadEntries(path, 8, 10, &Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, 6, entries[0].LogLine)
+	assert.Equal(t, 10, entries[1].LogLine)
+
+	entries, err = ReadEntries(path, 1, 10, &Query{MinSeverity: ERROR})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	entries, err = ReadEntries(path, 100, 10, &Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
+
+func TestTail(t *testing.T) {
+	testutils.SkipIfShort(t)
+	f, err := ioutil.TempFile("", "logsearch_tail_")
+	assert.NoError(t, err)
+	defer testutils.Remove(t, f.Name())
+	_, err = f.WriteString("line 1\nline 2\nline 3\n")
+	assert.NoError(t, err)
+
+	offset, err := TailOffset(f.Name(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), offset)
+	offset, err = TailOffset(f.Name(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), offset)
+	offset, err = TailOffset(f.Name(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(21), offset)
+
+	lines := make(chan string, 10)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Follow(f.Name(), 7, 10*time.Millisecond, stop, func(line string) error {
+			lines <- line
+			return nil
+		})
+	}()
+	next := func() string {
+		select {
+		case l := <-lines:
+			return l
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "Timed out waiting for a line.")
+		}
+		return ""
+	}
+	assert.Equal(t, "line 2", next())
+	assert.Equal(t, "line 3", next())
+
+	// Partial lines are only sent once complete.
+	_, err = f.WriteString("line")
+	assert.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	_, err = f.WriteString(" 4\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "line 4", next())
+
+	// Truncated files are read from the start.
+	assert.NoError(t, f.Truncate(0))
+	_, err = f.Seek(0, os.SEEK_SET)
+	assert.NoError(t, err)
+	_, err = f.WriteString("new\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", next())
+
+	close(stop)
+	assert.NoError(t, <-done)
+	assert.NoError(t, f.Close())
+
+	// Errors from the callback stop Follow.
+	err = Follow(f.Name(), 0, time.Millisecond, nil, func(line string) error {
+		return fmt.Errorf("Stop at %s", strings.TrimSpace(line))
+	})
+	assert.EqualError(t, err, "Stop at new")
+}
@@ -0,0 +1,196 @@
+// Package logsearch parses, searches and tails the log files written by glog.
+package logsearch
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Severities written by glog, in increasing order.
+	INFO    = "INFO"
+	WARNING = "WARNING"
+	ERROR   = "ERROR"
+	FATAL   = "FATAL"
+
+	// The longest line read from a log file, longer lines are truncated.
+	MAX_LINE_LENGTH = 1024 * 1024
+)
+
+var (
+	// SEVERITIES lists the glog severities in increasing order.
+	SEVERITIES = []string{INFO, WARNING, ERROR, FATAL}
+
+	// glogLineRe matches the header of a glog line, which looks like:
+	//
+	//    Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+	//
+	glogLineRe = regexp.MustCompile(`^([IWEF])(\d\d)(\d\d) (\d\d:\d\d:\d\d\.\d{6})\s+(\d+) ([^:\]]+):(\d+)\] ?(.*)$`)
+
+	severityFromLetter = map[string]string{
+		"I": INFO,
+		"W": WARNING,
+		"E": ERROR,
+		"F": FATAL,
+	}
+)
+
+// SeverityRank returns the position of the severity in SEVERITIES, or -1 if it
+// is not a glog severity.
+func SeverityRank(severity string) int {
+	for i, s := range SEVERITIES {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Entry is a single parsed glog entry.
+type Entry struct {
+	Severity string    `json:"severity"`
+	Time     time.Time `json:"time"`
+	ThreadId int64     `json:"thread_id"`
+	// Source file and line that logged the entry.
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// Message of the entry. Lines which follow the entry and don't look like a
+	// glog line, such as stack traces, are appended to the message.
+	Message string `json:"message"`
+
+	// LogFile is the name of the log file the entry was read from, and
+	// LogLine is the 1-based line of the log file it starts on.
+	LogFile string `json:"log_file"`
+	LogLine int    `json:"log_line"`
+}
+
+// LogFileInfo is the information encoded in the name of a glog log file, for
+// example "ingest.skia-testing-b.perf.log.ERROR.20141015-133007.3273".
+type LogFileInfo struct {
+	App      string
+	Severity string
+	Created  time.Time
+}
+
+// ParseLogFileName parses the name of a glog log file. Returns false if the
+// name isn't in the glog format, eg. the symlinks to the latest logs.
+func ParseLogFileName(name string) (*LogFileInfo, bool) {
+	tokens := strings.Split(name, ".")
+	if len(tokens) < 7 {
+		return nil, false
+	}
+	// The hostname may contain dots, so count from the end.
+	severity := tokens[len(tokens)-3]
+	if SeverityRank(severity) == -1 {
+		return nil, false
+	}
+	created, err := time.ParseInLocation("20060102-150405", tokens[len(tokens)-2], time.Local)
+	if err != nil {
+		return nil, false
+	}
+	return &LogFileInfo{
+		App:      tokens[0],
+		Severity: severity,
+		Created:  created,
+	}, true
+}
+
+// Parser reads Entries from a glog log file.
+type Parser struct {
+	scanner *bufio.Scanner
+	logFile string
+	// glog lines don't contain the year, it is tracked from the year the file
+	// was created and incremented when the month wraps around.
+	year      int
+	lastMonth time.Month
+	lineNum   int
+	// next is the header of the next entry, already read from the scanner.
+	next *Entry
+}
+
+// NewParser returns a Parser which reads the log file named logFile from r.
+// The year is used for the timestamps of the entries, which glog doesn't
+// record, see ParseLogFileName.
+func NewParser(r io.Reader, logFile string, year int) *Parser {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), MAX_LINE_LENGTH)
+	return &Parser{
+		scanner: s,
+		logFile: logFile,
+		year:    year,
+	}
+}
+
+// parseHeader parses a line which starts a glog entry, or returns nil if the
+// line doesn't start an entry.
+func (p *Parser) parseHeader(line string) *Entry {
+	m := glogLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	clock, err := time.Parse("15:04:05.000000", m[4])
+	if err != nil {
+		return nil
+	}
+	if time.Month(month) < p.lastMonth {
+		p.year++
+	}
+	p.lastMonth = time.Month(month)
+	threadId, _ := strconv.ParseInt(m[5], 10, 64)
+	lineNum, _ := strconv.Atoi(m[7])
+	return &Entry{
+		Severity: severityFromLetter[m[1]],
+		Time:     time.Date(p.year, time.Month(month), day, clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(), time.Local),
+		ThreadId: threadId,
+		File:     m[6],
+		Line:     lineNum,
+		Message:  m[8],
+		LogFile:  p.logFile,
+		LogLine:  p.lineNum,
+	}
+}
+
+// Next returns the next entry in the log file, or io.EOF once there are no
+// more entries. Lines before the first entry are skipped.
+func (p *Parser) Next() (*Entry, error) {
+	for p.scanner.Scan() {
+		p.lineNum++
+		line := p.scanner.Text()
+		if e := p.parseHeader(line); e != nil {
+			prev := p.next
+			p.next = e
+			if prev != nil {
+				return prev, nil
+			}
+		} else if p.next != nil {
+			p.next.Message += "\n" + line
+		}
+	}
+	if err := p.scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.next != nil {
+		e := p.next
+		p.next = nil
+		return e, nil
+	}
+	return nil, io.EOF
+}
+
+// ParseLine parses a single glog line, as read by Follow. Returns nil if the
+// line doesn't start a glog entry. The year is taken from now, unless that
+// would put the entry in the future.
+func ParseLine(line, logFile string, now time.Time) *Entry {
+	p := &Parser{logFile: logFile, year: now.Year()}
+	e := p.parseHeader(line)
+	if e != nil && e.Time.After(now.Add(24*time.Hour)) {
+		e.Time = e.Time.AddDate(-1, 0, 0)
+	}
+	return e
+}
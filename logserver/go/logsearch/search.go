@@ -0,0 +1,185 @@
+package logsearch
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"go.skia.org/infra/go/util"
+)
+
+const (
+	// The default limit on the number of results returned by Search.
+	DEFAULT_SEARCH_LIMIT = 1000
+)
+
+// Query describes a search over the logs of an app.
+type Query struct {
+	// App is the name of the app whose logs are searched, as it appears at the
+	// start of the log file names.
+	App string
+
+	// Regexp, if not nil, must match the message of the entries.
+	Regexp *regexp.Regexp
+
+	// MinSeverity is the lowest severity of the entries returned. Defaults to
+	// INFO.
+	MinSeverity string
+
+	// Begin and End, if not zero, limit the entries to those logged in
+	// [Begin, End).
+	Begin time.Time
+	End   time.Time
+
+	// Limit is the maximum number of entries returned, the most recent ones are
+	// kept. Defaults to DEFAULT_SEARCH_LIMIT.
+	Limit int
+}
+
+// Matches returns true if the entry matches the query.
+func (q *Query) Matches(e *Entry) bool {
+	if SeverityRank(e.Severity) < SeverityRank(q.minSeverity()) {
+		return false
+	}
+	if !q.Begin.IsZero() && e.Time.Before(q.Begin) {
+		return false
+	}
+	if !q.End.IsZero() && !e.Time.Before(q.End) {
+		return false
+	}
+	return q.Regexp == nil || q.Regexp.MatchString(e.Message)
+}
+
+func (q *Query) minSeverity() string {
+	if q.MinSeverity == "" {
+		return INFO
+	}
+	return q.MinSeverity
+}
+
+// EntrySlice sorts entries by time.
+type EntrySlice []*Entry
+
+func (p EntrySlice) Len() int           { return len(p) }
+func (p EntrySlice) Less(i, j int) bool { return p[i].Time.Before(p[j].Time) }
+func (p EntrySlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// Search returns the entries in the log files of an app in dir which match the
+// query, sorted by time.
+//
+// glog writes each entry to the file of its severity and of every lower
+// severity, so only the files of the query's MinSeverity are read.
+func Search(dir string, q *Query) ([]*Entry, error) {
+	if SeverityRank(q.minSeverity()) == -1 {
+		return nil, fmt.Errorf("Unknown severity %q", q.MinSeverity)
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DEFAULT_SEARCH_LIMIT
+	}
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", dir, err)
+	}
+	results := EntrySlice{}
+	for _, fi := range fileInfos {
+		// Skip directories and the symlinks to the latest logs.
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		info, ok := ParseLogFileName(fi.Name())
+		if !ok || info.App != q.App || info.Severity != q.minSeverity() {
+			continue
+		}
+		// Skip files written entirely outside of the time range.
+		if !q.Begin.IsZero() && fi.ModTime().Before(q.Begin) {
+			continue
+		}
+		if !q.End.IsZero() && !info.Created.Before(q.End) {
+			continue
+		}
+		entries, err := searchFile(filepath.Join(dir, fi.Name()), info, q)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, entries...)
+	}
+	sort.Sort(results)
+	if len(results) > limit {
+		results = results[len(results)-limit:]
+	}
+	return results, nil
+}
+
+// searchFile returns the entries in the given log file which match the query.
+func searchFile(path string, info *LogFileInfo, q *Query) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %s: %s", path, err)
+	}
+	defer util.Close(f)
+	rv := []*Entry{}
+	p := NewParser(f, filepath.Base(path), info.Created.Year())
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			return rv, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("Failed to read %s: %s", path, err)
+		}
+		if q.Matches(e) {
+			rv = append(rv, e)
+		}
+	}
+}
+
+// ReadEntries returns up to n entries from the given log file, starting with
+// the entry which contains the given 1-based line, that match the query. The
+// query's App and Limit are ignored.
+func ReadEntries(path string, start, n int, q *Query) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %s: %s", path, err)
+	}
+	defer util.Close(f)
+	year := time.Now().Year()
+	if info, ok := ParseLogFileName(filepath.Base(path)); ok {
+		year = info.Created.Year()
+	}
+	rv := []*Entry{}
+	p := NewParser(f, filepath.Base(path), year)
+	// last is the last entry which starts before the start line, it is
+	// returned if it spans the start line.
+	var last *Entry
+	for len(rv) < n {
+		e, err := p.Next()
+		if err == io.EOF {
+			if last != nil && start <= p.lineNum && q.Matches(last) {
+				rv = append(rv, last)
+			}
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Failed to read %s: %s", path, err)
+		}
+		if e.LogLine < start {
+			last = e
+			continue
+		}
+		if last != nil && e.LogLine > start && q.Matches(last) {
+			rv = append(rv, last)
+			if len(rv) == n {
+				break
+			}
+		}
+		last = nil
+		if q.Matches(e) {
+			rv = append(rv, e)
+		}
+	}
+	return rv, nil
+}
@@ -0,0 +1,119 @@
+package logsearch
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.skia.org/infra/go/util"
+)
+
+// TailOffset returns the offset in the file at which its last n lines start.
+func TailOffset(path string, n int) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to open %s: %s", path, err)
+	}
+	defer util.Close(f)
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to stat %s: %s", path, err)
+	}
+	if n <= 0 {
+		return fi.Size(), nil
+	}
+	// Read backwards in blocks, counting newlines. The newline which ends the
+	// last line doesn't count.
+	const BLOCK_SIZE = 64 * 1024
+	buf := make([]byte, BLOCK_SIZE)
+	offset := fi.Size()
+	end := fi.Size() - 1
+	for offset > 0 {
+		size := int64(BLOCK_SIZE)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		if _, err := f.ReadAt(buf[:size], offset); err != nil {
+			return 0, fmt.Errorf("Failed to read %s: %s", path, err)
+		}
+		for i := size - 1; i >= 0; i-- {
+			if buf[i] != '\n' || offset+i == end {
+				continue
+			}
+			n--
+			if n == 0 {
+				return offset + i + 1, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// Follow reads the complete lines written to the file after the given offset
+// and calls fn for each of them, without the trailing newline. It keeps
+// checking the file for new lines every period, until stop is closed or fn
+// returns an error. If the file is truncated, it is read again from the start.
+func Follow(path string, offset int64, period time.Duration, stop <-chan struct{}, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Failed to open %s: %s", path, err)
+	}
+	defer util.Close(f)
+	if _, err := f.Seek(offset, os.SEEK_SET); err != nil {
+		return fmt.Errorf("Failed to seek in %s: %s", path, err)
+	}
+	buf := make([]byte, 64*1024)
+	// partial holds the start of a line whose end hasn't been written yet.
+	partial := []byte{}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := f.Read(buf)
+			if n > 0 {
+				offset += int64(n)
+				partial = append(partial, buf[:n]...)
+				for {
+					i := bytes.IndexByte(partial, '\n')
+					if i == -1 {
+						break
+					}
+					if err := fn(string(partial[:i])); err != nil {
+						return err
+					}
+					partial = partial[i+1:]
+				}
+				if len(partial) > MAX_LINE_LENGTH {
+					if err := fn(string(partial)); err != nil {
+						return err
+					}
+					partial = []byte{}
+				}
+			}
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("Failed to read %s: %s", path, err)
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("Failed to stat %s: %s", path, err)
+		}
+		if fi.Size() < offset {
+			offset = 0
+			partial = []byte{}
+			if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+				return fmt.Errorf("Failed to seek in %s: %s", path, err)
+			}
+		}
+	}
+}
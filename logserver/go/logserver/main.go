@@ -5,6 +5,7 @@ package main
 import (
 	"bufio"
 	"encoding/gob"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
@@ -13,17 +14,22 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/exec"
+	"go.skia.org/infra/go/fileutil"
+	"go.skia.org/infra/go/httputils"
 	"go.skia.org/infra/go/influxdb"
 	"go.skia.org/infra/go/metadata"
 	"go.skia.org/infra/go/metrics2"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/logserver/go/logsearch"
 )
 
 const (
@@ -65,6 +71,15 @@ const (
 	ADD_RESPONSE_TEXT_PLAIN = `var logNode = document.createTextNode(this.responseText);
 					document.getElementById('file_content').appendChild(logNode);`
 	ADD_RESPONSE_TEXT_HTML = `document.getElementById('file_content').innerHTML = this.responseText;`
+
+	// The number of existing lines sent when a tail is started, by default.
+	DEFAULT_TAIL_LINES = 100
+
+	// How often tailed files are checked for new lines.
+	TAIL_POLL_PERIOD = 500 * time.Millisecond
+
+	// The number of entries returned by the entries endpoint, by default.
+	DEFAULT_ENTRIES = 100
 )
 
 var (
@@ -336,6 +351,234 @@ func serveFile(w http.ResponseWriter, r *http.Request, fs http.FileSystem, name
 	http.ServeContent(w, r, d.Name(), d.ModTime(), f)
 }
 
+// setAccessHeaders sets the headers which stop the response from being cached
+// and allow it to be shared with allowOrigin.
+func setAccessHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate, max-age=-1")
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	if *allowOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", *allowOrigin)
+	}
+}
+
+// logFilePath returns the path of the log file with the given name, which
+// must be a file directly in dir.
+func logFilePath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("Invalid log file name: %q", name)
+	}
+	return filepath.Join(*dir, name), nil
+}
+
+// parseQuery builds a logsearch.Query from the request's "q", "severity",
+// "begin" and "end" parameters. begin and end are RFC3339 timestamps.
+func parseQuery(r *http.Request) (*logsearch.Query, error) {
+	q := &logsearch.Query{
+		App:         r.FormValue("app"),
+		MinSeverity: strings.ToUpper(r.FormValue("severity")),
+	}
+	if q.MinSeverity != "" && logsearch.SeverityRank(q.MinSeverity) == -1 {
+		return nil, fmt.Errorf("Unknown severity %q", q.MinSeverity)
+	}
+	if re := r.FormValue("q"); re != "" {
+		var err error
+		if q.Regexp, err = regexp.Compile(re); err != nil {
+			return nil, fmt.Errorf("Invalid regexp %q: %s", re, err)
+		}
+	}
+	for _, t := range []struct {
+		param string
+		dest  *time.Time
+	}{{"begin", &q.Begin}, {"end", &q.End}} {
+		if v := r.FormValue(t.param); v != "" {
+			var err error
+			if *t.dest, err = time.Parse(time.RFC3339, v); err != nil {
+				return nil, fmt.Errorf("Invalid %s %q: %s", t.param, v, err)
+			}
+		}
+	}
+	return q, nil
+}
+
+// intParam returns the value of the integer parameter, or the default if it
+// isn't given.
+func intParam(r *http.Request, param string, defaultVal int) (int, error) {
+	v := r.FormValue(param)
+	if v == "" {
+		return defaultVal, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("Invalid %s %q", param, v)
+	}
+	return i, nil
+}
+
+// searchResult is a search match, with a link to the entry in its log file.
+type searchResult struct {
+	*logsearch.Entry
+	Url string `json:"url"`
+}
+
+// searchHandler searches all the logs of an app and returns the matching
+// entries as JSON. The parameters are:
+//
+//    app      - The name of the app, required.
+//    q        - A regexp which must match the message.
+//    severity - The minimum severity, INFO by default.
+//    begin    - Only entries logged at or after this RFC3339 timestamp.
+//    end      - Only entries logged before this RFC3339 timestamp.
+//    limit    - The maximum number of entries, the most recent are returned.
+//
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	setAccessHeaders(w)
+	q, err := parseQuery(r)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid query.")
+		return
+	}
+	if q.App == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Missing app."), "The app parameter is required.")
+		return
+	}
+	if q.Limit, err = intParam(r, "limit", logsearch.DEFAULT_SEARCH_LIMIT); err != nil {
+		httputils.ReportError(w, r, err, "Invalid limit.")
+		return
+	}
+	entries, err := logsearch.Search(*dir, q)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Search failed.")
+		return
+	}
+	results := make([]*searchResult, 0, len(entries))
+	for _, e := range entries {
+		results = append(results, &searchResult{
+			Entry: e,
+			Url:   fmt.Sprintf("/json/entries/%s?start=%d", url.QueryEscape(e.LogFile), e.LogLine),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		glog.Errorf("Failed to write search results: %s", err)
+	}
+}
+
+// entriesHandler returns the entries of a single log file, parsed, as JSON.
+// The parameters are:
+//
+//    start    - The 1-based line of the log file to start at.
+//    n        - The maximum number of entries returned.
+//    q, severity, begin, end - Filter the entries, as for searchHandler.
+//
+func entriesHandler(w http.ResponseWriter, r *http.Request) {
+	setAccessHeaders(w)
+	path, err := logFilePath(strings.TrimPrefix(r.URL.Path, "/json/entries/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	q, err := parseQuery(r)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid query.")
+		return
+	}
+	start, err := intParam(r, "start", 1)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid start.")
+		return
+	}
+	n, err := intParam(r, "n", DEFAULT_ENTRIES)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid n.")
+		return
+	}
+	if !fileutil.FileExists(path) {
+		http.NotFound(w, r)
+		return
+	}
+	entries, err := logsearch.ReadEntries(path, start, n, q)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to read entries.")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		glog.Errorf("Failed to write entries: %s", err)
+	}
+}
+
+// tailHandler streams the lines appended to a log file as Server-Sent Events.
+// The last 'lines' lines of the file are sent first. If 'format' is "json"
+// then each glog line is sent as a JSON encoded logsearch.Entry, and lines
+// which aren't glog lines are sent as an Entry with just a message.
+func tailHandler(w http.ResponseWriter, r *http.Request) {
+	setAccessHeaders(w)
+	name := strings.TrimPrefix(r.URL.Path, "/tail/")
+	path, err := logFilePath(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	lines, err := intParam(r, "lines", DEFAULT_TAIL_LINES)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid lines.")
+		return
+	}
+	asJson := r.FormValue("format") == "json"
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httputils.ReportError(w, r, fmt.Errorf("Streaming unsupported."), "Streaming unsupported.")
+		return
+	}
+	offset, err := logsearch.TailOffset(path, lines)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stop := make(chan struct{})
+	if cn, ok := w.(http.CloseNotifier); ok {
+		closed := cn.CloseNotify()
+		go func() {
+			<-closed
+			close(stop)
+		}()
+	}
+	send := func(line string) error {
+		data := line
+		if asJson {
+			e := logsearch.ParseLine(line, name, time.Now())
+			if e == nil {
+				e = &logsearch.Entry{Message: line, LogFile: name}
+			}
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			data = string(b)
+		}
+		// A newline in the data would end the event, so send each line of the
+		// data as its own data field.
+		for _, l := range strings.Split(data, "\n") {
+			if _, err := fmt.Fprintf(w, "data: %s\n", l); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprint(w, "\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	if err := logsearch.Follow(path, offset, TAIL_POLL_PERIOD, stop, send); err != nil {
+		glog.Infof("Stopped tailing %s: %s", name, err)
+	}
+}
+
 // getAppAndLogLevel returns the app name and the log level of the specified
 // glog file by parsing it.
 // It expects a structure that looks like this:
@@ -613,6 +856,9 @@ func main() {
 	go dirWatcher(*dirWatchDuration, *dir)
 
 	http.Handle("/file_server/", http.StripPrefix("/file_server/", FileServer(http.Dir(*dir))))
+	http.HandleFunc("/tail/", tailHandler)
+	http.HandleFunc("/json/search", searchHandler)
+	http.HandleFunc("/json/entries/", entriesHandler)
 	http.HandleFunc("/", FileServerWrapperHandler)
 	glog.Fatal(http.ListenAndServe(*port, nil))
 }
@@ -9,6 +9,7 @@ import (
 	"os"
 
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/perdiff/go/yee"
 )
 
 var options struct {
@@ -22,7 +23,7 @@ var options struct {
 	luminanceOnly bool
 	colorFactor   float64
 	downsample    int
-	output        *FloatGrayImage
+	output        *yee.FloatGrayImage
 }
 
 func loadImages(files []string) []image.Image {
@@ -76,14 +77,26 @@ func main() {
 	options.output = nil
 
 	if options.output_fname != "" {
-		options.output = MakeFloatGrayImage(images[0].Bounds().Max.X, images[0].Bounds().Max.Y)
+		options.output = yee.MakeFloatGrayImage(images[0].Bounds().Max.X, images[0].Bounds().Max.Y)
 	}
 
 	if options.verbose {
 		log.Println("Everything looks good, let's do the compare.")
 	}
 
-	result, num_pixels_different := Yee_Compare(images[0], images[1])
+	result, num_pixels_different, err := yee.Compare(images[0], images[1], &yee.Options{
+		Verbose:       options.verbose,
+		Debug:         options.debug,
+		Threshold:     options.threshold,
+		Gamma:         options.gamma,
+		LuminanceOnly: options.luminanceOnly,
+		ColorFactor:   options.colorFactor,
+		FOV:           options.fov,
+		Output:        options.output,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	if result {
 		log.Println("Image compare succeeded!")
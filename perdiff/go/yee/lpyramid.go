@@ -1,4 +1,4 @@
-package main
+package yee
 
 const MAX_PYR_LEVELS = 8
 
@@ -1,4 +1,7 @@
-package main
+// yee implements the perceptual image comparison from Hector Yee's "A
+// Perceptual Metric for Production Testing", which counts the pixels whose
+// difference is visible to the human eye.
+package yee
 
 import (
 	"fmt"
@@ -8,10 +11,53 @@ import (
 	"sync/atomic"
 )
 
-func Yee_Compare(ImgA, ImgB image.Image) (bool, int) {
+// Options controls the comparison done by Compare.
+type Options struct {
+	// Verbose logs the progress of the comparison.
+	Verbose bool
+
+	// Debug dumps the intermediate images into the current directory.
+	Debug bool
+
+	// Threshold is the number of perceptually different pixels below which
+	// the images are considered to be the same.
+	Threshold int
+
+	// Gamma is the value used to convert RGB into linear space.
+	Gamma float64
+
+	// LuminanceOnly ignores color in the comparison.
+	LuminanceOnly bool
+
+	// ColorFactor is how much of color to use, 0.0 ignores color and 1.0
+	// uses all of it.
+	ColorFactor float64
+
+	// FOV is the field of view subtended by the image, 0.1 to 89.9 degrees.
+	FOV float64
+
+	// Output, if not nil, has the perceptually different pixels set to 1 and
+	// all other pixels to 0. It must have the dimensions of the images.
+	Output *FloatGrayImage
+}
+
+// DefaultOptions returns the default Options.
+func DefaultOptions() *Options {
+	return &Options{
+		Threshold:   100,
+		Gamma:       2.2,
+		ColorFactor: 1.0,
+		FOV:         45.0,
+	}
+}
+
+// Compare compares the images using Yee's perceptual metric. It returns true
+// if the number of perceptually different pixels is below opts.Threshold and
+// the number of perceptually different pixels. The images must have the same
+// dimensions.
+func Compare(ImgA, ImgB image.Image, opts *Options) (bool, int, error) {
 	if ImgA.Bounds() != ImgB.Bounds() {
-		log.Fatal("I'm sorry, but the two images provided do not have the same dimensions.")
-		return false, 0
+		return false, 0, fmt.Errorf("The images do not have the same dimensions: %s vs %s", ImgA.Bounds(), ImgB.Bounds())
 	}
 
 	bounds := ImgA.Bounds()
@@ -32,66 +78,68 @@ outer:
 		}
 	}
 	if identical {
-		log.Println("The images are binary identical.")
-		return true, 0
+		if opts.Verbose {
+			log.Println("The images are binary identical.")
+		}
+		return true, 0, nil
 	}
 
-	if options.verbose {
+	if opts.Verbose {
 		log.Println("Converting the images to floating point")
 	}
 
 	AFloat := CopyImageToFloat(ImgA)
 	BFloat := CopyImageToFloat(ImgB)
 
-	if options.debug {
+	if opts.Debug {
 		AFloat.Dump("a_float.png")
 		BFloat.Dump("b_float.png")
 	}
 
-	if options.verbose {
+	if opts.Verbose {
 		log.Println("Gamma correcting...")
 	}
 
-	AGamma := AdjustGamma(AFloat, options.gamma)
-	BGamma := AdjustGamma(BFloat, options.gamma)
+	AGamma := AdjustGamma(AFloat, opts.Gamma)
+	BGamma := AdjustGamma(BFloat, opts.Gamma)
 
-	if options.debug {
+	if opts.Debug {
 		AGamma.Dump("a_gamma.png")
 		BGamma.Dump("b_gamma.png")
 	}
 
-	if options.verbose {
+	if opts.Verbose {
 		log.Println("Converting to LAB")
 	}
 
 	ALAB := RGBAToLAB(AFloat)
 	BLAB := RGBAToLAB(BFloat)
 
-	if options.debug {
+	if opts.Debug {
 		ALAB.Dump("a_LAB.png")
 		BLAB.Dump("b_LAB.png")
 	}
 
-	if options.verbose {
+	if opts.Verbose {
 		log.Println("Converting to grayscale")
 	}
 
 	AGray := RGBAToY(AGamma)
 	BGray := RGBAToY(BGamma)
 
-	if options.debug {
+	if opts.Debug {
 		AGray.Dump("a_gray.png")
 		AGray.Dump("b_gray.png")
 	}
 
-	if options.verbose {
+	if opts.Verbose {
 		log.Println("Constructing Laplacian pyramids")
 	}
 
 	APyramid := CreateLPyramid(AGray)
 	BPyramid := CreateLPyramid(BGray)
 
-	if options.debug {
+	if opts.Debug {
 		for i := 0; i < MAX_PYR_LEVELS; i++ {
 			fname := fmt.Sprintf("a_lpyramid_%d.png", i)
 			APyramid.levels[i].Dump(fname)
@@ -100,14 +148,14 @@ outer:
 		}
 	}
 
-	if options.verbose {
+	if opts.Verbose {
 		log.Println("Done with Laplacian Pyramid construction")
 	}
 
-	num_one_degree_pixels := 2 * math.Tan(options.fov*0.5*math.Pi/180) * 180 / math.Pi
+	num_one_degree_pixels := 2 * math.Tan(opts.FOV*0.5*math.Pi/180) * 180 / math.Pi
 	pixels_per_degree := float64(width) / num_one_degree_pixels
 
-	if options.verbose {
+	if opts.Verbose {
 		log.Println("Performing test...")
 	}
 
@@ -193,9 +241,9 @@ outer:
 
 				if delta > factor*VisibilityThreshold(adapt) {
 					pass = false
-				} else if !options.luminanceOnly {
+				} else if !opts.LuminanceOnly {
 					// CIE delta E test with some modifications
-					color_scale := options.colorFactor
+					color_scale := opts.ColorFactor
 					// ramp down the color test in scotopic regions
 					if adapt < 10.0 {
 						// Don't do the color test at all
@@ -216,23 +264,23 @@ outer:
 				if !pass {
 					atomic.AddInt32(&pixels_failed, 1)
 
-					if options.output != nil {
-						options.output.Set(x, y, 1)
+					if opts.Output != nil {
+						opts.Output.Set(x, y, 1)
 					}
-				} else if options.output != nil {
-					options.output.Set(x, y, 0)
+				} else if opts.Output != nil {
+					opts.Output.Set(x, y, 0)
 				}
 			}
 		}
 	})
 
-	if options.verbose {
+	if opts.Verbose {
 		log.Printf("Done!  Found %d pixels that were perceptually different.", pixels_failed)
 	}
 
-	if int(pixels_failed) < options.threshold {
-		return true, int(pixels_failed)
+	if int(pixels_failed) < opts.Threshold {
+		return true, int(pixels_failed), nil
 	}
 
-	return false, int(pixels_failed)
+	return false, int(pixels_failed), nil
 }
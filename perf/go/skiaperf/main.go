@@ -21,6 +21,7 @@ import (
 	storage "google.golang.org/api/storage/v1"
 
 	"go.skia.org/infra/go/auth"
+	"go.skia.org/infra/go/codereview"
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/eventbus"
 	"go.skia.org/infra/go/gitinfo"
@@ -198,7 +199,7 @@ func Init() {
 	}
 
 	rietveldAPI := rietveld.New(rietveld.RIETVELD_SKIA_URL, httputils.NewTimeoutClient())
-	branchTileBuilder = tracedb.NewBranchTileBuilder(db, git, rietveldAPI, evt)
+	branchTileBuilder = tracedb.NewBranchTileBuilder(db, git, codereview.NewRietveld(rietveldAPI), evt)
 }
 
 // showcutHandler handles the POST requests of the shortcut page.
@@ -50,6 +50,7 @@ var (
 	resourcesDir          = flag.String("resources_dir", "", "The directory to find templates, JS, and CSS files. If blank the current directory will be used.")
 	serviceAccountPath    = flag.String("service_account_path", "", "Path to the service account.  Can be empty string to use defaults or project metadata")
 	bucketName            = flag.String("bucket_name", "skia-push", "The name of the Google Storage bucket that contains push packages and info.")
+	packageDir            = flag.String("package_dir", "", "If set, packages and info are read from this local directory instead of the Google Storage bucket. See go/packages.LocalStore.")
 
 	influxHost     = flag.String("influxdb_host", influxdb.DEFAULT_HOST, "The InfluxDB hostname.")
 	influxUser     = flag.String("influxdb_name", influxdb.DEFAULT_USER, "The InfluxDB username.")
@@ -107,7 +108,6 @@ func Init() {
 	if err != nil {
 		sklog.Fatalf("Unable to retrieve hostname: %s", err)
 	}
-	packages.SetBucketName(*bucketName)
 	loadResouces()
 }
 
@@ -296,6 +296,23 @@ func listHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// installedHandler returns the list of packages pulld has installed, which
+// can lag behind the list in the package store.
+func installedHandler(w http.ResponseWriter, r *http.Request) {
+	installed, err := packages.FromLocalFile(*installedPackagesFile)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to read the installed packages.")
+		return
+	}
+	if installed == nil {
+		installed = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(installed); err != nil {
+		sklog.Errorf("Failed to write or encode output: %s", err)
+	}
+}
+
 // IndexBody is the context for evaluating the index.html template.
 type IndexBody struct {
 	Hostname string
@@ -334,13 +351,14 @@ func main() {
 		common.InitExternalWithMetrics2("pulld-not-gce", influxHost, influxUser, influxPassword, influxDatabase)
 	}
 	Init()
-	pullInit(*serviceAccountPath)
+	pullInit(*serviceAccountPath, *bucketName, *packageDir)
 	rebootMonitoringInit()
 
 	r := mux.NewRouter()
 	r.PathPrefix("/res/").HandlerFunc(httputils.MakeResourceHandler(*resourcesDir))
 	r.HandleFunc("/", mainHandler).Methods("GET")
 	r.HandleFunc("/_/list", listHandler).Methods("GET")
+	r.HandleFunc("/_/installed", installedHandler).Methods("GET")
 	r.HandleFunc("/_/change", changeHandler).Methods("POST")
 	r.HandleFunc("/pullpullpull", pullHandler)
 	http.Handle("/", httputils.LoggingGzipRequestResponse(r))
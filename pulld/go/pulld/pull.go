@@ -19,7 +19,8 @@ import (
 var (
 	httpTriggerCh = make(chan bool, 1)
 
-	store *storage.Service
+	// store is where the packages and the lists of installed packages are kept.
+	store packages.PackageStore
 )
 
 // differences returns all strings that appear in server but not local.
@@ -36,10 +37,10 @@ func differences(server, local []string) ([]string, []string) {
 	return newPackages, installedPackages
 }
 
-func step(client *http.Client, store *storage.Service, hostname string) {
+func step(store packages.PackageStore, hostname string) {
 	sklog.Info("About to read package list.")
 	// Read the old and new packages from their respective storage locations.
-	serverList, err := packages.InstalledForServer(client, store, hostname)
+	serverList, err := store.InstalledForServer(hostname)
 	if err != nil {
 		sklog.Errorf("Failed to retrieve remote package list: %s", err)
 		return
@@ -65,7 +66,7 @@ func step(client *http.Client, store *storage.Service, hostname string) {
 			sklog.Errorf("Failed to write local package list: %s", err)
 			continue
 		}
-		if err := packages.Install(client, store, name); err != nil {
+		if err := packages.Install(store, name); err != nil {
 			sklog.Errorf("Failed to install package %s: %s", name, err)
 			// Pop last name from 'installed' then rewrite the file since the
 			// install failed.
@@ -105,25 +106,38 @@ func pullHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Pull triggered.")
 }
 
-func pullInit(serviceAccountPath string) {
-	hostname, err := os.Hostname()
-	if err != nil {
-		sklog.Fatal(err)
+// newStore returns the PackageStore in packageDir, or in the Google Storage
+// bucket if packageDir is empty.
+func newStore(serviceAccountPath, bucketName, packageDir string) (packages.PackageStore, error) {
+	if packageDir != "" {
+		return packages.NewLocalStore(packageDir)
 	}
-	sklog.Infof("Running with hostname: %s", hostname)
-
 	client, err := auth.NewJWTServiceAccountClient("", serviceAccountPath, &http.Transport{Dial: httputils.DialTimeout}, storage.DevstorageFullControlScope, compute.ComputeReadonlyScope)
 	if err != nil {
-		sklog.Fatalf("Failed to create authenticated HTTP client: %s", err)
+		return nil, fmt.Errorf("Failed to create authenticated HTTP client: %s", err)
 	}
 	sklog.Info("Got authenticated client.")
 
-	store, err = storage.New(client)
+	gcs, err := storage.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create storage service client: %s", err)
+	}
+	return packages.NewGCSStore(client, gcs, bucketName), nil
+}
+
+func pullInit(serviceAccountPath, bucketName, packageDir string) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		sklog.Fatal(err)
+	}
+	sklog.Infof("Running with hostname: %s", hostname)
+
+	store, err = newStore(serviceAccountPath, bucketName, packageDir)
 	if err != nil {
-		sklog.Fatalf("Failed to create storage service client: %s", err)
+		sklog.Fatalf("Failed to create package store: %s", err)
 	}
 
-	step(client, store, hostname)
+	step(store, hostname)
 	timeCh := time.Tick(time.Second * 60)
 	go func() {
 		for {
@@ -131,7 +145,7 @@ func pullInit(serviceAccountPath string) {
 			case <-timeCh:
 			case <-httpTriggerCh:
 			}
-			step(client, store, hostname)
+			step(store, hostname)
 		}
 	}()
 }
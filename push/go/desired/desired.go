@@ -0,0 +1,358 @@
+// desired keeps the packages installed on servers in line with a declarative
+// desired state.
+//
+// The desired state is a toml file which maps each server to the apps that
+// should be installed on it, and for each app the version to install:
+//
+//    [servers.skia-monitoring]
+//    logserverd = "latest"
+//    grafanad = "4fa9c0d"
+//    prober = "prober/prober:someone@example.org:2016-06-01T12:00:00Z:4fa9c0d....deb"
+//
+// A version is either "latest", the prefix of the git hash the package was
+// built at, in which case the newest such package is used, or the full
+// package name. Servers that aren't in the file are left alone, but apps
+// installed on a server in the file that aren't listed for it are removed.
+package desired
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/metrics2"
+	"go.skia.org/infra/go/packages"
+	"go.skia.org/infra/go/util"
+)
+
+const (
+	// LATEST is the version that always resolves to the newest package.
+	LATEST = "latest"
+
+	// The kinds of Drift.
+	KIND_MISSING = "missing"
+	KIND_EXTRA   = "extra"
+	KIND_VERSION = "version"
+	KIND_UNKNOWN = "unknown"
+)
+
+// KINDS are all the kinds of Drift.
+var KINDS = []string{KIND_MISSING, KIND_EXTRA, KIND_VERSION, KIND_UNKNOWN}
+
+// State is the desired state of the servers.
+type State struct {
+	// Servers maps server names to app names to versions.
+	Servers map[string]map[string]string
+}
+
+// Load reads the desired State from the given toml file.
+func Load(filename string) (*State, error) {
+	state := &State{}
+	if _, err := toml.DecodeFile(filename, state); err != nil {
+		return nil, fmt.Errorf("Failed to decode desired state file: %s", err)
+	}
+	if state.Servers == nil {
+		state.Servers = map[string]map[string]string{}
+	}
+	return state, nil
+}
+
+// Resolve returns the name of the package of the app that the version refers
+// to, given the available packages of the app sorted from newest to oldest.
+func Resolve(app, version string, available []*packages.Package) (string, error) {
+	if len(available) == 0 {
+		return "", fmt.Errorf("No packages are available for %s.", app)
+	}
+	if version == LATEST || version == "" {
+		return available[0].Name, nil
+	}
+	if strings.HasSuffix(version, ".deb") {
+		if !strings.HasPrefix(version, app+"/") {
+			version = app + "/" + version
+		}
+		for _, p := range available {
+			if p.Name == version {
+				return p.Name, nil
+			}
+		}
+		return "", fmt.Errorf("Package %s doesn't exist.", version)
+	}
+	for _, p := range available {
+		if strings.HasPrefix(p.Hash, version) {
+			return p.Name, nil
+		}
+	}
+	return "", fmt.Errorf("No package of %s was built at %s.", app, version)
+}
+
+// Drift is a difference between the desired and the installed package of an
+// app on a server.
+type Drift struct {
+	App  string `json:"app"`
+	Kind string `json:"kind"`
+
+	// Installed is the installed package, or "" if the app isn't installed.
+	Installed string `json:"installed"`
+
+	// Desired is the desired package, or "" if the app shouldn't be
+	// installed or the desired version can't be resolved.
+	Desired string `json:"desired"`
+
+	// Message explains a KIND_UNKNOWN drift.
+	Message string `json:"message,omitempty"`
+}
+
+// ServerDiff is the difference between the desired and the installed
+// packages of a single server.
+type ServerDiff struct {
+	Server string `json:"server"`
+
+	// Installed is the current list of installed packages.
+	Installed []string `json:"installed"`
+
+	// Desired is the list of packages that reconciling would install. Apps
+	// whose desired version can't be resolved are left as they are.
+	Desired []string `json:"desired"`
+
+	Drift []*Drift `json:"drift"`
+
+	// Error is set if the installed packages couldn't be read, in which case
+	// the server isn't changed.
+	Error string `json:"error,omitempty"`
+
+	// Applied is true if Desired was written for the server.
+	Applied bool `json:"applied"`
+
+	generation int64
+}
+
+// Changed returns true if reconciling would change the list of installed
+// packages.
+func (d *ServerDiff) Changed() bool {
+	return d.Error == "" && !util.SSliceEqual(d.Installed, d.Desired)
+}
+
+// appOf returns the app name of a package name.
+func appOf(name string) string {
+	return strings.Split(name, "/")[0]
+}
+
+// isPlaceholder returns true for the "{appname}/" entries push uses for apps
+// that don't have a package chosen yet.
+func isPlaceholder(name string) bool {
+	return strings.HasSuffix(name, "/")
+}
+
+// DiffServer computes the difference between the desired apps of a server and
+// its installed packages. The apps for which skip returns true are left as
+// they are and aren't reported.
+func DiffServer(server string, apps map[string]string, installed *packages.Installed, available map[string][]*packages.Package, skip func(app string) bool) *ServerDiff {
+	d := &ServerDiff{
+		Server:     server,
+		Installed:  []string{},
+		Desired:    []string{},
+		Drift:      []*Drift{},
+		generation: installed.Generation,
+	}
+	current := map[string]string{}
+	for _, name := range installed.Names {
+		if isPlaceholder(name) {
+			continue
+		}
+		d.Installed = append(d.Installed, name)
+		current[appOf(name)] = name
+	}
+	sort.Strings(d.Installed)
+
+	allApps := []string{}
+	for app := range apps {
+		allApps = append(allApps, app)
+	}
+	for app := range current {
+		if _, ok := apps[app]; !ok {
+			allApps = append(allApps, app)
+		}
+	}
+	sort.Strings(allApps)
+
+	for _, app := range allApps {
+		installedName := current[app]
+		version, wanted := apps[app]
+		if skip != nil && skip(app) {
+			if installedName != "" {
+				d.Desired = append(d.Desired, installedName)
+			}
+			continue
+		}
+		if !wanted {
+			d.Drift = append(d.Drift, &Drift{App: app, Kind: KIND_EXTRA, Installed: installedName})
+			continue
+		}
+		desiredName, err := Resolve(app, version, available[app])
+		if err != nil {
+			d.Drift = append(d.Drift, &Drift{App: app, Kind: KIND_UNKNOWN, Installed: installedName, Message: err.Error()})
+			if installedName != "" {
+				d.Desired = append(d.Desired, installedName)
+			}
+			continue
+		}
+		d.Desired = append(d.Desired, desiredName)
+		if installedName == "" {
+			d.Drift = append(d.Drift, &Drift{App: app, Kind: KIND_MISSING, Desired: desiredName})
+		} else if installedName != desiredName {
+			d.Drift = append(d.Drift, &Drift{App: app, Kind: KIND_VERSION, Installed: installedName, Desired: desiredName})
+		}
+	}
+	sort.Strings(d.Desired)
+	return d
+}
+
+// Reconciler compares the desired state in a file with the packages
+// installed on the servers, and optionally changes the installed packages to
+// match.
+type Reconciler struct {
+	filename string
+	store    packages.PackageStore
+	skip     func(app string) bool
+	onChange func(server string)
+
+	mutex      sync.Mutex
+	last       []*ServerDiff
+	lastErr    error
+	lastUpdate time.Time
+}
+
+// NewReconciler creates a new Reconciler for the desired state in filename.
+// Apps for which skip returns true, e.g. because they are being rolled out,
+// are left alone. onChange is called for each server whose list of installed
+// packages is changed. Both skip and onChange may be nil.
+func NewReconciler(filename string, store packages.PackageStore, skip func(app string) bool, onChange func(server string)) *Reconciler {
+	return &Reconciler{
+		filename: filename,
+		store:    store,
+		skip:     skip,
+		onChange: onChange,
+	}
+}
+
+// Diff returns the difference between the desired state and the installed
+// packages of every server in the desired state, sorted by server name. The
+// desired state file is read again on every call.
+func (r *Reconciler) Diff() ([]*ServerDiff, error) {
+	state, err := Load(r.filename)
+	if err != nil {
+		return nil, err
+	}
+	available, err := r.store.AllAvailable()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve available packages: %s", err)
+	}
+	servers := []string{}
+	for server := range state.Servers {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	ret := []*ServerDiff{}
+	for _, server := range servers {
+		installed, err := r.store.InstalledForServer(server)
+		if err != nil {
+			ret = append(ret, &ServerDiff{
+				Server:    server,
+				Installed: []string{},
+				Desired:   []string{},
+				Drift:     []*Drift{},
+				Error:     err.Error(),
+			})
+			continue
+		}
+		ret = append(ret, DiffServer(server, state.Servers[server], installed, available, r.skip))
+	}
+	return ret, nil
+}
+
+// Apply changes the installed packages of every server in the desired state
+// to match it, and returns the differences it found.
+func (r *Reconciler) Apply() ([]*ServerDiff, error) {
+	diffs, err := r.Diff()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range diffs {
+		if !d.Changed() {
+			continue
+		}
+		glog.Infof("Reconciling %s from %q to %q", d.Server, d.Installed, d.Desired)
+		if err := r.store.PutInstalled(d.Server, d.Desired, d.generation); err != nil {
+			d.Error = err.Error()
+			glog.Errorf("Failed to reconcile %s: %s", d.Server, err)
+			continue
+		}
+		d.Applied = true
+		if r.onChange != nil {
+			r.onChange(d.Server)
+		}
+	}
+	return diffs, nil
+}
+
+// Last returns the differences found by the last call to Step, when they were
+// found, and the error of that Step if it failed.
+func (r *Reconciler) Last() ([]*ServerDiff, time.Time, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.last, r.lastUpdate, r.lastErr
+}
+
+// Step computes the differences, applying them if apply is true, and updates
+// the metrics and the result returned by Last.
+func (r *Reconciler) Step(apply bool) error {
+	var diffs []*ServerDiff
+	var err error
+	if apply {
+		diffs, err = r.Apply()
+	} else {
+		diffs, err = r.Diff()
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastErr = err
+	r.lastUpdate = time.Now()
+	if err != nil {
+		return err
+	}
+	r.last = diffs
+	updateMetrics(diffs)
+	return nil
+}
+
+// Run calls Step every period, forever.
+func (r *Reconciler) Run(period time.Duration, apply bool) {
+	for _ = range time.Tick(period) {
+		if err := r.Step(apply); err != nil {
+			glog.Errorf("Failed to reconcile desired state: %s", err)
+		}
+	}
+}
+
+// updateMetrics reports the number of drifting apps of each kind for each
+// server, before any changes were applied.
+func updateMetrics(diffs []*ServerDiff) {
+	for _, d := range diffs {
+		counts := map[string]int64{}
+		for _, drift := range d.Drift {
+			counts[drift.Kind]++
+		}
+		for _, kind := range KINDS {
+			metrics2.GetInt64Metric("push.drift", map[string]string{"server": d.Server, "kind": kind}).Update(counts[kind])
+		}
+		failed := int64(0)
+		if d.Error != "" {
+			failed = 1
+		}
+		metrics2.GetInt64Metric("push.drift.failed", map[string]string{"server": d.Server}).Update(failed)
+	}
+}
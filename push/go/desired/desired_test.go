@@ -0,0 +1,159 @@
+package desired
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/packages"
+	"go.skia.org/infra/go/testutils"
+)
+
+// packageName returns the name of a test package of the app built at the hash.
+func packageName(app, hash string) string {
+	return fmt.Sprintf("%s/%s:someone@example.org:2016-06-0%sT00:00:00Z:%s.deb", app, app, hash[:1], hash)
+}
+
+func available() map[string][]*packages.Package {
+	return map[string][]*packages.Package{
+		"logserverd": []*packages.Package{
+			{Name: packageName("logserverd", "3333"), Hash: "3333"},
+			{Name: packageName("logserverd", "2222"), Hash: "2222"},
+			{Name: packageName("logserverd", "1111"), Hash: "1111"},
+		},
+		"prober": []*packages.Package{
+			{Name: packageName("prober", "2abc"), Hash: "2abc"},
+		},
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ps := available()["logserverd"]
+	name, err := Resolve("logserverd", LATEST, ps)
+	assert.NoError(t, err)
+	assert.Equal(t, packageName("logserverd", "3333"), name)
+
+	name, err = Resolve("logserverd", "22", ps)
+	assert.NoError(t, err)
+	assert.Equal(t, packageName("logserverd", "2222"), name)
+
+	// Full names may leave off the app prefix.
+	name, err = Resolve("logserverd", packageName("logserverd", "1111")[len("logserverd/"):], ps)
+	assert.NoError(t, err)
+	assert.Equal(t, packageName("logserverd", "1111"), name)
+
+	_, err = Resolve("logserverd", "4444", ps)
+	assert.Error(t, err)
+	_, err = Resolve("logserverd", "logserverd/missing.deb", ps)
+	assert.Error(t, err)
+	_, err = Resolve("grafanad", LATEST, nil)
+	assert.Error(t, err)
+}
+
+func TestDiffServer(t *testing.T) {
+	installed := &packages.Installed{
+		Names: []string{
+			packageName("logserverd", "1111"),
+			packageName("pulld", "1111"),
+			"prober/",
+		},
+		Generation: 7,
+	}
+	apps := map[string]string{
+		"logserverd": LATEST,
+		"prober":     "2abc",
+		"grafanad":   LATEST,
+	}
+	d := DiffServer("skia-monitoring", apps, installed, available(), nil)
+	assert.Equal(t, []string{packageName("logserverd", "1111"), packageName("pulld", "1111")}, d.Installed)
+	assert.Equal(t, []string{packageName("logserverd", "3333"), packageName("prober", "2abc")}, d.Desired)
+	assert.Equal(t, []*Drift{
+		{App: "grafanad", Kind: KIND_UNKNOWN, Message: "No packages are available for grafanad."},
+		{App: "logserverd", Kind: KIND_VERSION, Installed: packageName("logserverd", "1111"), Desired: packageName("logserverd", "3333")},
+		{App: "prober", Kind: KIND_MISSING, Desired: packageName("prober", "2abc")},
+		{App: "pulld", Kind: KIND_EXTRA, Installed: packageName("pulld", "1111")},
+	}, d.Drift)
+	assert.True(t, d.Changed())
+	assert.Equal(t, int64(7), d.generation)
+
+	// Skipped apps are left as they are.
+	d = DiffServer("skia-monitoring", apps, installed, available(), func(app string) bool {
+		return app != "prober"
+	})
+	assert.Equal(t, []string{packageName("logserverd", "1111"), packageName("prober", "2abc"), packageName("pulld", "1111")}, d.Desired)
+	assert.Equal(t, 1, len(d.Drift))
+
+	// No drift.
+	d = DiffServer("skia-monitoring", map[string]string{"logserverd": "1"}, &packages.Installed{Names: []string{packageName("logserverd", "1111")}}, available(), nil)
+	assert.Equal(t, 0, len(d.Drift))
+	assert.False(t, d.Changed())
+}
+
+func TestReconciler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "desired")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	store, err := packages.NewLocalStore(filepath.Join(dir, "store"))
+	assert.NoError(t, err)
+	for _, p := range available()["logserverd"] {
+		name := filepath.Join(dir, "store", "debs", filepath.FromSlash(p.Name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.NoError(t, ioutil.WriteFile(name, []byte{}, 0644))
+		assert.NoError(t, ioutil.WriteFile(name+packages.METADATA_SUFFIX, []byte(fmt.Sprintf(`{"appname": "logserverd", "hash": %q, "datetime": "2016-06-0%sT00:00:00Z"}`, p.Hash, p.Hash[:1])), 0644))
+	}
+	assert.NoError(t, store.PutInstalled("skia-a", []string{packageName("logserverd", "1111")}, -1))
+	assert.NoError(t, store.PutInstalled("skia-untouched", []string{packageName("logserverd", "1111")}, -1))
+
+	filename := filepath.Join(dir, "desired.toml")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`
+[servers.skia-a]
+logserverd = "latest"
+
+[servers.skia-missing]
+logserverd = "2222"
+`), 0644))
+	changed := []string{}
+	r := NewReconciler(filename, store, nil, func(server string) {
+		changed = append(changed, server)
+	})
+
+	// A dry run doesn't change anything.
+	assert.NoError(t, r.Step(false))
+	diffs, _, err := r.Last()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(diffs))
+	assert.Equal(t, "skia-a", diffs[0].Server)
+	assert.Equal(t, KIND_VERSION, diffs[0].Drift[0].Kind)
+	assert.False(t, diffs[0].Applied)
+	assert.NotEqual(t, "", diffs[1].Error)
+	installed, err := store.InstalledForServer("skia-a")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{packageName("logserverd", "1111")}, installed.Names)
+
+	assert.NoError(t, r.Step(true))
+	diffs, _, err = r.Last()
+	assert.NoError(t, err)
+	assert.True(t, diffs[0].Applied)
+	assert.False(t, diffs[1].Applied)
+	assert.Equal(t, []string{"skia-a"}, changed)
+	installed, err = store.InstalledForServer("skia-a")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{packageName("logserverd", "3333")}, installed.Names)
+	installed, err = store.InstalledForServer("skia-untouched")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{packageName("logserverd", "1111")}, installed.Names)
+
+	// Once reconciled there is no drift.
+	diffs, err = r.Diff()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(diffs[0].Drift))
+
+	// Errors in the desired state file are reported.
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`[servers`), 0644))
+	assert.Error(t, r.Step(true))
+	_, _, err = r.Last()
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/login"
+	"go.skia.org/infra/push/go/desired"
+)
+
+// DesiredUI is the format of the drift from the desired state sent to the UI
+// as JSON.
+type DesiredUI struct {
+	// Enabled is false if push isn't running with a desired state.
+	Enabled bool `json:"enabled"`
+
+	// Reconcile is true if push applies the desired state continuously.
+	Reconcile bool                  `json:"reconcile"`
+	Diffs     []*desired.ServerDiff `json:"diffs"`
+	Updated   time.Time             `json:"updated"`
+	Error     string                `json:"error"`
+}
+
+func writeDesired(w http.ResponseWriter, diffs []*desired.ServerDiff, updated time.Time, err error) {
+	ui := DesiredUI{
+		Enabled:   reconciler != nil,
+		Reconcile: *reconcile,
+		Diffs:     diffs,
+		Updated:   updated,
+	}
+	if err != nil {
+		ui.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ui); err != nil {
+		glog.Errorf("Failed to write or encode output: %s", err)
+	}
+}
+
+// desiredHandler handles the GET of the JSON of the drift from the desired
+// state. With refresh=true it computes a fresh dry-run diff, otherwise it
+// returns the diff of the last reconcile.
+func desiredHandler(w http.ResponseWriter, r *http.Request) {
+	if reconciler == nil {
+		writeDesired(w, nil, time.Time{}, nil)
+		return
+	}
+	if r.FormValue("refresh") == "true" {
+		diffs, err := reconciler.Diff()
+		writeDesired(w, diffs, time.Now(), err)
+		return
+	}
+	diffs, updated, err := reconciler.Last()
+	writeDesired(w, diffs, updated, err)
+}
+
+// applyDesiredHandler handles the POST to change the installed packages to
+// match the desired state right away.
+func applyDesiredHandler(w http.ResponseWriter, r *http.Request) {
+	if !login.IsAdmin(r) {
+		httputils.ReportError(w, r, nil, "You must be logged on as an admin to push.")
+		return
+	}
+	if reconciler == nil {
+		httputils.ReportError(w, r, nil, "Push is not running with a desired state.")
+		return
+	}
+	glog.Infof("Desired state applied by %s", login.LoggedInAs(r))
+	if err := reconciler.Step(true); err != nil {
+		httputils.ReportError(w, r, err, "Failed to apply the desired state.")
+		return
+	}
+	diffs, updated, err := reconciler.Last()
+	writeDesired(w, diffs, updated, err)
+}
@@ -26,6 +26,8 @@ import (
 	"go.skia.org/infra/go/packages"
 	"go.skia.org/infra/go/systemd"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/push/go/desired"
+	"go.skia.org/infra/push/go/rollout"
 	compute "google.golang.org/api/compute/v1"
 	storage "google.golang.org/api/storage/v1"
 )
@@ -50,14 +52,21 @@ var (
 	// fastClient is an HTTP client that is unauthorized and fails quickly.
 	fastClient *http.Client
 
-	// store is an Google Storage API client authorized to read and write gs://skia-push.
-	store *storage.Service
+	// store is where the packages and the lists of installed packages are kept.
+	store packages.PackageStore
 
 	// comp is an Google Compute API client authorized to read compute information.
 	comp *compute.Service
 
 	// packageInfo is a cache of info about packages.
 	packageInfo *packages.AllInfo
+
+	// rollouts runs the staged rollouts of packages.
+	rollouts *rollout.Manager
+
+	// reconciler compares the installed packages with the desired state, nil
+	// if there is no desired state.
+	reconciler *desired.Reconciler
 )
 
 // flags
@@ -68,6 +77,10 @@ var (
 	resourcesDir   = flag.String("resources_dir", "", "The directory to find templates, JS, and CSS files. If blank the current directory will be used.")
 	project        = flag.String("project", "google.com:skia-buildbots", "The Google Compute Engine project.")
 	bucketName     = flag.String("bucket_name", "skia-push", "The name of the Google Storage bucket that contains push packages and info.")
+	packageDir     = flag.String("package_dir", "", "If set, packages and info are kept in this local directory instead of the Google Storage bucket. See go/packages.LocalStore.")
+	rolloutState   = flag.String("rollout_state", "rollouts.json", "The file the state of staged rollouts is kept in.")
+	desiredState   = flag.String("desired_state", "", "If set, the toml file of the desired packages of each server. See push/go/desired.")
+	reconcile      = flag.Bool("reconcile", false, "If true, continuously change the installed packages to match --desired_state. Otherwise only report the drift.")
 
 	influxHost     = flag.String("influxdb_host", influxdb.DEFAULT_HOST, "The InfluxDB hostname.")
 	influxUser     = flag.String("influxdb_name", influxdb.DEFAULT_USER, "The InfluxDB username.")
@@ -113,27 +126,59 @@ func Init() {
 
 	serverNames = config.AllServerNames()
 
-	if client, err = auth.NewDefaultJWTServiceAccountClient(auth.SCOPE_FULL_CONTROL, auth.SCOPE_GCE); err != nil {
-		glog.Fatalf("Failed to create authenticated HTTP client: %s", err)
-	}
-
 	fastClient = NewFastTimeoutClient()
 
-	if store, err = storage.New(client); err != nil {
-		glog.Fatalf("Failed to create storage service client: %s", err)
-	}
-	if comp, err = compute.New(client); err != nil {
-		glog.Fatalf("Failed to create compute service client: %s", err)
+	if *packageDir != "" {
+		// A self-contained setup that doesn't talk to Google Cloud, so servers
+		// are reached by their names.
+		if store, err = packages.NewLocalStore(*packageDir); err != nil {
+			glog.Fatalf("Failed to create local package store: %s", err)
+		}
+		ip = &IPAddresses{ip: map[string]string{}}
+	} else {
+		if client, err = auth.NewDefaultJWTServiceAccountClient(auth.SCOPE_FULL_CONTROL, auth.SCOPE_GCE); err != nil {
+			glog.Fatalf("Failed to create authenticated HTTP client: %s", err)
+		}
+		gcs, err := storage.New(client)
+		if err != nil {
+			glog.Fatalf("Failed to create storage service client: %s", err)
+		}
+		store = packages.NewGCSStore(client, gcs, *bucketName)
+		if comp, err = compute.New(client); err != nil {
+			glog.Fatalf("Failed to create compute service client: %s", err)
+		}
+		ip, err = NewIPAddresses(comp)
+		if err != nil {
+			glog.Fatalf("Failed to load IP addresses at startup: %s", err)
+		}
 	}
-	ip, err = NewIPAddresses(comp)
+
+	packageInfo, err = packages.NewAllInfo(store, serverNames)
 	if err != nil {
-		glog.Fatalf("Failed to load IP addresses at startup: %s", err)
+		glog.Fatalf("Failed to create packages.AllInfo at startup: %s", err)
 	}
 
-	packages.SetBucketName(*bucketName)
-	packageInfo, err = packages.NewAllInfo(client, store, serverNames)
+	plans, err := rollout.LoadPlans(*configFilename)
 	if err != nil {
-		glog.Fatalf("Failed to create packages.AllInfo at startup: %s", err)
+		glog.Fatalf("Failed to load rollout plans: %s", err)
+	}
+	rollouts, err = rollout.NewManager(*rolloutState, plans, rolloutInstaller{}, rolloutChecker{}, fastClient)
+	if err != nil {
+		glog.Fatalf("Failed to create rollout.Manager at startup: %s", err)
+	}
+	go rollouts.Run(time.Minute)
+
+	if *desiredState != "" {
+		reconciler = desired.NewReconciler(*desiredState, store, rollouts.Running, func(server string) {
+			if err := packageInfo.ForceRefresh(); err != nil {
+				glog.Errorf("Failed to refresh: %s", err)
+			}
+			triggerPull(server)
+		})
+		if err := reconciler.Step(*reconcile); err != nil {
+			glog.Errorf("Failed to reconcile desired state: %s", err)
+		}
+		go reconciler.Run(time.Minute, *reconcile)
 	}
 }
 
@@ -256,6 +301,34 @@ func getStatus(server string) []*systemd.UnitStatus {
 	return ret
 }
 
+// getInstalled returns the packages that pulld reports as installed on the
+// server, which can lag behind the list in the package store.
+func getInstalled(server string) ([]string, error) {
+	resp, err := fastClient.Get(fmt.Sprintf("http://%s:10114/_/installed", ip.Resolve(server)))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get installed packages of %s: %s", server, err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Failed to get installed packages of %s: status code %d", server, resp.StatusCode)
+	}
+	installed := []string{}
+	if err := json.NewDecoder(resp.Body).Decode(&installed); err != nil {
+		return nil, fmt.Errorf("Failed to decode installed packages of %s: %s", server, err)
+	}
+	return installed, nil
+}
+
+// triggerPull asks pulld on the server to check for new packages right away.
+func triggerPull(server string) {
+	resp, err := fastClient.Get(fmt.Sprintf("http://%s:10114/pullpullpull", server))
+	if err != nil || resp == nil {
+		glog.Infof("Failed to trigger an instant pull for server %s: %v %v", server, err, resp)
+	} else {
+		util.Close(resp.Body)
+	}
+}
+
 // serviceStatus returns a map[string]*systemd.UnitStatus, with one entry for each service running on each
 // server. The keys for the return value are "<server_name>:<service_name>", for example,
 // "skia-push:logserverd.service".
@@ -384,12 +457,7 @@ func stateHandler(w http.ResponseWriter, r *http.Request) {
 				httputils.ReportError(w, r, err, "Failed to update server.")
 				return
 			}
-			resp, err := fastClient.Get(fmt.Sprintf("http://%s:10114/pullpullpull", push.Server))
-			if err != nil || resp == nil {
-				glog.Infof("Failed to trigger an instant pull for server %s: %v %v", push.Server, err, resp)
-			} else {
-				util.Close(resp.Body)
-			}
+			triggerPull(push.Server)
 			allInstalled[push.Server].Names = newInstalled
 		}
 	}
@@ -512,6 +580,11 @@ func main() {
 	r.HandleFunc("/_/change", changeHandler)
 	r.HandleFunc("/_/state", stateHandler)
 	r.HandleFunc("/_/status", statusHandler)
+	r.HandleFunc("/_/rollouts", rolloutsHandler).Methods("GET")
+	r.HandleFunc("/_/rollout", startRolloutHandler).Methods("POST")
+	r.HandleFunc("/_/rollout/abort", abortRolloutHandler).Methods("POST")
+	r.HandleFunc("/_/desired", desiredHandler).Methods("GET")
+	r.HandleFunc("/_/desired/apply", applyDesiredHandler).Methods("POST")
 	r.HandleFunc("/loginstatus/", login.StatusHandler)
 	r.HandleFunc("/logout/", login.LogoutHandler)
 	r.HandleFunc("/oauth2callback/", login.OAuth2CallbackHandler)
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/login"
+	"go.skia.org/infra/go/util"
+)
+
+// rolloutInstaller implements rollout.Installer by updating the list of
+// installed packages of a server in the package store and asking its pulld to
+// pick up the change.
+type rolloutInstaller struct{}
+
+func (rolloutInstaller) Install(server, app, packageName string) (string, error) {
+	// Always read the latest list, the cached one may have an old generation.
+	installed, err := store.InstalledForServer(server)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read installed packages of %s: %s", server, err)
+	}
+	prev := ""
+	newInstalled := []string{}
+	for _, name := range installed.Names {
+		if strings.Split(name, "/")[0] == app {
+			prev = name
+			if packageName != "" {
+				newInstalled = append(newInstalled, packageName)
+			}
+		} else {
+			newInstalled = append(newInstalled, name)
+		}
+	}
+	if prev == "" && packageName != "" {
+		newInstalled = append(newInstalled, packageName)
+	}
+	glog.Infof("Rollout updating %s with %#v giving %#v", server, packageName, newInstalled)
+	if err := packageInfo.PutInstalled(server, newInstalled, installed.Generation); err != nil {
+		return "", err
+	}
+	triggerPull(server)
+	return prev, nil
+}
+
+// rolloutChecker implements rollout.Checker by checking that pulld on the
+// server has installed the package and that all of its services are active.
+type rolloutChecker struct{}
+
+func (rolloutChecker) Check(server, packageName string) error {
+	p, ok := packageInfo.AllAvailableByPackageName()[packageName]
+	if !ok {
+		return fmt.Errorf("Unknown package %s", packageName)
+	}
+	installed, err := getInstalled(server)
+	if err != nil {
+		return err
+	}
+	if !util.In(packageName, installed) {
+		return fmt.Errorf("%s is not installed on %s yet", packageName, server)
+	}
+	allStatus := getStatus(server)
+	if allStatus == nil {
+		return fmt.Errorf("Failed to get the status of the services on %s", server)
+	}
+	active := map[string]bool{}
+	for _, status := range allStatus {
+		if status.Status != nil {
+			active[status.Status.Name] = status.Status.ActiveState == "active"
+		}
+	}
+	for _, service := range p.Services {
+		if !active[service] {
+			return fmt.Errorf("%s is not active on %s", service, server)
+		}
+	}
+	return nil
+}
+
+// StartRollout is the form of the JSON requests we receive from the UI to
+// start a staged rollout of a package.
+type StartRollout struct {
+	// Name is the unique package id, such as 'pull/pull:jcgregori....'.
+	Name string `json:"name"`
+}
+
+// rolloutsHandler handles the GET of the JSON of all the rollouts.
+func rolloutsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rollouts.Rollouts()); err != nil {
+		glog.Errorf("Failed to write or encode output: %s", err)
+	}
+}
+
+// startRolloutHandler handles the POST of a StartRollout, which starts a
+// staged rollout of the package to every server configured to run its app.
+func startRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	if !login.IsAdmin(r) {
+		httputils.ReportError(w, r, nil, "You must be logged on as an admin to push.")
+		return
+	}
+	start := StartRollout{}
+	defer util.Close(r.Body)
+	if err := json.NewDecoder(r.Body).Decode(&start); err != nil {
+		httputils.ReportError(w, r, err, "Failed to decode rollout request.")
+		return
+	}
+	appName := strings.Split(start.Name, "/")[0]
+	if _, err := rollouts.Start(start.Name, config.AllServerNamesWithPackage(appName), login.LoggedInAs(r)); err != nil {
+		httputils.ReportError(w, r, err, fmt.Sprintf("Failed to start rollout: %s", err))
+		return
+	}
+	rolloutsHandler(w, r)
+}
+
+// abortRolloutHandler handles the POST to abort the running rollout given by
+// the 'id' query parameter, which reverts the servers it already updated.
+func abortRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	if !login.IsAdmin(r) {
+		httputils.ReportError(w, r, nil, "You must be logged on as an admin to push.")
+		return
+	}
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid rollout id.")
+		return
+	}
+	if err := rollouts.Abort(id, login.LoggedInAs(r)); err != nil {
+		httputils.ReportError(w, r, err, fmt.Sprintf("Failed to abort rollout: %s", err))
+		return
+	}
+	rolloutsHandler(w, r)
+}
@@ -22,6 +22,8 @@ var (
 	force          = flag.Bool("force", false, "If true then install the package even if it hasn't previously been installed on the given server.")
 	dryrun         = flag.Bool("dryrun", false, "If true don't actually push, but just log what actions would be taken.")
 	configFilename = flag.String("config_filename", "skiapush.conf", "Config filename used by Push.")
+	bucketName     = flag.String("bucket_name", "skia-push", "The name of the Google Storage bucket that contains push packages and info.")
+	packageDir     = flag.String("package_dir", "", "If set, packages and info are kept in this local directory instead of the Google Storage bucket. See go/packages.LocalStore.")
 )
 
 func init() {
@@ -56,17 +58,29 @@ func main() {
 	serverName := args[1] // "skia-debugger" or "*"
 
 	// Create the needed clients.
-	client, err := auth.NewDefaultJWTServiceAccountClient(storage.DevstorageReadWriteScope, compute.ComputeReadonlyScope)
-	if err != nil {
-		glog.Fatalf("Failed to create authenticated HTTP client: %s\nDid you run get_service_account?", err)
-	}
-	store, err := storage.New(client)
-	if err != nil {
-		glog.Fatalf("Failed to create storage service client: %s", err)
-	}
-	comp, err := compute.New(client)
-	if err != nil {
-		glog.Fatalf("Failed to create compute service client: %s", err)
+	var store packages.PackageStore
+	var comp *compute.Service
+	client := http.DefaultClient
+	if *packageDir != "" {
+		var err error
+		if store, err = packages.NewLocalStore(*packageDir); err != nil {
+			glog.Fatalf("Failed to create local package store: %s", err)
+		}
+	} else {
+		var err error
+		client, err = auth.NewDefaultJWTServiceAccountClient(storage.DevstorageReadWriteScope, compute.ComputeReadonlyScope)
+		if err != nil {
+			glog.Fatalf("Failed to create authenticated HTTP client: %s\nDid you run get_service_account?", err)
+		}
+		gcs, err := storage.New(client)
+		if err != nil {
+			glog.Fatalf("Failed to create storage service client: %s", err)
+		}
+		store = packages.NewGCSStore(client, gcs, *bucketName)
+		comp, err = compute.New(client)
+		if err != nil {
+			glog.Fatalf("Failed to create compute service client: %s", err)
+		}
 	}
 
 	servers, err := expand(appName, serverName)
@@ -99,16 +113,16 @@ func expand(appName, s string) ([]string, error) {
 
 // installOnServer installs the named app on the compute engine instance of the given name.  It then tries to force
 // pulld to pick up the changes by pinging the ip address of the server directly.
-func installOnServer(client *http.Client, store *storage.Service, comp *compute.Service, appName, serverName string) error {
+func installOnServer(client *http.Client, store packages.PackageStore, comp *compute.Service, appName, serverName string) error {
 	// Get the current set of packages installed on the server.
-	installed, err := packages.InstalledForServer(client, store, serverName)
+	installed, err := store.InstalledForServer(serverName)
 	if err != nil {
 		return fmt.Errorf("Failed to get the current installed packages on %s: %s", serverName, err)
 	}
 	glog.Infof("Installed Packages on %s:\n%s", serverName, strings.Join(installed.Names, "\n"))
 
 	// Get the sorted list of available versions of the given package.
-	available, err := packages.AllAvailableApp(store, appName)
+	available, err := store.AllAvailableApp(appName)
 	if err != nil {
 		return fmt.Errorf("Failed to get the list of available versions for package %s: %s", appName, err)
 	}
@@ -139,10 +153,10 @@ func installOnServer(client *http.Client, store *storage.Service, comp *compute.
 
 	if *dryrun {
 		glog.Info("Is in dry run mode.  Would be calling")
-		glog.Infof(`packages.PutInstalled(store, "%s", %q, %d)`, serverName, newInstalled, installed.Generation)
+		glog.Infof(`store.PutInstalled("%s", %q, %d)`, serverName, newInstalled, installed.Generation)
 	} else {
-		// Write the new list of packages back to Google Storage.
-		if err := packages.PutInstalled(store, serverName, newInstalled, installed.Generation); err != nil {
+		// Write the new list of packages back to the store.
+		if err := store.PutInstalled(serverName, newInstalled, installed.Generation); err != nil {
 			return fmt.Errorf("Failed to write updated package for %s: %s", appName, err)
 		}
 	}
@@ -168,8 +182,12 @@ func installOnServer(client *http.Client, store *storage.Service, comp *compute.
 	return nil
 }
 
-// findIPAddress returns the ip address of the server with the given name.
+// findIPAddress returns the ip address of the server with the given name. If
+// comp is nil then the name itself is returned.
 func findIPAddress(comp *compute.Service, name string) (string, error) {
+	if comp == nil {
+		return name, nil
+	}
 	// We have to look in each zone for the server with the given name.
 	zones, err := comp.Zones.List(*project).Do()
 	if err != nil {
@@ -0,0 +1,534 @@
+// rollout pushes a package to all the servers of an app in stages.
+//
+// A rollout first installs the package on the canary servers of the app and
+// then on the remaining servers in waves. After each wave is installed its
+// servers must stay healthy for the gate duration before the next wave is
+// started. If any server fails its health check then every server updated by
+// the rollout is reverted to the package it had installed before.
+package rollout
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/util"
+)
+
+const (
+	// States of a Rollout.
+	STATE_RUNNING         = "running"
+	STATE_SUCCEEDED       = "succeeded"
+	STATE_ROLLED_BACK     = "rolled back"
+	STATE_ROLLBACK_FAILED = "rollback failed"
+
+	// The defaults for a Plan.
+	DEFAULT_GATE_MINUTES  = 10
+	DEFAULT_GRACE_MINUTES = 2
+
+	// The number of finished rollouts that are kept.
+	MAX_FINISHED_ROLLOUTS = 50
+)
+
+// Plan describes how a package is rolled out for an app. It is loaded from
+// the [rollouts.{appname}] sections of skiapush.conf, for example:
+//
+//    [rollouts.logserverd]
+//    canaries = ["skia-testing-b"]
+//    waveSize = 2
+//    gateMinutes = 15
+//    healthURL = "http://{server}:10115/"
+//
+type Plan struct {
+	// Canaries are the servers which get the package first, in a wave of their
+	// own. If empty the first server of the app is used.
+	Canaries []string
+
+	// WaveSize is the number of servers in each of the remaining waves. If zero
+	// all the remaining servers are in one wave.
+	WaveSize int
+
+	// GateMinutes is how long the servers of a wave must stay healthy before
+	// the next wave is started.
+	GateMinutes int
+
+	// GraceMinutes is how long after a wave is installed before the health
+	// checks count, which gives pulld time to install the package and restart
+	// the services.
+	GraceMinutes int
+
+	// HealthURL, if not empty, must return a 200 for each server of a wave
+	// during the gate. The text "{server}" is replaced with the server name.
+	// It can point at the app itself, or at a prober check for it.
+	HealthURL string
+}
+
+// DefaultPlan is used for apps that don't have a Plan configured.
+var DefaultPlan = &Plan{
+	GateMinutes:  DEFAULT_GATE_MINUTES,
+	GraceMinutes: DEFAULT_GRACE_MINUTES,
+}
+
+// LoadPlans loads the rollout plans from the given config file, keyed by app
+// name.
+func LoadPlans(filename string) (map[string]*Plan, error) {
+	var config struct {
+		Rollouts map[string]*Plan
+	}
+	if _, err := toml.DecodeFile(filename, &config); err != nil {
+		return nil, fmt.Errorf("Failed to decode config file: %s", err)
+	}
+	if config.Rollouts == nil {
+		config.Rollouts = map[string]*Plan{}
+	}
+	for _, p := range config.Rollouts {
+		if p.GateMinutes <= 0 {
+			p.GateMinutes = DEFAULT_GATE_MINUTES
+		}
+		if p.GraceMinutes < 0 {
+			p.GraceMinutes = 0
+		}
+	}
+	return config.Rollouts, nil
+}
+
+// Waves splits the servers into the waves of the plan, canaries first.
+// Canaries which aren't in servers are ignored.
+func (p *Plan) Waves(servers []string) [][]string {
+	remaining := append([]string{}, servers...)
+	sort.Strings(remaining)
+	canaries := []string{}
+	for _, c := range p.Canaries {
+		if util.In(c, remaining) {
+			canaries = append(canaries, c)
+		}
+	}
+	if len(canaries) == 0 && len(remaining) > 0 {
+		canaries = []string{remaining[0]}
+	}
+	rest := []string{}
+	for _, s := range remaining {
+		if !util.In(s, canaries) {
+			rest = append(rest, s)
+		}
+	}
+
+	waves := [][]string{}
+	if len(canaries) > 0 {
+		waves = append(waves, canaries)
+	}
+	size := p.WaveSize
+	if size <= 0 {
+		size = len(rest)
+	}
+	for len(rest) > 0 {
+		n := size
+		if n > len(rest) {
+			n = len(rest)
+		}
+		waves = append(waves, rest[:n])
+		rest = rest[n:]
+	}
+	return waves
+}
+
+// Installer changes which package of an app is installed on a server.
+type Installer interface {
+	// Install makes packageName the installed package of the app on the
+	// server and returns the name of the package it replaced, or "" if the
+	// app wasn't installed. If packageName is "" then the app is removed.
+	Install(server, app, packageName string) (string, error)
+}
+
+// Checker checks the health of a package on a server.
+type Checker interface {
+	// Check returns an error if the services of the package aren't healthy on
+	// the server.
+	Check(server, packageName string) error
+}
+
+// Rollout is the state of the rollout of a single package.
+type Rollout struct {
+	Id          int64      `json:"id"`
+	App         string     `json:"app"`
+	Package     string     `json:"package"`
+	User        string     `json:"user"`
+	State       string     `json:"state"`
+	Message     string     `json:"message"`
+	Waves       [][]string `json:"waves"`
+	HealthURL   string     `json:"healthURL"`
+	GateMinutes int        `json:"gateMinutes"`
+	Grace       int        `json:"graceMinutes"`
+
+	// Wave is the index of the current wave.
+	Wave int `json:"wave"`
+
+	// WaveStarted is when the current wave was installed, or zero if it hasn't
+	// been installed yet.
+	WaveStarted time.Time `json:"waveStarted"`
+
+	// Previous maps each server the rollout has installed the package on to
+	// the package that was installed before.
+	Previous map[string]string `json:"previous"`
+
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+}
+
+// Done returns true if the rollout has finished.
+func (r *Rollout) Done() bool {
+	return r.State != STATE_RUNNING
+}
+
+func (r *Rollout) copy() *Rollout {
+	rv := &Rollout{}
+	*rv = *r
+	rv.Waves = make([][]string, len(r.Waves))
+	for i, w := range r.Waves {
+		rv.Waves[i] = append([]string{}, w...)
+	}
+	rv.Previous = make(map[string]string, len(r.Previous))
+	for k, v := range r.Previous {
+		rv.Previous[k] = v
+	}
+	return rv
+}
+
+// CheckHealthURL returns an error if the health URL, with "{server}" replaced
+// by the server name, doesn't respond with a 200.
+func CheckHealthURL(c *http.Client, healthURL, server string) error {
+	url := strings.Replace(healthURL, "{server}", server, -1)
+	resp, err := c.Get(url)
+	if err != nil {
+		return fmt.Errorf("Health check of %s failed: %s", url, err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Health check of %s failed: status code %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// Manager runs rollouts and persists their state, so that running rollouts
+// continue after a restart.
+type Manager struct {
+	// stepMutex serializes Step and Abort, which install packages and check
+	// health without holding mutex.
+	stepMutex sync.Mutex
+
+	// mutex protects rollouts and nextId.
+	mutex     sync.Mutex
+	filename  string
+	installer Installer
+	checker   Checker
+	client    *http.Client
+	plans     map[string]*Plan
+
+	// rollouts is sorted by Id, oldest first.
+	rollouts []*Rollout
+	nextId   int64
+}
+
+// NewManager creates a new Manager which stores its state in filename.
+// The client is used for health URL checks.
+func NewManager(filename string, plans map[string]*Plan, installer Installer, checker Checker, client *http.Client) (*Manager, error) {
+	m := &Manager{
+		filename:  filename,
+		installer: installer,
+		checker:   checker,
+		client:    client,
+		plans:     plans,
+		rollouts:  []*Rollout{},
+		nextId:    1,
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err == nil {
+		if err := json.Unmarshal(b, &m.rollouts); err != nil {
+			return nil, fmt.Errorf("Failed to decode rollout state from %s: %s", filename, err)
+		}
+		for _, r := range m.rollouts {
+			if r.Id >= m.nextId {
+				m.nextId = r.Id + 1
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Failed to read rollout state: %s", err)
+	}
+	return m, nil
+}
+
+// Start begins the rollout of the package to the given servers, which must be
+// all the servers the package's app is installed on. Only one rollout of an
+// app can be running at a time.
+func (m *Manager) Start(packageName string, servers []string, user string) (*Rollout, error) {
+	app := strings.Split(packageName, "/")[0]
+	if app == "" || app == packageName {
+		return nil, fmt.Errorf("Not a valid package name: %q", packageName)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("No servers run %s.", app)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, r := range m.rollouts {
+		if r.App == app && !r.Done() {
+			return nil, fmt.Errorf("A rollout of %s is already running.", app)
+		}
+	}
+	plan, ok := m.plans[app]
+	if !ok {
+		plan = DefaultPlan
+	}
+	r := &Rollout{
+		Id:          m.nextId,
+		App:         app,
+		Package:     packageName,
+		User:        user,
+		State:       STATE_RUNNING,
+		Waves:       plan.Waves(servers),
+		HealthURL:   plan.HealthURL,
+		GateMinutes: plan.GateMinutes,
+		Grace:       plan.GraceMinutes,
+		Previous:    map[string]string{},
+		Started:     time.Now(),
+	}
+	m.nextId++
+	m.rollouts = append(m.rollouts, r)
+	glog.Infof("Starting rollout %d of %s in waves %q", r.Id, packageName, r.Waves)
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+	return r.copy(), nil
+}
+
+// Rollouts returns copies of all the known rollouts, newest first.
+func (m *Manager) Rollouts() []*Rollout {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	rv := make([]*Rollout, 0, len(m.rollouts))
+	for i := len(m.rollouts) - 1; i >= 0; i-- {
+		rv = append(rv, m.rollouts[i].copy())
+	}
+	return rv
+}
+
+// Running returns true if a rollout of the app is running.
+func (m *Manager) Running(app string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, r := range m.rollouts {
+		if r.App == app && !r.Done() {
+			return true
+		}
+	}
+	return false
+}
+
+// Abort stops the running rollout with the given id and reverts the servers
+// it has updated.
+func (m *Manager) Abort(id int64, user string) error {
+	m.stepMutex.Lock()
+	defer m.stepMutex.Unlock()
+	var r *Rollout
+	m.mutex.Lock()
+	for _, rollout := range m.rollouts {
+		if rollout.Id == id {
+			r = rollout.copy()
+			break
+		}
+	}
+	m.mutex.Unlock()
+	if r == nil {
+		return fmt.Errorf("Unknown rollout: %d", id)
+	}
+	if r.Done() {
+		return fmt.Errorf("Rollout %d has already finished.", id)
+	}
+
+	m.rollback(r, fmt.Sprintf("Aborted by %s.", user), time.Now())
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.update([]*Rollout{r})
+	return m.save()
+}
+
+// Step moves all the running rollouts forward, as of the given time. It is
+// called periodically by Run.
+//
+// The running rollouts are copied and stepped without holding the mutex, since
+// installing packages and checking health can be slow, and then the changed
+// copies replace the originals.
+func (m *Manager) Step(now time.Time) error {
+	m.stepMutex.Lock()
+	defer m.stepMutex.Unlock()
+	running := []*Rollout{}
+	m.mutex.Lock()
+	for _, r := range m.rollouts {
+		if !r.Done() {
+			running = append(running, r.copy())
+		}
+	}
+	m.mutex.Unlock()
+
+	changed := []*Rollout{}
+	for _, r := range running {
+		if m.step(r, now) {
+			changed = append(changed, r)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.update(changed)
+	m.trim()
+	return m.save()
+}
+
+// Run calls Step every period, forever.
+func (m *Manager) Run(period time.Duration) {
+	for _ = range time.Tick(period) {
+		if err := m.Step(time.Now()); err != nil {
+			glog.Errorf("Failed to step rollouts: %s", err)
+		}
+	}
+}
+
+// step moves the rollout forward and returns true if its state changed.
+func (m *Manager) step(r *Rollout, now time.Time) bool {
+	wave := r.Waves[r.Wave]
+	if r.WaveStarted.IsZero() {
+		for _, server := range wave {
+			prev, err := m.installer.Install(server, r.App, r.Package)
+			if err != nil {
+				m.rollback(r, fmt.Sprintf("Failed to install on %s: %s", server, err), now)
+				return true
+			}
+			r.Previous[server] = prev
+		}
+		glog.Infof("Rollout %d installed wave %d: %q", r.Id, r.Wave, wave)
+		r.WaveStarted = now
+		r.Message = fmt.Sprintf("Installed wave %d of %d.", r.Wave+1, len(r.Waves))
+		return true
+	}
+
+	gateStart := r.WaveStarted.Add(time.Duration(r.Grace) * time.Minute)
+	if now.Before(gateStart) {
+		return false
+	}
+	for _, server := range wave {
+		err := m.checker.Check(server, r.Package)
+		if err == nil && r.HealthURL != "" {
+			err = CheckHealthURL(m.client, r.HealthURL, server)
+		}
+		if err != nil {
+			m.rollback(r, fmt.Sprintf("Wave %d failed its health check on %s: %s", r.Wave+1, server, err), now)
+			return true
+		}
+	}
+	if now.Before(gateStart.Add(time.Duration(r.GateMinutes) * time.Minute)) {
+		return false
+	}
+
+	r.Wave++
+	r.WaveStarted = time.Time{}
+	if r.Wave == len(r.Waves) {
+		// Leave Wave pointing at the last wave.
+		r.Wave--
+		r.State = STATE_SUCCEEDED
+		r.Message = fmt.Sprintf("Installed on all %d waves.", len(r.Waves))
+		r.Finished = now
+		glog.Infof("Rollout %d of %s succeeded.", r.Id, r.Package)
+		return true
+	}
+	// Install the next wave right away.
+	m.step(r, now)
+	return true
+}
+
+// rollback reverts every server the rollout installed the package on.
+func (m *Manager) rollback(r *Rollout, reason string, now time.Time) {
+	glog.Warningf("Rolling back rollout %d of %s: %s", r.Id, r.Package, reason)
+	r.State = STATE_ROLLED_BACK
+	r.Message = reason
+	r.Finished = now
+	servers := make([]string, 0, len(r.Previous))
+	for server := range r.Previous {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	failed := []string{}
+	for _, server := range servers {
+		if _, err := m.installer.Install(server, r.App, r.Previous[server]); err != nil {
+			glog.Errorf("Failed to roll back %s on %s: %s", r.App, server, err)
+			failed = append(failed, server)
+		}
+	}
+	if len(failed) > 0 {
+		r.State = STATE_ROLLBACK_FAILED
+		r.Message = fmt.Sprintf("%s Failed to roll back %s.", reason, strings.Join(failed, ", "))
+	}
+}
+
+// update replaces the rollouts with the same Ids as the given ones.
+func (m *Manager) update(changed []*Rollout) {
+	byId := make(map[int64]*Rollout, len(changed))
+	for _, r := range changed {
+		byId[r.Id] = r
+	}
+	for i, r := range m.rollouts {
+		if c, ok := byId[r.Id]; ok {
+			m.rollouts[i] = c
+		}
+	}
+}
+
+// trim drops the oldest finished rollouts beyond MAX_FINISHED_ROLLOUTS.
+func (m *Manager) trim() {
+	finished := 0
+	for _, r := range m.rollouts {
+		if r.Done() {
+			finished++
+		}
+	}
+	keep := make([]*Rollout, 0, len(m.rollouts))
+	for _, r := range m.rollouts {
+		if r.Done() && finished > MAX_FINISHED_ROLLOUTS {
+			finished--
+			continue
+		}
+		keep = append(keep, r)
+	}
+	m.rollouts = keep
+}
+
+// save writes the rollouts to the state file.
+func (m *Manager) save() error {
+	b, err := json.MarshalIndent(m.rollouts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to encode rollout state: %s", err)
+	}
+	tmp := m.filename + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(m.filename), 0755); err != nil {
+		return fmt.Errorf("Failed to create rollout state dir: %s", err)
+	}
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("Failed to write rollout state: %s", err)
+	}
+	if err := os.Rename(tmp, m.filename); err != nil {
+		return fmt.Errorf("Failed to write rollout state: %s", err)
+	}
+	return nil
+}
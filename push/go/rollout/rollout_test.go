@@ -0,0 +1,302 @@
+package rollout
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+const (
+	OLD_PACKAGE = "myapp/myapp:someone@example.org:2016-06-01T00:00:00Z:aaaa.deb"
+	NEW_PACKAGE = "myapp/myapp:someone@example.org:2016-06-02T00:00:00Z:bbbb.deb"
+)
+
+// fakeInstaller keeps the installed package of a single app for each server.
+type fakeInstaller struct {
+	installed map[string]string
+	fail      map[string]bool
+}
+
+func (f *fakeInstaller) Install(server, app, packageName string) (string, error) {
+	if f.fail[server] {
+		return "", fmt.Errorf("%s is down", server)
+	}
+	prev := f.installed[server]
+	f.installed[server] = packageName
+	return prev, nil
+}
+
+// fakeChecker fails the checks of the packages in unhealthy.
+type fakeChecker struct {
+	unhealthy map[string]bool
+	checked   []string
+}
+
+func (f *fakeChecker) Check(server, packageName string) error {
+	f.checked = append(f.checked, server)
+	if f.unhealthy[packageName] {
+		return fmt.Errorf("%s is crashing", packageName)
+	}
+	return nil
+}
+
+// blockingInstaller signals on installing and then waits on release for each
+// Install.
+type blockingInstaller struct {
+	fakeInstaller
+	installing chan string
+	release    chan bool
+}
+
+func (b *blockingInstaller) Install(server, app, packageName string) (string, error) {
+	b.installing <- server
+	<-b.release
+	return b.fakeInstaller.Install(server, app, packageName)
+}
+
+func setup(t *testing.T, plans map[string]*Plan) (*Manager, *fakeInstaller, *fakeChecker, string) {
+	dir, err := ioutil.TempDir("", "rollout")
+	assert.NoError(t, err)
+	installer := &fakeInstaller{
+		installed: map[string]string{"a": OLD_PACKAGE, "b": OLD_PACKAGE, "c": OLD_PACKAGE, "d": ""},
+		fail:      map[string]bool{},
+	}
+	checker := &fakeChecker{unhealthy: map[string]bool{}}
+	m, err := NewManager(filepath.Join(dir, "rollouts.json"), plans, installer, checker, http.DefaultClient)
+	assert.NoError(t, err)
+	return m, installer, checker, dir
+}
+
+func TestWaves(t *testing.T) {
+	servers := []string{"e", "d", "c", "b", "a"}
+	assert.Equal(t, [][]string{{"a"}, {"b", "c", "d", "e"}}, DefaultPlan.Waves(servers))
+	p := &Plan{Canaries: []string{"c", "unknown"}, WaveSize: 3}
+	assert.Equal(t, [][]string{{"c"}, {"a", "b", "d"}, {"e"}}, p.Waves(servers))
+	assert.Equal(t, [][]string{{"a"}}, p.Waves([]string{"a"}))
+	assert.Equal(t, [][]string{}, p.Waves([]string{}))
+}
+
+func TestLoadPlans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rollout")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	filename := filepath.Join(dir, "skiapush.conf")
+	conf := `
+[servers]
+  [servers.a]
+  appNames = ["myapp"]
+
+[rollouts]
+  [rollouts.myapp]
+  canaries = ["a"]
+  waveSize = 2
+  healthURL = "http://{server}:8000/"
+`
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(conf), 0644))
+	plans, err := LoadPlans(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Plan{
+		"myapp": &Plan{
+			Canaries:    []string{"a"},
+			WaveSize:    2,
+			GateMinutes: DEFAULT_GATE_MINUTES,
+			HealthURL:   "http://{server}:8000/",
+		},
+	}, plans)
+}
+
+func TestRolloutSucceeds(t *testing.T) {
+	m, installer, checker, dir := setup(t, map[string]*Plan{
+		"myapp": &Plan{Canaries: []string{"b"}, WaveSize: 1, GateMinutes: 10, GraceMinutes: 1},
+	})
+	defer testutils.RemoveAll(t, dir)
+
+	r, err := m.Start(NEW_PACKAGE, []string{"a", "b", "c"}, "someone@example.org")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"b"}, {"a"}, {"c"}}, r.Waves)
+	_, err = m.Start(NEW_PACKAGE, []string{"a"}, "someone@example.org")
+	assert.Error(t, err)
+	assert.True(t, m.Running("myapp"))
+	assert.False(t, m.Running("otherapp"))
+
+	// The canary is installed first.
+	now := time.Now()
+	assert.NoError(t, m.Step(now))
+	assert.Equal(t, NEW_PACKAGE, installer.installed["b"])
+	assert.Equal(t, OLD_PACKAGE, installer.installed["a"])
+
+	// Nothing is checked during the grace period, and the next wave waits for
+	// the gate.
+	assert.NoError(t, m.Step(now.Add(30*time.Second)))
+	assert.Equal(t, 0, len(checker.checked))
+	assert.NoError(t, m.Step(now.Add(5*time.Minute)))
+	assert.Equal(t, []string{"b"}, checker.checked)
+	assert.Equal(t, OLD_PACKAGE, installer.installed["a"])
+
+	// Once the gate passes the next wave is installed right away.
+	now = now.Add(11 * time.Minute)
+	assert.NoError(t, m.Step(now))
+	assert.Equal(t, NEW_PACKAGE, installer.installed["a"])
+	assert.Equal(t, OLD_PACKAGE, installer.installed["c"])
+	r = m.Rollouts()[0]
+	assert.Equal(t, 1, r.Wave)
+	assert.Equal(t, STATE_RUNNING, r.State)
+
+	now = now.Add(11 * time.Minute)
+	assert.NoError(t, m.Step(now))
+	now = now.Add(11 * time.Minute)
+	assert.NoError(t, m.Step(now))
+	r = m.Rollouts()[0]
+	assert.Equal(t, STATE_SUCCEEDED, r.State)
+	assert.Equal(t, now, r.Finished)
+	assert.Equal(t, NEW_PACKAGE, installer.installed["c"])
+	assert.Equal(t, map[string]string{"a": OLD_PACKAGE, "b": OLD_PACKAGE, "c": OLD_PACKAGE}, r.Previous)
+
+	assert.False(t, m.Running("myapp"))
+
+	// A new rollout of the app can be started now.
+	_, err = m.Start(OLD_PACKAGE, []string{"a"}, "someone@example.org")
+	assert.NoError(t, err)
+}
+
+func TestRolloutRollsBack(t *testing.T) {
+	m, installer, checker, dir := setup(t, map[string]*Plan{})
+	defer testutils.RemoveAll(t, dir)
+
+	// The app is new on d, so it is removed again on a rollback.
+	_, err := m.Start(NEW_PACKAGE, []string{"a", "b", "d"}, "someone@example.org")
+	assert.NoError(t, err)
+	now := time.Now()
+	assert.NoError(t, m.Step(now))
+	now = now.Add(time.Duration(DEFAULT_GRACE_MINUTES+DEFAULT_GATE_MINUTES) * time.Minute)
+	assert.NoError(t, m.Step(now))
+	assert.Equal(t, NEW_PACKAGE, installer.installed["d"])
+
+	checker.unhealthy[NEW_PACKAGE] = true
+	now = now.Add(time.Duration(DEFAULT_GRACE_MINUTES) * time.Minute)
+	assert.NoError(t, m.Step(now))
+	r := m.Rollouts()[0]
+	assert.Equal(t, STATE_ROLLED_BACK, r.State)
+	assert.True(t, strings.Contains(r.Message, "is crashing"), r.Message)
+	assert.Equal(t, map[string]string{"a": OLD_PACKAGE, "b": OLD_PACKAGE, "c": OLD_PACKAGE, "d": ""}, installer.installed)
+
+	// Finished rollouts aren't stepped.
+	assert.NoError(t, m.Step(now.Add(time.Hour)))
+	assert.Equal(t, OLD_PACKAGE, installer.installed["a"])
+}
+
+func TestRolloutInstallFailure(t *testing.T) {
+	m, installer, _, dir := setup(t, map[string]*Plan{
+		"myapp": &Plan{Canaries: []string{"a", "b"}, GateMinutes: 1},
+	})
+	defer testutils.RemoveAll(t, dir)
+
+	installer.fail["b"] = true
+	_, err := m.Start(NEW_PACKAGE, []string{"a", "b"}, "someone@example.org")
+	assert.NoError(t, err)
+	assert.NoError(t, m.Step(time.Now()))
+	r := m.Rollouts()[0]
+	assert.Equal(t, STATE_ROLLED_BACK, r.State)
+	assert.Equal(t, OLD_PACKAGE, installer.installed["a"])
+
+	// Servers that can't be reverted are reported.
+	_, err = m.Start(NEW_PACKAGE, []string{"a", "c"}, "someone@example.org")
+	assert.NoError(t, err)
+	assert.NoError(t, m.Step(time.Now()))
+	installer.fail["a"] = true
+	assert.NoError(t, m.Abort(r.Id+1, "someone@example.org"))
+	r = m.Rollouts()[0]
+	assert.Equal(t, STATE_ROLLBACK_FAILED, r.State)
+	assert.True(t, strings.Contains(r.Message, "Aborted by someone@example.org. Failed to roll back a."), r.Message)
+	assert.Error(t, m.Abort(r.Id, "someone@example.org"))
+}
+
+func TestStepDoesNotBlockReaders(t *testing.T) {
+	m, installer, _, dir := setup(t, map[string]*Plan{})
+	defer testutils.RemoveAll(t, dir)
+	blocking := &blockingInstaller{
+		fakeInstaller: *installer,
+		installing:    make(chan string),
+		release:       make(chan bool),
+	}
+	m.installer = blocking
+
+	_, err := m.Start(NEW_PACKAGE, []string{"a", "b"}, "someone@example.org")
+	assert.NoError(t, err)
+	stepped := make(chan error)
+	go func() {
+		stepped <- m.Step(time.Now())
+	}()
+	assert.Equal(t, "a", <-blocking.installing)
+
+	// The rollouts can be read and started while a wave is being installed.
+	done := make(chan bool)
+	go func() {
+		assert.Equal(t, 1, len(m.Rollouts()))
+		assert.True(t, m.Running("myapp"))
+		_, err := m.Start("otherapp/otherapp:someone@example.org:2016-06-02T00:00:00Z:cccc.deb", []string{"a"}, "someone@example.org")
+		assert.NoError(t, err)
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Rollouts were locked during Install.")
+	}
+
+	// The rollout of otherapp started after the Step began, so it waits for
+	// the next Step.
+	blocking.release <- true
+	assert.NoError(t, <-stepped)
+	rollouts := m.Rollouts()
+	assert.Equal(t, 2, len(rollouts))
+	assert.Equal(t, OLD_PACKAGE, rollouts[1].Previous["a"])
+	assert.False(t, rollouts[1].WaveStarted.IsZero())
+	assert.True(t, rollouts[0].WaveStarted.IsZero())
+}
+
+func TestRolloutPersistence(t *testing.T) {
+	m, installer, checker, dir := setup(t, map[string]*Plan{})
+	defer testutils.RemoveAll(t, dir)
+
+	_, err := m.Start(NEW_PACKAGE, []string{"a", "b"}, "someone@example.org")
+	assert.NoError(t, err)
+	now := time.Now()
+	assert.NoError(t, m.Step(now))
+
+	// A new Manager continues the rollout where the old one left off.
+	m, err = NewManager(m.filename, map[string]*Plan{}, installer, checker, http.DefaultClient)
+	assert.NoError(t, err)
+	rollouts := m.Rollouts()
+	assert.Equal(t, 1, len(rollouts))
+	assert.Equal(t, OLD_PACKAGE, rollouts[0].Previous["a"])
+	assert.True(t, now.Equal(rollouts[0].WaveStarted))
+	assert.NoError(t, m.Step(now.Add(time.Hour)))
+	assert.Equal(t, NEW_PACKAGE, installer.installed["b"])
+
+	r, err := m.Start(NEW_PACKAGE, []string{"c"}, "someone@example.org")
+	assert.Error(t, err)
+	m.Step(now.Add(2 * time.Hour))
+	r, err = m.Start(OLD_PACKAGE, []string{"c"}, "someone@example.org")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), r.Id)
+}
+
+func TestCheckHealthURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthy" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	assert.NoError(t, CheckHealthURL(http.DefaultClient, ts.URL+"/{server}", "healthy"))
+	assert.Error(t, CheckHealthURL(http.DefaultClient, ts.URL+"/{server}", "sick"))
+}
@@ -17,6 +17,7 @@ import (
 
 var (
 	rolloverLogs   = common.NewMultiStringFlag("rollover_logs", nil, "A set of log file paths that may roll over.  e.g. supply run_isolated.log to monitor run_isolated.log and run_isolated.log.1")
+	globLogs       = common.NewMultiStringFlag("glob_logs", nil, "A set of name:format:pattern triples.  All files matching the glob pattern, including ones created later, are parsed with the given format and reported as name.  Formats are "+strings.Join(logparser.FormatNames(), ", ")+".  e.g. swarming:swarming_bot:/b/s/logs/*.log")
 	persistenceDir = flag.String("persistence_dir", "/var/cloudlogger", "The directory in which persistence data regarding the logging progress should be kept.")
 
 	pollPeriod = flag.Duration("poll_period", 1*time.Minute, `The period used to poll the log files`)
@@ -51,6 +52,18 @@ func main() {
 		scanners = append(scanners, logagents.NewRollover(logparser.ParsePythonLog, cleanupName(r), r, r+".1"))
 	}
 
+	for _, g := range *globLogs {
+		parts := strings.SplitN(g, ":", 3)
+		if len(parts) != 3 {
+			sklog.Fatalf("Invalid --glob_logs value %q, must be name:format:pattern", g)
+		}
+		parse, ok := logparser.Formats[parts[1]]
+		if !ok {
+			sklog.Fatalf("Unknown log format %q, must be one of %s", parts[1], strings.Join(logparser.FormatNames(), ", "))
+		}
+		scanners = append(scanners, logagents.NewGlobScanner(parse, parts[0], parts[2]))
+	}
+
 	scan(scanners, *pollPeriod)
 }
 
@@ -7,15 +7,16 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
-	"net"
+	"os"
 	"strings"
 	"time"
 
 	"go.skia.org/infra/go/auth"
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/exec"
+	"go.skia.org/infra/go/failover"
 	"go.skia.org/infra/go/influxdb"
-	"go.skia.org/infra/go/metrics2"
+	"go.skia.org/infra/go/sharedb"
 	"go.skia.org/infra/go/sklog"
 )
 
@@ -29,6 +30,11 @@ var (
 	livenessPeriod    = flag.Duration("liveness_period", time.Second, "How often to test the livenessAddr")
 	livenessTimeout   = flag.Duration("liveness_timeout", time.Second, "How long to wait for the livenessAddr to respond/connect.")
 	livenessThreshold = flag.Int("liveness_threshold", 5, "How many liveness failures in a row constitute the livenessAddr being down.")
+	recoverThreshold  = flag.Int("recover_threshold", 1, "How many liveness successes in a row constitute the livenessAddr being back up.")
+
+	leaseFile    = flag.String("lease_file", "", "If set, a file on a file system shared with the other spares that holds the lease a spare must have to bring up the virtual ip.")
+	leaseShareDB = flag.String("lease_sharedb", "", "If set, the address of a sharedb server that holds the lease a spare must have to bring up the virtual ip.")
+	leaseTTL     = flag.Duration("lease_ttl", 30*time.Second, "How long a lease lasts without being renewed.")
 
 	syncPeriod     = flag.Duration("sync_period", time.Minute, "How often to sync the image from syncPath.")
 	syncRemotePath = flag.String("sync_remote_path", "", `Where the image is stored on the remote machine.  This should include ip address.  E.g. "192.168.1.198:/opt/rpi_img/prod.img"`)
@@ -43,44 +49,6 @@ var (
 	stopServingPlaybook  = flag.String("stop_serving_playbook", "", "The Ansible playbook that, when run locally, will stop serving the image.  This should be idempotent.")
 )
 
-type virtualIPManager struct {
-	Addr                string
-	Period              time.Duration
-	Timeout             time.Duration
-	Threshold           int
-	consecutiveFailures int
-}
-
-func NewVirtualIPManager(addr string, period, timeout time.Duration, threshold int) *virtualIPManager {
-	return &virtualIPManager{
-		Addr:                addr,
-		Period:              period,
-		Timeout:             timeout,
-		Threshold:           threshold,
-		consecutiveFailures: 0,
-	}
-}
-
-func (v *virtualIPManager) Run() {
-	for range time.Tick(v.Period) {
-		conn, err := net.DialTimeout("tcp", v.Addr, v.Timeout)
-		if err != nil {
-			sklog.Errorf("Had problem connecting to %s: %s", v.Addr, err)
-			v.consecutiveFailures++
-			if v.consecutiveFailures == v.Threshold {
-				bringUpVIP()
-			}
-		} else {
-			sklog.Infof("Connected successfully to %s: %v\n", v.Addr, conn.Close())
-			if v.consecutiveFailures >= v.Threshold {
-				tearDownVIP()
-			}
-			v.consecutiveFailures = 0
-		}
-		metrics2.GetInt64Metric("skolo.hotspare.consecutive_failures", nil).Update(int64(v.consecutiveFailures))
-	}
-}
-
 func isServing() bool {
 	out, err := exec.RunSimple("ifconfig")
 	if err != nil {
@@ -89,24 +57,42 @@ func isServing() bool {
 	return strings.Contains(out, *virtualInterface)
 }
 
-func bringUpVIP() {
+func bringUpVIP() error {
 	sklog.Infof("Bringing up VIP, master is dead")
 	cmd := fmt.Sprintf("sudo ifconfig %s %s", *virtualInterface, *virtualIp)
 	out, err := exec.RunSimple(cmd)
 	sklog.Infof("Output: %s", out)
 	if err != nil {
-		sklog.Errorf("Could not bring up VIP: %s", err)
+		return fmt.Errorf("Could not bring up VIP: %s", err)
 	}
+	return nil
 }
 
-func tearDownVIP() {
+func tearDownVIP() error {
 	sklog.Infof("Tearing down VIP, master is live")
 	cmd := fmt.Sprintf("sudo ifconfig %s down", *virtualInterface)
 	out, err := exec.RunSimple(cmd)
 	sklog.Infof("Output: %s", out)
 	if err != nil {
-		sklog.Errorf("Could not tear down VIP: %s", err)
+		return fmt.Errorf("Could not tear down VIP: %s", err)
 	}
+	return nil
+}
+
+// newLease returns the failover.Lease given by the flags, or nil if no lease
+// is configured.
+func newLease() (failover.Lease, error) {
+	if *leaseFile != "" {
+		return failover.NewFileLease(*leaseFile), nil
+	}
+	if *leaseShareDB != "" {
+		client, err := sharedb.New(*leaseShareDB)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to connect to sharedb: %s", err)
+		}
+		return failover.NewShareDBLease(client, "hotspare", "leases", *virtualIp), nil
+	}
+	return nil, nil
 }
 
 type imageSyncer struct {
@@ -210,8 +196,21 @@ func main() {
 		sklog.Fatalf("Could not setup cloud sklog: %s", err)
 	}
 
-	lt := NewVirtualIPManager(*livenessAddr, *livenessPeriod, *livenessTimeout, *livenessThreshold)
-	go lt.Run()
+	action := failover.ActionFuncs{TakeoverFunc: bringUpVIP, ReleaseFunc: tearDownVIP}
+	m := failover.New("skolo.hotspare", failover.NewTCPCheck(*livenessAddr, *livenessTimeout), action, *livenessThreshold, *recoverThreshold)
+	m.SetActive(isServing())
+	lease, err := newLease()
+	if err != nil {
+		sklog.Fatalf("Failed to create lease: %s", err)
+	}
+	if lease != nil {
+		hostname, err := os.Hostname()
+		if err != nil {
+			sklog.Fatalf("Could not get hostname: %s", err)
+		}
+		m.SetLease(lease, hostname, *leaseTTL)
+	}
+	go m.Run(*livenessPeriod)
 
 	is := NewImageSyncer(*syncPeriod, *syncRemotePath, *syncLocalPath)
 	go is.Run()
@@ -0,0 +1,182 @@
+package logagents
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/skolo/go/logparser"
+)
+
+// The most bytes read from a single file in one scan. Anything beyond that is read on the next
+// scan, so the raspberry pi doesn't run out of memory on large files.
+const MAX_SCAN_BYTES = 4 * 1024 * 1024
+
+// globLog watches all the files matching a glob pattern, including files that are created after it
+// starts. It remembers how far it has read each file, so only new lines are read and reported.
+//
+// The last log read from a file is held back until the next scan, because more lines of it, like
+// the rest of a stack trace, may not have been written yet. If nothing is added to the file by the
+// next scan the held back log is reported as is.
+type globLog struct {
+	Name    string
+	Parse   logparser.Parser `json:"-"`
+	Pattern string
+	Files   map[string]*fileProgress
+
+	IsFirstScan bool
+}
+
+// fileProgress is how far a file matched by a globLog has been read.
+type fileProgress struct {
+	// Offset is the number of bytes of the file which have been read.
+	Offset int64
+	// Pending is the text of the held back log.
+	Pending string
+}
+
+// NewGlobScanner returns a LogScanner which reports the logs in all files that match the pattern,
+// as interpreted by filepath.Glob. Logs that are in the files when the scanner is first created
+// are not reported, but new files are reported from their start.
+func NewGlobScanner(parse logparser.Parser, reportName, pattern string) LogScanner {
+	log := &globLog{
+		Name:        reportName,
+		Parse:       parse,
+		Pattern:     pattern,
+		Files:       map[string]*fileProgress{},
+		IsFirstScan: true,
+	}
+	if err := readFromPersistenceFile(reportName, log); err != nil {
+		sklog.Warningf("Could not read from persistence file for %s.  Starting with default values: %s", reportName, err)
+	}
+	if log.Files == nil {
+		log.Files = map[string]*fileProgress{}
+	}
+	return log
+}
+
+func (g *globLog) ReportName() string {
+	return g.Name
+}
+
+func (g *globLog) Scan(client sklog.CloudLogger) error {
+	matches, err := filepath.Glob(g.Pattern)
+	if err != nil {
+		return fmt.Errorf("Bad pattern %s: %s", g.Pattern, err)
+	}
+	sort.Strings(matches)
+	seen := map[string]bool{}
+	for _, path := range matches {
+		fi, err := os.Stat(path)
+		if err != nil {
+			sklog.Warningf("Could not stat %s: %s", path, err)
+			continue
+		}
+		if fi.IsDir() {
+			continue
+		}
+		seen[path] = true
+		fp, ok := g.Files[path]
+		if !ok {
+			fp = &fileProgress{}
+			if g.IsFirstScan {
+				fp.Offset = fi.Size()
+			}
+			g.Files[path] = fp
+			sklog.Infof("Following %s from offset %d", path, fp.Offset)
+		}
+		if fi.Size() < fp.Offset {
+			sklog.Infof("Detected truncation of %s", path)
+			g.flush(client, fp)
+			fp.Offset = 0
+		}
+		size := fi.Size() - fp.Offset
+		if size > MAX_SCAN_BYTES {
+			size = MAX_SCAN_BYTES
+		}
+		text, err := readLines(path, fp.Offset, size)
+		if err != nil {
+			return fmt.Errorf("Problem reading log file %s: %s", path, err)
+		}
+		if text == "" {
+			g.flush(client, fp)
+			continue
+		}
+		fp.Offset += int64(len(text))
+		lp := g.Parse(fp.Pending + text)
+		g.reportLogs(client, lp, 0, lp.Len()-1)
+		fp.Pending = lp.RawLine(lp.Len() - 1)
+	}
+
+	// Forget files that have gone away.
+	for path, fp := range g.Files {
+		if !seen[path] {
+			sklog.Infof("%s is gone", path)
+			g.flush(client, fp)
+			delete(g.Files, path)
+		}
+	}
+
+	g.IsFirstScan = false
+	return writeToPersistenceFile(g.ReportName(), g)
+}
+
+// flush reports the held back log of a file, if any.
+func (g *globLog) flush(client sklog.CloudLogger, fp *fileProgress) {
+	if fp.Pending == "" {
+		return
+	}
+	lp := g.Parse(fp.Pending)
+	g.reportLogs(client, lp, 0, lp.Len())
+	fp.Pending = ""
+}
+
+// reportLogs reports the logs in [start, end) in batches.
+func (g *globLog) reportLogs(client sklog.CloudLogger, lp logparser.ParsedLog, start, end int) {
+	logs := make([]*sklog.LogPayload, 0, BATCH_REPORT_SIZE)
+	for i := start; i < end; i++ {
+		log := lp.ReadLine(i)
+		if log == nil {
+			continue
+		}
+		logs = append(logs, log)
+		if len(logs) >= BATCH_REPORT_SIZE {
+			client.BatchCloudLog(g.ReportName(), logs...)
+			logs = nil
+		}
+	}
+	if len(logs) > 0 {
+		client.BatchCloudLog(g.ReportName(), logs...)
+	}
+}
+
+// readLines reads size bytes from the file, starting at offset, and returns the complete lines in
+// them. If there is no newline in MAX_SCAN_BYTES bytes then they are all returned, so a very long
+// line doesn't stop the file from being read.
+func readLines(path string, offset, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer util.Close(f)
+	if _, err := f.Seek(offset, os.SEEK_SET); err != nil {
+		return "", err
+	}
+	buf := make([]byte, size)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	buf = buf[:n]
+	if i := bytes.LastIndexByte(buf, '\n'); i != -1 {
+		buf = buf[:i+1]
+	} else if n < MAX_SCAN_BYTES {
+		buf = buf[:0]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,110 @@
+package logagents
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/skolo/go/logparser"
+)
+
+// recordingCloudLogger keeps the payloads it is sent.
+type recordingCloudLogger struct {
+	payloads []*sklog.LogPayload
+}
+
+func (r *recordingCloudLogger) CloudLog(reportName string, payload *sklog.LogPayload) {
+	r.payloads = append(r.payloads, payload)
+}
+
+func (r *recordingCloudLogger) BatchCloudLog(reportName string, payloads ...*sklog.LogPayload) {
+	r.payloads = append(r.payloads, payloads...)
+}
+
+func (r *recordingCloudLogger) Payloads() []string {
+	rv := []string{}
+	for _, p := range r.payloads {
+		rv = append(rv, p.Payload)
+	}
+	r.payloads = nil
+	return rv
+}
+
+func appendToFile(t *testing.T, path, s string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(s)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+}
+
+func TestGlobScanner(t *testing.T) {
+	mockOutPersistence()
+	dir, err := ioutil.TempDir("", "globscanner")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+
+	// Logs that are there before the first scan are not reported.
+	first := filepath.Join(dir, "first.log")
+	appendToFile(t, first, "2016/05/27 15:20:15 Old news\n")
+	g := NewGlobScanner(logparser.ParseGoLog, "golog", filepath.Join(dir, "*.log"))
+	logger := &recordingCloudLogger{}
+	assert.NoError(t, g.Scan(logger))
+	assert.Equal(t, []string{}, logger.Payloads())
+
+	// The last log is held back until the next scan, so the rest of a panic is kept with it.
+	appendToFile(t, first, "2016/05/27 15:20:16 One\n2016/05/27 15:20:17 Two\npanic: oops\n")
+	assert.NoError(t, g.Scan(logger))
+	assert.Equal(t, []string{"One"}, logger.Payloads())
+	appendToFile(t, first, "goroutine 1 [running]:\n2016/05/27 15:20:18 Three\n2016/05/27 15:20:19 Partial")
+	assert.NoError(t, g.Scan(logger))
+	assert.Equal(t, []string{"Two\npanic: oops\ngoroutine 1 [running]:"}, logger.Payloads())
+	assert.NoError(t, g.Scan(logger))
+	assert.Equal(t, []string{"Three"}, logger.Payloads())
+
+	// New files are followed from the start and partial lines are read once complete.
+	second := filepath.Join(dir, "second.log")
+	appendToFile(t, second, "2016/05/27 15:20:19 Hello\n")
+	appendToFile(t, first, " line\n")
+	assert.NoError(t, g.Scan(logger))
+	assert.NoError(t, g.Scan(logger))
+	assert.Equal(t, []string{"Partial line", "Hello"}, logger.Payloads())
+
+	// Truncated files are read from the start, removed files are forgotten.
+	assert.NoError(t, ioutil.WriteFile(first, []byte("2016/05/27 15:20:20 Rotated\n"), 0644))
+	assert.NoError(t, os.Remove(second))
+	assert.NoError(t, g.Scan(logger))
+	assert.NoError(t, g.Scan(logger))
+	assert.Equal(t, []string{"Rotated"}, logger.Payloads())
+	assert.Equal(t, 1, len(g.(*globLog).Files))
+}
+
+func TestGlobScannerPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "globscanner")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	writeToPersistenceFile = _writeToPersistenceFile
+	readFromPersistenceFile = _readFromPersistenceFile
+	defer mockOutPersistence()
+	assert.NoError(t, SetPersistenceDir(filepath.Join(dir, "persist")))
+
+	log := filepath.Join(dir, "test.log")
+	appendToFile(t, log, "2016/05/27 15:20:15 Old news\n")
+	logger := &recordingCloudLogger{}
+	g := NewGlobScanner(logparser.ParseGoLog, "golog", filepath.Join(dir, "*.log"))
+	assert.NoError(t, g.Scan(logger))
+	appendToFile(t, log, "2016/05/27 15:20:16 One\n2016/05/27 15:20:17 Two\n")
+	assert.NoError(t, g.Scan(logger))
+	assert.Equal(t, []string{"One"}, logger.Payloads())
+
+	// A new scanner picks up where the last one left off.
+	g = NewGlobScanner(logparser.ParseGoLog, "golog", filepath.Join(dir, "*.log"))
+	appendToFile(t, log, "2016/05/27 15:20:18 Three\n")
+	assert.NoError(t, g.Scan(logger))
+	assert.Equal(t, []string{"Two"}, logger.Payloads())
+	assert.Equal(t, false, g.(*globLog).IsFirstScan)
+}
@@ -3,9 +3,12 @@ package logparser
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -31,6 +34,9 @@ type ParsedLog interface {
 	ReadAndNext() *sklog.LogPayload
 	// ReadLine sets the line to the specified number and returns the parsed line at that location.
 	ReadLine(int) *sklog.LogPayload
+	// RawLine returns the unparsed text of the specified line, including any continuation lines
+	// and the trailing newline.
+	RawLine(int) string
 }
 
 type logParser struct {
@@ -88,18 +94,45 @@ func (p *logParser) ReadLine(i int) *sklog.LogPayload {
 	return nil
 }
 
-// syslog doesn't have a year in its logs.  We will interpret it as in "this year".
-var currentYear int
+func (p *logParser) RawLine(i int) string {
+	if i >= 0 && i < len(p.Content) {
+		return p.Content[i]
+	}
+	return ""
+}
+
+// Formats maps the names of the supported log formats to their parsers.
+var Formats = map[string]Parser{
+	"glog":         ParseGlog,
+	"golog":        ParseGoLog,
+	"json":         ParseJSONLog,
+	"python":       ParsePythonLog,
+	"swarming_bot": ParseSwarmingBotLog,
+	"syslog":       ParseSyslog,
+}
+
+// FormatNames returns the sorted names of the supported log formats.
+func FormatNames() []string {
+	names := make([]string, 0, len(Formats))
+	for name := range Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
 func ParseSyslog(contents string) ParsedLog {
+	// syslog doesn't have a year in its logs.  We will interpret it as in "this year",
+	// taken any time a new parsed log is created.  This will hopefully minimize
+	// problems around Dec 31.
+	year := time.Now().Year()
 	p := &logParser{
 		SplitLine: syslogLine,
-		ParseLine: parseSysLog,
-		Line:      0,
+		ParseLine: func(line string) *sklog.LogPayload {
+			return parseSysLog(line, year)
+		},
+		Line: 0,
 	}
-	// Update current year any time a new parsed log is created.
-	// This will hopefully minimize problems around Dec 31.
-	currentYear = time.Now().Year()
 	p.init(bytes.NewBufferString(contents))
 	return p
 }
@@ -110,7 +143,7 @@ var syslogParse = regexp.MustCompile(`(?s)(?P<time>\S\S\S \d\d \d\d:\d\d:\d\d) (
 
 const syslogRef = "Jan 2 15:04:05 2006"
 
-func parseSysLog(line string) *sklog.LogPayload {
+func parseSysLog(line string, year int) *sklog.LogPayload {
 	p := sklog.LogPayload{}
 	matches := syslogParse.FindStringSubmatch(line)
 	if len(matches) < 4 {
@@ -118,7 +151,7 @@ func parseSysLog(line string) *sklog.LogPayload {
 		return &p
 	}
 	// matches[1] is time, [3] is payload
-	t := fmt.Sprintf("%s %d", matches[1], currentYear)
+	t := fmt.Sprintf("%s %d", matches[1], year)
 	if parsed, err := time.ParseInLocation(syslogRef, t, time.Local); err == nil {
 		p.Time = parsed
 	} else {
@@ -176,3 +209,227 @@ func parsePythonLog(line string) *sklog.LogPayload {
 
 	return &p
 }
+
+func ParseGlog(contents string) ParsedLog {
+	// glog doesn't have a year in its logs either.
+	year := time.Now().Year()
+	p := &logParser{
+		SplitLine: glogLine,
+		ParseLine: func(line string) *sklog.LogPayload {
+			return parseGlog(line, year)
+		},
+		Line: 0,
+	}
+	p.init(bytes.NewBufferString(contents))
+	return p
+}
+
+// glog has a format like [severity][month][day] [time(implicitly local)] [thread] [file:line]] [payload]
+var glogLine = regexp.MustCompile(`^[IWEF]\d\d\d\d \d\d:\d\d:\d\d\.\d{6}\s+\d+ [^ ]+:\d+\] `)
+var glogParse = regexp.MustCompile(`(?s)^(?P<severity>[IWEF])(?P<time>\d\d\d\d \d\d:\d\d:\d\d\.\d{6})\s+\d+ (?P<payload>.*)`)
+
+var glogHeader = regexp.MustCompile(`^Log file created at: (\d\d\d\d/\d\d/\d\d \d\d:\d\d:\d\d)`)
+
+const glogRef = "0102 15:04:05.000000 2006"
+
+var glogSeverity = map[string]string{
+	"I": sklog.INFO,
+	"W": sklog.WARNING,
+	"E": sklog.ERROR,
+	"F": sklog.ALERT,
+}
+
+func parseGlog(line string, year int) *sklog.LogPayload {
+	p := sklog.LogPayload{}
+	if m := glogHeader.FindStringSubmatch(line); m != nil {
+		// The header glog writes at the start of every file.
+		if parsed, err := time.ParseInLocation("2006/01/02 15:04:05", m[1], time.Local); err == nil {
+			p.Time = parsed
+		}
+		p.Payload = strings.TrimSpace(line)
+		p.Severity = sklog.INFO
+		return &p
+	}
+	matches := glogParse.FindStringSubmatch(line)
+	if len(matches) < 4 {
+		sklog.CloudLogError("glogparser", fmt.Errorf("Problem parsing glog line: \n%s\n", line))
+		return &p
+	}
+	// matches[1] is severity, [2] is time, [3] is payload, starting with file:line]
+	t := fmt.Sprintf("%s %d", matches[2], year)
+	if parsed, err := time.ParseInLocation(glogRef, t, time.Local); err == nil {
+		p.Time = parsed
+	} else {
+		sklog.CloudLogError("glogparser", fmt.Errorf("Problem parsing date: %s\n", err))
+	}
+	p.Payload = strings.TrimSpace(matches[3])
+	p.Severity = glogSeverity[matches[1]]
+
+	return &p
+}
+
+func ParseGoLog(contents string) ParsedLog {
+	p := &logParser{
+		SplitLine: goLogLine,
+		ParseLine: parseGoLog,
+		Line:      0,
+	}
+	p.init(bytes.NewBufferString(contents))
+	return p
+}
+
+// The Go standard log has a format like [date] [time(implicitly local)] [payload], where the time
+// may have microseconds and the payload may start with file:line: depending on the log flags.
+// Panics are not prefixed and end up in the payload of the preceding line.
+var goLogLine = regexp.MustCompile(`^\d\d\d\d/\d\d/\d\d \d\d:\d\d:\d\d(\.\d{6})? `)
+var goLogParse = regexp.MustCompile(`(?s)^(?P<time>\d\d\d\d/\d\d/\d\d \d\d:\d\d:\d\d(\.\d{6})?) (?P<payload>.*)`)
+
+// goPanic matches the lines which start a panic or a runtime crash.
+var goPanic = regexp.MustCompile(`(?m)^(panic: |fatal error: )`)
+
+const goLogRef = "2006/01/02 15:04:05"
+
+func parseGoLog(line string) *sklog.LogPayload {
+	p := sklog.LogPayload{}
+	matches := goLogParse.FindStringSubmatch(line)
+	if len(matches) < 4 {
+		if goPanic.MatchString(line) {
+			// A crash before anything was logged.
+			p.Payload = strings.TrimSpace(line)
+			p.Severity = sklog.CRITICAL
+			return &p
+		}
+		sklog.CloudLogError("gologparser", fmt.Errorf("Problem parsing Go log line: \n%s\n", line))
+		return &p
+	}
+	// matches[1] is time, [3] is payload. The fractional seconds are optional and accepted by
+	// time.Parse even though the reference doesn't have them.
+	if parsed, err := time.ParseInLocation(goLogRef, matches[1], time.Local); err == nil {
+		p.Time = parsed
+	} else {
+		sklog.CloudLogError("gologparser", fmt.Errorf("Problem parsing date: %s\n", err))
+	}
+	p.Payload = strings.TrimSpace(matches[3])
+	p.Severity = sklog.INFO
+	if goPanic.MatchString(p.Payload) {
+		p.Severity = sklog.CRITICAL
+	}
+
+	return &p
+}
+
+func ParseJSONLog(contents string) ParsedLog {
+	p := &logParser{
+		SplitLine: jsonLogLine,
+		ParseLine: parseJSONLog,
+		Line:      0,
+	}
+	p.init(bytes.NewBufferString(contents))
+	return p
+}
+
+// JSON-lines logs have one JSON object per line. Lines which are not JSON objects, such as stack
+// traces written by a crashing program, are appended to the payload of the preceding line.
+var jsonLogLine = regexp.MustCompile(`^\s*\{`)
+
+// The keys which are commonly used for the time, severity and message of a JSON log entry.
+var jsonTimeKeys = []string{"time", "timestamp", "ts"}
+var jsonSeverityKeys = []string{"severity", "level"}
+var jsonMessageKeys = []string{"message", "msg"}
+
+var jsonLogSeverity = map[string]string{
+	"DEBUG":    sklog.DEBUG,
+	"INFO":     sklog.INFO,
+	"NOTICE":   sklog.NOTICE,
+	"WARN":     sklog.WARNING,
+	"WARNING":  sklog.WARNING,
+	"ERROR":    sklog.ERROR,
+	"CRITICAL": sklog.CRITICAL,
+	"FATAL":    sklog.ALERT,
+	"ALERT":    sklog.ALERT,
+}
+
+func parseJSONLog(line string) *sklog.LogPayload {
+	p := sklog.LogPayload{}
+	first, rest := line, ""
+	if i := strings.Index(line, "\n"); i != -1 {
+		first, rest = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(first), &entry); err != nil {
+		sklog.CloudLogError("jsonlogparser", fmt.Errorf("Problem parsing JSON log line: %s\n%s\n", err, line))
+		return &p
+	}
+
+	if v, key := jsonField(entry, jsonTimeKeys); key != "" {
+		switch t := v.(type) {
+		case string:
+			if parsed, err := time.Parse(time.RFC3339Nano, t); err == nil {
+				p.Time = parsed
+			} else {
+				sklog.CloudLogError("jsonlogparser", fmt.Errorf("Problem parsing date: %s\n", err))
+			}
+		case float64:
+			// Seconds since the epoch, kept to the microsecond to avoid floating point noise.
+			sec, frac := math.Modf(t)
+			p.Time = time.Unix(int64(sec), int64(math.Floor(frac*1e6+0.5))*1000).UTC()
+		}
+	}
+
+	p.Severity = sklog.INFO
+	if v, key := jsonField(entry, jsonSeverityKeys); key != "" {
+		if s, ok := v.(string); ok {
+			if sev, found := jsonLogSeverity[strings.ToUpper(s)]; found {
+				p.Severity = sev
+			}
+		}
+	}
+
+	if v, key := jsonField(entry, jsonMessageKeys); key != "" {
+		p.Payload = fmt.Sprintf("%v", v)
+	} else {
+		p.Payload = strings.TrimSpace(first)
+	}
+	if rest != "" {
+		p.Payload += "\n" + rest
+	}
+
+	return &p
+}
+
+// jsonField returns the value of the first of the keys present in the entry, and that key. The
+// key is "" if none of them are present.
+func jsonField(entry map[string]interface{}, keys []string) (interface{}, string) {
+	for _, k := range keys {
+		if v, ok := entry[k]; ok {
+			return v, k
+		}
+	}
+	return nil, ""
+}
+
+// ParseSwarmingBotLog parses the logs written by Swarming bots, e.g. swarming_bot.log. They use
+// the same format as ParsePythonLog, but Python tracebacks, which are kept in the payload of the
+// preceding line, raise the severity of that line to at least ERROR.
+func ParseSwarmingBotLog(contents string) ParsedLog {
+	p := &logParser{
+		SplitLine: pythonLogLine,
+		ParseLine: parseSwarmingBotLog,
+		Line:      0,
+	}
+	p.init(bytes.NewBufferString(contents))
+	return p
+}
+
+var pythonTraceback = regexp.MustCompile(`(?m)^Traceback \(most recent call last\):$`)
+
+func parseSwarmingBotLog(line string) *sklog.LogPayload {
+	p := parsePythonLog(line)
+	if pythonTraceback.MatchString(p.Payload) {
+		switch p.Severity {
+		case sklog.DEBUG, sklog.INFO, sklog.NOTICE, sklog.WARNING:
+			p.Severity = sklog.ERROR
+		}
+	}
+	return p
+}
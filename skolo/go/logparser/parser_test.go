@@ -94,3 +94,141 @@ func TestPythonLogParsing(t *testing.T) {
 	assert.Equal(t, expected, *payload)
 	assert.Equal(t, 3, lp.CurrLine())
 }
+
+func TestGlogParsing(t *testing.T) {
+	contents := testutils.MustReadFile("glog1")
+	lp := ParseGlog(contents)
+	assert.Equal(t, 4, lp.Len(), "Wrong number of log lines")
+	year := time.Now().Year()
+
+	// The file header.
+	payload := lp.ReadLine(0)
+	assert.NotNil(t, payload)
+	assert.Equal(t, sklog.INFO, payload.Severity)
+	assert.Equal(t, time.Date(2016, 5, 27, 15, 20, 15, 0, time.Local), payload.Time)
+
+	payload = lp.ReadLine(1)
+	expected := sklog.LogPayload{
+		Payload:  "main.go:42] Starting up",
+		Time:     time.Date(year, 5, 27, 15, 20, 15, 123456000, time.Local),
+		Severity: sklog.INFO,
+	}
+	assert.NotNil(t, payload)
+	assert.Equal(t, expected, *payload)
+
+	// Stack traces stay with their line.
+	payload = lp.ReadLine(2)
+	expected = sklog.LogPayload{
+		Payload:  "main.go:55] Something bad happened\ngoroutine 1 [running]:\nmain.main()\n\t/home/chrome-bot/main.go:55 +0x1a",
+		Time:     time.Date(year, 5, 27, 15, 20, 16, 1000, time.Local),
+		Severity: sklog.ERROR,
+	}
+	assert.NotNil(t, payload)
+	assert.Equal(t, expected, *payload)
+
+	payload = lp.ReadLine(3)
+	assert.NotNil(t, payload)
+	assert.Equal(t, sklog.ALERT, payload.Severity)
+	assert.Equal(t, "watchdog.go:10] Giving up", payload.Payload)
+	assert.Equal(t, "F0527 15:20:17.500000    1235 watchdog.go:10] Giving up\n", lp.RawLine(3))
+}
+
+func TestGoLogParsing(t *testing.T) {
+	contents := testutils.MustReadFile("golog1")
+	lp := ParseGoLog(contents)
+	assert.Equal(t, 2, lp.Len(), "Wrong number of log lines")
+
+	payload := lp.ReadLine(0)
+	expected := sklog.LogPayload{
+		Payload:  "Starting up",
+		Time:     time.Date(2016, 5, 27, 15, 20, 15, 0, time.Local),
+		Severity: sklog.INFO,
+	}
+	assert.NotNil(t, payload)
+	assert.Equal(t, expected, *payload)
+
+	// Panics are kept with the preceding line and make it CRITICAL.
+	payload = lp.ReadLine(1)
+	assert.NotNil(t, payload)
+	assert.Equal(t, time.Date(2016, 5, 27, 15, 20, 16, 250000000, time.Local), payload.Time)
+	assert.Equal(t, sklog.CRITICAL, payload.Severity)
+	assert.Contains(t, payload.Payload, "main.go:20: Listening on :8000\npanic: runtime error")
+	assert.Contains(t, payload.Payload, "\t/home/chrome-bot/main.go:30 +0x1a")
+
+	// A crash with nothing logged before it.
+	lp = ParseGoLog("panic: oh no\n\ngoroutine 1 [running]:\n")
+	assert.Equal(t, 1, lp.Len())
+	payload = lp.ReadLine(0)
+	assert.Equal(t, sklog.CRITICAL, payload.Severity)
+	assert.Equal(t, "panic: oh no\n\ngoroutine 1 [running]:", payload.Payload)
+}
+
+func TestJSONLogParsing(t *testing.T) {
+	contents := testutils.MustReadFile("jsonlog1")
+	lp := ParseJSONLog(contents)
+	assert.Equal(t, 4, lp.Len(), "Wrong number of log lines")
+
+	payload := lp.ReadLine(0)
+	expected := sklog.LogPayload{
+		Payload:  "Starting up",
+		Time:     time.Date(2016, 5, 27, 15, 20, 15, 500000000, time.UTC),
+		Severity: sklog.INFO,
+	}
+	assert.NotNil(t, payload)
+	assert.Equal(t, expected, *payload)
+
+	payload = lp.ReadLine(1)
+	expected = sklog.LogPayload{
+		Payload:  "Disk almost full",
+		Time:     time.Unix(1464362416, 250000000).UTC(),
+		Severity: sklog.WARNING,
+	}
+	assert.NotNil(t, payload)
+	assert.Equal(t, expected, *payload)
+
+	// Lines which aren't JSON are kept with the preceding line.
+	payload = lp.ReadLine(2)
+	expected = sklog.LogPayload{
+		Payload:  "Crashed\nTraceback (most recent call last):\n  File \"bot.py\", line 3, in <module>\nValueError: bad",
+		Time:     time.Date(2016, 5, 27, 15, 20, 17, 0, time.UTC),
+		Severity: sklog.ERROR,
+	}
+	assert.NotNil(t, payload)
+	assert.Equal(t, expected, *payload)
+
+	// Without a message the whole line is the payload.
+	payload = lp.ReadLine(3)
+	assert.NotNil(t, payload)
+	assert.Equal(t, `{"event": "no message"}`, payload.Payload)
+	assert.Equal(t, sklog.INFO, payload.Severity)
+}
+
+func TestSwarmingBotLogParsing(t *testing.T) {
+	contents := testutils.MustReadFile("swarmingbotlog1")
+	lp := ParseSwarmingBotLog(contents)
+	assert.Equal(t, 3, lp.Len(), "Wrong number of log lines")
+
+	payload := lp.ReadLine(0)
+	expected := sklog.LogPayload{
+		Payload:  "Starting bot",
+		Time:     time.Date(2016, 5, 27, 15, 20, 15, 100000000, time.UTC),
+		Severity: sklog.INFO,
+	}
+	assert.NotNil(t, payload)
+	assert.Equal(t, expected, *payload)
+
+	// Tracebacks are kept with their line and make it an ERROR.
+	payload = lp.ReadLine(1)
+	expected = sklog.LogPayload{
+		Payload:  "Poll failed\nTraceback (most recent call last):\n  File \"bot_main.py\", line 523, in _run_bot\n    resp = remote.poll()\nURLError: <urlopen error timed out>",
+		Time:     time.Date(2016, 5, 27, 15, 20, 16, 200000000, time.UTC),
+		Severity: sklog.ERROR,
+	}
+	assert.NotNil(t, payload)
+	assert.Equal(t, expected, *payload)
+
+	payload = lp.ReadLine(2)
+	assert.NotNil(t, payload)
+	assert.Equal(t, sklog.ERROR, payload.Severity)
+	assert.Equal(t, "Bot died", payload.Payload)
+}
@@ -0,0 +1,329 @@
+// adapter mirrors the Tasks in a task scheduler DB into a buildbot.DB as
+// synthetic Builds, so that the dashboards and metrics which read the buildbot
+// DB keep working while bots migrate from buildbot to the task scheduler.
+//
+// Each Task becomes a Build on a fake master, with the task name as builder.
+// Build numbers are assigned per builder in the order in which the Adapter
+// sees the Tasks, and are kept in a local BoltDB file so that updates to a Task
+// overwrite the same Build.
+package adapter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/buildbot"
+	"go.skia.org/infra/task_scheduler/go/db"
+)
+
+const (
+	// DEFAULT_MASTER is the name of the fake master of the synthetic Builds.
+	DEFAULT_MASTER = "client.skia.task_scheduler"
+
+	// BUILDSLAVE is the BuildSlave of the synthetic Builds. Tasks don't know
+	// which Swarming bot ran them.
+	BUILDSLAVE = "swarming"
+
+	// BRANCH is the branch of the synthetic Builds.
+	BRANCH = "master"
+
+	// PROPERTY_SOURCE is the source of the properties of the synthetic
+	// Builds.
+	PROPERTY_SOURCE = "TaskScheduler"
+
+	// BUCKET_NUMBERS maps Task IDs to Build numbers.
+	BUCKET_NUMBERS = "numbers"
+
+	// BUCKET_MAX_NUMBERS maps builders to the highest Build number assigned.
+	BUCKET_MAX_NUMBERS = "max_numbers"
+)
+
+// StepSource provides the steps of the Build for a Task.
+type StepSource interface {
+	// Steps returns the steps of the Task, or nil if they aren't known.
+	Steps(t *db.Task) ([]*buildbot.BuildStep, error)
+}
+
+// TaskResults returns the buildbot result which corresponds to the status of
+// the Task.
+func TaskResults(t *db.Task) int {
+	switch t.Status {
+	case db.TASK_STATUS_SUCCESS:
+		return buildbot.BUILDBOT_SUCCESS
+	case db.TASK_STATUS_FAILURE:
+		return buildbot.BUILDBOT_FAILURE
+	case db.TASK_STATUS_MISHAP:
+		return buildbot.BUILDBOT_EXCEPTION
+	default:
+		return buildbot.BUILDBOT_SUCCESS
+	}
+}
+
+// TaskStep returns a single step which covers the whole Task, for Tasks whose
+// real steps aren't known.
+func TaskStep(t *db.Task) *buildbot.BuildStep {
+	s := &buildbot.BuildStep{
+		Name:    t.Name,
+		Number:  0,
+		Results: TaskResults(t),
+		Started: t.Started,
+	}
+	if t.Done() {
+		s.Finished = t.Finished
+	}
+	return s
+}
+
+// TaskToBuild returns the synthetic Build for the Task on the given master,
+// with the given number and steps. If steps is empty the Build gets the single
+// step from TaskStep.
+func TaskToBuild(t *db.Task, master string, number int, steps []*buildbot.BuildStep) (*buildbot.Build, error) {
+	if len(steps) == 0 {
+		steps = []*buildbot.BuildStep{TaskStep(t)}
+	}
+	commits := make([]string, len(t.Commits))
+	copy(commits, t.Commits)
+	b := &buildbot.Build{
+		Builder:     t.Name,
+		Master:      master,
+		Number:      number,
+		BuildSlave:  BUILDSLAVE,
+		Branch:      BRANCH,
+		Commits:     commits,
+		GotRevision: t.Revision,
+		Properties: [][]interface{}{
+			{"buildername", t.Name, PROPERTY_SOURCE},
+			{"buildnumber", number, PROPERTY_SOURCE},
+			{"got_revision", t.Revision, PROPERTY_SOURCE},
+			{"mastername", master, PROPERTY_SOURCE},
+			{"repository", t.Repo, PROPERTY_SOURCE},
+			{"slavename", BUILDSLAVE, PROPERTY_SOURCE},
+			{"taskId", t.Id, PROPERTY_SOURCE},
+			{"swarmingTaskId", t.SwarmingTaskId, PROPERTY_SOURCE},
+			{"isolatedOutput", t.IsolatedOutput, PROPERTY_SOURCE},
+		},
+		Results:    TaskResults(t),
+		Steps:      steps,
+		Started:    t.Started,
+		Comments:   []*buildbot.BuildComment{},
+		Repository: t.Repo,
+	}
+	if t.Done() {
+		b.Finished = t.Finished
+	}
+	props, err := json.Marshal(b.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode build properties: %s", err)
+	}
+	b.PropertiesStr = string(props)
+	return b, nil
+}
+
+// Adapter writes a synthetic Build to a buildbot.DB for every Task which is
+// started or modified in a task scheduler DB.
+type Adapter struct {
+	tasks    db.TaskReader
+	builds   buildbot.DB
+	master   string
+	steps    StepSource
+	numbers  *bolt.DB
+	backfill time.Duration
+	queryId  string
+
+	// pending contains the started Tasks which still need to be written,
+	// keyed by ID. Tasks stay here until a Step writes them successfully.
+	pending map[string]*db.Task
+}
+
+// New returns an Adapter which copies Tasks from tasks into builds, on the
+// given master. The Build numbers are kept in numbersFile. steps may be nil, in
+// which case every Build has the single step from TaskStep. When the Adapter
+// starts, and whenever it loses track of the modified Tasks, it copies all
+// Tasks created within backfill.
+func New(tasks db.TaskReader, builds buildbot.DB, master string, steps StepSource, numbersFile string, backfill time.Duration) (*Adapter, error) {
+	numbers, err := bolt.Open(numbersFile, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open build numbers %s: %s", numbersFile, err)
+	}
+	err = numbers.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{BUCKET_NUMBERS, BUCKET_MAX_NUMBERS} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize build numbers: %s", err)
+	}
+	return &Adapter{
+		tasks:    tasks,
+		builds:   builds,
+		master:   master,
+		steps:    steps,
+		numbers:  numbers,
+		backfill: backfill,
+		pending:  map[string]*db.Task{},
+	}, nil
+}
+
+// Close stops tracking the modified Tasks and closes the build numbers file.
+func (a *Adapter) Close() error {
+	if a.queryId != "" {
+		a.tasks.StopTrackingModifiedTasks(a.queryId)
+		a.queryId = ""
+	}
+	return a.numbers.Close()
+}
+
+// modifiedTasks returns the Tasks modified since the last call. On the first
+// call, and after the task DB forgot about the query, it returns the Tasks
+// created within the backfill period instead.
+func (a *Adapter) modifiedTasks() ([]*db.Task, error) {
+	if a.queryId != "" {
+		tasks, err := a.tasks.GetModifiedTasks(a.queryId)
+		if err == nil {
+			return tasks, nil
+		}
+		if !db.IsUnknownId(err) {
+			return nil, fmt.Errorf("Failed to retrieve modified tasks: %s", err)
+		}
+		glog.Warningf("Lost track of modified tasks; backfilling.")
+		a.queryId = ""
+	}
+	queryId, err := a.tasks.StartTrackingModifiedTasks()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to track modified tasks: %s", err)
+	}
+	now := time.Now()
+	tasks, err := a.tasks.GetTasksFromDateRange(now.Add(-a.backfill), now)
+	if err != nil {
+		a.tasks.StopTrackingModifiedTasks(queryId)
+		return nil, fmt.Errorf("Failed to retrieve tasks: %s", err)
+	}
+	a.queryId = queryId
+	return tasks, nil
+}
+
+// numberKey returns the value under which a Build number is stored.
+func numberKey(n int) []byte {
+	ret := make([]byte, 8)
+	binary.BigEndian.PutUint64(ret, uint64(n))
+	return ret
+}
+
+// buildNumbers returns the Build numbers of the Tasks, keyed by Task ID, and
+// assigns new numbers to the Tasks which don't have one yet. New numbers follow
+// the highest number assigned so far, or the highest number in the buildbot DB
+// for builders which the Adapter hasn't seen before.
+func (a *Adapter) buildNumbers(tasks []*db.Task) (map[string]int, error) {
+	ret := make(map[string]int, len(tasks))
+	err := a.numbers.Update(func(tx *bolt.Tx) error {
+		numbers := tx.Bucket([]byte(BUCKET_NUMBERS))
+		maxNumbers := tx.Bucket([]byte(BUCKET_MAX_NUMBERS))
+		for _, t := range tasks {
+			if v := numbers.Get([]byte(t.Id)); v != nil {
+				ret[t.Id] = int(binary.BigEndian.Uint64(v))
+				continue
+			}
+			var next int
+			if v := maxNumbers.Get([]byte(t.Name)); v != nil {
+				next = int(binary.BigEndian.Uint64(v)) + 1
+			} else {
+				max, err := a.builds.GetMaxBuildNumber(a.master, t.Name)
+				if err != nil {
+					return fmt.Errorf("Failed to find the last build of %s: %s", t.Name, err)
+				}
+				next = max + 1
+			}
+			if err := numbers.Put([]byte(t.Id), numberKey(next)); err != nil {
+				return err
+			}
+			if err := maxNumbers.Put([]byte(t.Name), numberKey(next)); err != nil {
+				return err
+			}
+			ret[t.Id] = next
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to assign build numbers: %s", err)
+	}
+	return ret, nil
+}
+
+// taskSlice sorts Tasks by creation time, so that Build numbers increase with
+// the age of the Tasks.
+type taskSlice []*db.Task
+
+func (s taskSlice) Len() int           { return len(s) }
+func (s taskSlice) Less(i, j int) bool { return s[i].Created.Before(s[j].Created) }
+func (s taskSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Step writes the Builds for the Tasks which were modified since the last Step.
+// Tasks which haven't started yet are skipped, since a Build can't be pending.
+// If Step fails, the next Step writes the Tasks again.
+func (a *Adapter) Step() error {
+	modified, err := a.modifiedTasks()
+	if err != nil {
+		return err
+	}
+	for _, t := range modified {
+		if t.Status != db.TASK_STATUS_PENDING && !t.Started.IsZero() {
+			a.pending[t.Id] = t
+		}
+	}
+	if len(a.pending) == 0 {
+		return nil
+	}
+	tasks := make([]*db.Task, 0, len(a.pending))
+	for _, t := range a.pending {
+		tasks = append(tasks, t)
+	}
+	sort.Sort(taskSlice(tasks))
+	numbers, err := a.buildNumbers(tasks)
+	if err != nil {
+		return err
+	}
+	builds := make([]*buildbot.Build, 0, len(tasks))
+	for _, t := range tasks {
+		var steps []*buildbot.BuildStep
+		if a.steps != nil && t.Done() {
+			steps, err = a.steps.Steps(t)
+			if err != nil {
+				glog.Warningf("Failed to retrieve the steps of task %s; using a single step: %s", t.Id, err)
+				steps = nil
+			}
+		}
+		b, err := TaskToBuild(t, a.master, numbers[t.Id], steps)
+		if err != nil {
+			return err
+		}
+		builds = append(builds, b)
+	}
+	if err := a.builds.PutBuilds(builds); err != nil {
+		return fmt.Errorf("Failed to insert builds: %s", err)
+	}
+	a.pending = map[string]*db.Task{}
+	glog.Infof("Wrote %d builds for modified tasks.", len(builds))
+	return nil
+}
+
+// Run calls Step every period, forever. It calls fn after every successful
+// Step, if fn isn't nil.
+func (a *Adapter) Run(period time.Duration, fn func()) {
+	for _ = range time.Tick(period) {
+		if err := a.Step(); err != nil {
+			glog.Errorf("Failed to copy tasks into the buildbot DB: %s", err)
+			continue
+		}
+		if fn != nil {
+			fn()
+		}
+	}
+}
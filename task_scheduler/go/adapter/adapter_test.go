@@ -0,0 +1,267 @@
+package adapter
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/buildbot"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/task_scheduler/go/db"
+)
+
+func makeTask(name string, created time.Time) *db.Task {
+	return &db.Task{
+		Name:     name,
+		Repo:     "skia.git",
+		Revision: "abc123",
+		Commits:  []string{"abc123", "def456"},
+		Created:  created,
+	}
+}
+
+func TestTaskToBuild(t *testing.T) {
+	now := time.Now().UTC()
+	task := makeTask("Test-Linux", now)
+	task.Id = "task1"
+	task.Started = now
+	task.Status = db.TASK_STATUS_RUNNING
+
+	b, err := TaskToBuild(task, DEFAULT_MASTER, 3, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test-Linux", b.Builder)
+	assert.Equal(t, DEFAULT_MASTER, b.Master)
+	assert.Equal(t, 3, b.Number)
+	assert.Equal(t, []string{"abc123", "def456"}, b.Commits)
+	assert.Equal(t, "abc123", b.GotRevision)
+	assert.Equal(t, "skia.git", b.Repository)
+	assert.False(t, b.IsFinished())
+	assert.Equal(t, 1, len(b.Steps))
+	assert.False(t, b.Steps[0].IsFinished())
+	props := [][]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(b.PropertiesStr), &props))
+	assert.Equal(t, len(b.Properties), len(props))
+
+	task.Status = db.TASK_STATUS_MISHAP
+	task.Finished = now.Add(time.Minute)
+	b, err = TaskToBuild(task, DEFAULT_MASTER, 3, nil)
+	assert.NoError(t, err)
+	assert.True(t, b.IsFinished())
+	assert.Equal(t, buildbot.BUILDBOT_EXCEPTION, b.Results)
+	assert.Equal(t, buildbot.BUILDBOT_EXCEPTION, b.Steps[0].Results)
+}
+
+type testStepSource map[string][]*buildbot.BuildStep
+
+func (s testStepSource) Steps(t *db.Task) ([]*buildbot.BuildStep, error) {
+	return s[t.Id], nil
+}
+
+func TestAdapter(t *testing.T) {
+	testutils.SkipIfShort(t)
+	dir, err := ioutil.TempDir("", "adapter")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+
+	tasks := db.NewInMemoryDB()
+	builds, err := buildbot.NewLocalDB(filepath.Join(dir, "buildbot.db"))
+	assert.NoError(t, err)
+	defer testutils.CloseInTest(t, builds)
+
+	// Builds on the master which were ingested from buildbot come first.
+	now := time.Now().UTC()
+	old := &buildbot.Build{
+		Master:   DEFAULT_MASTER,
+		Builder:  "Test-Linux",
+		Number:   7,
+		Started:  now.Add(-time.Hour),
+		Finished: now.Add(-time.Hour),
+	}
+	assert.NoError(t, builds.PutBuild(old))
+
+	running := makeTask("Test-Linux", now.Add(-10*time.Minute))
+	running.Started = running.Created
+	running.Status = db.TASK_STATUS_RUNNING
+	pending := makeTask("Test-Linux", now.Add(-5*time.Minute))
+	other := makeTask("Perf-Linux", now.Add(-5*time.Minute))
+	other.Started = other.Created
+	other.Status = db.TASK_STATUS_RUNNING
+	assert.NoError(t, tasks.PutTasks([]*db.Task{running, pending, other}))
+
+	steps := testStepSource{}
+	a, err := New(tasks, builds, DEFAULT_MASTER, steps, filepath.Join(dir, "numbers.db"), 24*time.Hour)
+	assert.NoError(t, err)
+
+	// The first Step backfills the tasks which have started.
+	assert.NoError(t, a.Step())
+	b, err := builds.GetBuildFromDB(DEFAULT_MASTER, "Test-Linux", 8)
+	assert.NoError(t, err)
+	assert.False(t, b.IsFinished())
+	assert.Equal(t, 1, len(b.Steps))
+	b, err = builds.GetBuildFromDB(DEFAULT_MASTER, "Perf-Linux", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, other.Id, b.Properties[6][1])
+	exists, err := builds.BuildExists(DEFAULT_MASTER, "Test-Linux", 9)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// Updated tasks overwrite their build, and new tasks get the next number.
+	running.Status = db.TASK_STATUS_FAILURE
+	running.Finished = now
+	steps[running.Id] = []*buildbot.BuildStep{
+		{Name: "compile", Number: 0, Results: buildbot.BUILDBOT_SUCCESS, Started: running.Started, Finished: running.Started},
+		{Name: "test", Number: 1, Results: buildbot.BUILDBOT_FAILURE, Started: running.Started, Finished: now},
+	}
+	pending.Started = now
+	pending.Status = db.TASK_STATUS_RUNNING
+	assert.NoError(t, tasks.PutTasks([]*db.Task{running, pending}))
+	assert.NoError(t, a.Step())
+	b, err = builds.GetBuildFromDB(DEFAULT_MASTER, "Test-Linux", 8)
+	assert.NoError(t, err)
+	assert.True(t, b.IsFinished())
+	assert.Equal(t, buildbot.BUILDBOT_FAILURE, b.Results)
+	assert.Equal(t, 2, len(b.Steps))
+	b, err = builds.GetBuildFromDB(DEFAULT_MASTER, "Test-Linux", 9)
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(b.Started))
+
+	// The numbers survive a restart.
+	assert.NoError(t, a.Close())
+	a, err = New(tasks, builds, DEFAULT_MASTER, nil, filepath.Join(dir, "numbers.db"), 24*time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, a.Step())
+	max, err := builds.GetMaxBuildNumber(DEFAULT_MASTER, "Test-Linux")
+	assert.NoError(t, err)
+	assert.Equal(t, 9, max)
+	assert.NoError(t, a.Close())
+}
+
+// failingDB is a buildbot.DB which fails to insert Builds while fail is true.
+type failingDB struct {
+	buildbot.DB
+	fail bool
+}
+
+func (d *failingDB) PutBuilds(builds []*buildbot.Build) error {
+	if d.fail {
+		return fmt.Errorf("PutBuilds failed")
+	}
+	return d.DB.PutBuilds(builds)
+}
+
+func TestAdapterRetry(t *testing.T) {
+	testutils.SkipIfShort(t)
+	dir, err := ioutil.TempDir("", "adapter")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+
+	tasks := db.NewInMemoryDB()
+	localBuilds, err := buildbot.NewLocalDB(filepath.Join(dir, "buildbot.db"))
+	assert.NoError(t, err)
+	defer testutils.CloseInTest(t, localBuilds)
+	builds := &failingDB{DB: localBuilds}
+
+	a, err := New(tasks, builds, DEFAULT_MASTER, nil, filepath.Join(dir, "numbers.db"), 24*time.Hour)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, a.Close())
+	}()
+	assert.NoError(t, a.Step())
+
+	// The modified tasks are kept when writing the builds fails.
+	now := time.Now().UTC()
+	task := makeTask("Test-Linux", now)
+	task.Started = now
+	task.Status = db.TASK_STATUS_RUNNING
+	assert.NoError(t, tasks.PutTasks([]*db.Task{task}))
+	builds.fail = true
+	assert.Error(t, a.Step())
+	exists, err := builds.BuildExists(DEFAULT_MASTER, "Test-Linux", 0)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// The next Step writes them, even without further modifications.
+	builds.fail = false
+	assert.NoError(t, a.Step())
+	b, err := builds.GetBuildFromDB(DEFAULT_MASTER, "Test-Linux", 0)
+	assert.NoError(t, err)
+	assert.False(t, b.IsFinished())
+
+	// Later modifications replace the kept tasks.
+	builds.fail = true
+	task.Status = db.TASK_STATUS_RUNNING
+	assert.NoError(t, tasks.PutTasks([]*db.Task{task}))
+	assert.Error(t, a.Step())
+	task.Status = db.TASK_STATUS_SUCCESS
+	task.Finished = now.Add(time.Minute)
+	assert.NoError(t, tasks.PutTasks([]*db.Task{task}))
+	builds.fail = false
+	assert.NoError(t, a.Step())
+	b, err = builds.GetBuildFromDB(DEFAULT_MASTER, "Test-Linux", 0)
+	assert.NoError(t, err)
+	assert.True(t, b.IsFinished())
+	assert.Equal(t, buildbot.BUILDBOT_SUCCESS, b.Results)
+}
+
+func compress(t *testing.T, b []byte) []byte {
+	buf := bytes.Buffer{}
+	w := zlib.NewWriter(&buf)
+	_, err := w.Write(b)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestIsolateStepSource(t *testing.T) {
+	started := time.Date(2016, 9, 1, 12, 0, 0, 0, time.UTC)
+	results, err := json.Marshal([]StepResult{
+		{Name: "compile", Started: started, Finished: started.Add(time.Minute), Results: buildbot.BUILDBOT_SUCCESS},
+		{Name: "test", Started: started.Add(time.Minute), Finished: started.Add(2 * time.Minute), Results: buildbot.BUILDBOT_FAILURE},
+	})
+	assert.NoError(t, err)
+	items := map[string][]byte{
+		"withsteps": []byte(`{"files": {"steps.json": {"h": "steps"}, "out.txt": {"h": "out"}}}`),
+		"nosteps":   []byte(`{"files": {"out.txt": {"h": "out"}}}`),
+		"steps":     results,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, RETRIEVE_PATH, r.URL.Path)
+		req := struct {
+			Digest string `json:"digest"`
+		}{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		item, ok := items[req.Digest]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string][]byte{"content": compress(t, item)}))
+	}))
+	defer ts.Close()
+
+	s := NewIsolateStepSource(nil, ts.URL)
+	steps, err := s.Steps(&db.Task{IsolatedOutput: "withsteps"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(steps))
+	assert.Equal(t, "test", steps[1].Name)
+	assert.Equal(t, 1, steps[1].Number)
+	assert.Equal(t, buildbot.BUILDBOT_FAILURE, steps[1].Results)
+	assert.True(t, started.Add(2*time.Minute).Equal(steps[1].Finished))
+
+	steps, err = s.Steps(&db.Task{IsolatedOutput: "nosteps"})
+	assert.NoError(t, err)
+	assert.Nil(t, steps)
+	steps, err = s.Steps(&db.Task{})
+	assert.NoError(t, err)
+	assert.Nil(t, steps)
+	_, err = s.Steps(&db.Task{IsolatedOutput: "missing"})
+	assert.Error(t, err)
+}
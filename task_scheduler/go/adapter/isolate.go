@@ -0,0 +1,159 @@
+package adapter
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.skia.org/infra/go/buildbot"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/isolate"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/task_scheduler/go/db"
+)
+
+const (
+	// STEPS_FILE is the file in the isolated output of a Task which lists
+	// the steps of the Task, if the Task wrote one. It contains a JSON list
+	// of StepResult.
+	STEPS_FILE = "steps.json"
+
+	// RETRIEVE_PATH is the path of the isolate server API which returns the
+	// contents of an item.
+	RETRIEVE_PATH = "/_ah/api/isolateservice/v1/retrieve"
+)
+
+// StepResult is how a Task reports one of its steps in STEPS_FILE.
+type StepResult struct {
+	Name     string    `json:"name"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Results is a buildbot result, eg. buildbot.BUILDBOT_FAILURE.
+	Results int `json:"results"`
+}
+
+// isolatedFile is the part of an .isolated file which the IsolateStepSource
+// needs.
+type isolatedFile struct {
+	Files map[string]struct {
+		Hash string `json:"h"`
+	} `json:"files"`
+}
+
+// IsolateStepSource is a StepSource which reads the steps of a Task from the
+// STEPS_FILE in its isolated output. Tasks without an isolated output or
+// without a STEPS_FILE have no known steps.
+type IsolateStepSource struct {
+	client    *http.Client
+	serverURL string
+	namespace string
+}
+
+// NewIsolateStepSource returns an IsolateStepSource which retrieves isolated
+// outputs from the isolate server at serverURL, eg. isolate.ISOLATE_SERVER_URL.
+// If c is nil a client with a timeout is used.
+func NewIsolateStepSource(c *http.Client, serverURL string) *IsolateStepSource {
+	if c == nil {
+		c = httputils.NewTimeoutClient()
+	}
+	return &IsolateStepSource{
+		client:    c,
+		serverURL: strings.TrimRight(serverURL, "/"),
+		namespace: isolate.DEFAULT_NAMESPACE,
+	}
+}
+
+// retrieve returns the contents of the item with the given digest.
+func (s *IsolateStepSource) retrieve(digest string) ([]byte, error) {
+	req, err := json.Marshal(map[string]interface{}{
+		"digest": digest,
+		"namespace": map[string]string{
+			"namespace": s.namespace,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Post(s.serverURL+RETRIEVE_PATH, "application/json", bytes.NewReader(req))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve %s: %s", digest, err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to retrieve %s: %s", digest, resp.Status)
+	}
+	item := struct {
+		// Content is the compressed contents of small items.
+		Content []byte `json:"content"`
+		// URL is where the compressed contents of large items are.
+		URL string `json:"url"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+		return nil, fmt.Errorf("Failed to decode %s: %s", digest, err)
+	}
+	compressed := item.Content
+	if len(compressed) == 0 && item.URL != "" {
+		resp, err := s.client.Get(item.URL)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to download %s: %s", digest, err)
+		}
+		defer util.Close(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Failed to download %s: %s", digest, resp.Status)
+		}
+		if compressed, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("Failed to download %s: %s", digest, err)
+		}
+	}
+	if !strings.HasSuffix(s.namespace, "-gzip") {
+		return compressed, nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decompress %s: %s", digest, err)
+	}
+	defer util.Close(r)
+	return ioutil.ReadAll(r)
+}
+
+// Steps implements StepSource.
+func (s *IsolateStepSource) Steps(t *db.Task) ([]*buildbot.BuildStep, error) {
+	if t.IsolatedOutput == "" {
+		return nil, nil
+	}
+	b, err := s.retrieve(t.IsolatedOutput)
+	if err != nil {
+		return nil, err
+	}
+	isolated := isolatedFile{}
+	if err := json.Unmarshal(b, &isolated); err != nil {
+		return nil, fmt.Errorf("Invalid isolated output %s: %s", t.IsolatedOutput, err)
+	}
+	f, ok := isolated.Files[STEPS_FILE]
+	if !ok {
+		return nil, nil
+	}
+	if b, err = s.retrieve(f.Hash); err != nil {
+		return nil, err
+	}
+	results := []StepResult{}
+	if err := json.Unmarshal(b, &results); err != nil {
+		return nil, fmt.Errorf("Invalid %s in %s: %s", STEPS_FILE, t.IsolatedOutput, err)
+	}
+	steps := make([]*buildbot.BuildStep, 0, len(results))
+	for i, r := range results {
+		steps = append(steps, &buildbot.BuildStep{
+			Name:     r.Name,
+			Number:   i,
+			Results:  r.Results,
+			Started:  r.Started,
+			Finished: r.Finished,
+		})
+	}
+	return steps, nil
+}
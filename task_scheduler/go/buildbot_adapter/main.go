@@ -0,0 +1,76 @@
+// buildbot_adapter copies the Tasks of the task scheduler into the buildbot
+// database as synthetic Builds, so that the dashboards and metrics which read
+// the buildbot database keep working while bots migrate to the task scheduler.
+package main
+
+import (
+	"flag"
+	"path"
+	"time"
+
+	"github.com/skia-dev/glog"
+
+	"go.skia.org/infra/go/buildbot"
+	"go.skia.org/infra/go/common"
+	"go.skia.org/infra/go/fileutil"
+	"go.skia.org/infra/go/influxdb"
+	"go.skia.org/infra/go/isolate"
+	"go.skia.org/infra/go/metrics2"
+	"go.skia.org/infra/task_scheduler/go/adapter"
+	"go.skia.org/infra/task_scheduler/go/db/remote_db"
+)
+
+const (
+	APP_NAME = "buildbot_adapter"
+
+	// NUMBERS_FILENAME is the file in the workdir which holds the build
+	// numbers of the tasks.
+	NUMBERS_FILENAME = "build_numbers.db"
+)
+
+// flags
+var (
+	backfill       = flag.Duration("backfill", 24*time.Hour, "When starting, copy the tasks created within this period.")
+	buildbotDbHost = flag.String("buildbot_db_host", "skia-datahopper2:8000", "Where the Skia buildbot database is hosted.")
+	isolateServer  = flag.String("isolate_server", isolate.ISOLATE_SERVER_URL, "The isolate server from which to read the steps of tasks. If blank, every build has a single step.")
+	local          = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
+	master         = flag.String("master", adapter.DEFAULT_MASTER, "The fake master of the builds.")
+	period         = flag.Duration("period", time.Minute, "The time between copies of the modified tasks.")
+	taskDbUrl      = flag.String("task_db_url", "", "URL of the task scheduler database server, see remote_db.NewServer. Should end with a slash.")
+	workdir        = flag.String("workdir", ".", "Working directory.")
+
+	influxHost     = flag.String("influxdb_host", influxdb.DEFAULT_HOST, "The InfluxDB hostname.")
+	influxUser     = flag.String("influxdb_name", influxdb.DEFAULT_USER, "The InfluxDB username.")
+	influxPassword = flag.String("influxdb_password", influxdb.DEFAULT_PASSWORD, "The InfluxDB password.")
+	influxDatabase = flag.String("influxdb_database", influxdb.DEFAULT_DATABASE, "The InfluxDB database.")
+)
+
+func main() {
+	defer common.LogPanic()
+	common.InitWithMetrics2(APP_NAME, influxHost, influxUser, influxPassword, influxDatabase, local)
+
+	if *taskDbUrl == "" {
+		glog.Fatal("--task_db_url is required.")
+	}
+	if _, err := fileutil.EnsureDirExists(*workdir); err != nil {
+		glog.Fatal(err)
+	}
+	tasks, err := remote_db.NewClient(*taskDbUrl)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	builds, err := buildbot.NewRemoteDB(*buildbotDbHost)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	var steps adapter.StepSource
+	if *isolateServer != "" {
+		steps = adapter.NewIsolateStepSource(nil, *isolateServer)
+	}
+	a, err := adapter.New(tasks, builds, *master, steps, path.Join(*workdir, NUMBERS_FILENAME), *backfill)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	lv := metrics2.NewLiveness("last-successful-buildbot-adapter-step")
+	a.Run(*period, lv.Reset)
+}
@@ -1456,3 +1456,125 @@ func TestBlacklist(t *testing.T) {
 	assert.Equal(t, 1, len(tasks))
 	assert.NotEqual(t, c1, tasks[0].Revision)
 }
+
+func TestLocalSwarming(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	workdir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, workdir)
+
+	run := func(dir string, cmd ...string) {
+		_, err := exec.RunCwd(dir, cmd...)
+		assert.NoError(t, err)
+	}
+
+	addFile := func(repoDir, subPath, contents string) {
+		assert.NoError(t, ioutil.WriteFile(path.Join(repoDir, subPath), []byte(contents), os.ModePerm))
+		run(repoDir, "git", "add", subPath)
+	}
+
+	repoDir := path.Join(workdir, repoName)
+
+	assert.NoError(t, ioutil.WriteFile(path.Join(workdir, ".gclient"), []byte("dummy"), os.ModePerm))
+
+	assert.NoError(t, os.Mkdir(path.Join(workdir, repoName), os.ModePerm))
+	run(repoDir, "git", "init")
+	run(repoDir, "git", "config", "user.email", "test@skia.org")
+	run(repoDir, "git", "config", "user.name", "Skia Tester")
+	run(repoDir, "git", "remote", "add", "origin", ".")
+
+	infraBotsSubDir := path.Join("infra", "bots")
+	assert.NoError(t, os.MkdirAll(path.Join(repoDir, infraBotsSubDir), os.ModePerm))
+
+	// The task prints a file from the repo, which is only available to it
+	// if the isolated inputs ended up in the local Swarming emulator.
+	addFile(repoDir, "somefile.txt", "local swarming works")
+	addFile(repoDir, path.Join(infraBotsSubDir, "dummy.isolate"), `{
+  'variables': {
+    'command': [
+      'cat', '../../somefile.txt',
+    ],
+    'files': [
+      '../../somefile.txt',
+    ],
+  },
+}`)
+
+	taskName := "dummytask"
+	cfg := &TasksCfg{
+		Tasks: map[string]*TaskSpec{
+			taskName: &TaskSpec{
+				CipdPackages: []*CipdPackage{},
+				Dependencies: []string{},
+				Dimensions:   []string{"pool:Skia"},
+				Isolate:      "dummy.isolate",
+				Priority:     1.0,
+			},
+		},
+	}
+	f, err := os.Create(path.Join(repoDir, TASKS_CFG_FILE))
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewEncoder(f).Encode(&cfg))
+	assert.NoError(t, f.Close())
+	run(repoDir, "git", "add", TASKS_CFG_FILE)
+	run(repoDir, "git", "commit", "-m", "Add a task")
+	run(repoDir, "git", "push", "origin", "master")
+	run(repoDir, "git", "branch", "-u", "origin/master")
+
+	// Setup the scheduler with a local Swarming emulator, which also stores
+	// the isolated inputs.
+	repos := gitinfo.NewRepoMap(workdir)
+	repo, err := repos.Repo(repoName)
+	assert.NoError(t, err)
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+	swarmingClient, err := swarming.NewLocalClient(path.Join(workdir, "local_swarming"), []*swarming.LocalBot{
+		&swarming.LocalBot{
+			Id:         "bot1",
+			Dimensions: map[string][]string{"pool": []string{"Skia"}},
+		},
+	}, nil)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, swarmingClient.Close())
+	}()
+	isolateClient, err := isolate.NewClient(workdir)
+	assert.NoError(t, err)
+	isolateClient.ServerUrl = isolate.FAKE_SERVER_URL
+	isolateClient.LocalStore = swarmingClient
+	s, err := NewTaskScheduler(d, cache, time.Duration(math.MaxInt64), workdir, []string{repoName}, isolateClient, swarmingClient, 1.0)
+	assert.NoError(t, err)
+
+	// Trigger the task.
+	assert.NoError(t, s.MainLoop())
+	head, err := repo.FullHash("HEAD")
+	assert.NoError(t, err)
+	tasks, err := cache.GetTasksForCommits(repoName, []string{head})
+	assert.NoError(t, err)
+	task, ok := tasks[head][taskName]
+	assert.True(t, ok)
+
+	// Wait for the task to finish.
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		res, err := swarmingClient.GetTask(task.SwarmingTaskId)
+		assert.NoError(t, err)
+		if res.State == swarming.TASK_STATE_COMPLETED {
+			break
+		}
+		assert.True(t, res.State == swarming.TASK_STATE_PENDING || res.State == swarming.TASK_STATE_RUNNING, "Unexpected task state %q", res.State)
+		assert.True(t, time.Now().Before(deadline), "Timed out waiting for task.")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// The scheduler should pick up the result.
+	assert.NoError(t, updateUnfinishedTasks(cache, d, swarmingClient))
+	task, err = d.GetTaskById(task.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, db.TASK_STATUS_SUCCESS, task.Status)
+	output, err := swarmingClient.TaskOutput(task.SwarmingTaskId)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "local swarming works")
+}
@@ -64,6 +64,7 @@ var (
 	host           = flag.String("host", "localhost", "HTTP service host")
 	port           = flag.String("port", ":8000", "HTTP service port (e.g., ':8000')")
 	local          = flag.Bool("local", false, "Whether we're running on a dev machine vs in production.")
+	localBots      = flag.String("local_swarming_bots", "", "If running locally, a JSON file listing fake Swarming bots, see swarming.ReadLocalBots. Tasks are run as local processes on these bots. If blank, tasks are never run.")
 	resourcesDir   = flag.String("resources_dir", "", "The directory to find templates, JS, and CSS files. If blank, assumes you're running inside a checkout and will attempt to find the resources relative to this source file.")
 	scoreDecay24Hr = flag.Float64("scoreDecay24Hr", 0.9, "Task candidate scores are penalized using linear time decay. This is the desired value after 24 hours. Setting it to 1.0 causes commits not to be prioritized according to commit time.")
 	timePeriod     = flag.String("timePeriod", "4d", "Time period to use.")
@@ -284,14 +285,30 @@ func main() {
 
 	// Authenticated HTTP client.
 	oauthCacheFile := path.Join(*workdir, "google_storage_token.data")
-	httpClient, err := auth.NewClient(*local, oauthCacheFile, swarming.AUTH_SCOPE)
+	scopes := []string{swarming.AUTH_SCOPE}
+	if *local && *localBots != "" {
+		// The local Swarming emulator publishes task notifications itself.
+		scopes = append(scopes, swarming.PUBSUB_SCOPE)
+	}
+	httpClient, err := auth.NewClient(*local, oauthCacheFile, scopes...)
 	if err != nil {
 		glog.Fatal(err)
 	}
 
 	// Initialize Swarming client.
 	var swarm swarming.ApiClient
-	if *local {
+	var localSwarm *swarming.LocalClient
+	if *local && *localBots != "" {
+		bots, err := swarming.ReadLocalBots(*localBots)
+		if err != nil {
+			glog.Fatal(err)
+		}
+		localSwarm, err = swarming.NewLocalClient(path.Join(*workdir, "local_swarming"), bots, httpClient)
+		if err != nil {
+			glog.Fatal(err)
+		}
+		swarm = localSwarm
+	} else if *local {
 		swarm = swarming.NewTestClient()
 		if err != nil {
 			glog.Fatal(err)
@@ -311,6 +328,10 @@ func main() {
 	if *local {
 		isolateClient.ServerUrl = isolate.FAKE_SERVER_URL
 	}
+	if localSwarm != nil {
+		// The local bots can only run inputs stored in localSwarm.
+		isolateClient.LocalStore = localSwarm
+	}
 
 	// Initialize the database.
 	// TODO(benjaminwagner): Create a signal handler which closes the DB.
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime/pprof"
 	"syscall"
+	"time"
 
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/common"
@@ -30,6 +31,9 @@ var (
 	influxPassword = flag.String("influxdb_password", influxdb.DEFAULT_PASSWORD, "The InfluxDB password.")
 	influxDatabase = flag.String("influxdb_database", influxdb.DEFAULT_DATABASE, "The InfluxDB database.")
 	local          = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
+	retentionFile  = flag.String("retention_rules", "", "A JSON file of retention rules for the traces, see traceservice.ReadRetentionRules. If empty no data is removed.")
+	retentionEvery = flag.Duration("retention_period", time.Hour, "How often the retention rules are applied.")
+	compactEvery   = flag.Duration("compact_period", 0, "How often the BoltDB file is compacted. Zero means never.")
 )
 
 func main() {
@@ -39,6 +43,29 @@ func main() {
 		glog.Fatalf("Failed to initialize the tracestore server: %s", err)
 	}
 
+	if *retentionFile != "" {
+		rules, err := traceservice.ReadRetentionRules(*retentionFile)
+		if err != nil {
+			glog.Fatalf("Failed to read retention rules: %s", err)
+		}
+		go func() {
+			for _ = range time.Tick(*retentionEvery) {
+				if _, err := ts.ApplyRetention(rules, time.Now()); err != nil {
+					glog.Errorf("Failed to apply retention rules: %s", err)
+				}
+			}
+		}()
+	}
+	if *compactEvery > 0 {
+		go func() {
+			for _ = range time.Tick(*compactEvery) {
+				if err := ts.Compact(); err != nil {
+					glog.Errorf("Failed to compact: %s", err)
+				}
+			}
+		}()
+	}
+
 	lis, err := net.Listen("tcp", *port)
 	if err != nil {
 		glog.Fatalf("failed to listen: %v", err)